@@ -16,6 +16,10 @@
 # * Create a GIN index on the `books(ts_vec)` column.
 # * `VACUUM ANALYZE` the `books` table.
 #
+# Note: this script only runs when the database is first initialized.
+# Subsequent schema changes are applied by the `bookman migrate`
+# command of the web server (see `web/migrate/sql/`).
+#
 
 # set sane behavior
 set -euo pipefail
@@ -65,6 +69,12 @@ echo "
     -- book content
     body TEXT NOT NULL,
 
+    -- time book was created
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    -- time book was moved to trash (NULL if not deleted)
+    deleted_at TIMESTAMP WITH TIME ZONE,
+
     -- fts vector
     ts_vec tsvector GENERATED ALWAYS AS (to_tsvector('english',
       COALESCE(name, '') || ' ' ||
@@ -79,6 +89,8 @@ echo "
   COMMENT ON COLUMN books.name IS 'Book title';
   COMMENT ON COLUMN books.author IS 'Author name';
   COMMENT ON COLUMN books.body IS 'Book contents';
+  COMMENT ON COLUMN books.created_at IS 'Time book was created';
+  COMMENT ON COLUMN books.deleted_at IS 'Time book was moved to trash, or NULL';
   COMMENT ON COLUMN books.ts_vec IS 'Book FTS vector';
 
   -- set owner and privileges
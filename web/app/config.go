@@ -1,13 +1,17 @@
 package app
 
 import (
+  "fmt"
+  "net/netip"
   "os"
+  "strconv"
+  "strings"
 )
 
 // Configuration values.  Use NewConfigFromEnv() to create a new
 // configuration from environment variables.
 type Config struct {
-  // file containing database password 
+  // file containing database password
   PasswordPath string
 
   // database dsn
@@ -15,6 +19,68 @@ type Config struct {
 
   // http host and port
   HttpAddr string
+
+  // file containing database password for migrations
+  MigratePasswordPath string
+
+  // database dsn for migrations
+  MigrateDsn string
+
+  // database role which owns migrated database objects
+  MigrateRole string
+
+  // apply pending migrations at startup?
+  MigrateOnStart bool
+
+  // remove project gutenberg header and license from uploaded books?
+  UploadStripBoilerplate bool
+
+  // maximum size of each uploaded file, in bytes
+  UploadMaxFileSize int
+
+  // maximum size of upload request body, in bytes
+  UploadMaxRequestSize int
+
+  // maximum number of entries in each uploaded archive
+  UploadMaxArchiveEntries int
+
+  // maximum total decompressed size of each uploaded archive, in bytes
+  UploadMaxArchiveSize int
+
+  // maximum number of search result snippet fragments (0 to show a
+  // single snippet around the best match)
+  SnippetFragments int
+
+  // maximum number of words in each search result snippet fragment
+  SnippetWords int
+
+  // login session lifetime, in seconds
+  SessionMaxAge int
+
+  // only send session cookies over HTTPS?
+  SessionSecure bool
+
+  // maximum search requests per minute for each client (0 to disable)
+  RateLimitSearch int
+
+  // maximum burst of search requests for each client
+  RateLimitSearchBurst int
+
+  // maximum upload requests per minute for each client (0 to disable)
+  RateLimitUpload int
+
+  // maximum burst of upload requests for each client
+  RateLimitUploadBurst int
+
+  // maximum edit requests per minute for each client (0 to disable)
+  RateLimitEdit int
+
+  // maximum burst of edit requests for each client
+  RateLimitEditBurst int
+
+  // addresses of trusted reverse proxies, whose X-Forwarded-For
+  // headers are used to get client addresses
+  TrustedProxies []netip.Prefix
 }
 
 // default configuration
@@ -22,6 +88,98 @@ var defaultConfig = Config {
   PasswordPath: "/run/secrets/bookman_web_password", // default password file path
   Dsn: "host=db dbname=bookman user=bookman_web", // default database dsn
   HttpAddr: ":3000", // default http listen address
+  MigratePasswordPath: "/run/secrets/bookman_postgres_password", // default migration password file path
+  MigrateDsn: "host=db dbname=bookman user=postgres", // default migration database dsn
+  MigrateRole: "bookman_sys", // default migration role
+  MigrateOnStart: false, // do not apply migrations at startup by default
+  UploadStripBoilerplate: false, // keep project gutenberg license by default
+  UploadMaxFileSize: 32 << 20, // default maximum file size (32 MiB)
+  UploadMaxRequestSize: 256 << 20, // default maximum upload request size (256 MiB)
+  UploadMaxArchiveEntries: 10000, // default maximum number of archive entries
+  UploadMaxArchiveSize: 1 << 30, // default maximum decompressed archive size (1 GiB)
+  SnippetFragments: 2, // default number of snippet fragments
+  SnippetWords: 15, // default number of words per snippet fragment
+  SessionMaxAge: 7 * 24 * 60 * 60, // default session lifetime (7 days)
+  SessionSecure: true, // send session cookies over HTTPS only by default
+  RateLimitSearch: 60, // default search rate limit (1 per second)
+  RateLimitSearchBurst: 20, // default search burst
+  RateLimitUpload: 10, // default upload rate limit
+  RateLimitUploadBurst: 5, // default upload burst
+  RateLimitEdit: 120, // default edit rate limit (2 per second)
+  RateLimitEditBurst: 30, // default edit burst
+  TrustedProxies: nil, // do not trust proxy headers by default
+}
+
+// Parse integer environment variable.
+//
+// Returns the default value if the environment variable is not set,
+// or an error if the value is not an integer or is less than the
+// given minimum value.
+func getEnvInt(key string, def, min int) (int, error) {
+  s := os.Getenv(key)
+  if s == "" {
+    return def, nil
+  }
+
+  val, err := strconv.Atoi(s)
+  if err != nil {
+    return 0, fmt.Errorf("%s: %w", key, err)
+  } else if val < min {
+    return 0, fmt.Errorf("%s: value must be at least %d", key, min)
+  }
+
+  return val, nil
+}
+
+// Parse boolean environment variable.
+//
+// Returns the default value if the environment variable is not set,
+// or an error if the value is not a boolean.
+func getEnvBool(key string, def bool) (bool, error) {
+  s := os.Getenv(key)
+  if s == "" {
+    return def, nil
+  }
+
+  val, err := strconv.ParseBool(s)
+  if err != nil {
+    return false, fmt.Errorf("%s: %w", key, err)
+  }
+
+  return val, nil
+}
+
+// Parse comma-separated list of IP addresses and CIDR prefixes from
+// environment variable.  IP addresses are converted to single-address
+// prefixes.
+//
+// Returns the default value if the environment variable is not set,
+// or an error if an address or prefix is invalid.
+func getEnvPrefixes(key string, def []netip.Prefix) ([]netip.Prefix, error) {
+  s := os.Getenv(key)
+  if s == "" {
+    return def, nil
+  }
+
+  var r []netip.Prefix
+  for _, v := range(strings.Split(s, ",")) {
+    v = strings.TrimSpace(v)
+    if strings.Contains(v, "/") {
+      prefix, err := netip.ParsePrefix(v)
+      if err != nil {
+        return nil, fmt.Errorf("%s: %w", key, err)
+      }
+      r = append(r, prefix.Masked())
+    } else {
+      addr, err := netip.ParseAddr(v)
+      if err != nil {
+        return nil, fmt.Errorf("%s: %w", key, err)
+      }
+      r = append(r, netip.PrefixFrom(addr, addr.BitLen()))
+    }
+  }
+
+  return r, nil
 }
 
 // Create new configuration from environment variables
@@ -32,7 +190,51 @@ var defaultConfig = Config {
 // * BOOKMAN_PASSWORD_PATH: path to file containing database password
 // * BOOKMAN_DATABASE_DSN: database dsn
 // * BOOKMAN_HTTP_ADDR: host and port to listen for http requests
-func NewConfigFromEnv() Config {
+// * BOOKMAN_MIGRATE_PASSWORD_PATH: path to file containing database
+//   password for migrations
+// * BOOKMAN_MIGRATE_DSN: database dsn for migrations
+// * BOOKMAN_MIGRATE_ROLE: database role which owns migrated database
+//   objects
+// * BOOKMAN_MIGRATE_ON_START: apply pending migrations at startup
+//   (boolean)
+// * BOOKMAN_UPLOAD_STRIP_BOILERPLATE: remove project gutenberg header
+//   and license from uploaded books (boolean)
+// * BOOKMAN_UPLOAD_MAX_FILE_SIZE: maximum size of each uploaded file,
+//   in bytes
+// * BOOKMAN_UPLOAD_MAX_REQUEST_SIZE: maximum size of upload request
+//   body, in bytes
+// * BOOKMAN_UPLOAD_MAX_ARCHIVE_ENTRIES: maximum number of entries in
+//   each uploaded archive
+// * BOOKMAN_UPLOAD_MAX_ARCHIVE_SIZE: maximum total decompressed size
+//   of each uploaded archive, in bytes
+// * BOOKMAN_SNIPPET_FRAGMENTS: maximum number of search result
+//   snippet fragments (0 for a single snippet around the best match)
+// * BOOKMAN_SNIPPET_WORDS: maximum number of words per search result
+//   snippet fragment (minimum 2)
+// * BOOKMAN_SESSION_MAX_AGE: login session lifetime, in seconds
+//   (minimum 60)
+// * BOOKMAN_SESSION_SECURE: only send session cookies over HTTPS
+//   (boolean)
+// * BOOKMAN_RATE_LIMIT_SEARCH: maximum search requests per minute for
+//   each client (0 to disable)
+// * BOOKMAN_RATE_LIMIT_SEARCH_BURST: maximum burst of search requests
+//   for each client (minimum 1)
+// * BOOKMAN_RATE_LIMIT_UPLOAD: maximum upload requests per minute for
+//   each client (0 to disable)
+// * BOOKMAN_RATE_LIMIT_UPLOAD_BURST: maximum burst of upload requests
+//   for each client (minimum 1)
+// * BOOKMAN_RATE_LIMIT_EDIT: maximum edit requests per minute for each
+//   client (0 to disable)
+// * BOOKMAN_RATE_LIMIT_EDIT_BURST: maximum burst of edit requests for
+//   each client (minimum 1)
+// * BOOKMAN_TRUSTED_PROXIES: comma-separated list of IP addresses and
+//   CIDR prefixes of trusted reverse proxies
+//
+// Returns an error if a numeric, boolean, or address environment
+// variable is invalid.
+func NewConfigFromEnv() (Config, error) {
+  var err error
+
   // create config
   config := defaultConfig
 
@@ -54,6 +256,120 @@ func NewConfigFromEnv() Config {
     config.HttpAddr = httpAddr
   }
 
+  // get migration password file path
+  migratePasswordPath := os.Getenv("BOOKMAN_MIGRATE_PASSWORD_PATH")
+  if migratePasswordPath != "" {
+    config.MigratePasswordPath = migratePasswordPath
+  }
+
+  // get migration dsn
+  migrateDsn := os.Getenv("BOOKMAN_MIGRATE_DSN")
+  if migrateDsn != "" {
+    config.MigrateDsn = migrateDsn
+  }
+
+  // get migration role
+  migrateRole := os.Getenv("BOOKMAN_MIGRATE_ROLE")
+  if migrateRole != "" {
+    config.MigrateRole = migrateRole
+  }
+
+  // parse migrate on start flag
+  config.MigrateOnStart, err = getEnvBool("BOOKMAN_MIGRATE_ON_START", config.MigrateOnStart)
+  if err != nil {
+    return config, err
+  }
+
+  // parse strip boilerplate flag
+  config.UploadStripBoilerplate, err = getEnvBool("BOOKMAN_UPLOAD_STRIP_BOILERPLATE", config.UploadStripBoilerplate)
+  if err != nil {
+    return config, err
+  }
+
+  // parse maximum file size
+  config.UploadMaxFileSize, err = getEnvInt("BOOKMAN_UPLOAD_MAX_FILE_SIZE", config.UploadMaxFileSize, 1)
+  if err != nil {
+    return config, err
+  }
+
+  // parse maximum request size
+  config.UploadMaxRequestSize, err = getEnvInt("BOOKMAN_UPLOAD_MAX_REQUEST_SIZE", config.UploadMaxRequestSize, 1)
+  if err != nil {
+    return config, err
+  }
+
+  // parse maximum archive entry count
+  config.UploadMaxArchiveEntries, err = getEnvInt("BOOKMAN_UPLOAD_MAX_ARCHIVE_ENTRIES", config.UploadMaxArchiveEntries, 1)
+  if err != nil {
+    return config, err
+  }
+
+  // parse maximum archive size
+  config.UploadMaxArchiveSize, err = getEnvInt("BOOKMAN_UPLOAD_MAX_ARCHIVE_SIZE", config.UploadMaxArchiveSize, 1)
+  if err != nil {
+    return config, err
+  }
+
+  // parse snippet fragment count
+  config.SnippetFragments, err = getEnvInt("BOOKMAN_SNIPPET_FRAGMENTS", config.SnippetFragments, 0)
+  if err != nil {
+    return config, err
+  }
+
+  // parse snippet fragment length
+  config.SnippetWords, err = getEnvInt("BOOKMAN_SNIPPET_WORDS", config.SnippetWords, 2)
+  if err != nil {
+    return config, err
+  }
+
+  // parse session lifetime
+  config.SessionMaxAge, err = getEnvInt("BOOKMAN_SESSION_MAX_AGE", config.SessionMaxAge, 60)
+  if err != nil {
+    return config, err
+  }
+
+  // parse secure session cookie flag
+  config.SessionSecure, err = getEnvBool("BOOKMAN_SESSION_SECURE", config.SessionSecure)
+  if err != nil {
+    return config, err
+  }
+
+  // parse search rate limit and burst
+  config.RateLimitSearch, err = getEnvInt("BOOKMAN_RATE_LIMIT_SEARCH", config.RateLimitSearch, 0)
+  if err != nil {
+    return config, err
+  }
+  config.RateLimitSearchBurst, err = getEnvInt("BOOKMAN_RATE_LIMIT_SEARCH_BURST", config.RateLimitSearchBurst, 1)
+  if err != nil {
+    return config, err
+  }
+
+  // parse upload rate limit and burst
+  config.RateLimitUpload, err = getEnvInt("BOOKMAN_RATE_LIMIT_UPLOAD", config.RateLimitUpload, 0)
+  if err != nil {
+    return config, err
+  }
+  config.RateLimitUploadBurst, err = getEnvInt("BOOKMAN_RATE_LIMIT_UPLOAD_BURST", config.RateLimitUploadBurst, 1)
+  if err != nil {
+    return config, err
+  }
+
+  // parse edit rate limit and burst
+  config.RateLimitEdit, err = getEnvInt("BOOKMAN_RATE_LIMIT_EDIT", config.RateLimitEdit, 0)
+  if err != nil {
+    return config, err
+  }
+  config.RateLimitEditBurst, err = getEnvInt("BOOKMAN_RATE_LIMIT_EDIT_BURST", config.RateLimitEditBurst, 1)
+  if err != nil {
+    return config, err
+  }
+
+  // parse trusted proxy addresses
+  config.TrustedProxies, err = getEnvPrefixes("BOOKMAN_TRUSTED_PROXIES", config.TrustedProxies)
+  if err != nil {
+    return config, err
+  }
+
   // return configuration
-  return config
+  return config, nil
 }
@@ -1,10 +1,45 @@
 package app
 
 import (
+  "net/netip"
   "reflect"
   "testing"
 )
 
+// Get expected default config, modified by the given function.
+func expConfig(fn func(*Config)) Config {
+  config := Config {
+    PasswordPath: "/run/secrets/bookman_web_password",
+    Dsn: "host=db dbname=bookman user=bookman_web",
+    HttpAddr: ":3000",
+    MigratePasswordPath: "/run/secrets/bookman_postgres_password",
+    MigrateDsn: "host=db dbname=bookman user=postgres",
+    MigrateRole: "bookman_sys",
+    MigrateOnStart: false,
+    UploadStripBoilerplate: false,
+    UploadMaxFileSize: 32 << 20,
+    UploadMaxRequestSize: 256 << 20,
+    UploadMaxArchiveEntries: 10000,
+    UploadMaxArchiveSize: 1 << 30,
+    SnippetFragments: 2,
+    SnippetWords: 15,
+    SessionMaxAge: 7 * 24 * 60 * 60,
+    SessionSecure: true,
+    RateLimitSearch: 60,
+    RateLimitSearchBurst: 20,
+    RateLimitUpload: 10,
+    RateLimitUploadBurst: 5,
+    RateLimitEdit: 120,
+    RateLimitEditBurst: 30,
+  }
+
+  if fn != nil {
+    fn(&config)
+  }
+
+  return config
+}
+
 func TestNewConfigFromEnv(t *testing.T) {
   var tests = []struct {
     name string // test name
@@ -12,41 +47,111 @@ func TestNewConfigFromEnv(t *testing.T) {
     exp Config // expected config
   } {{
     name: "default",
-    exp: Config {
-      PasswordPath: "/run/secrets/bookman_web_password",
-      Dsn: "host=db dbname=bookman user=bookman_web",
-      HttpAddr: ":3000",
-    },
+    exp: expConfig(nil),
   }, {
     name: "password",
     env: map[string]string {
       "BOOKMAN_PASSWORD_PATH": "foo bar baz",
     },
-    exp: Config {
-      PasswordPath: "foo bar baz",
-      Dsn: "host=db dbname=bookman user=bookman_web",
-      HttpAddr: ":3000",
-    },
+    exp: expConfig(func(c *Config) {
+      c.PasswordPath = "foo bar baz"
+    }),
   }, {
     name: "dsn",
     env: map[string]string {
       "BOOKMAN_DATABASE_DSN": "foo bar baz",
     },
-    exp: Config {
-      PasswordPath: "/run/secrets/bookman_web_password",
-      Dsn: "foo bar baz",
-      HttpAddr: ":3000",
-    },
+    exp: expConfig(func(c *Config) {
+      c.Dsn = "foo bar baz"
+    }),
   }, {
     name: "dsn",
     env: map[string]string {
       "BOOKMAN_HTTP_ADDRESS": "foo bar baz",
     },
-    exp: Config {
-      PasswordPath: "/run/secrets/bookman_web_password",
-      Dsn: "host=db dbname=bookman user=bookman_web",
-      HttpAddr: "foo bar baz",
+    exp: expConfig(func(c *Config) {
+      c.HttpAddr = "foo bar baz"
+    }),
+  }, {
+    name: "migrate",
+    env: map[string]string {
+      "BOOKMAN_MIGRATE_PASSWORD_PATH": "foo",
+      "BOOKMAN_MIGRATE_DSN": "bar",
+      "BOOKMAN_MIGRATE_ROLE": "baz",
+      "BOOKMAN_MIGRATE_ON_START": "true",
     },
+    exp: expConfig(func(c *Config) {
+      c.MigratePasswordPath = "foo"
+      c.MigrateDsn = "bar"
+      c.MigrateRole = "baz"
+      c.MigrateOnStart = true
+    }),
+  }, {
+    name: "upload",
+    env: map[string]string {
+      "BOOKMAN_UPLOAD_STRIP_BOILERPLATE": "1",
+      "BOOKMAN_UPLOAD_MAX_FILE_SIZE": "1000",
+      "BOOKMAN_UPLOAD_MAX_REQUEST_SIZE": "2000",
+      "BOOKMAN_UPLOAD_MAX_ARCHIVE_ENTRIES": "10",
+      "BOOKMAN_UPLOAD_MAX_ARCHIVE_SIZE": "3000",
+    },
+    exp: expConfig(func(c *Config) {
+      c.UploadStripBoilerplate = true
+      c.UploadMaxFileSize = 1000
+      c.UploadMaxRequestSize = 2000
+      c.UploadMaxArchiveEntries = 10
+      c.UploadMaxArchiveSize = 3000
+    }),
+  }, {
+    name: "snippets",
+    env: map[string]string {
+      "BOOKMAN_SNIPPET_FRAGMENTS": "0",
+      "BOOKMAN_SNIPPET_WORDS": "30",
+    },
+    exp: expConfig(func(c *Config) {
+      c.SnippetFragments = 0
+      c.SnippetWords = 30
+    }),
+  }, {
+    name: "sessions",
+    env: map[string]string {
+      "BOOKMAN_SESSION_MAX_AGE": "3600",
+      "BOOKMAN_SESSION_SECURE": "false",
+    },
+    exp: expConfig(func(c *Config) {
+      c.SessionMaxAge = 3600
+      c.SessionSecure = false
+    }),
+  }, {
+    name: "rate limits",
+    env: map[string]string {
+      "BOOKMAN_RATE_LIMIT_SEARCH": "0",
+      "BOOKMAN_RATE_LIMIT_SEARCH_BURST": "1",
+      "BOOKMAN_RATE_LIMIT_UPLOAD": "2",
+      "BOOKMAN_RATE_LIMIT_UPLOAD_BURST": "3",
+      "BOOKMAN_RATE_LIMIT_EDIT": "4",
+      "BOOKMAN_RATE_LIMIT_EDIT_BURST": "5",
+    },
+    exp: expConfig(func(c *Config) {
+      c.RateLimitSearch = 0
+      c.RateLimitSearchBurst = 1
+      c.RateLimitUpload = 2
+      c.RateLimitUploadBurst = 3
+      c.RateLimitEdit = 4
+      c.RateLimitEditBurst = 5
+    }),
+  }, {
+    name: "trusted proxies",
+    env: map[string]string {
+      "BOOKMAN_TRUSTED_PROXIES": "10.0.0.1, 172.16.1.2/12,::1",
+    },
+    exp: expConfig(func(c *Config) {
+      c.TrustedProxies = []netip.Prefix {
+        netip.MustParsePrefix("10.0.0.1/32"),
+        netip.MustParsePrefix("172.16.0.0/12"),
+        netip.MustParsePrefix("::1/128"),
+      }
+    }),
   }}
 
   for _, test := range(tests) {
@@ -57,11 +162,79 @@ func TestNewConfigFromEnv(t *testing.T) {
       }
 
       // load config from environment
-      got := NewConfigFromEnv()
+      got, err := NewConfigFromEnv()
+      if err != nil {
+        t.Fatal(err)
+      }
 
       if !reflect.DeepEqual(got, test.exp) {
         t.Fatalf("got %#v, exp %#v", got, test.exp)
       }
     })
   }
+
+  // tests expected to fail
+  var failTests = []struct {
+    name string // test name
+    env map[string]string // test env vars
+  } {{
+    name: "migrate on start not bool",
+    env: map[string]string { "BOOKMAN_MIGRATE_ON_START": "foo" },
+  }, {
+    name: "snippet fragments not int",
+    env: map[string]string { "BOOKMAN_SNIPPET_FRAGMENTS": "foo" },
+  }, {
+    name: "snippet fragments negative",
+    env: map[string]string { "BOOKMAN_SNIPPET_FRAGMENTS": "-1" },
+  }, {
+    name: "snippet words too small",
+    env: map[string]string { "BOOKMAN_SNIPPET_WORDS": "1" },
+  }, {
+    name: "max file size zero",
+    env: map[string]string { "BOOKMAN_UPLOAD_MAX_FILE_SIZE": "0" },
+  }, {
+    name: "max request size not int",
+    env: map[string]string { "BOOKMAN_UPLOAD_MAX_REQUEST_SIZE": "foo" },
+  }, {
+    name: "max archive entries zero",
+    env: map[string]string { "BOOKMAN_UPLOAD_MAX_ARCHIVE_ENTRIES": "0" },
+  }, {
+    name: "max archive size not int",
+    env: map[string]string { "BOOKMAN_UPLOAD_MAX_ARCHIVE_SIZE": "foo" },
+  }, {
+    name: "session max age too small",
+    env: map[string]string { "BOOKMAN_SESSION_MAX_AGE": "59" },
+  }, {
+    name: "session secure not bool",
+    env: map[string]string { "BOOKMAN_SESSION_SECURE": "foo" },
+  }, {
+    name: "search rate limit negative",
+    env: map[string]string { "BOOKMAN_RATE_LIMIT_SEARCH": "-1" },
+  }, {
+    name: "upload burst zero",
+    env: map[string]string { "BOOKMAN_RATE_LIMIT_UPLOAD_BURST": "0" },
+  }, {
+    name: "edit rate limit not int",
+    env: map[string]string { "BOOKMAN_RATE_LIMIT_EDIT": "foo" },
+  }, {
+    name: "trusted proxy invalid address",
+    env: map[string]string { "BOOKMAN_TRUSTED_PROXIES": "10.0.0.1,foo" },
+  }, {
+    name: "trusted proxy invalid prefix",
+    env: map[string]string { "BOOKMAN_TRUSTED_PROXIES": "10.0.0.0/33" },
+  }}
+
+  for _, test := range(failTests) {
+    t.Run(test.name, func(t *testing.T) {
+      // set custom env vars
+      for k, v := range(test.env) {
+        t.Setenv(k, v)
+      }
+
+      // load config from environment
+      if got, err := NewConfigFromEnv(); err == nil {
+        t.Fatalf("got %#v, exp err", got)
+      }
+    })
+  }
 }
@@ -20,16 +20,16 @@ type Context struct {
   Pool *pgxpool.Pool
 }
 
-// Create database pool from config.
-func newPool(ctx context.Context, config Config) (*pgxpool.Pool, error) {
+// Create database pool from DSN and path to password file.
+func newPool(ctx context.Context, dsn, passwordPath string) (*pgxpool.Pool, error) {
   // read dsn password from secrets file
-  password, err := os.ReadFile(config.PasswordPath)
+  password, err := os.ReadFile(passwordPath)
   if err != nil {
     return nil, err
   }
 
   // parse dsn as pool config
-  poolConfig, err := pgxpool.ParseConfig(config.Dsn)
+  poolConfig, err := pgxpool.ParseConfig(dsn)
   if err != nil {
     return nil, err
   }
@@ -41,10 +41,15 @@ func newPool(ctx context.Context, config Config) (*pgxpool.Pool, error) {
   return pgxpool.NewWithConfig(ctx, poolConfig)
 }
 
+// Create database pool for schema migrations from config.
+func NewMigratePool(ctx context.Context, config Config) (*pgxpool.Pool, error) {
+  return newPool(ctx, config.MigrateDsn, config.MigratePasswordPath)
+}
+
 // create new application context
 func NewContext(ctx context.Context, config Config) (*Context, error) {
   // create pool
-  pool, err := newPool(ctx, config)
+  pool, err := newPool(ctx, config.Dsn, config.PasswordPath)
   if err != nil {
     return nil, err
   }
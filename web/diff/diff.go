@@ -0,0 +1,265 @@
+// Line-based text diffs.
+package diff
+
+import (
+  "fmt"
+  "strings"
+)
+
+// Edit operation.
+type Op byte
+
+const (
+  Equal Op = ' ' // line is in both texts
+  Delete Op = '-' // line is only in the old text
+  Insert Op = '+' // line is only in the new text
+)
+
+// Line of diff.
+type Edit struct {
+  Op Op // edit operation
+  Text string // line text, sans newline
+}
+
+// Maximum number of inserted and deleted lines found by Lines().
+//
+// If the texts differ by more lines than this, then the differing part
+// of the old text is replaced by the differing part of the new text in
+// a single edit, rather than using excessive time and memory to find a
+// minimal diff.
+const maxEditDistance = 2000
+
+// Get minimal list of edits which convert old lines to new lines.
+func Lines(a, b []string) []Edit {
+  // find common prefix
+  pre := 0
+  for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+    pre++
+  }
+
+  // find common suffix
+  suf := 0
+  for suf < len(a) - pre && suf < len(b) - pre && a[len(a) - 1 - suf] == b[len(b) - 1 - suf] {
+    suf++
+  }
+
+  // build result
+  r := make([]Edit, 0, len(a) + len(b) - pre - suf)
+  for _, s := range(a[:pre]) {
+    r = append(r, Edit { Equal, s })
+  }
+  r = append(r, myers(a[pre:len(a) - suf], b[pre:len(b) - suf])...)
+  for _, s := range(a[len(a) - suf:]) {
+    r = append(r, Edit { Equal, s })
+  }
+
+  return r
+}
+
+// Find shortest edit script with Myers' algorithm.
+//
+// ref: "An O(ND) Difference Algorithm and Its Variations", Myers 1986
+func myers(a, b []string) []Edit {
+  n, m := len(a), len(b)
+  max := n + m
+  if max == 0 {
+    return nil
+  }
+
+  // furthest x reached on each diagonal k, indexed by k + offset
+  offset := max + 1
+  v := make([]int, 2 * max + 3)
+
+  // copies of v[-d-1:d+2] before each step, for backtracking
+  var trace [][]int
+
+  // find length of shortest edit script
+  dist := -1
+  for d := 0; d <= max && d <= maxEditDistance && dist < 0; d++ {
+    trace = append(trace, append([]int(nil), v[offset - d - 1:offset + d + 2]...))
+
+    for k := -d; k <= d; k += 2 {
+      // move down (insert) or right (delete)
+      var x int
+      if k == -d || (k != d && v[offset + k - 1] < v[offset + k + 1]) {
+        x = v[offset + k + 1]
+      } else {
+        x = v[offset + k - 1] + 1
+      }
+      y := x - k
+
+      // follow diagonal (equal lines)
+      for x < n && y < m && a[x] == b[y] {
+        x++
+        y++
+      }
+      v[offset + k] = x
+
+      if x >= n && y >= m {
+        dist = d
+        break
+      }
+    }
+  }
+
+  // texts differ by too much, replace all lines
+  if dist < 0 {
+    r := make([]Edit, 0, n + m)
+    for _, s := range(a) {
+      r = append(r, Edit { Delete, s })
+    }
+    for _, s := range(b) {
+      r = append(r, Edit { Insert, s })
+    }
+    return r
+  }
+
+  // backtrack from end, building edits in reverse order
+  var r []Edit
+  x, y := n, m
+  for d := dist; d > 0; d-- {
+    // get v before step d (note: prev[i] is v[i - d - 1])
+    prev := trace[d]
+    get := func(k int) int { return prev[k + d + 1] }
+
+    // find previous diagonal
+    k := x - y
+    var prevK int
+    if k == -d || (k != d && get(k - 1) < get(k + 1)) {
+      prevK = k + 1
+    } else {
+      prevK = k - 1
+    }
+    prevX := get(prevK)
+    prevY := prevX - prevK
+
+    // add diagonal
+    for x > prevX && y > prevY {
+      r = append(r, Edit { Equal, a[x - 1] })
+      x--
+      y--
+    }
+
+    // add insert or delete
+    if x == prevX {
+      r = append(r, Edit { Insert, b[prevY] })
+    } else {
+      r = append(r, Edit { Delete, a[prevX] })
+    }
+    x, y = prevX, prevY
+  }
+
+  // add leading diagonal
+  for x > 0 && y > 0 {
+    r = append(r, Edit { Equal, a[x - 1] })
+    x--
+    y--
+  }
+
+  // reverse edits
+  for i, j := 0, len(r) - 1; i < j; i, j = i + 1, j - 1 {
+    r[i], r[j] = r[j], r[i]
+  }
+
+  return r
+}
+
+// Split text into lines.  A trailing newline does not start a new
+// line, and an empty text has no lines.
+func SplitLines(s string) []string {
+  if s == "" {
+    return nil
+  }
+
+  s = strings.ReplaceAll(s, "\r\n", "\n")
+  return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Format hunk range as "start,count".
+func hunkRange(start, count int) string {
+  // empty ranges start at the line before the hunk
+  if count > 0 {
+    start++
+  }
+
+  return fmt.Sprintf("%d,%d", start, count)
+}
+
+// Get unified diff of old and new text with the given number of lines
+// of context around each change.
+//
+// Returns an empty string if the texts have the same lines.
+func Unified(aName, bName, a, b string, context int) string {
+  edits := Lines(SplitLines(a), SplitLines(b))
+
+  var sb strings.Builder
+  aLine, bLine := 0, 0 // line numbers of start of current edit
+  for i := 0; i < len(edits); {
+    // skip to next change
+    if edits[i].Op == Equal {
+      aLine++
+      bLine++
+      i++
+      continue
+    }
+
+    // write header before first hunk
+    if sb.Len() == 0 {
+      fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+    }
+
+    // find start of hunk (leading context)
+    start := i - context
+    if start < 0 {
+      start = 0
+    }
+    aStart, bStart := aLine - (i - start), bLine - (i - start)
+
+    // find end of hunk: extend until there are more than 2 * context
+    // equal lines before the next change, or the end of the edits
+    end := i
+    for j := i; j < len(edits); j++ {
+      if edits[j].Op != Equal {
+        end = j + 1
+      } else if j - end >= 2 * context {
+        break
+      }
+    }
+    end += context
+    if end > len(edits) {
+      end = len(edits)
+    }
+
+    // count lines in hunk
+    aCount, bCount := 0, 0
+    for _, e := range(edits[start:end]) {
+      if e.Op != Insert {
+        aCount++
+      }
+      if e.Op != Delete {
+        bCount++
+      }
+    }
+
+    // write hunk
+    fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+    for _, e := range(edits[start:end]) {
+      sb.WriteByte(byte(e.Op))
+      sb.WriteString(e.Text)
+      sb.WriteByte('\n')
+    }
+
+    // advance to end of hunk
+    for _, e := range(edits[i:end]) {
+      if e.Op != Insert {
+        aLine++
+      }
+      if e.Op != Delete {
+        bLine++
+      }
+    }
+    i = end
+  }
+
+  return sb.String()
+}
@@ -0,0 +1,171 @@
+package diff
+
+import (
+  "fmt"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+// Apply edits to old lines.  Returns the old lines and the new lines.
+func applyEdits(edits []Edit) ([]string, []string) {
+  var a, b []string
+  for _, e := range(edits) {
+    if e.Op != Insert {
+      a = append(a, e.Text)
+    }
+    if e.Op != Delete {
+      b = append(b, e.Text)
+    }
+  }
+
+  return a, b
+}
+
+// Count inserted and deleted lines.
+func countChanges(edits []Edit) int {
+  r := 0
+  for _, e := range(edits) {
+    if e.Op != Equal {
+      r++
+    }
+  }
+
+  return r
+}
+
+func TestLines(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    a string // old text
+    b string // new text
+    exp int // expected number of changed lines
+  } {
+    { "empty", "", "", 0 },
+    { "same", "a\nb\nc", "a\nb\nc", 0 },
+    { "insert", "", "a\nb", 2 },
+    { "delete", "a\nb", "", 2 },
+    { "change", "a\nb\nc", "a\nx\nc", 2 },
+    { "myers", "a\nb\nc\na\nb\nb\na", "c\nb\na\nb\na\nc", 5 },
+    { "interleaved", "a\nb\nc\nd\ne", "x\nb\ny\nd\nz", 6 },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      a, b := SplitLines(test.a), SplitLines(test.b)
+      edits := Lines(a, b)
+
+      // check that edits convert a to b
+      gotA, gotB := applyEdits(edits)
+      if !reflect.DeepEqual(gotA, a) || !reflect.DeepEqual(gotB, b) {
+        t.Fatalf("got (%#v, %#v), exp (%#v, %#v)", gotA, gotB, a, b)
+      }
+
+      // check that edits are minimal
+      if got := countChanges(edits); got != test.exp {
+        t.Fatalf("got %d changes, exp %d", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestLinesMaxEditDistance(t *testing.T) {
+  // build texts with no lines in common
+  var a, b []string
+  for i := 0; i < maxEditDistance; i++ {
+    a = append(a, fmt.Sprintf("a%d", i))
+    b = append(b, fmt.Sprintf("b%d", i))
+  }
+
+  edits := Lines(a, b)
+  gotA, gotB := applyEdits(edits)
+  if !reflect.DeepEqual(gotA, a) || !reflect.DeepEqual(gotB, b) {
+    t.Fatal("edits do not convert a to b")
+  }
+  if got, exp := countChanges(edits), 2 * maxEditDistance; got != exp {
+    t.Fatalf("got %d changes, exp %d", got, exp)
+  }
+}
+
+func TestSplitLines(t *testing.T) {
+  tests := []struct {
+    val string // test value
+    exp []string // expected result
+  } {
+    { "", nil },
+    { "\n", []string { "" } },
+    { "a", []string { "a" } },
+    { "a\r\nb\n", []string { "a", "b" } },
+    { "a\n\nb", []string { "a", "", "b" } },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.val, func(t *testing.T) {
+      if got := SplitLines(test.val); !reflect.DeepEqual(got, test.exp) {
+        t.Fatalf("got %#v, exp %#v", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestUnified(t *testing.T) {
+  // build text with numbered lines
+  lines := func(n int, changes map[int]string) string {
+    var sb strings.Builder
+    for i := 1; i <= n; i++ {
+      if s, ok := changes[i]; ok {
+        sb.WriteString(s)
+      } else {
+        fmt.Fprintf(&sb, "%d\n", i)
+      }
+    }
+    return sb.String()
+  }
+
+  tests := []struct {
+    name string // test name
+    a string // old text
+    b string // new text
+    exp string // expected diff
+  } {{
+    name: "same",
+    a: "a\nb\n",
+    b: "a\nb\n",
+    exp: "",
+  }, {
+    name: "new file",
+    a: "",
+    b: "a\nb\n",
+    exp: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+  }, {
+    name: "deleted file",
+    a: "a\nb\n",
+    b: "",
+    exp: "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+  }, {
+    name: "one hunk",
+    a: lines(10, nil),
+    b: lines(10, map[int]string { 5: "five\n" }),
+    exp: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+  }, {
+    name: "merged hunks",
+    a: lines(10, nil),
+    b: lines(10, map[int]string { 2: "", 8: "" }),
+    exp: "--- a\n+++ b\n@@ -1,10 +1,8 @@\n 1\n-2\n 3\n 4\n 5\n 6\n 7\n-8\n 9\n 10\n",
+  }, {
+    name: "two hunks",
+    a: lines(20, nil),
+    b: lines(20, map[int]string { 2: "", 18: "x\n18\n" }),
+    exp: "--- a\n+++ b\n" +
+         "@@ -1,5 +1,4 @@\n 1\n-2\n 3\n 4\n 5\n" +
+         "@@ -15,6 +14,7 @@\n 15\n 16\n 17\n+x\n 18\n 19\n 20\n",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      if got := Unified("a", "b", test.a, test.b, 3); got != test.exp {
+        t.Fatalf("got %q, exp %q", got, test.exp)
+      }
+    })
+  }
+}
@@ -0,0 +1,281 @@
+package export
+
+import (
+  "archive/zip"
+  "bookman/model"
+  "encoding/xml"
+  "fmt"
+  "html/template"
+  "io"
+  "strings"
+  textTemplate "text/template"
+  "time"
+)
+
+// Maximum number of paragraphs in each EPUB content document.
+//
+// E-readers load each content document in the spine separately, so
+// long books are split into several documents.
+const epubChapterSize = 200
+
+// META-INF/container.xml
+const epubContainer = `<?xml version='1.0' encoding='UTF-8'?>
+<container version='1.0' xmlns='urn:oasis:names:tc:opendocument:xmlns:container'>
+  <rootfiles>
+    <rootfile full-path='OEBPS/content.opf' media-type='application/oebps-package+xml'/>
+  </rootfiles>
+</container>
+`
+
+// template functions for xml templates
+var epubFuncs = textTemplate.FuncMap {
+  // escape xml text
+  "xml": func(s string) (string, error) {
+    var sb strings.Builder
+    err := xml.EscapeText(&sb, []byte(s))
+    return sb.String(), err
+  },
+}
+
+// OEBPS/content.opf template
+var epubPackageTemplate = textTemplate.Must(textTemplate.New("opf").Funcs(epubFuncs).Parse(`<?xml version='1.0' encoding='UTF-8'?>
+<package version='3.0' xmlns='http://www.idpf.org/2007/opf' unique-identifier='book-id'>
+  <metadata xmlns:dc='http://purl.org/dc/elements/1.1/'>
+    <dc:identifier id='book-id'>urn:bookman:book:{{ .Book.Id }}</dc:identifier>
+    <dc:title>{{ xml .Book.Name }}</dc:title>
+    {{- if .Book.Author }}
+    <dc:creator>{{ xml .Book.Author }}</dc:creator>
+    {{- end }}
+    <dc:language>{{ xml .Lang }}</dc:language>
+    {{- if .Book.ReleaseDate }}
+    <dc:date>{{ xml .Book.ReleaseDate }}</dc:date>
+    {{- end }}
+    <meta property='dcterms:modified'>{{ .Modified }}</meta>
+  </metadata>
+
+  <manifest>
+    <item id='nav' href='nav.xhtml' media-type='application/xhtml+xml' properties='nav'/>
+    {{- range .Chapters }}
+    <item id='{{ .Id }}' href='{{ .Id }}.xhtml' media-type='application/xhtml+xml'/>
+    {{- end }}
+  </manifest>
+
+  <spine>
+    {{- range .Chapters }}
+    <itemref idref='{{ .Id }}'/>
+    {{- end }}
+  </spine>
+</package>
+`))
+
+// OEBPS/nav.xhtml template
+var epubNavTemplate = textTemplate.Must(textTemplate.New("nav").Funcs(epubFuncs).Parse(`<?xml version='1.0' encoding='UTF-8'?>
+<!DOCTYPE html>
+<html xmlns='http://www.w3.org/1999/xhtml' xmlns:epub='http://www.idpf.org/2007/ops' lang='{{ xml .Lang }}' xml:lang='{{ xml .Lang }}'>
+  <head>
+    <title>{{ xml .Book.Name }}</title>
+  </head>
+
+  <body>
+    <nav epub:type='toc'>
+      <h1>{{ xml .Book.Name }}</h1>
+      <ol>
+        {{- range .Chapters }}
+        <li><a href='{{ .Id }}.xhtml'>{{ xml .Title }}</a></li>
+        {{- end }}
+      </ol>
+    </nav>
+  </body>
+</html>
+`))
+
+// OEBPS/chapter-N.xhtml template
+//
+// Note: html/template escapes the XML declaration, so it is written by
+// Epub() instead.
+var epubChapterTemplate = template.Must(template.New("chapter").Parse(`<!DOCTYPE html>
+<html xmlns='http://www.w3.org/1999/xhtml' lang='{{ .Lang }}' xml:lang='{{ .Lang }}'>
+  <head>
+    <title>{{ .Title }}</title>
+  </head>
+
+  <body>
+{{- if .First }}
+    <h1>{{ .Book.Name }}</h1>
+    {{- if .Book.Author }}
+    <p class='author'>{{ .Book.Author }}</p>
+    {{- end }}
+{{- end }}
+{{ range .Paragraphs }}
+    {{ template "paragraph" . }}
+{{- end }}
+  </body>
+</html>
+`))
+
+func init() {
+  template.Must(epubChapterTemplate.Parse(paragraphTemplate))
+}
+
+// EPUB content document.
+type epubChapter struct {
+  Id string // manifest item ID and file name, sans extension
+  Title string // title in table of contents
+  Paragraphs []Paragraph // chapter paragraphs
+}
+
+// Is the rune allowed in an XML 1.0 document?
+//
+// See the `Char` production in the XML 1.0 specification.
+func isXmlChar(r rune) bool {
+  return r == '\t' || r == '\n' || r == '\r' ||
+    (r >= 0x20 && r <= 0xD7FF) ||
+    (r >= 0xE000 && r <= 0xFFFD) ||
+    (r >= 0x10000 && r <= 0x10FFFF)
+}
+
+// Remove characters which are not allowed in XML documents (e.g. the
+// form feeds which separate pages in some Project Gutenberg texts).
+//
+// html/template does not escape these characters, and e-readers reject
+// content documents which contain them.
+func xmlChars(s string) string {
+  return strings.Map(func(r rune) rune {
+    if !isXmlChar(r) {
+      return -1
+    }
+    return r
+  }, s)
+}
+
+// Split paragraphs into chapters.
+func epubChapters(paras []Paragraph) []epubChapter {
+  var r []epubChapter
+
+  for i := 0; i == 0 || i < len(paras); i += epubChapterSize {
+    end := i + epubChapterSize
+    if end > len(paras) {
+      end = len(paras)
+    }
+
+    num := len(r) + 1
+    r = append(r, epubChapter {
+      Id: fmt.Sprintf("chapter-%d", num),
+      Title: fmt.Sprintf("Part %d", num),
+      Paragraphs: paras[i:end],
+    })
+  }
+
+  return r
+}
+
+// Write file to zip.
+func writeZipFile(zw *zip.Writer, name string, method uint16, modified time.Time, fn func(io.Writer) error) error {
+  fw, err := zw.CreateHeader(&zip.FileHeader {
+    Name: name,
+    Method: method,
+    Modified: modified,
+  })
+  if err != nil {
+    return err
+  }
+
+  return fn(fw)
+}
+
+// Write string to zip.
+func writeZipString(zw *zip.Writer, name string, method uint16, modified time.Time, s string) error {
+  return writeZipFile(zw, name, method, modified, func(w io.Writer) error {
+    _, err := io.WriteString(w, s)
+    return err
+  })
+}
+
+// Write book as EPUB 3 file.
+//
+// Characters which are not allowed in XML documents are removed from
+// the book metadata and text.
+//
+// The book is identified by a `urn:bookman:book:` URN and the
+// modification time of the EPUB is the time the book was added.
+func Epub(w io.Writer, book model.FullBook) error {
+  // remove characters which are not allowed in xml
+  book.Name = xmlChars(book.Name)
+  book.Author = xmlChars(book.Author)
+  book.ReleaseDate = xmlChars(book.ReleaseDate)
+  book.Body = xmlChars(book.Body)
+
+  modified := book.CreatedAt.UTC().Truncate(time.Second)
+  chapters := epubChapters(Paragraphs(book.Body))
+  lang := LanguageCode(book.Language)
+
+  zw := zip.NewWriter(w)
+
+  // write mimetype; must be the first file in the zip and must not be
+  // compressed
+  if err := writeZipString(zw, "mimetype", zip.Store, modified, "application/epub+zip"); err != nil {
+    return err
+  }
+
+  // write container
+  if err := writeZipString(zw, "META-INF/container.xml", zip.Deflate, modified, epubContainer); err != nil {
+    return err
+  }
+
+  // template data for package document and nav document
+  data := struct {
+    Book model.FullBook
+    Lang string
+    Modified string
+    Chapters []epubChapter
+  } {
+    Book: book,
+    Lang: lang,
+    Modified: modified.Format(time.RFC3339),
+    Chapters: chapters,
+  }
+
+  // write package document
+  if err := writeZipFile(zw, "OEBPS/content.opf", zip.Deflate, modified, func(w io.Writer) error {
+    return epubPackageTemplate.Execute(w, data)
+  }); err != nil {
+    return err
+  }
+
+  // write nav document
+  if err := writeZipFile(zw, "OEBPS/nav.xhtml", zip.Deflate, modified, func(w io.Writer) error {
+    return epubNavTemplate.Execute(w, data)
+  }); err != nil {
+    return err
+  }
+
+  // write chapters
+  for i, chapter := range(chapters) {
+    if err := writeZipFile(zw, "OEBPS/" + chapter.Id + ".xhtml", zip.Deflate, modified, func(w io.Writer) error {
+      // write xml declaration
+      if _, err := io.WriteString(w, xml.Header); err != nil {
+        return err
+      }
+
+      // write document
+      return epubChapterTemplate.Execute(w, struct {
+        Book model.FullBook
+        Lang string
+        Title string
+        First bool
+        Paragraphs []Paragraph
+      } {
+        Book: book,
+        Lang: lang,
+        Title: book.Name + " - " + chapter.Title,
+        First: i == 0,
+        Paragraphs: chapter.Paragraphs,
+      })
+    }); err != nil {
+      return err
+    }
+  }
+
+  // finish zip
+  return zw.Close()
+}
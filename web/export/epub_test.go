@@ -0,0 +1,200 @@
+package export
+
+import (
+  "archive/zip"
+  "bookman/ingest"
+  "bookman/model"
+  "bytes"
+  "encoding/xml"
+  "fmt"
+  "io"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestEpub(t *testing.T) {
+  // build long body which spans several chapters
+  var paras []string
+  for i := 0; i < 2 * epubChapterSize + 1; i++ {
+    paras = append(paras, fmt.Sprintf("Paragraph %d of the book, which is long enough to be wrapped\nprose.", i))
+  }
+
+  book := model.FullBook {
+    Id: 1234,
+    Name: "Cats & Dogs",
+    Author: "Jane Doe",
+    ReleaseDate: "January, 1901",
+    Language: "English",
+    CreatedAt: time.Date(2023, 4, 5, 6, 7, 8, 9, time.UTC),
+    Body: strings.Join(paras, "\n\n"),
+  }
+
+  // write epub
+  var buf bytes.Buffer
+  if err := Epub(&buf, book); err != nil {
+    t.Fatal(err)
+  }
+  data := buf.Bytes()
+
+  t.Run("mimetype", func(t *testing.T) {
+    // open zip
+    zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    // check first entry
+    fh := zr.File[0]
+    if fh.Name != "mimetype" || fh.Method != zip.Store {
+      t.Fatalf("got %q (method %d), exp stored mimetype", fh.Name, fh.Method)
+    }
+
+    // check first entry contents
+    rc, err := fh.Open()
+    if err != nil {
+      t.Fatal(err)
+    }
+    defer rc.Close()
+    got, err := io.ReadAll(rc)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if string(got) != "application/epub+zip" {
+      t.Fatalf("got %q, exp \"application/epub+zip\"", got)
+    }
+  })
+
+  t.Run("chapters", func(t *testing.T) {
+    // open zip
+    zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    for _, name := range([]string { "chapter-1", "chapter-2", "chapter-3" }) {
+      if _, err := zr.Open("OEBPS/" + name + ".xhtml"); err != nil {
+        t.Errorf("missing %s: %v", name, err)
+      }
+    }
+  })
+
+  t.Run("roundtrip", func(t *testing.T) {
+    if !ingest.IsEpub(data) {
+      t.Fatal("got false, exp true")
+    }
+
+    // parse epub
+    got, err := ingest.ParseEpub(data, ingest.ArchiveLimits { MaxEntries: 100, MaxFileSize: 1 << 20, MaxSize: 1 << 20 })
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    // check metadata
+    exp := ingest.Epub {
+      Title: "Cats & Dogs",
+      Author: "Jane Doe",
+      Language: "en",
+      Date: "January, 1901",
+    }
+    gotText := got.Text
+    got.Text = ""
+    if got != exp {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+
+    // check text
+    expText := "Cats & Dogs\n\nJane Doe\n\nParagraph 0 of the book, which is long enough to be wrapped prose."
+    if !strings.HasPrefix(gotText, expText) {
+      t.Fatalf("got %q, exp prefix %q", gotText[:len(expText)], expText)
+    }
+    lastText := fmt.Sprintf("Paragraph %d of the book, which is long enough to be wrapped prose.", 2 * epubChapterSize)
+    if !strings.HasSuffix(gotText, lastText) {
+      t.Fatalf("missing suffix %q", lastText)
+    }
+  })
+}
+
+func TestEpubControlCharacters(t *testing.T) {
+  book := model.FullBook {
+    Id: 1234,
+    Name: "Cats\x01 & Dogs",
+    Author: "Jane Doe",
+    CreatedAt: time.Date(2023, 4, 5, 6, 7, 8, 9, time.UTC),
+    Body: "Page one.\n\n\fPage\x00 two.\n\nPage three.\x1b",
+  }
+
+  // write epub
+  var buf bytes.Buffer
+  if err := Epub(&buf, book); err != nil {
+    t.Fatal(err)
+  }
+  data := buf.Bytes()
+
+  // open zip
+  zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // check that every xml document is well-formed
+  for _, fh := range(zr.File) {
+    if !strings.HasSuffix(fh.Name, ".xml") && !strings.HasSuffix(fh.Name, ".xhtml") && !strings.HasSuffix(fh.Name, ".opf") {
+      continue
+    }
+
+    t.Run(fh.Name, func(t *testing.T) {
+      rc, err := fh.Open()
+      if err != nil {
+        t.Fatal(err)
+      }
+      defer rc.Close()
+
+      dec := xml.NewDecoder(rc)
+      dec.Strict = true
+      for {
+        if _, err := dec.Token(); err == io.EOF {
+          break
+        } else if err != nil {
+          t.Fatal(err)
+        }
+      }
+    })
+  }
+
+  // check text
+  got, err := ingest.ParseEpub(data, ingest.ArchiveLimits { MaxEntries: 100, MaxFileSize: 1 << 20, MaxSize: 1 << 20 })
+  if err != nil {
+    t.Fatal(err)
+  }
+  exp := "Cats & Dogs\n\nJane Doe\n\nPage one.\n\nPage two.\n\nPage three."
+  if got.Text != exp {
+    t.Fatalf("got %q, exp %q", got.Text, exp)
+  }
+}
+
+func TestXmlChars(t *testing.T) {
+  tests := []struct {
+    name string
+    val string
+    exp string
+  } {
+    { "empty", "", "" },
+    { "plain", "foo bar", "foo bar" },
+    { "whitespace", "foo\tbar\r\nbaz", "foo\tbar\r\nbaz" },
+    { "form feed", "foo\fbar", "foobar" },
+    { "nul", "foo\x00bar", "foobar" },
+    { "escape", "\x1b[1mfoo", "[1mfoo" },
+    { "non-ascii", "caf\u00e9 \U0001F600", "caf\u00e9 \U0001F600" },
+    { "noncharacter", "foo\uFFFEbar", "foobar" },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      got := xmlChars(test.val)
+      if got != test.exp {
+        t.Fatalf("got %q, exp %q", got, test.exp)
+      }
+    })
+  }
+}
@@ -0,0 +1,132 @@
+// Export books as standalone HTML documents and EPUB files.
+package export
+
+import (
+  "regexp"
+  "strings"
+)
+
+// Paragraph of book text.
+type Paragraph struct {
+  // lines of paragraph, sans leading and trailing whitespace
+  Lines []string
+
+  // Preserve line breaks?  True for verse, tables of contents, and
+  // other paragraphs made of short lines.
+  Verse bool
+}
+
+// Get paragraph text with lines joined by spaces.
+func (p Paragraph) Text() string {
+  return strings.Join(p.Lines, " ")
+}
+
+// Lines shorter than this are treated as intentional line breaks
+// rather than hard wrapping.
+//
+// Project Gutenberg texts are hard-wrapped at 70-75 columns, so a
+// paragraph where every line but the last is shorter than this is
+// probably verse.
+const verseLineLength = 55
+
+// blank line separator
+var blankLineRe = regexp.MustCompile(`\n[ \t]*\n`)
+
+// Split book body into paragraphs.
+//
+// Paragraphs are separated by one or more blank lines, as in Project
+// Gutenberg texts.  Carriage returns are ignored.
+func Paragraphs(body string) []Paragraph {
+  // normalize line endings
+  body = strings.ReplaceAll(body, "\r\n", "\n")
+
+  var r []Paragraph
+  for _, block := range(blankLineRe.Split(body, -1)) {
+    // get non-empty lines
+    var lines []string
+    for _, line := range(strings.Split(block, "\n")) {
+      if line = strings.TrimSpace(line); line != "" {
+        lines = append(lines, line)
+      }
+    }
+
+    // skip empty blocks
+    if len(lines) == 0 {
+      continue
+    }
+
+    // check for verse
+    verse := len(lines) > 1
+    for _, line := range(lines[:len(lines) - 1]) {
+      if len(line) >= verseLineLength {
+        verse = false
+        break
+      }
+    }
+
+    // add paragraph
+    r = append(r, Paragraph { Lines: lines, Verse: verse })
+  }
+
+  return r
+}
+
+// Characters which are not allowed in download file names.
+var fileNameRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Get safe download file name for the given book name and extension.
+func FileName(name, ext string) string {
+  s := strings.Trim(fileNameRe.ReplaceAllString(name, "_"), "_.")
+  if s == "" {
+    s = "book"
+  }
+
+  return s + ext
+}
+
+// Map of lowercase language name to language code.
+var languageCodes = map[string]string {
+  "chinese": "zh",
+  "danish": "da",
+  "dutch": "nl",
+  "english": "en",
+  "esperanto": "eo",
+  "finnish": "fi",
+  "french": "fr",
+  "german": "de",
+  "greek": "el",
+  "hungarian": "hu",
+  "italian": "it",
+  "latin": "la",
+  "norwegian": "no",
+  "polish": "pl",
+  "portuguese": "pt",
+  "russian": "ru",
+  "spanish": "es",
+  "swedish": "sv",
+}
+
+// language code
+var languageCodeRe = regexp.MustCompile(`^[a-zA-Z]{2,3}(?:-[a-zA-Z0-9]{1,8})*$`)
+
+// Get language code for the given language.
+//
+// The stored language is either a language name from a Project
+// Gutenberg header (e.g. "English") or a language code from an EPUB
+// (e.g. "en-US").  Returns "und" (undetermined) if the language is
+// empty or unknown.
+func LanguageCode(lang string) string {
+  lang = strings.TrimSpace(lang)
+
+  // check for language name
+  if code, ok := languageCodes[strings.ToLower(lang)]; ok {
+    return code
+  }
+
+  // check for language code
+  if languageCodeRe.MatchString(lang) {
+    return lang
+  }
+
+  return "und"
+}
@@ -0,0 +1,93 @@
+package export
+
+import (
+  "reflect"
+  "testing"
+)
+
+func TestParagraphs(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    val string // test body
+    exp []Paragraph // expected paragraphs
+  } {{
+    name: "empty",
+    val: "",
+    exp: nil,
+  }, {
+    name: "prose",
+    val: "It was a dark and stormy night; the rain fell in torrents, except at\r\n" +
+         "occasional intervals.\r\n" +
+         "\r\n" +
+         "  \r\n" +
+         "Second paragraph.\n",
+    exp: []Paragraph {{
+      Lines: []string {
+        "It was a dark and stormy night; the rain fell in torrents, except at",
+        "occasional intervals.",
+      },
+    }, {
+      Lines: []string { "Second paragraph." },
+    }},
+  }, {
+    name: "verse",
+    val: "\n\n    Tyger Tyger, burning bright,\n    In the forests of the night;\n",
+    exp: []Paragraph {{
+      Lines: []string {
+        "Tyger Tyger, burning bright,",
+        "In the forests of the night;",
+      },
+      Verse: true,
+    }},
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      got := Paragraphs(test.val)
+      if !reflect.DeepEqual(got, test.exp) {
+        t.Fatalf("got %#v, exp %#v", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestLanguageCode(t *testing.T) {
+  tests := []struct {
+    val string // test value
+    exp string // expected result
+  } {
+    { "English", "en" },
+    { " french ", "fr" },
+    { "en-US", "en-US" },
+    { "", "und" },
+    { "Klingon or Elvish", "und" },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.val, func(t *testing.T) {
+      if got := LanguageCode(test.val); got != test.exp {
+        t.Fatalf("got %q, exp %q", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestFileName(t *testing.T) {
+  tests := []struct {
+    val string // test value
+    exp string // expected result
+  } {
+    { "Frankenstein", "Frankenstein.epub" },
+    { "Alice's Adventures in Wonderland", "Alice_s_Adventures_in_Wonderland.epub" },
+    { "../../etc/passwd", "etc_passwd.epub" },
+    { "\"日本\"", "book.epub" },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.val, func(t *testing.T) {
+      if got := FileName(test.val, ".epub"); got != test.exp {
+        t.Fatalf("got %q, exp %q", got, test.exp)
+      }
+    })
+  }
+}
@@ -0,0 +1,61 @@
+package export
+
+import (
+  "bookman/model"
+  "html/template"
+  "io"
+)
+
+// standalone html document template
+var htmlTemplate = template.Must(template.New("book").Parse(`<!DOCTYPE html>
+<html lang='{{ .Lang }}'>
+  <head>
+    <meta charset='utf-8'/>
+    <meta name='viewport' content='width=device-width, initial-scale=1'/>
+    <meta name='author' content='{{ .Book.Author }}'/>
+    <title>{{ .Book.Name }}</title>
+    <style>
+      body { max-width: 40em; margin: 2em auto; padding: 0 1em; font-family: serif; line-height: 1.5; }
+      header { text-align: center; margin-bottom: 3em; }
+      p.verse { margin-left: 2em; }
+    </style>
+  </head>
+
+  <body>
+    <header>
+      <h1>{{ .Book.Name }}</h1>
+      <p>{{ .Book.Author }}</p>
+    </header>
+{{ range .Paragraphs }}
+    {{ template "paragraph" . }}
+{{- end }}
+  </body>
+</html>
+`))
+
+// paragraph template, shared by html and epub templates
+const paragraphTemplate = `{{ define "paragraph" -}}
+{{ if .Verse -}}
+<p class='verse'>{{ range $i, $line := .Lines }}{{ if $i }}<br/>
+{{ end }}{{ $line }}{{ end }}</p>
+{{- else -}}
+<p>{{ .Text }}</p>
+{{- end }}
+{{- end }}`
+
+func init() {
+  template.Must(htmlTemplate.Parse(paragraphTemplate))
+}
+
+// Write book as standalone HTML document.
+func HTML(w io.Writer, book model.FullBook) error {
+  return htmlTemplate.Execute(w, struct {
+    Book model.FullBook
+    Lang string
+    Paragraphs []Paragraph
+  } {
+    Book: book,
+    Lang: LanguageCode(book.Language),
+    Paragraphs: Paragraphs(book.Body),
+  })
+}
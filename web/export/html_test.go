@@ -0,0 +1,43 @@
+package export
+
+import (
+  "bookman/model"
+  "strings"
+  "testing"
+)
+
+func TestHTML(t *testing.T) {
+  book := model.FullBook {
+    Id: 1,
+    Name: "Cats & Dogs",
+    Author: "<script>alert(1)</script>",
+    Language: "English",
+    Body: "First paragraph, which is long enough to be hard-wrapped rather than\n" +
+          "verse.\n\nShort line\nand another\n",
+  }
+
+  // write html
+  var sb strings.Builder
+  if err := HTML(&sb, book); err != nil {
+    t.Fatal(err)
+  }
+  got := sb.String()
+
+  // check for expected fragments
+  for _, exp := range([]string {
+    "<html lang='en'>",
+    "<title>Cats &amp; Dogs</title>",
+    "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>",
+    "<p>First paragraph, which is long enough to be hard-wrapped rather than verse.</p>",
+    "<p class='verse'>Short line<br/>\nand another</p>",
+  }) {
+    if !strings.Contains(got, exp) {
+      t.Errorf("missing %q in %s", exp, got)
+    }
+  }
+
+  // check for unescaped script
+  if strings.Contains(got, "<script>") {
+    t.Errorf("unescaped script in %s", got)
+  }
+}
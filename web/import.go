@@ -0,0 +1,344 @@
+package main
+
+import (
+  "bookman/app"
+  "bookman/ingest"
+  "bookman/model"
+  "context"
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+  "io/fs"
+  "log"
+  "os"
+  "path/filepath"
+)
+
+// import command options.
+type importOptions struct {
+  dir string // directory to import
+  dryRun bool // show books which would be imported without importing them?
+  batchSize int // number of books imported in each transaction
+  conflict model.ConflictPolicy // name conflict policy
+}
+
+// Parse conflict policy command-line flag.
+func parseConflictPolicy(s string) (model.ConflictPolicy, error) {
+  switch p := model.ConflictPolicy(s); p {
+  case model.ConflictFail, model.ConflictSkip, model.ConflictReplace, model.ConflictRename:
+    return p, nil
+  default:
+    return "", fmt.Errorf("unknown conflict policy: %s", s)
+  }
+}
+
+// Parse import command arguments.
+func parseImportArgs(args []string) (importOptions, error) {
+  var opts importOptions
+  var conflict string
+  var err error
+
+  // parse flags
+  flags := flag.NewFlagSet("import", flag.ContinueOnError)
+  flags.BoolVar(&opts.dryRun, "dry-run", false, "show books which would be imported without importing them")
+  flags.IntVar(&opts.batchSize, "batch-size", 100, "number of books imported in each transaction")
+  flags.StringVar(&conflict, "conflict", string(model.ConflictFail), "action taken when a book name is in use: fail, skip, replace, or rename")
+  if err = flags.Parse(args); err != nil {
+    return importOptions{}, err
+  }
+
+  // parse conflict policy
+  opts.conflict, err = parseConflictPolicy(conflict)
+  if err != nil {
+    return importOptions{}, err
+  }
+
+  // check batch size
+  if opts.batchSize < 1 {
+    return importOptions{}, fmt.Errorf("invalid batch size: %d", opts.batchSize)
+  }
+
+  // get directory
+  if flags.NArg() != 1 {
+    return importOptions{}, errors.New("missing directory")
+  }
+  opts.dir = flags.Arg(0)
+
+  // return success
+  return opts, nil
+}
+
+// Find book files in the given directory tree, sorted by path.
+//
+// Returns paths relative to the directory.  Hidden files and
+// directories, and files which are not books, are skipped; see
+// ingest.IsBookPath().
+func findBookFiles(dir string) ([]string, error) {
+  var paths []string
+
+  err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+    if err != nil {
+      return err
+    }
+
+    // get path relative to directory
+    rel, err := filepath.Rel(dir, path)
+    if err != nil {
+      return err
+    } else if rel == "." {
+      return nil
+    }
+
+    // skip hidden directories
+    if d.IsDir() {
+      if d.Name()[0] == '.' {
+        return filepath.SkipDir
+      }
+      return nil
+    }
+
+    // add book files
+    if d.Type().IsRegular() && ingest.IsBookPath(filepath.ToSlash(rel)) {
+      paths = append(paths, rel)
+    }
+
+    return nil
+  })
+
+  return paths, err
+}
+
+// Import statistics.
+type importStats struct {
+  statuses map[model.UploadStatus]int // number of books by upload status
+  imported int // number of books which were already imported
+}
+
+// Bulk importer.  Reads book files from a directory and uploads them
+// in batches.
+//
+// Books whose body hash matches an existing book (including books in
+// the trash) or a book imported earlier in the same run are skipped,
+// so an interrupted import can be resumed by running it again.
+type importer struct {
+  dir string // directory
+  opts ingest.Options // upload pipeline options
+  maxFileSize int // maximum file size, in bytes
+  limits ingest.ArchiveLimits // EPUB limits
+  hashes map[string]bool // body hashes of existing and imported books
+  stats importStats // import statistics
+  dryRun bool // log books as "would import" instead of by status?
+  log func(string, ...any) // log function
+
+  paths []string // remaining files in current batch
+  batch []string // paths of books returned by Next() in current batch
+  batchHashes []string // body hashes of books in current batch
+}
+
+// Read and convert book file.
+func (im *importer) readFile(rel string) (model.UploadedFile, error) {
+  // open file
+  f, err := os.Open(filepath.Join(im.dir, rel))
+  if err != nil {
+    return model.UploadedFile{}, err
+  }
+  defer f.Close()
+
+  // read file, up to one byte past the size limit
+  data, err := io.ReadAll(io.LimitReader(f, int64(im.maxFileSize) + 1))
+  if err != nil {
+    return model.UploadedFile{}, err
+  } else if len(data) > im.maxFileSize {
+    return model.UploadedFile{}, fmt.Errorf("file is larger than the maximum file size (%d bytes)", im.maxFileSize)
+  }
+
+  // convert file to book
+  return ingest.File(filepath.Base(rel), data, im.opts, im.limits)
+}
+
+// Get next book in current batch.  Returns io.EOF at the end of the
+// batch.
+//
+// Files which cannot be read or converted are logged and counted as
+// failed rather than failing the batch.
+func (im *importer) Next() (model.UploadedFile, error) {
+  for len(im.paths) > 0 {
+    // shift next path
+    rel := im.paths[0]
+    im.paths = im.paths[1:]
+
+    // read file
+    file, err := im.readFile(rel)
+    if err != nil {
+      im.log("failed %s: %v", rel, err)
+      im.stats.statuses[model.UploadFailed] += 1
+      continue
+    }
+
+    // skip books which were already imported
+    hash := model.BodyHash(file.Body)
+    if im.hashes[hash] {
+      im.stats.imported += 1
+      continue
+    }
+    im.hashes[hash] = true
+
+    // add book to batch, return book
+    im.batch = append(im.batch, rel)
+    im.batchHashes = append(im.batchHashes, hash)
+    return file, nil
+  }
+
+  return model.UploadedFile{}, io.EOF
+}
+
+// Import the given files in batches of the given size.
+//
+// The upload function is called once for each batch.  It must read
+// all books from the importer and return one result for each book.
+func (im *importer) run(paths []string, batchSize int, upload func(model.UploadSource) ([]model.UploadResult, error)) error {
+  for start := 0; start < len(paths); start += batchSize {
+    // get batch
+    end := start + batchSize
+    if end > len(paths) {
+      end = len(paths)
+    }
+    im.paths, im.batch, im.batchHashes = paths[start:end], nil, nil
+
+    // upload batch
+    results, err := upload(im)
+    if err != nil {
+      return fmt.Errorf("files %d-%d: %w", start + 1, end, err)
+    }
+
+    // record results
+    for i, r := range(results) {
+      im.stats.statuses[r.Status] += 1
+
+      if r.Status == model.UploadFailed {
+        // allow failed books to be imported from another file
+        delete(im.hashes, im.batchHashes[i])
+        im.log("failed %s: %v", im.batch[i], r.Err)
+      } else if im.dryRun {
+        im.log("would import %s: %s", im.batch[i], r.Name)
+      } else {
+        im.log("%s %s: %s", r.Status, im.batch[i], r.Name)
+      }
+    }
+
+    // show progress
+    im.log("progress: %d/%d files", end, len(paths))
+  }
+
+  return nil
+}
+
+// import command.
+//
+// Usage:
+//
+//   bookman import [-dry-run] [-batch-size N] [-conflict policy] <dir>
+//
+// Walks the given directory and imports each `.txt` and `.epub` file
+// as a book, using the same conversion pipeline as uploads.  Books are
+// imported in batches, with each batch in a separate transaction.
+// Books which have already been imported (identified by the hash of
+// their contents) are skipped, so an interrupted import can be resumed
+// by running it again.
+//
+// If `-dry-run` is set, then the books which would be imported are
+// logged, but the library is not changed.
+func importCommand(ctx context.Context, config app.Config, args []string) error {
+  // parse arguments
+  opts, err := parseImportArgs(args)
+  if err != nil {
+    return err
+  }
+
+  // find book files
+  paths, err := findBookFiles(opts.dir)
+  if err != nil {
+    return err
+  }
+  log.Printf("found %d file(s)", len(paths))
+
+  // create application context from context and config
+  appCtx, err := app.NewContext(ctx, config)
+  if err != nil {
+    return err
+  }
+  defer appCtx.Pool.Close()
+
+  // get body hashes of existing books
+  existing, err := appCtx.Model.BodyHashes(ctx, appCtx.Pool)
+  if err != nil {
+    return err
+  }
+
+  // create importer
+  im := importer {
+    dir: opts.dir,
+    opts: ingest.Options {
+      StripBoilerplate: config.UploadStripBoilerplate,
+    },
+    maxFileSize: config.UploadMaxFileSize,
+    limits: ingest.ArchiveLimits {
+      MaxEntries: config.UploadMaxArchiveEntries,
+      MaxFileSize: int64(config.UploadMaxFileSize),
+      MaxSize: int64(config.UploadMaxArchiveSize),
+    },
+    hashes: make(map[string]bool, len(existing)),
+    stats: importStats { statuses: map[model.UploadStatus]int{} },
+    dryRun: opts.dryRun,
+    log: log.Printf,
+  }
+  for _, hash := range(existing) {
+    im.hashes[hash] = true
+  }
+
+  // get upload function
+  ctx = model.ContextWithActor(ctx, "bookman import")
+  uploadOpts := model.UploadOptions { Conflict: opts.conflict, Partial: true }
+  upload := func(src model.UploadSource) ([]model.UploadResult, error) {
+    return appCtx.Model.Upload(ctx, appCtx.Pool, src, uploadOpts)
+  }
+  if opts.dryRun {
+    upload = dryRunUpload
+  }
+
+  // import books
+  if err := im.run(paths, opts.batchSize, upload); err != nil {
+    return err
+  }
+
+  // show summary
+  s := im.stats
+  log.Printf(
+    "created %d, renamed %d, replaced %d, skipped %d, already imported %d, failed %d",
+    s.statuses[model.UploadCreated],
+    s.statuses[model.UploadRenamed],
+    s.statuses[model.UploadReplaced],
+    s.statuses[model.UploadSkipped],
+    s.imported,
+    s.statuses[model.UploadFailed],
+  )
+
+  return nil
+}
+
+// Upload function for dry runs: read all books from the source and
+// report them as created, without changing the library.
+func dryRunUpload(src model.UploadSource) ([]model.UploadResult, error) {
+  var results []model.UploadResult
+  for {
+    file, err := src.Next()
+    if err == io.EOF {
+      return results, nil
+    } else if err != nil {
+      return nil, err
+    }
+
+    results = append(results, model.UploadResult { Name: file.Name, Status: model.UploadCreated })
+  }
+}
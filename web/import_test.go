@@ -0,0 +1,228 @@
+package main
+
+import (
+  "bookman/model"
+  "errors"
+  "io"
+  "os"
+  "path/filepath"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+func TestParseImportArgs(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    tests := []struct {
+      name string // test name
+      args []string // command arguments
+      exp importOptions // expected options
+    } {{
+      name: "default",
+      args: []string { "books" },
+      exp: importOptions { dir: "books", batchSize: 100, conflict: model.ConflictFail },
+    }, {
+      name: "flags",
+      args: []string { "-dry-run", "-batch-size", "10", "-conflict", "rename", "books" },
+      exp: importOptions { dir: "books", dryRun: true, batchSize: 10, conflict: model.ConflictRename },
+    }}
+
+    for _, test := range(tests) {
+      t.Run(test.name, func(t *testing.T) {
+        got, err := parseImportArgs(test.args)
+        if err != nil {
+          t.Fatal(err)
+        }
+
+        if got != test.exp {
+          t.Fatalf("got %#v, exp %#v", got, test.exp)
+        }
+      })
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    for _, args := range([][]string {
+      []string {},
+      []string { "foo", "bar" },
+      []string { "-batch-size", "0", "books" },
+      []string { "-conflict", "foo", "books" },
+      []string { "-foo", "books" },
+    }) {
+      if got, err := parseImportArgs(args); err == nil {
+        t.Fatalf("%v: got %#v, exp err", args, got)
+      }
+    }
+  })
+}
+
+// Create files with the given relative paths and contents in a
+// temporary directory.  Returns the directory.
+func makeImportDir(t *testing.T, files [][2]string) string {
+  t.Helper()
+
+  dir := t.TempDir()
+  for _, file := range(files) {
+    path := filepath.Join(dir, filepath.FromSlash(file[0]))
+    if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+      t.Fatal(err)
+    }
+
+    if err := os.WriteFile(path, []byte(file[1]), 0644); err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  return dir
+}
+
+func TestFindBookFiles(t *testing.T) {
+  dir := makeImportDir(t, [][2]string {
+    { "b.txt", "b" },
+    { "a/c.epub", "c" },
+    { "a/d.jpg", "d" },
+    { ".hidden/e.txt", "e" },
+    { ".f.txt", "f" },
+  })
+
+  got, err := findBookFiles(dir)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  exp := []string { filepath.Join("a", "c.epub"), "b.txt" }
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %#v, exp %#v", got, exp)
+  }
+}
+
+func TestImporterRun(t *testing.T) {
+  dir := makeImportDir(t, [][2]string {
+    { "a.txt", "aaa" },
+    { "b.txt", "bbb" },
+    { "c.txt", "aaa" },
+    { "d.txt", "ddd" },
+    { "e.txt", "eee" },
+  })
+  paths := []string { "a.txt", "b.txt", "c.txt", "d.txt", "e.txt", "missing.txt" }
+
+  // create importer
+  newImporter := func(hashes ...string) *importer {
+    im := &importer {
+      dir: dir,
+      maxFileSize: 1000,
+      hashes: map[string]bool {},
+      stats: importStats { statuses: map[model.UploadStatus]int{} },
+      log: func(string, ...any) {},
+    }
+
+    for _, hash := range(hashes) {
+      im.hashes[hash] = true
+    }
+
+    return im
+  }
+
+  // mock upload function: records book names of each batch, and fails
+  // books named "e"
+  var batches [][]string
+  upload := func(src model.UploadSource) ([]model.UploadResult, error) {
+    var names []string
+    var results []model.UploadResult
+    for {
+      file, err := src.Next()
+      if err == io.EOF {
+        break
+      } else if err != nil {
+        return nil, err
+      }
+
+      names = append(names, file.Name)
+      r := model.UploadResult { Name: file.Name, Status: model.UploadCreated }
+      if file.Name == "e" {
+        r = model.UploadResult { Name: file.Name, Status: model.UploadFailed, Err: model.ErrDuplicate }
+      }
+      results = append(results, r)
+    }
+
+    batches = append(batches, names)
+    return results, nil
+  }
+
+  t.Run("pass", func(t *testing.T) {
+    batches = nil
+
+    // note: "b" was imported by a previous run
+    im := newImporter(model.BodyHash("bbb"))
+    if err := im.run(paths, 2, upload); err != nil {
+      t.Fatal(err)
+    }
+
+    // check batches
+    // (note: "c" has the same contents as "a")
+    exp := [][]string { { "a" }, { "d" }, { "e" } }
+    if !reflect.DeepEqual(batches, exp) {
+      t.Fatalf("got %#v, exp %#v", batches, exp)
+    }
+
+    // check stats
+    expStats := importStats {
+      statuses: map[model.UploadStatus]int {
+        model.UploadCreated: 2,
+        model.UploadFailed: 2,
+      },
+      imported: 2,
+    }
+    if !reflect.DeepEqual(im.stats, expStats) {
+      t.Fatalf("got %#v, exp %#v", im.stats, expStats)
+    }
+
+    // check that failed books are not marked as imported
+    if im.hashes[model.BodyHash("eee")] {
+      t.Fatal("got failed book hash, exp none")
+    }
+  })
+
+  t.Run("file too large", func(t *testing.T) {
+    batches = nil
+
+    im := newImporter()
+    im.maxFileSize = 2
+    if err := im.run(paths[:1], 2, upload); err != nil {
+      t.Fatal(err)
+    }
+
+    if im.stats.statuses[model.UploadFailed] != 1 {
+      t.Fatalf("got %#v, exp 1 failed", im.stats)
+    }
+  })
+
+  t.Run("upload error", func(t *testing.T) {
+    im := newImporter()
+    err := im.run(paths, 2, func(model.UploadSource) ([]model.UploadResult, error) {
+      return nil, errors.New("some error")
+    })
+
+    if err == nil || !strings.Contains(err.Error(), "files 1-2") {
+      t.Fatalf("got %v, exp err", err)
+    }
+  })
+
+  t.Run("dry run", func(t *testing.T) {
+    var logs []string
+    im := newImporter()
+    im.dryRun = true
+    im.log = func(format string, args ...any) {
+      logs = append(logs, format)
+    }
+
+    if err := im.run(paths[:2], 10, dryRunUpload); err != nil {
+      t.Fatal(err)
+    }
+
+    exp := []string { "would import %s: %s", "would import %s: %s", "progress: %d/%d files" }
+    if !reflect.DeepEqual(logs, exp) {
+      t.Fatalf("got %#v, exp %#v", logs, exp)
+    }
+  })
+}
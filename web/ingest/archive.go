@@ -0,0 +1,268 @@
+package ingest
+
+import (
+  "archive/tar"
+  "archive/zip"
+  "bytes"
+  "bookman/model"
+  "compress/gzip"
+  "errors"
+  "fmt"
+  "io"
+  "path"
+  "strings"
+)
+
+// Returned when an archive is not a valid zip or tar.gz file.
+var ErrInvalidArchive = errors.New("invalid archive")
+
+// Returned when an archive or archive entry exceeds a size or entry
+// count limit.
+var ErrArchiveTooLarge = errors.New("archive too large")
+
+// Archive limits, used to guard against decompression bombs.
+type ArchiveLimits struct {
+  MaxEntries int // maximum number of entries, including skipped entries
+  MaxFileSize int64 // maximum decompressed size of each entry, in bytes
+  MaxSize int64 // maximum total decompressed size, in bytes
+}
+
+// Book read from an archive entry.
+type ArchiveEntry struct {
+  Path string // path of entry in archive
+  Size int64 // decompressed size of entry, in bytes
+  File model.UploadedFile // book
+
+  // Error which occurred while reading or converting this entry, or
+  // nil on success.  Errors in one entry do not prevent the remaining
+  // entries from being read.
+  Err error
+}
+
+// Archive of uploaded files.  Use OpenArchive() to open an archive.
+type Archive interface {
+  // Get next book.  Returns io.EOF if there are no more books.
+  //
+  // Errors which prevent the rest of the archive from being read (for
+  // example, an invalid archive or an exceeded limit) are returned as
+  // an error.  Errors which only affect the current entry are returned
+  // in the Err field of the entry instead.
+  Next() (ArchiveEntry, error)
+}
+
+// Check if the given data is a zip or tar.gz archive.
+//
+// The archive type is detected from the contents rather than the file
+// name.  EPUB files are zip files, but are not treated as archives;
+// they are read by ParseEpub(), which applies the same limits.
+func IsArchive(data []byte) bool {
+  return isZip(data) || isGzip(data)
+}
+
+// Is the given data a zip file which is not an EPUB?
+func isZip(data []byte) bool {
+  return bytes.HasPrefix(data, []byte("PK\x03\x04")) && !IsEpub(data)
+}
+
+// Is the given data gzip-compressed?
+func isGzip(data []byte) bool {
+  return bytes.HasPrefix(data, []byte { 0x1f, 0x8b })
+}
+
+// Open zip or tar.gz archive.
+//
+// Only regular files with a `.txt` or `.epub` extension are read;
+// other entries (directories, hidden files, and files in other formats)
+// are skipped.  The book name of each entry which does not provide its
+// own name (e.g., a Project Gutenberg header or EPUB title) is the base
+// name of the entry path without the extension.
+func OpenArchive(data []byte, opts Options, limits ArchiveLimits) (Archive, error) {
+  base := archiveReader { opts: opts, limits: limits }
+
+  switch {
+  case isZip(data):
+    // open zip
+    zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+    if err != nil {
+      return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+    }
+
+    // check entry count
+    if len(zr.File) > limits.MaxEntries {
+      return nil, fmt.Errorf("%w: archive has more than %d entries", ErrArchiveTooLarge, limits.MaxEntries)
+    }
+
+    return &zipArchive { archiveReader: base, files: zr.File }, nil
+  case isGzip(data):
+    // open gzip stream
+    gz, err := gzip.NewReader(bytes.NewReader(data))
+    if err != nil {
+      return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+    }
+
+    // limit total decompressed size, including skipped entries
+    lr := &sizeLimitReader { r: gz, limit: limits.MaxSize }
+
+    return &tarArchive { archiveReader: base, tr: tar.NewReader(lr) }, nil
+  default:
+    return nil, ErrInvalidArchive
+  }
+}
+
+// Reader which fails with ErrArchiveTooLarge once more than limit
+// bytes have been read.
+type sizeLimitReader struct {
+  r io.Reader // underlying reader
+  limit int64 // maximum number of bytes
+  n int64 // number of bytes read so far
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+  n, err := l.r.Read(p)
+  l.n += int64(n)
+  if l.n > l.limit {
+    return n, fmt.Errorf("%w: archive is larger than %d bytes when decompressed", ErrArchiveTooLarge, l.limit)
+  }
+
+  return n, err
+}
+
+// Clean archive entry path: remove leading slashes and `.` and `..`
+// elements.
+func cleanPath(p string) string {
+  return strings.TrimPrefix(path.Clean("/" + p), "/")
+}
+
+// Common archive state.
+type archiveReader struct {
+  opts Options // upload pipeline options
+  limits ArchiveLimits // archive limits
+  size int64 // total decompressed size of entries read so far
+}
+
+// Read and convert archive entry.
+//
+// Returns an error if reading the entry fails or if the total size
+// limit is exceeded.  Entries which are larger than the maximum file
+// size, or which cannot be converted, are returned with an entry
+// error.
+func (a *archiveReader) read(p string, r io.Reader) (ArchiveEntry, error) {
+  entry := ArchiveEntry { Path: p }
+
+  // read entry data, up to one byte past the size limit
+  data, err := io.ReadAll(io.LimitReader(r, a.limits.MaxFileSize + 1))
+  if err != nil {
+    if errors.Is(err, ErrArchiveTooLarge) {
+      return ArchiveEntry{}, err
+    }
+    return ArchiveEntry{}, fmt.Errorf("%w: %s: %w", ErrInvalidArchive, p, err)
+  }
+
+  // check total size
+  a.size += int64(len(data))
+  if a.size > a.limits.MaxSize {
+    return ArchiveEntry{}, fmt.Errorf("%w: archive is larger than %d bytes when decompressed", ErrArchiveTooLarge, a.limits.MaxSize)
+  }
+
+  // check entry size
+  if int64(len(data)) > a.limits.MaxFileSize {
+    entry.Err = fmt.Errorf("%w: %s: file is larger than %d bytes", ErrArchiveTooLarge, p, a.limits.MaxFileSize)
+    return entry, nil
+  }
+  entry.Size = int64(len(data))
+
+  // get book name from entry path
+  name := path.Base(p)
+  name = strings.TrimSuffix(name, path.Ext(name))
+
+  // convert entry to book
+  if IsEpub(data) {
+    // parse epub; the files read from the epub count toward the total
+    // size of the archive
+    limits := a.limits
+    limits.MaxSize -= a.size
+    epub, size, err := parseEpub(data, limits)
+    a.size += size
+    if a.size > a.limits.MaxSize {
+      return ArchiveEntry{}, fmt.Errorf("%w: archive is larger than %d bytes when decompressed", ErrArchiveTooLarge, a.limits.MaxSize)
+    } else if err != nil {
+      entry.Err = fmt.Errorf("%s: %w", p, err)
+    } else {
+      entry.File = epubFile(name, epub)
+    }
+  } else {
+    entry.File = Text(name, data, a.opts)
+  }
+
+  // return entry
+  return entry, nil
+}
+
+// Zip archive.
+type zipArchive struct {
+  archiveReader
+  files []*zip.File // remaining files
+}
+
+func (a *zipArchive) Next() (ArchiveEntry, error) {
+  for len(a.files) > 0 {
+    // shift next file
+    f := a.files[0]
+    a.files = a.files[1:]
+
+    // skip unsupported entries
+    p := cleanPath(f.Name)
+    if !f.Mode().IsRegular() || !IsBookPath(p) {
+      continue
+    }
+
+    // open entry
+    rc, err := f.Open()
+    if err != nil {
+      return ArchiveEntry{}, fmt.Errorf("%w: %s: %w", ErrInvalidArchive, p, err)
+    }
+
+    // read entry
+    entry, err := a.read(p, rc)
+    rc.Close()
+    return entry, err
+  }
+
+  return ArchiveEntry{}, io.EOF
+}
+
+// Gzip-compressed tar archive.
+type tarArchive struct {
+  archiveReader
+  tr *tar.Reader // tar reader
+  count int // number of entries read so far
+}
+
+func (a *tarArchive) Next() (ArchiveEntry, error) {
+  for {
+    // read next header
+    h, err := a.tr.Next()
+    if err == io.EOF {
+      return ArchiveEntry{}, io.EOF
+    } else if errors.Is(err, ErrArchiveTooLarge) {
+      return ArchiveEntry{}, err
+    } else if err != nil {
+      return ArchiveEntry{}, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+    }
+
+    // check entry count
+    a.count += 1
+    if a.count > a.limits.MaxEntries {
+      return ArchiveEntry{}, fmt.Errorf("%w: archive has more than %d entries", ErrArchiveTooLarge, a.limits.MaxEntries)
+    }
+
+    // skip unsupported entries
+    p := cleanPath(h.Name)
+    if !h.FileInfo().Mode().IsRegular() || !IsBookPath(p) {
+      continue
+    }
+
+    // read entry
+    return a.read(p, a.tr)
+  }
+}
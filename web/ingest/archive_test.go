@@ -0,0 +1,241 @@
+package ingest
+
+import (
+  "archive/tar"
+  "bytes"
+  "compress/gzip"
+  "errors"
+  "io"
+  "strings"
+  "testing"
+)
+
+// Create tar.gz file containing the given files, in order.  Entries
+// with a trailing slash are added as directories.
+func makeTarGz(t *testing.T, files [][2]string) []byte {
+  t.Helper()
+
+  var buf bytes.Buffer
+  gz := gzip.NewWriter(&buf)
+  tw := tar.NewWriter(gz)
+
+  for _, file := range(files) {
+    h := tar.Header { Name: file[0], Mode: 0644, Size: int64(len(file[1])), Typeflag: tar.TypeReg }
+    if strings.HasSuffix(file[0], "/") {
+      h = tar.Header { Name: file[0], Mode: 0755, Typeflag: tar.TypeDir }
+    }
+
+    if err := tw.WriteHeader(&h); err != nil {
+      t.Fatal(err)
+    }
+
+    if _, err := tw.Write([]byte(file[1])); err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  if err := tw.Close(); err != nil {
+    t.Fatal(err)
+  }
+
+  if err := gz.Close(); err != nil {
+    t.Fatal(err)
+  }
+
+  return buf.Bytes()
+}
+
+// Read all entries from archive.  Returns the entries read before the
+// first error, and the error.
+func readArchive(t *testing.T, data []byte, limits ArchiveLimits) ([]ArchiveEntry, error) {
+  t.Helper()
+
+  a, err := OpenArchive(data, Options{}, limits)
+  if err != nil {
+    return nil, err
+  }
+
+  var entries []ArchiveEntry
+  for {
+    entry, err := a.Next()
+    if err == io.EOF {
+      return entries, nil
+    } else if err != nil {
+      return entries, err
+    }
+
+    entries = append(entries, entry)
+  }
+}
+
+// default test archive limits
+// (note: the size of tar.gz archives includes tar headers)
+var testLimits = ArchiveLimits { MaxEntries: 10, MaxFileSize: 100, MaxSize: 10000 }
+
+// test archive contents
+var testArchiveFiles = [][2]string {
+  { "books/", "" },
+  { "books/emma.txt", "emma body" },
+  { "books/.hidden.txt", "hidden" },
+  { "__MACOSX/books/._emma.txt", "resource fork" },
+  { "books/cover.jpg", "not a book" },
+  { "./books/nested/Persuasion.TXT", "caf\xe9" },
+}
+
+func TestIsArchive(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    data []byte // file contents
+    exp bool // expected result
+  } {
+    { "zip", makeZip(t, [][2]string { { "foo.txt", "bar" } }), true },
+    { "tar.gz", makeTarGz(t, [][2]string { { "foo.txt", "bar" } }), true },
+    { "epub", makeTestEpub(t), false },
+    { "text", []byte("foo"), false },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      if got := IsArchive(test.data); got != test.exp {
+        t.Fatalf("got %v, exp %v", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestOpenArchive(t *testing.T) {
+  formats := []struct {
+    name string // format name
+    make func(*testing.T, [][2]string) []byte // create archive
+  } {
+    { "zip", makeZip },
+    { "tar.gz", makeTarGz },
+  }
+
+  for _, format := range(formats) {
+    t.Run(format.name, func(t *testing.T) {
+      t.Run("pass", func(t *testing.T) {
+        got, err := readArchive(t, format.make(t, testArchiveFiles), testLimits)
+        if err != nil {
+          t.Fatal(err)
+        }
+
+        if len(got) != 2 {
+          t.Fatalf("got %d entries, exp 2: %#v", len(got), got)
+        }
+
+        // check first entry
+        if got[0].Path != "books/emma.txt" || got[0].Size != 9 || got[0].File.Name != "emma" || got[0].File.Body != "emma body" || got[0].Err != nil {
+          t.Fatalf("got %#v", got[0])
+        }
+
+        // check second entry (transcoded, name from path)
+        if got[1].Path != "books/nested/Persuasion.TXT" || got[1].File.Name != "Persuasion" || got[1].File.Body != "café" || got[1].File.Encoding != "iso-8859-1" {
+          t.Fatalf("got %#v", got[1])
+        }
+      })
+
+      t.Run("epub", func(t *testing.T) {
+        data := format.make(t, [][2]string { { "foo.epub", string(makeTestEpub(t)) } })
+        got, err := readArchive(t, data, ArchiveLimits { MaxEntries: 10, MaxFileSize: 10000, MaxSize: 10000 })
+        if err != nil {
+          t.Fatal(err)
+        }
+
+        if len(got) != 1 || got[0].File.Name != "The Test Book" || got[0].Err != nil {
+          t.Fatalf("got %#v", got)
+        }
+      })
+
+      t.Run("epub bomb", func(t *testing.T) {
+        data := format.make(t, [][2]string {
+          { "bomb.epub", string(makeBombEpub(t, strings.Repeat("x", 10 << 20), 1)) },
+          { "small.txt", "small" },
+        })
+
+        got, err := readArchive(t, data, ArchiveLimits { MaxEntries: 10, MaxFileSize: 100000, MaxSize: 1000000 })
+        if err != nil {
+          t.Fatal(err)
+        }
+
+        if len(got) != 2 || !errors.Is(got[0].Err, ErrArchiveTooLarge) || got[1].Err != nil || got[1].File.Body != "small" {
+          t.Fatalf("got %#v", got)
+        }
+      })
+
+      t.Run("epub counts toward size", func(t *testing.T) {
+        data := format.make(t, [][2]string {
+          { "foo.epub", string(makeBombEpub(t, strings.Repeat("x", 5000), 10)) },
+        })
+
+        _, err := readArchive(t, data, ArchiveLimits { MaxEntries: 10, MaxFileSize: 10000, MaxSize: 20000 })
+        if !errors.Is(err, ErrArchiveTooLarge) {
+          t.Fatalf("got %v, exp ErrArchiveTooLarge", err)
+        }
+      })
+
+      t.Run("file too large", func(t *testing.T) {
+        data := format.make(t, [][2]string {
+          { "big.txt", strings.Repeat("x", 101) },
+          { "small.txt", "small" },
+        })
+
+        got, err := readArchive(t, data, testLimits)
+        if err != nil {
+          t.Fatal(err)
+        }
+
+        if len(got) != 2 || !errors.Is(got[0].Err, ErrArchiveTooLarge) || got[1].Err != nil || got[1].File.Body != "small" {
+          t.Fatalf("got %#v", got)
+        }
+      })
+
+      t.Run("archive too large", func(t *testing.T) {
+        var files [][2]string
+        for i := 0; i < 10; i++ {
+          files = append(files, [2]string { strings.Repeat("x", i + 1) + ".txt", strings.Repeat("x", 100) })
+        }
+
+        _, err := readArchive(t, format.make(t, files), ArchiveLimits { MaxEntries: 100, MaxFileSize: 100, MaxSize: 500 })
+        if !errors.Is(err, ErrArchiveTooLarge) {
+          t.Fatalf("got %v, exp ErrArchiveTooLarge", err)
+        }
+      })
+
+      t.Run("too many entries", func(t *testing.T) {
+        var files [][2]string
+        for i := 0; i < 11; i++ {
+          files = append(files, [2]string { strings.Repeat("x", i + 1) + ".jpg", "" })
+        }
+
+        _, err := readArchive(t, format.make(t, files), testLimits)
+        if !errors.Is(err, ErrArchiveTooLarge) {
+          t.Fatalf("got %v, exp ErrArchiveTooLarge", err)
+        }
+      })
+    })
+  }
+
+  t.Run("skipped entries count toward size", func(t *testing.T) {
+    data := makeTarGz(t, [][2]string {
+      { "big.jpg", strings.Repeat("x", 20000) },
+      { "foo.txt", "foo" },
+    })
+
+    _, err := readArchive(t, data, testLimits)
+    if !errors.Is(err, ErrArchiveTooLarge) {
+      t.Fatalf("got %v, exp ErrArchiveTooLarge", err)
+    }
+  })
+
+  t.Run("invalid", func(t *testing.T) {
+    for _, data := range([][]byte {
+      []byte("foo"),
+      []byte("\x1f\x8bfoo"),
+    }) {
+      if _, err := readArchive(t, data, testLimits); !errors.Is(err, ErrInvalidArchive) {
+        t.Fatalf("%q: got %v, exp ErrInvalidArchive", data, err)
+      }
+    }
+  })
+}
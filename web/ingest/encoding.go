@@ -0,0 +1,110 @@
+package ingest
+
+import (
+  "bytes"
+  "golang.org/x/text/encoding"
+  "golang.org/x/text/encoding/charmap"
+  "golang.org/x/text/encoding/unicode"
+  "golang.org/x/text/unicode/norm"
+  "strings"
+  "unicode/utf8"
+)
+
+// Detected character encodings.
+const (
+  encodingUtf8 = "utf-8"
+  encodingUtf8Bom = "utf-8-bom"
+  encodingUtf16le = "utf-16le"
+  encodingUtf16be = "utf-16be"
+  encodingWindows1252 = "windows-1252"
+  encodingLatin1 = "iso-8859-1"
+)
+
+// Byte order marks.
+var (
+  bomUtf8 = []byte { 0xEF, 0xBB, 0xBF }
+  bomUtf16le = []byte { 0xFF, 0xFE }
+  bomUtf16be = []byte { 0xFE, 0xFF }
+)
+
+// Detect the character encoding of a text file.
+//
+// Files with a byte order mark are detected as UTF-8 or UTF-16.  Files
+// without a byte order mark are detected as UTF-8 if they are valid
+// UTF-8.  Otherwise they are detected as Windows-1252 if they contain
+// bytes in the range 0x80-0x9F (printable characters in Windows-1252,
+// but control characters in ISO-8859-1), and as ISO-8859-1 if not.
+func detectEncoding(data []byte) string {
+  switch {
+  case bytes.HasPrefix(data, bomUtf8):
+    return encodingUtf8Bom
+  case bytes.HasPrefix(data, bomUtf16le):
+    return encodingUtf16le
+  case bytes.HasPrefix(data, bomUtf16be):
+    return encodingUtf16be
+  case utf8.Valid(data):
+    return encodingUtf8
+  }
+
+  // check for windows-1252 printable characters
+  for _, b := range(data) {
+    if b >= 0x80 && b <= 0x9F {
+      return encodingWindows1252
+    }
+  }
+
+  return encodingLatin1
+}
+
+// Get decoder for detected encoding.
+func decoder(enc string) *encoding.Decoder {
+  switch enc {
+  case encodingUtf8Bom:
+    return unicode.UTF8BOM.NewDecoder()
+  case encodingUtf16le:
+    return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder()
+  case encodingUtf16be:
+    return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder()
+  case encodingWindows1252:
+    return charmap.Windows1252.NewDecoder()
+  case encodingLatin1:
+    return charmap.ISO8859_1.NewDecoder()
+  default:
+    return encoding.Nop.NewDecoder()
+  }
+}
+
+// Replaces line endings with LF and removes NUL characters, which
+// Postgres does not allow in text values.
+var lineEndingReplacer = strings.NewReplacer("\r\n", "\n", "\r", "\n", "\x00", "")
+
+// Normalize text: replace CRLF and CR line endings with LF, remove NUL
+// characters, replace invalid UTF-8 sequences with U+FFFD, and convert
+// to Unicode Normalization Form C (NFC).
+func normalizeText(s string) string {
+  s = lineEndingReplacer.Replace(s)
+  s = strings.ToValidUTF8(s, "�")
+  return norm.NFC.String(s)
+}
+
+// Decode text file to normalized UTF-8.
+//
+// Returns the normalized text and the detected encoding.  See
+// detectEncoding() for the encoding detection rules, and
+// normalizeText() for the normalization rules.
+func decodeText(data []byte) (string, string) {
+  // detect encoding
+  enc := detectEncoding(data)
+
+  // transcode to utf-8
+  buf, err := decoder(enc).Bytes(data)
+  if err != nil {
+    // decoders replace invalid input rather than failing, so this should
+    // not happen; fall back to the raw data and let normalizeText()
+    // replace any invalid sequences
+    buf = data
+  }
+
+  // return normalized text and encoding
+  return normalizeText(string(buf)), enc
+}
@@ -0,0 +1,82 @@
+package ingest
+
+import (
+  "testing"
+)
+
+func TestDecodeText(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    data string // raw file contents
+    exp string // expected text
+    enc string // expected encoding
+  } {{
+    name: "empty",
+    data: "",
+    exp: "",
+    enc: "utf-8",
+  }, {
+    name: "ascii",
+    data: "foo\nbar\n",
+    exp: "foo\nbar\n",
+    enc: "utf-8",
+  }, {
+    name: "utf-8",
+    data: "café",
+    exp: "café",
+    enc: "utf-8",
+  }, {
+    name: "utf-8 bom",
+    data: "\xef\xbb\xbfcafé",
+    exp: "café",
+    enc: "utf-8-bom",
+  }, {
+    name: "utf-16le bom",
+    data: "\xff\xfec\x00a\x00f\x00\xe9\x00",
+    exp: "café",
+    enc: "utf-16le",
+  }, {
+    name: "utf-16be bom",
+    data: "\xfe\xff\x00c\x00a\x00f\x00\xe9",
+    exp: "café",
+    enc: "utf-16be",
+  }, {
+    name: "iso-8859-1",
+    data: "caf\xe9 na\xefve",
+    exp: "café naïve",
+    enc: "iso-8859-1",
+  }, {
+    name: "windows-1252",
+    data: "\x93caf\xe9\x94 \x96 \x80",
+    exp: "“café” – €",
+    enc: "windows-1252",
+  }, {
+    name: "crlf",
+    data: "foo\r\nbar\rbaz\n",
+    exp: "foo\nbar\nbaz\n",
+    enc: "utf-8",
+  }, {
+    name: "nfc",
+    data: "cafe\u0301",
+    exp: "café",
+    enc: "utf-8",
+  }, {
+    name: "nul",
+    data: "foo\x00bar",
+    exp: "foobar",
+    enc: "utf-8",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      got, enc := decodeText([]byte(test.data))
+      if got != test.exp {
+        t.Fatalf("got %q, exp %q", got, test.exp)
+      }
+
+      if enc != test.enc {
+        t.Fatalf("got encoding %q, exp %q", enc, test.enc)
+      }
+    })
+  }
+}
@@ -0,0 +1,351 @@
+package ingest
+
+import (
+  "archive/zip"
+  "bytes"
+  "encoding/xml"
+  "errors"
+  "fmt"
+  "io"
+  "net/url"
+  "path"
+  "strings"
+)
+
+// Metadata and text extracted from an EPUB file.
+type Epub struct {
+  Title string // book title (first `dc:title`)
+  Author string // author names (`dc:creator`, separated by "; ")
+  Language string // language code (`dc:language`)
+  Date string // publication date (`dc:date`)
+  Text string // plain text of spine documents, in reading order
+}
+
+// Check if the given data is an EPUB file.
+//
+// Checks for a zip file which contains a `mimetype` entry with the
+// contents `application/epub+zip`, regardless of the file name.
+func IsEpub(data []byte) bool {
+  // check for zip header
+  if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+    return false
+  }
+
+  // open zip
+  zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+  if err != nil {
+    return false
+  }
+
+  // read mimetype entry
+  er := epubReader { zr: zr, limits: epubMimetypeLimits }
+  buf, err := er.read("mimetype")
+  if err != nil {
+    return false
+  }
+
+  // check mimetype
+  return strings.TrimSpace(string(buf)) == "application/epub+zip"
+}
+
+// Returned when an EPUB file is missing a required file or element.
+var ErrInvalidEpub = errors.New("invalid EPUB")
+
+// Limits used to read the `mimetype` entry in IsEpub().
+var epubMimetypeLimits = ArchiveLimits {
+  MaxEntries: 1,
+  MaxFileSize: 64,
+  MaxSize: 64,
+}
+
+// Zip reader which limits the decompressed size of files read from an
+// EPUB file, to guard against decompression bombs.  The MaxFileSize
+// limit applies to each file, and the MaxSize limit applies to the
+// total size of all files read, including files which are read more
+// than once.
+type epubReader struct {
+  zr *zip.Reader // zip reader
+  limits ArchiveLimits // limits
+  size int64 // total decompressed size of files read so far
+}
+
+// Read file with the given path from zip file.
+//
+// Returns ErrArchiveTooLarge if the file is larger than the maximum
+// file size or if the total size limit is exceeded.
+func (r *epubReader) read(name string) ([]byte, error) {
+  // open file
+  fh, err := r.zr.Open(name)
+  if err != nil {
+    return nil, fmt.Errorf("%w: %s: %w", ErrInvalidEpub, name, err)
+  }
+  defer fh.Close()
+
+  // read file, up to one byte past the size limit
+  buf, err := io.ReadAll(io.LimitReader(fh, r.limits.MaxFileSize + 1))
+  if err != nil {
+    return nil, fmt.Errorf("%w: %s: %w", ErrInvalidEpub, name, err)
+  }
+
+  // check file size and total size
+  r.size += int64(len(buf))
+  if int64(len(buf)) > r.limits.MaxFileSize {
+    return nil, fmt.Errorf("%w: %s: file is larger than %d bytes", ErrArchiveTooLarge, name, r.limits.MaxFileSize)
+  } else if r.size > r.limits.MaxSize {
+    return nil, fmt.Errorf("%w: EPUB is larger than %d bytes when decompressed", ErrArchiveTooLarge, r.limits.MaxSize)
+  }
+
+  // return file data
+  return buf, nil
+}
+
+// META-INF/container.xml
+type epubContainer struct {
+  Rootfiles []struct {
+    FullPath string `xml:"full-path,attr"`
+  } `xml:"rootfiles>rootfile"`
+}
+
+// OPF package document
+type epubPackage struct {
+  Titles []string `xml:"metadata>title"`
+  Creators []string `xml:"metadata>creator"`
+  Languages []string `xml:"metadata>language"`
+  Dates []string `xml:"metadata>date"`
+
+  Items []struct {
+    Id string `xml:"id,attr"`
+    Href string `xml:"href,attr"`
+    MediaType string `xml:"media-type,attr"`
+  } `xml:"manifest>item"`
+
+  ItemRefs []struct {
+    IdRef string `xml:"idref,attr"`
+  } `xml:"spine>itemref"`
+}
+
+// Get first value from list of metadata values, or an empty string if
+// the list is empty.
+func first(vals []string) string {
+  if len(vals) > 0 {
+    return strings.TrimSpace(vals[0])
+  }
+
+  return ""
+}
+
+// Parse EPUB file.
+//
+// Reads the package document referenced by `META-INF/container.xml`,
+// then converts the XHTML documents in the spine to plain text in
+// reading order.
+//
+// The number of entries, the decompressed size of each file, and the
+// total decompressed size of the files read are checked against the
+// given limits.  Returns ErrArchiveTooLarge if a limit is exceeded.
+func ParseEpub(data []byte, limits ArchiveLimits) (Epub, error) {
+  r, _, err := parseEpub(data, limits)
+  return r, err
+}
+
+// Parse EPUB file.  Returns the EPUB and the total decompressed size
+// of the files read, including when parsing fails.
+func parseEpub(data []byte, limits ArchiveLimits) (Epub, int64, error) {
+  var r Epub
+
+  // open zip
+  zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+  if err != nil {
+    return r, 0, fmt.Errorf("%w: %w", ErrInvalidEpub, err)
+  }
+
+  // check entry count
+  if len(zr.File) > limits.MaxEntries {
+    return r, 0, fmt.Errorf("%w: EPUB has more than %d entries", ErrArchiveTooLarge, limits.MaxEntries)
+  }
+  er := epubReader { zr: zr, limits: limits }
+
+  // read container
+  buf, err := er.read("META-INF/container.xml")
+  if err != nil {
+    return r, er.size, err
+  }
+
+  // parse container
+  var container epubContainer
+  if err := xml.Unmarshal(buf, &container); err != nil {
+    return r, er.size, fmt.Errorf("%w: container.xml: %w", ErrInvalidEpub, err)
+  } else if len(container.Rootfiles) == 0 {
+    return r, er.size, fmt.Errorf("%w: container.xml: missing rootfile", ErrInvalidEpub)
+  }
+
+  // read package document
+  opfPath := container.Rootfiles[0].FullPath
+  buf, err = er.read(opfPath)
+  if err != nil {
+    return r, er.size, err
+  }
+
+  // parse package document
+  var pkg epubPackage
+  if err := xml.Unmarshal(buf, &pkg); err != nil {
+    return r, er.size, fmt.Errorf("%w: %s: %w", ErrInvalidEpub, opfPath, err)
+  }
+
+  // populate metadata
+  r.Title = first(pkg.Titles)
+  r.Language = first(pkg.Languages)
+  r.Date = first(pkg.Dates)
+
+  // join authors
+  var authors []string
+  for _, creator := range(pkg.Creators) {
+    if s := strings.TrimSpace(creator); s != "" {
+      authors = append(authors, s)
+    }
+  }
+  r.Author = strings.Join(authors, "; ")
+
+  // build map of manifest item ID to item path
+  items := map[string]string{}
+  for _, item := range(pkg.Items) {
+    // skip non-xhtml items
+    if item.MediaType != "application/xhtml+xml" && item.MediaType != "text/html" {
+      continue
+    }
+
+    // unescape href
+    href, err := url.PathUnescape(item.Href)
+    if err != nil {
+      return r, er.size, fmt.Errorf("%w: %s: invalid href %s", ErrInvalidEpub, opfPath, item.Href)
+    }
+
+    // resolve href relative to package document
+    items[item.Id] = path.Join(path.Dir(opfPath), href)
+  }
+
+  // convert spine documents to text
+  var texts []string
+  for _, ref := range(pkg.ItemRefs) {
+    // get item path, skip non-xhtml items
+    itemPath, ok := items[ref.IdRef]
+    if !ok {
+      continue
+    }
+
+    // read document
+    buf, err := er.read(itemPath)
+    if err != nil {
+      return r, er.size, err
+    }
+
+    // convert document to text
+    if text := HtmlToText(buf); text != "" {
+      texts = append(texts, text)
+    }
+  }
+  r.Text = strings.Join(texts, "\n\n")
+
+  // return success
+  return r, er.size, nil
+}
+
+// elements which separate paragraphs
+var htmlBlockElements = map[string]bool {
+  "address": true,
+  "article": true,
+  "aside": true,
+  "blockquote": true,
+  "br": true,
+  "dd": true,
+  "div": true,
+  "dl": true,
+  "dt": true,
+  "figcaption": true,
+  "figure": true,
+  "footer": true,
+  "h1": true,
+  "h2": true,
+  "h3": true,
+  "h4": true,
+  "h5": true,
+  "h6": true,
+  "header": true,
+  "hr": true,
+  "li": true,
+  "ol": true,
+  "p": true,
+  "pre": true,
+  "section": true,
+  "table": true,
+  "td": true,
+  "th": true,
+  "tr": true,
+  "ul": true,
+}
+
+// elements whose contents are not text
+var htmlSkipElements = map[string]bool {
+  "head": true,
+  "script": true,
+  "style": true,
+}
+
+// Convert XHTML or HTML document to plain text.
+//
+// Block elements (paragraphs, headings, list items, etc) are separated
+// by blank lines and whitespace within each block is collapsed.
+// Malformed markup is parsed on a best-effort basis.
+func HtmlToText(data []byte) string {
+  // create lenient decoder
+  d := xml.NewDecoder(bytes.NewReader(data))
+  d.Strict = false
+  d.AutoClose = xml.HTMLAutoClose
+  d.Entity = xml.HTMLEntity
+
+  var blocks []string
+  var sb strings.Builder
+  skip := 0
+
+  // append current block to list of blocks
+  flush := func() {
+    if s := strings.Join(strings.Fields(sb.String()), " "); s != "" {
+      blocks = append(blocks, s)
+    }
+    sb.Reset()
+  }
+
+  for {
+    tok, err := d.Token()
+    if err != nil {
+      // end of document or unrecoverable error
+      break
+    }
+
+    switch t := tok.(type) {
+    case xml.StartElement:
+      name := strings.ToLower(t.Name.Local)
+      if htmlSkipElements[name] {
+        skip += 1
+      } else if htmlBlockElements[name] {
+        flush()
+      }
+    case xml.EndElement:
+      name := strings.ToLower(t.Name.Local)
+      if htmlSkipElements[name] {
+        if skip > 0 {
+          skip -= 1
+        }
+      } else if htmlBlockElements[name] {
+        flush()
+      }
+    case xml.CharData:
+      if skip == 0 {
+        sb.Write(t)
+      }
+    }
+  }
+  flush()
+
+  return strings.Join(blocks, "\n\n")
+}
@@ -0,0 +1,252 @@
+package ingest
+
+import (
+  "archive/zip"
+  "bytes"
+  "errors"
+  "strings"
+  "testing"
+)
+
+// test container.xml
+const testContainerXml = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+
+// test package document
+const testContentOpf = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="id">urn:uuid:1234</dc:identifier>
+    <dc:title>The Test Book</dc:title>
+    <dc:creator>Jane Doe</dc:creator>
+    <dc:creator>John Roe</dc:creator>
+    <dc:language>en</dc:language>
+    <dc:date>1901-02-03</dc:date>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="text/chapter%201.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ch2" href="text/chapter2.xhtml" media-type="application/xhtml+xml"/>
+    <item id="css" href="style.css" media-type="text/css"/>
+  </manifest>
+  <spine>
+    <itemref idref="ch2"/>
+    <itemref idref="ch1"/>
+  </spine>
+</package>`
+
+// test chapter 1
+const testChapter1 = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml">
+  <head><title>Ignored</title><style>p { color: red }</style></head>
+  <body>
+    <h1>Chapter 1</h1>
+    <p>It was a
+      dark &amp; stormy night&mdash;or so they said.</p>
+    <p>Second <em>paragraph</em>.<br/>Next line.</p>
+  </body>
+</html>`
+
+// test chapter 2
+const testChapter2 = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml">
+  <body><p>Preface.</p></body>
+</html>`
+
+// Build zip file from ordered list of name/content pairs.
+//
+// The first entry is stored uncompressed, as required for the EPUB
+// `mimetype` entry.
+func makeZip(t *testing.T, files [][2]string) []byte {
+  t.Helper()
+
+  var buf bytes.Buffer
+  zw := zip.NewWriter(&buf)
+
+  for i, file := range(files) {
+    method := zip.Deflate
+    if i == 0 {
+      method = zip.Store
+    }
+
+    fw, err := zw.CreateHeader(&zip.FileHeader { Name: file[0], Method: method })
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if _, err := fw.Write([]byte(file[1])); err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  if err := zw.Close(); err != nil {
+    t.Fatal(err)
+  }
+
+  return buf.Bytes()
+}
+
+// test EPUB limits
+var testEpubLimits = ArchiveLimits { MaxEntries: 10, MaxFileSize: 10000, MaxSize: 100000 }
+
+// Build test EPUB file.
+func makeTestEpub(t *testing.T) []byte {
+  return makeZip(t, [][2]string {
+    { "mimetype", "application/epub+zip" },
+    { "META-INF/container.xml", testContainerXml },
+    { "OEBPS/content.opf", testContentOpf },
+    { "OEBPS/nav.xhtml", "<html><body><nav>Contents</nav></body></html>" },
+    { "OEBPS/text/chapter 1.xhtml", testChapter1 },
+    { "OEBPS/text/chapter2.xhtml", testChapter2 },
+    { "OEBPS/style.css", "p { color: red }" },
+  })
+}
+
+// Build test EPUB file whose spine contains the given chapter n times.
+// Used to test decompression limits.
+func makeBombEpub(t *testing.T, chapter string, n int) []byte {
+  opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <manifest><item id="ch" href="ch.xhtml" media-type="application/xhtml+xml"/></manifest>
+  <spine>` + strings.Repeat(`<itemref idref="ch"/>`, n) + `</spine>
+</package>`
+
+  return makeZip(t, [][2]string {
+    { "mimetype", "application/epub+zip" },
+    { "META-INF/container.xml", testContainerXml },
+    { "OEBPS/content.opf", opf },
+    { "OEBPS/ch.xhtml", "<html><body><p>" + chapter + "</p></body></html>" },
+  })
+}
+
+func TestIsEpub(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    data []byte // file data
+    exp bool // expected result
+  } {
+    { "epub", makeTestEpub(t), true },
+    { "text", []byte("some text"), false },
+    { "empty", []byte{}, false },
+    { "zip", makeZip(t, [][2]string { { "foo.txt", "foo" } }), false },
+    { "wrong mimetype", makeZip(t, [][2]string { { "mimetype", "application/zip" } }), false },
+    { "large mimetype", makeZip(t, [][2]string { { "mimetype", "application/epub+zip" + strings.Repeat(" ", 1 << 20) } }), false },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      if got := IsEpub(test.data); got != test.exp {
+        t.Fatalf("got %v, exp %v", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestParseEpub(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    got, err := ParseEpub(makeTestEpub(t), testEpubLimits)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    exp := Epub {
+      Title: "The Test Book",
+      Author: "Jane Doe; John Roe",
+      Language: "en",
+      Date: "1901-02-03",
+      Text: "Preface.\n\nChapter 1\n\nIt was a dark & stormy night—or so they said.\n\nSecond paragraph.\n\nNext line.",
+    }
+
+    if got != exp {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    tests := []struct {
+      name string // test name
+      data []byte // file data
+    } {{
+      name: "not zip",
+      data: []byte("foo"),
+    }, {
+      name: "missing container",
+      data: makeZip(t, [][2]string {
+        { "mimetype", "application/epub+zip" },
+      }),
+    }, {
+      name: "missing package",
+      data: makeZip(t, [][2]string {
+        { "mimetype", "application/epub+zip" },
+        { "META-INF/container.xml", testContainerXml },
+      }),
+    }, {
+      name: "missing chapter",
+      data: makeZip(t, [][2]string {
+        { "mimetype", "application/epub+zip" },
+        { "META-INF/container.xml", testContainerXml },
+        { "OEBPS/content.opf", testContentOpf },
+      }),
+    }}
+
+    for _, test := range(tests) {
+      t.Run(test.name, func(t *testing.T) {
+        if got, err := ParseEpub(test.data, testEpubLimits); !errors.Is(err, ErrInvalidEpub) {
+          t.Fatalf("got %#v, %v, exp ErrInvalidEpub", got, err)
+        }
+      })
+    }
+  })
+
+  t.Run("too large", func(t *testing.T) {
+    // too many entries
+    var files [][2]string
+    for i := 0; i < 11; i++ {
+      files = append(files, [2]string { strings.Repeat("x", i + 1), "" })
+    }
+
+    tests := []struct {
+      name string // test name
+      data []byte // file data
+    } {
+      { "zip bomb", makeBombEpub(t, strings.Repeat("x", 10 << 20), 1) },
+      { "repeated spine", makeBombEpub(t, strings.Repeat("x", 5000), 100) },
+      { "too many entries", makeZip(t, files) },
+    }
+
+    for _, test := range(tests) {
+      t.Run(test.name, func(t *testing.T) {
+        if got, err := ParseEpub(test.data, testEpubLimits); !errors.Is(err, ErrArchiveTooLarge) {
+          t.Fatalf("got %#v, %v, exp ErrArchiveTooLarge", got, err)
+        }
+      })
+    }
+  })
+}
+
+func TestHtmlToText(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    val string // html
+    exp string // expected text
+  } {
+    { "empty", "", "" },
+    { "paragraphs", "<p>foo</p><p>bar  baz</p>", "foo\n\nbar baz" },
+    { "inline", "<p>foo <b>bar</b> <i>baz</i></p>", "foo bar baz" },
+    { "skip", "<html><head><title>x</title></head><body><script>y</script>z</body></html>", "z" },
+    { "entities", "<p>a&nbsp;b &lt;c&gt;</p>", "a b <c>" },
+    { "unclosed", "<p>foo<p>bar", "foo\n\nbar" },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      if got := HtmlToText([]byte(test.val)); got != test.exp {
+        t.Fatalf("got %q, exp %q", got, test.exp)
+      }
+    })
+  }
+}
@@ -0,0 +1,103 @@
+package ingest
+
+import (
+  "regexp"
+  "strings"
+)
+
+// Metadata from the header of a Project Gutenberg text.
+type GutenbergMetadata struct {
+  Title string // book title (`Title:` header)
+  Author string // author name (`Author:` header)
+  ReleaseDate string // release date, sans ebook number (`Release Date:` header)
+  Language string // language name (`Language:` header)
+  Encoding string // character set name (`Character set encoding:` header)
+}
+
+// start of text marker
+var gutenbergStartRe = regexp.MustCompile(`(?im)^\*\*\*\s*START OF (?:THE|THIS) PROJECT GUTENBERG E-?BOOK.*$`)
+
+// end of text marker
+var gutenbergEndRe = regexp.MustCompile(`(?im)^\*\*\*\s*END OF (?:THE|THIS) PROJECT GUTENBERG E-?BOOK.*$`)
+
+// trailing "End of Project Gutenberg's ..." line in older texts
+var gutenbergEndLineRe = regexp.MustCompile(`(?i)\n\s*End of (?:the )?Project Gutenberg.*\s*$`)
+
+// header field line
+var gutenbergFieldRe = regexp.MustCompile(`^([A-Za-z][A-Za-z ]*):\s*(.*?)\s*$`)
+
+// ebook number suffix of release date (e.g. "[eBook #84]")
+var gutenbergEbookRe = regexp.MustCompile(`(?i)\s*\[e-?book #\d+\]`)
+
+// Parse header fields into map of lowercase field name to value.
+//
+// Indented lines which follow a field are treated as continuations of
+// the field value.
+func parseGutenbergFields(header string) map[string]string {
+  r := map[string]string{}
+
+  lastKey := ""
+  for _, line := range(strings.Split(header, "\n")) {
+    line = strings.TrimRight(line, "\r")
+
+    if md := gutenbergFieldRe.FindStringSubmatch(line); md != nil {
+      // field line
+      lastKey = strings.ToLower(md[1])
+      if _, ok := r[lastKey]; !ok {
+        r[lastKey] = md[2]
+      } else {
+        // ignore repeated field
+        lastKey = ""
+      }
+    } else if lastKey != "" && len(strings.TrimSpace(line)) > 0 && (line[0] == ' ' || line[0] == '\t') {
+      // continuation line
+      r[lastKey] += " " + strings.TrimSpace(line)
+    } else {
+      // other line
+      lastKey = ""
+    }
+  }
+
+  return r
+}
+
+// Parse Project Gutenberg text.
+//
+// Returns the metadata from the header, the text between the `*** START
+// OF` and `*** END OF` markers (with the license boilerplate removed),
+// and true if the text contains a `*** START OF` marker.
+//
+// If the text does not contain a `*** START OF` marker, then it is not
+// treated as a Project Gutenberg text, and the returned metadata is
+// empty and the returned body is the unmodified text.
+func ParseGutenberg(text string) (GutenbergMetadata, string, bool) {
+  var md GutenbergMetadata
+
+  // find start marker
+  start := gutenbergStartRe.FindStringIndex(text)
+  if start == nil {
+    return md, text, false
+  }
+
+  // parse header fields
+  fields := parseGutenbergFields(text[:start[0]])
+  md.Title = fields["title"]
+  md.Author = fields["author"]
+  md.ReleaseDate = gutenbergEbookRe.ReplaceAllString(fields["release date"], "")
+  md.Language = fields["language"]
+  md.Encoding = fields["character set encoding"]
+
+  // get body after start marker
+  body := text[start[1]:]
+
+  // trim body at end marker
+  if end := gutenbergEndRe.FindStringIndex(body); end != nil {
+    body = body[:end[0]]
+  }
+
+  // remove trailing "End of Project Gutenberg's ..." line
+  body = gutenbergEndLineRe.ReplaceAllString(body, "")
+
+  // return result
+  return md, strings.Trim(body, "\r\n") + "\n", true
+}
@@ -0,0 +1,103 @@
+package ingest
+
+import (
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+// Read test file from testdata directory.
+func readTestData(t *testing.T, name string) []byte {
+  t.Helper()
+
+  data, err := os.ReadFile(filepath.Join("testdata", name))
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  return data
+}
+
+func TestParseGutenberg(t *testing.T) {
+  tests := []struct {
+    name string // test file name
+    ok bool // expected gutenberg flag
+    exp GutenbergMetadata // expected metadata
+    bodyStart string // expected body prefix
+    bodyEnd string // expected body suffix
+  } {{
+    name: "frankenstein.txt",
+    ok: true,
+    exp: GutenbergMetadata {
+      Title: "Frankenstein or, The Modern Prometheus",
+      Author: "Mary Wollstonecraft Shelley",
+      ReleaseDate: "October, 1993",
+      Language: "English",
+      Encoding: "UTF-8",
+    },
+    bodyStart: "Frankenstein;\n",
+    bodyEnd: "by Mary Wollstonecraft (Godwin) Shelley\n",
+  }, {
+    name: "alice.txt",
+    ok: true,
+    exp: GutenbergMetadata {
+      Title: "Alice's Adventures in Wonderland",
+      Author: "Lewis Carroll",
+      ReleaseDate: "March, 1994",
+      Language: "English",
+      Encoding: "ISO-8859-1",
+    },
+    bodyStart: "ALICE'S ADVENTURES IN WONDERLAND\n",
+    bodyEnd: "\nLewis Carroll\n",
+  }, {
+    name: "plain.txt",
+    ok: false,
+    bodyStart: "Some Notes\n",
+    bodyEnd: "Nothing to see here.\n",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      text := string(readTestData(t, test.name))
+
+      got, body, ok := ParseGutenberg(text)
+      if ok != test.ok {
+        t.Fatalf("got ok %v, exp %v", ok, test.ok)
+      }
+
+      if got != test.exp {
+        t.Fatalf("got %#v, exp %#v", got, test.exp)
+      }
+
+      if !strings.HasPrefix(body, test.bodyStart) {
+        t.Fatalf("got body %q, exp prefix %q", body, test.bodyStart)
+      }
+
+      if !strings.HasSuffix(body, test.bodyEnd) {
+        t.Fatalf("got body %q, exp suffix %q", body, test.bodyEnd)
+      }
+
+      if ok && strings.Contains(body, "Project Gutenberg") {
+        t.Fatalf("got boilerplate in body %q", body)
+      }
+    })
+  }
+
+  t.Run("crlf", func(t *testing.T) {
+    text := strings.ReplaceAll(string(readTestData(t, "frankenstein.txt")), "\n", "\r\n")
+
+    got, body, ok := ParseGutenberg(text)
+    if !ok {
+      t.Fatal("got ok false, exp true")
+    }
+
+    if got.Author != "Mary Wollstonecraft Shelley" {
+      t.Fatalf("got author %q", got.Author)
+    }
+
+    if !strings.HasPrefix(body, "Frankenstein;\r\n") || strings.Contains(body, "*** END") {
+      t.Fatalf("got body %q", body)
+    }
+  })
+}
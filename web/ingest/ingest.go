@@ -0,0 +1,136 @@
+// Upload pipeline: convert uploaded files to books.
+package ingest
+
+import (
+  "bookman/model"
+  "fmt"
+  "path"
+  "strings"
+)
+
+// Upload pipeline options.
+type Options struct {
+  // Remove the Project Gutenberg header and license from the body of
+  // Project Gutenberg texts?
+  StripBoilerplate bool
+}
+
+// Build book from uploaded text file.
+//
+// If the text is a Project Gutenberg text, then the book name, author,
+// release date, and language are populated from the Project Gutenberg
+// header.  Otherwise the book name is the file name without the `.txt`
+// extension.
+//
+// The text is transcoded from the detected character encoding to UTF-8
+// and normalized before it is parsed; see decodeText().
+func Text(fileName string, data []byte, opts Options) model.UploadedFile {
+  text, enc := decodeText(data)
+
+  // build default result
+  r := model.UploadedFile {
+    Name: strings.TrimSuffix(fileName, ".txt"),
+    Body: text,
+    Encoding: enc,
+  }
+
+  // parse project gutenberg header
+  md, body, ok := ParseGutenberg(text)
+  if !ok {
+    return r
+  }
+
+  // populate metadata
+  if md.Title != "" {
+    r.Name = md.Title
+  }
+  r.Author = md.Author
+  r.ReleaseDate = md.ReleaseDate
+  r.Language = md.Language
+
+  // strip header and license
+  if opts.StripBoilerplate {
+    r.Body = body
+  }
+
+  // return result
+  return r
+}
+
+// Build book from uploaded EPUB file.
+//
+// The book name, author, release date, and language are populated
+// from the EPUB package metadata.  If the EPUB has no title, then the
+// book name is the file name without the `.epub` extension.
+//
+// The EPUB is read with the given limits; see ParseEpub().
+func EpubFile(fileName string, data []byte, limits ArchiveLimits) (model.UploadedFile, error) {
+  // parse epub
+  epub, err := ParseEpub(data, limits)
+  if err != nil {
+    return model.UploadedFile{}, err
+  }
+
+  // build result
+  return epubFile(fileName, epub), nil
+}
+
+// Build book from parsed EPUB file.
+func epubFile(fileName string, epub Epub) model.UploadedFile {
+  r := model.UploadedFile {
+    Name: epub.Title,
+    Author: epub.Author,
+    ReleaseDate: epub.Date,
+    Language: epub.Language,
+    Body: normalizeText(epub.Text),
+    Encoding: encodingUtf8,
+  }
+
+  // default to file name
+  if r.Name == "" {
+    r.Name = strings.TrimSuffix(fileName, ".epub")
+  }
+
+  // return result
+  return r
+}
+
+// Check if the given slash-separated file path is a supported book
+// file: a `.txt` or `.epub` file (case-insensitive) which is not a
+// hidden file, is not in a hidden directory, and is not in a macOS
+// resource fork directory (`__MACOSX`).
+func IsBookPath(p string) bool {
+  // skip hidden files and macOS resource forks
+  for _, s := range(strings.Split(p, "/")) {
+    if strings.HasPrefix(s, ".") || s == "__MACOSX" {
+      return false
+    }
+  }
+
+  // check extension
+  switch strings.ToLower(path.Ext(p)) {
+  case ".txt", ".epub":
+    return true
+  default:
+    return false
+  }
+}
+
+// Build book from uploaded file.
+//
+// The file format is detected from the file contents rather than the
+// file name: EPUB files are converted with EpubFile() and the given
+// limits, and all other files are treated as plain text and converted
+// with Text().
+func File(fileName string, data []byte, opts Options, limits ArchiveLimits) (model.UploadedFile, error) {
+  if IsEpub(data) {
+    r, err := EpubFile(fileName, data, limits)
+    if err != nil {
+      return r, fmt.Errorf("%s: %w", fileName, err)
+    }
+
+    return r, nil
+  }
+
+  return Text(fileName, data, opts), nil
+}
@@ -0,0 +1,138 @@
+package ingest
+
+import (
+  "bookman/model"
+  "strings"
+  "testing"
+)
+
+func TestText(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    file string // test file name
+    opts Options // pipeline options
+    exp model.UploadedFile // expected result (sans body)
+    stripped bool // expect boilerplate to be removed from body?
+  } {{
+    name: "gutenberg",
+    file: "frankenstein.txt",
+    exp: model.UploadedFile {
+      Name: "Frankenstein or, The Modern Prometheus",
+      Author: "Mary Wollstonecraft Shelley",
+      ReleaseDate: "October, 1993",
+      Language: "English",
+      Encoding: "utf-8",
+    },
+  }, {
+    name: "gutenberg strip",
+    file: "frankenstein.txt",
+    opts: Options { StripBoilerplate: true },
+    exp: model.UploadedFile {
+      Name: "Frankenstein or, The Modern Prometheus",
+      Author: "Mary Wollstonecraft Shelley",
+      ReleaseDate: "October, 1993",
+      Language: "English",
+      Encoding: "utf-8",
+    },
+    stripped: true,
+  }, {
+    name: "plain",
+    file: "plain.txt",
+    opts: Options { StripBoilerplate: true },
+    exp: model.UploadedFile {
+      Name: "plain",
+      Encoding: "utf-8",
+    },
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      data := readTestData(t, test.file)
+      got := Text(test.file, data, test.opts)
+
+      // check body
+      if test.stripped {
+        if strings.Contains(got.Body, "Project Gutenberg") {
+          t.Fatalf("got boilerplate in body %q", got.Body)
+        }
+      } else if got.Body != string(data) {
+        t.Fatalf("got body %q, exp %q", got.Body, string(data))
+      }
+
+      // check metadata
+      got.Body = ""
+      if got != test.exp {
+        t.Fatalf("got %#v, exp %#v", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestFile(t *testing.T) {
+  t.Run("epub", func(t *testing.T) {
+    // note: file name has no .epub extension
+    got, err := File("foo.bin", makeTestEpub(t), Options{}, testEpubLimits)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if got.Name != "The Test Book" || got.Author != "Jane Doe; John Roe" || got.Language != "en" || got.ReleaseDate != "1901-02-03" {
+      t.Fatalf("got %#v", got)
+    }
+  })
+
+  t.Run("text", func(t *testing.T) {
+    got, err := File("foo.txt", []byte("bar"), Options{}, testEpubLimits)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    exp := model.UploadedFile { Name: "foo", Body: "bar", Encoding: "utf-8" }
+    if got != exp {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("latin-1", func(t *testing.T) {
+    got, err := File("foo.txt", []byte("caf\xe9"), Options{}, testEpubLimits)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    exp := model.UploadedFile { Name: "foo", Body: "café", Encoding: "iso-8859-1" }
+    if got != exp {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("invalid epub", func(t *testing.T) {
+    data := makeZip(t, [][2]string { { "mimetype", "application/epub+zip" } })
+    if got, err := File("foo.epub", data, Options{}, testEpubLimits); err == nil {
+      t.Fatalf("got %#v, exp err", got)
+    }
+  })
+}
+
+func TestIsBookPath(t *testing.T) {
+  tests := []struct {
+    path string // file path
+    exp bool // expected result
+  } {
+    { "foo.txt", true },
+    { "foo/bar.TXT", true },
+    { "foo/bar.epub", true },
+    { "foo.jpg", false },
+    { "foo", false },
+    { ".foo.txt", false },
+    { ".git/foo.txt", false },
+    { "__MACOSX/foo.txt", false },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.path, func(t *testing.T) {
+      if got := IsBookPath(test.path); got != test.exp {
+        t.Fatalf("got %v, exp %v", got, test.exp)
+      }
+    })
+  }
+}
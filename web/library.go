@@ -0,0 +1,193 @@
+package main
+
+import (
+  "bookman/app"
+  "bookman/library"
+  "bookman/model"
+  "context"
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+  "log"
+  "os"
+)
+
+// export command.
+//
+// Usage:
+//
+//   bookman export [file]
+//
+// Writes a library archive containing the metadata and contents of all
+// books, including books in the trash, to the given file, or to
+// standard output if the file is omitted or `-`.
+func exportCommand(ctx context.Context, config app.Config, args []string) error {
+  // get output path
+  path := "-"
+  if len(args) > 1 {
+    return errors.New("too many arguments")
+  } else if len(args) == 1 {
+    path = args[0]
+  }
+
+  // create application context from context and config
+  appCtx, err := app.NewContext(ctx, config)
+  if err != nil {
+    return err
+  }
+  defer appCtx.Pool.Close()
+
+  // open output
+  var out io.WriteCloser = os.Stdout
+  if path != "-" {
+    f, err := os.Create(path)
+    if err != nil {
+      return err
+    }
+    out = f
+  }
+
+  // write archive
+  w := library.NewWriter(out)
+  err = appCtx.Model.ExportLibrary(ctx, appCtx.Pool, w)
+  if err == nil {
+    err = w.Close()
+  }
+  if path != "-" {
+    if closeErr := out.Close(); err == nil {
+      err = closeErr
+    }
+
+    // remove incomplete archive
+    if err != nil {
+      os.Remove(path)
+    }
+  }
+
+  return err
+}
+
+// restore command options.
+type restoreOptions struct {
+  path string // archive path, or "-" for standard input
+  conflict model.ConflictPolicy // name conflict policy
+  duplicates model.DuplicatePolicy // duplicate contents policy
+  partial bool // restore remaining books if a book fails?
+}
+
+// Parse restore command arguments.
+func parseRestoreArgs(args []string) (restoreOptions, error) {
+  var opts restoreOptions
+  var conflict, duplicates string
+  var err error
+
+  // parse flags
+  flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+  flags.StringVar(&conflict, "conflict", string(model.ConflictFail), "action taken when a book name is in use: fail, skip, replace, or rename")
+  flags.StringVar(&duplicates, "duplicates", string(model.DuplicateAllow), "action taken when book contents are in use: fail, skip, or allow")
+  flags.BoolVar(&opts.partial, "partial", false, "restore remaining books if a book fails")
+  if err = flags.Parse(args); err != nil {
+    return restoreOptions{}, err
+  }
+
+  // parse conflict policy
+  opts.conflict, err = parseConflictPolicy(conflict)
+  if err != nil {
+    return restoreOptions{}, err
+  }
+
+  // parse duplicate policy
+  switch opts.duplicates = model.DuplicatePolicy(duplicates); opts.duplicates {
+  case model.DuplicateFail, model.DuplicateSkip, model.DuplicateAllow:
+    // valid duplicate policy
+  default:
+    return restoreOptions{}, fmt.Errorf("unknown duplicate policy: %s", duplicates)
+  }
+
+  // get archive path
+  if flags.NArg() != 1 {
+    return restoreOptions{}, errors.New("missing file")
+  }
+  opts.path = flags.Arg(0)
+
+  // return success
+  return opts, nil
+}
+
+// restore command.
+//
+// Usage:
+//
+//   bookman restore [-conflict policy] [-duplicates policy] [-partial] <file>
+//
+// Restores the books and collections in the given library archive (or
+// standard input, if the file is `-`) into the library.  Name conflicts
+// with existing books are resolved with the given conflict policy
+// (default: `fail`), and books whose contents match an existing book
+// are resolved with the given duplicate policy (default: `allow`).
+// The whole archive is restored in one transaction;
+// if `-partial` is set, then books which fail are logged and the
+// remaining books are still restored.
+func restoreCommand(ctx context.Context, config app.Config, args []string) error {
+  // parse arguments
+  opts, err := parseRestoreArgs(args)
+  if err != nil {
+    return err
+  }
+
+  // open input
+  var in io.Reader = os.Stdin
+  if opts.path != "-" {
+    f, err := os.Open(opts.path)
+    if err != nil {
+      return err
+    }
+    defer f.Close()
+    in = f
+  }
+
+  // open archive
+  r, err := library.NewReader(in, int64(config.UploadMaxFileSize))
+  if err != nil {
+    return err
+  }
+  log.Printf("restoring %d book(s) from archive created at %s", len(r.Manifest().Books), r.Manifest().CreatedAt)
+
+  // create application context from context and config
+  appCtx, err := app.NewContext(ctx, config)
+  if err != nil {
+    return err
+  }
+  defer appCtx.Pool.Close()
+
+  // restore books
+  ctx = model.ContextWithActor(ctx, "bookman restore")
+  results, err := appCtx.Model.RestoreLibrary(ctx, appCtx.Pool, r, model.UploadOptions {
+    Conflict: opts.conflict,
+    Duplicates: opts.duplicates,
+    Partial: opts.partial,
+  })
+  if err != nil {
+    return err
+  }
+
+  // log results
+  counts := map[model.UploadStatus]int{}
+  for _, r := range(results) {
+    counts[r.Status] += 1
+    if r.Status == model.UploadFailed {
+      log.Printf("failed %s: %v", r.Name, r.Err)
+    }
+  }
+  log.Printf(
+    "created %d, renamed %d, replaced %d, skipped %d, failed %d",
+    counts[model.UploadCreated],
+    counts[model.UploadRenamed],
+    counts[model.UploadReplaced],
+    counts[model.UploadSkipped],
+    counts[model.UploadFailed],
+  )
+
+  return nil
+}
@@ -0,0 +1,258 @@
+// Portable library archives, used to back up and restore libraries.
+//
+// A library archive is a gzip-compressed tar file which contains a
+// JSON manifest (`manifest.json`) followed by one UTF-8 text file for
+// each book (`books/{id}.txt`).  The manifest contains the metadata of
+// every book and collection, along with the path and SHA-256 hash of
+// the text file of each book.
+package library
+
+import (
+  "archive/tar"
+  "bookman/model"
+  "compress/gzip"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "time"
+)
+
+// Manifest format name.
+const Format = "bookman-library"
+
+// Manifest format version.
+const Version = 1
+
+// Path of manifest in archive.
+const manifestPath = "manifest.json"
+
+// Maximum size of manifest, in bytes.
+const maxManifestSize = 256 << 20
+
+// Returned when an archive is not a valid library archive.
+var ErrInvalid = errors.New("invalid library archive")
+
+// Book in manifest.
+type ManifestBook struct {
+  model.LibraryBook
+
+  Path string `json:"path"` // path of book text file in archive
+}
+
+// Library archive manifest.
+type Manifest struct {
+  Format string `json:"format"` // format name (always Format)
+  Version int `json:"version"` // format version
+  CreatedAt time.Time `json:"created_at"` // time archive was created
+  Books []ManifestBook `json:"books"` // books, in archive order
+  Collections []model.LibraryCollection `json:"collections"` // collections
+}
+
+// Get path of text file for book with the given ID.
+func bookPath(id int) string {
+  return fmt.Sprintf("books/%d.txt", id)
+}
+
+// Library archive writer.  Implements model.LibraryWriter.
+type Writer struct {
+  gz *gzip.Writer // gzip writer
+  tw *tar.Writer // tar writer
+  now time.Time // archive creation time
+}
+
+// Create library archive writer which writes to the given writer.
+//
+// The caller must call Close() to finish the archive.
+func NewWriter(w io.Writer) *Writer {
+  gz := gzip.NewWriter(w)
+  return &Writer {
+    gz: gz,
+    tw: tar.NewWriter(gz),
+    now: time.Now().UTC(),
+  }
+}
+
+// Write file to archive.
+func (w *Writer) writeFile(path string, data []byte) error {
+  // write header
+  if err := w.tw.WriteHeader(&tar.Header {
+    Typeflag: tar.TypeReg,
+    Name: path,
+    Mode: 0644,
+    Size: int64(len(data)),
+    ModTime: w.now,
+  }); err != nil {
+    return err
+  }
+
+  // write data
+  _, err := w.tw.Write(data)
+  return err
+}
+
+// Write manifest.  Must be called once, before WriteBody().
+func (w *Writer) WriteLibrary(lib model.Library) error {
+  // build manifest
+  m := Manifest {
+    Format: Format,
+    Version: Version,
+    CreatedAt: w.now,
+    Books: make([]ManifestBook, 0, len(lib.Books)),
+    Collections: lib.Collections,
+  }
+  for _, book := range(lib.Books) {
+    m.Books = append(m.Books, ManifestBook { LibraryBook: book, Path: bookPath(book.Id) })
+  }
+  if m.Collections == nil {
+    m.Collections = []model.LibraryCollection{}
+  }
+
+  // encode manifest
+  data, err := json.MarshalIndent(m, "", "  ")
+  if err != nil {
+    return err
+  }
+
+  // write manifest
+  return w.writeFile(manifestPath, data)
+}
+
+// Write body of given book.
+func (w *Writer) WriteBody(id int, body string) error {
+  return w.writeFile(bookPath(id), []byte(body))
+}
+
+// Finish archive.  Does not close the underlying writer.
+func (w *Writer) Close() error {
+  if err := w.tw.Close(); err != nil {
+    return err
+  }
+
+  return w.gz.Close()
+}
+
+// Library archive reader.  Implements model.LibrarySource.
+//
+// Books are read in archive order.  The hash of each book is checked
+// against the manifest as it is read.
+type Reader struct {
+  tr *tar.Reader // tar reader
+  manifest Manifest // manifest
+  books map[string]model.LibraryBook // unread books, by path
+  maxFileSize int64 // maximum size of each book, in bytes
+}
+
+// Create library archive reader which reads from the given reader.
+//
+// Reads and checks the manifest, which must be the first file in the
+// archive.  Books larger than the given maximum size are rejected.
+func NewReader(r io.Reader, maxFileSize int64) (*Reader, error) {
+  // open gzip stream
+  gz, err := gzip.NewReader(r)
+  if err != nil {
+    return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+  }
+  tr := tar.NewReader(gz)
+
+  // read manifest header
+  h, err := tr.Next()
+  if err != nil {
+    return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+  } else if h.Name != manifestPath {
+    return nil, fmt.Errorf("%w: first file is %s, expected %s", ErrInvalid, h.Name, manifestPath)
+  }
+
+  // decode manifest
+  var m Manifest
+  if err := json.NewDecoder(io.LimitReader(tr, maxManifestSize)).Decode(&m); err != nil {
+    return nil, fmt.Errorf("%w: %s: %w", ErrInvalid, manifestPath, err)
+  }
+
+  // check format and version
+  if m.Format != Format {
+    return nil, fmt.Errorf("%w: unknown format: %q", ErrInvalid, m.Format)
+  } else if m.Version != Version {
+    return nil, fmt.Errorf("%w: unsupported version: %d", ErrInvalid, m.Version)
+  }
+
+  // index books by path
+  books := make(map[string]model.LibraryBook, len(m.Books))
+  for _, book := range(m.Books) {
+    if _, ok := books[book.Path]; ok {
+      return nil, fmt.Errorf("%w: duplicate path: %s", ErrInvalid, book.Path)
+    }
+    books[book.Path] = book.LibraryBook
+  }
+
+  // return reader
+  return &Reader {
+    tr: tr,
+    manifest: m,
+    books: books,
+    maxFileSize: maxFileSize,
+  }, nil
+}
+
+// Get archive manifest.
+func (r *Reader) Manifest() Manifest {
+  return r.manifest
+}
+
+// Get library metadata from manifest.
+func (r *Reader) Library() model.Library {
+  books := make([]model.LibraryBook, 0, len(r.manifest.Books))
+  for _, book := range(r.manifest.Books) {
+    books = append(books, book.LibraryBook)
+  }
+
+  return model.Library {
+    Books: books,
+    Collections: r.manifest.Collections,
+  }
+}
+
+// Get metadata and body of next book.  Returns io.EOF if there are no
+// more books.
+//
+// Returns ErrInvalid if a file is not listed in the manifest, if the
+// hash of a book does not match the manifest, or if a book in the
+// manifest is missing from the archive.
+func (r *Reader) Next() (model.LibraryBook, string, error) {
+  // read next header
+  h, err := r.tr.Next()
+  if err == io.EOF {
+    // check for missing books
+    for path := range(r.books) {
+      return model.LibraryBook{}, "", fmt.Errorf("%w: missing file: %s", ErrInvalid, path)
+    }
+
+    return model.LibraryBook{}, "", io.EOF
+  } else if err != nil {
+    return model.LibraryBook{}, "", fmt.Errorf("%w: %w", ErrInvalid, err)
+  }
+
+  // get book
+  book, ok := r.books[h.Name]
+  if !ok {
+    return model.LibraryBook{}, "", fmt.Errorf("%w: unexpected file: %s", ErrInvalid, h.Name)
+  }
+  delete(r.books, h.Name)
+
+  // read body, up to one byte past the size limit
+  data, err := io.ReadAll(io.LimitReader(r.tr, r.maxFileSize + 1))
+  if err != nil {
+    return model.LibraryBook{}, "", fmt.Errorf("%w: %s: %w", ErrInvalid, h.Name, err)
+  } else if int64(len(data)) > r.maxFileSize {
+    return model.LibraryBook{}, "", fmt.Errorf("%w: %s: file is larger than %d bytes", ErrInvalid, h.Name, r.maxFileSize)
+  }
+  body := string(data)
+
+  // check hash
+  if hash := model.BodyHash(body); hash != book.BodySha256 {
+    return model.LibraryBook{}, "", fmt.Errorf("%w: %s: hash mismatch", ErrInvalid, h.Name)
+  }
+
+  // return book
+  return book, body, nil
+}
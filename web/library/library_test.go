@@ -0,0 +1,215 @@
+package library
+
+import (
+  "archive/tar"
+  "bookman/model"
+  "bytes"
+  "compress/gzip"
+  "errors"
+  "io"
+  "reflect"
+  "testing"
+  "time"
+)
+
+// test library
+var testLibrary = model.Library {
+  Books: []model.LibraryBook {
+    model.LibraryBook {
+      Id: 1,
+      Name: "foo",
+      Author: "Jane Doe",
+      Tags: []string { "bar" },
+      CreatedAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+      BodySha256: model.BodyHash("foo body"),
+    },
+    model.LibraryBook {
+      Id: 3,
+      Name: "baz",
+      Author: "John Roe",
+      Tags: []string {},
+      CreatedAt: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+      DeletedAt: &time.Time{},
+      BodySha256: model.BodyHash("baz body"),
+    },
+  },
+  Collections: []model.LibraryCollection {
+    model.LibraryCollection { Name: "blum", Books: []int { 3, 1 } },
+  },
+}
+
+// test library bodies, by book ID
+var testBodies = map[int]string { 1: "foo body", 3: "baz body" }
+
+// Write library archive with the given library and bodies.
+func writeTestArchive(t *testing.T, lib model.Library, bodies map[int]string) []byte {
+  t.Helper()
+
+  var buf bytes.Buffer
+  w := NewWriter(&buf)
+  if err := w.WriteLibrary(lib); err != nil {
+    t.Fatal(err)
+  }
+
+  for _, book := range(lib.Books) {
+    if err := w.WriteBody(book.Id, bodies[book.Id]); err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  if err := w.Close(); err != nil {
+    t.Fatal(err)
+  }
+
+  return buf.Bytes()
+}
+
+// Write tar.gz file containing the given files, in order.
+func writeTarGz(t *testing.T, files [][2]string) []byte {
+  t.Helper()
+
+  var buf bytes.Buffer
+  gz := gzip.NewWriter(&buf)
+  tw := tar.NewWriter(gz)
+  for _, file := range(files) {
+    if err := tw.WriteHeader(&tar.Header { Name: file[0], Mode: 0644, Size: int64(len(file[1])) }); err != nil {
+      t.Fatal(err)
+    }
+    if _, err := tw.Write([]byte(file[1])); err != nil {
+      t.Fatal(err)
+    }
+  }
+  if err := tw.Close(); err != nil {
+    t.Fatal(err)
+  }
+  if err := gz.Close(); err != nil {
+    t.Fatal(err)
+  }
+
+  return buf.Bytes()
+}
+
+// Read all books from library archive.
+func readTestArchive(r *Reader) (map[int]string, error) {
+  bodies := map[int]string{}
+  for {
+    book, body, err := r.Next()
+    if err == io.EOF {
+      return bodies, nil
+    } else if err != nil {
+      return nil, err
+    }
+
+    bodies[book.Id] = body
+  }
+}
+
+func TestRoundTrip(t *testing.T) {
+  data := writeTestArchive(t, testLibrary, testBodies)
+
+  // open archive
+  r, err := NewReader(bytes.NewReader(data), 1000)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // check manifest
+  m := r.Manifest()
+  if m.Format != Format || m.Version != Version || m.CreatedAt.IsZero() {
+    t.Fatalf("got %#v", m)
+  }
+  if m.Books[0].Path != "books/1.txt" || m.Books[1].Path != "books/3.txt" {
+    t.Fatalf("got %#v", m.Books)
+  }
+
+  // check library
+  if got := r.Library(); !reflect.DeepEqual(got, testLibrary) {
+    t.Fatalf("got %#v, exp %#v", got, testLibrary)
+  }
+
+  // check bodies
+  got, err := readTestArchive(r)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if !reflect.DeepEqual(got, testBodies) {
+    t.Fatalf("got %#v, exp %#v", got, testBodies)
+  }
+}
+
+func TestNewReader(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    data []byte // archive
+  } {{
+    name: "not gzip",
+    data: []byte("foo"),
+  }, {
+    name: "empty",
+    data: writeTarGz(t, nil),
+  }, {
+    name: "no manifest",
+    data: writeTarGz(t, [][2]string { { "books/1.txt", "foo" } }),
+  }, {
+    name: "invalid manifest",
+    data: writeTarGz(t, [][2]string { { "manifest.json", "foo" } }),
+  }, {
+    name: "unknown format",
+    data: writeTarGz(t, [][2]string { { "manifest.json", `{"format":"foo","version":1}` } }),
+  }, {
+    name: "unsupported version",
+    data: writeTarGz(t, [][2]string { { "manifest.json", `{"format":"bookman-library","version":2}` } }),
+  }, {
+    name: "duplicate path",
+    data: writeTarGz(t, [][2]string { { "manifest.json", `{"format":"bookman-library","version":1,"books":[{"id":1,"path":"a"},{"id":2,"path":"a"}]}` } }),
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      if _, err := NewReader(bytes.NewReader(test.data), 1000); !errors.Is(err, ErrInvalid) {
+        t.Fatalf("got %v, exp ErrInvalid", err)
+      }
+    })
+  }
+}
+
+func TestReaderNext(t *testing.T) {
+  manifest := `{"format":"bookman-library","version":1,"books":[` +
+    `{"id":1,"path":"books/1.txt","body_sha256":"` + model.BodyHash("foo") + `"}` +
+  `]}`
+
+  tests := []struct {
+    name string // test name
+    files [][2]string // archive files
+    maxFileSize int64 // maximum file size
+  } {{
+    name: "hash mismatch",
+    files: [][2]string { { "manifest.json", manifest }, { "books/1.txt", "bar" } },
+    maxFileSize: 1000,
+  }, {
+    name: "unexpected file",
+    files: [][2]string { { "manifest.json", manifest }, { "books/2.txt", "foo" } },
+    maxFileSize: 1000,
+  }, {
+    name: "missing file",
+    files: [][2]string { { "manifest.json", manifest } },
+    maxFileSize: 1000,
+  }, {
+    name: "file too large",
+    files: [][2]string { { "manifest.json", manifest }, { "books/1.txt", "foo" } },
+    maxFileSize: 2,
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      r, err := NewReader(bytes.NewReader(writeTarGz(t, test.files)), test.maxFileSize)
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if _, err := readTestArchive(r); !errors.Is(err, ErrInvalid) {
+        t.Fatalf("got %v, exp ErrInvalid", err)
+      }
+    })
+  }
+}
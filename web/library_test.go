@@ -0,0 +1,50 @@
+package main
+
+import (
+  "bookman/model"
+  "testing"
+)
+
+func TestParseRestoreArgs(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    tests := []struct {
+      name string // test name
+      args []string // command arguments
+      exp restoreOptions // expected options
+    } {{
+      name: "default",
+      args: []string { "foo.tar.gz" },
+      exp: restoreOptions { path: "foo.tar.gz", conflict: model.ConflictFail, duplicates: model.DuplicateAllow },
+    }, {
+      name: "flags",
+      args: []string { "-conflict", "skip", "-duplicates", "skip", "-partial", "-" },
+      exp: restoreOptions { path: "-", conflict: model.ConflictSkip, duplicates: model.DuplicateSkip, partial: true },
+    }}
+
+    for _, test := range(tests) {
+      t.Run(test.name, func(t *testing.T) {
+        got, err := parseRestoreArgs(test.args)
+        if err != nil {
+          t.Fatal(err)
+        }
+
+        if got != test.exp {
+          t.Fatalf("got %#v, exp %#v", got, test.exp)
+        }
+      })
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    for _, args := range([][]string {
+      []string {},
+      []string { "foo", "bar" },
+      []string { "-conflict", "foo", "foo.tar.gz" },
+      []string { "-duplicates", "foo", "foo.tar.gz" },
+    }) {
+      if got, err := parseRestoreArgs(args); err == nil {
+        t.Fatalf("%v: got %#v, exp err", args, got)
+      }
+    }
+  })
+}
@@ -4,25 +4,125 @@ import (
   "bookman/app"
   "bookman/web"
   "context"
+  "fmt"
+  "log"
   "net/http"
+  "os"
+  "sort"
 )
 
-func main() {
-  // read config from env
-  config := app.NewConfigFromEnv()
+// Command handler.
+type command struct {
+  // argument synopsis and one-line description
+  args, desc string
+
+  // command function
+  fn func(ctx context.Context, config app.Config, args []string) error
+}
+
+// Map of command name to command handler.
+var commands = map[string]command {
+  "serve": command {
+    args: "",
+    desc: "run web server (default)",
+    fn: serve,
+  },
+
+  "migrate": command {
+    args: "[up [version] | down [count] | status]",
+    desc: "apply, revert, or show database schema migrations",
+    fn: migrateCommand,
+  },
+
+  "import": command {
+    args: "[-dry-run] [-batch-size N] [-conflict policy] <dir>",
+    desc: "import books from a directory tree",
+    fn: importCommand,
+  },
+
+  "export": command {
+    args: "[file]",
+    desc: "write library archive of all books to file or standard output",
+    fn: exportCommand,
+  },
+
+  "restore": command {
+    args: "[-conflict policy] [-duplicates policy] [-partial] <file>",
+    desc: "restore books from library archive",
+    fn: restoreCommand,
+  },
+
+  "user": command {
+    args: "(list | add [-role role] <name> | passwd <name> | role <name> <role> | delete <name>)",
+    desc: "list, add, or remove users, or set user password or role (passwords are read from standard input)",
+    fn: userCommand,
+  },
+}
+
+// Print usage to standard error.
+func usage() {
+  // get sorted list of command names
+  names := make([]string, 0, len(commands))
+  for name := range(commands) {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+
+  // print usage
+  fmt.Fprintf(os.Stderr, "Usage: %s [command] [args...]\n\nCommands:\n", os.Args[0])
+  for _, name := range(names) {
+    cmd := commands[name]
+    fmt.Fprintf(os.Stderr, "  %s %s\n    %s\n", name, cmd.args, cmd.desc)
+  }
+}
+
+// Run web server.
+func serve(ctx context.Context, config app.Config, args []string) error {
+  // apply pending migrations
+  if config.MigrateOnStart {
+    if err := migrateUp(ctx, config, 0); err != nil {
+      return err
+    }
+  }
 
   // create application context from context and config
-  appCtx, err := app.NewContext(context.Background(), config)
+  appCtx, err := app.NewContext(ctx, config)
   if err != nil {
-    panic(err)
+    return err
   }
 
   // create web router
   r, err := web.NewRouter(appCtx)
   if err != nil {
-    panic(err)
+    return err
   }
 
   // run http server
-  panic(http.ListenAndServe(config.HttpAddr, r))
+  return http.ListenAndServe(config.HttpAddr, r)
+}
+
+func main() {
+  // read config from env
+  config, err := app.NewConfigFromEnv()
+  if err != nil {
+    log.Fatal(err)
+  }
+
+  // get command name and arguments (default to "serve")
+  name, args := "serve", []string{}
+  if len(os.Args) > 1 {
+    name, args = os.Args[1], os.Args[2:]
+  }
+
+  // get command
+  cmd, ok := commands[name]
+  if !ok {
+    usage()
+    os.Exit(1)
+  }
+
+  // run command
+  if err := cmd.fn(context.Background(), config, args); err != nil {
+    log.Fatal(err)
+  }
 }
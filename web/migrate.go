@@ -0,0 +1,157 @@
+package main
+
+import (
+  "bookman/app"
+  "bookman/migrate"
+  "context"
+  "fmt"
+  "log"
+  "strconv"
+  "time"
+)
+
+// Create migrator from config.
+//
+// The returned close function closes the migration database pool.
+func newMigrator(ctx context.Context, config app.Config) (*migrate.Migrator, func(), error) {
+  // load migrations
+  migrations, err := migrate.Migrations()
+  if err != nil {
+    return nil, nil, err
+  }
+
+  // connect to database
+  pool, err := app.NewMigratePool(ctx, config)
+  if err != nil {
+    return nil, nil, err
+  }
+
+  // create migrator
+  mr := &migrate.Migrator {
+    Pool: pool,
+    Role: config.MigrateRole,
+    Migrations: migrations,
+    Log: func(dir string, m migrate.Migration) {
+      log.Printf("migrate %s: %04d_%s", dir, m.Version, m.Name)
+    },
+  }
+
+  return mr, pool.Close, nil
+}
+
+// Apply pending migrations up to and including the given version (0
+// for all pending migrations).
+func migrateUp(ctx context.Context, config app.Config, target int) error {
+  // create migrator
+  mr, done, err := newMigrator(ctx, config)
+  if err != nil {
+    return err
+  }
+  defer done()
+
+  // apply migrations
+  count, err := mr.Up(ctx, target)
+  if err != nil {
+    return err
+  }
+
+  log.Printf("applied %d migration(s)", count)
+  return nil
+}
+
+// Revert the given number of most recently applied migrations.
+func migrateDown(ctx context.Context, config app.Config, n int) error {
+  // create migrator
+  mr, done, err := newMigrator(ctx, config)
+  if err != nil {
+    return err
+  }
+  defer done()
+
+  // revert migrations
+  count, err := mr.Down(ctx, n)
+  if err != nil {
+    return err
+  }
+
+  log.Printf("reverted %d migration(s)", count)
+  return nil
+}
+
+// Print status of all migrations.
+func migrateStatus(ctx context.Context, config app.Config) error {
+  // create migrator
+  mr, done, err := newMigrator(ctx, config)
+  if err != nil {
+    return err
+  }
+  defer done()
+
+  // get status
+  rows, err := mr.Status(ctx)
+  if err != nil {
+    return err
+  }
+
+  // print status
+  for _, row := range(rows) {
+    appliedAt := "pending"
+    if row.AppliedAt != nil {
+      appliedAt = row.AppliedAt.Format(time.RFC3339)
+    }
+
+    fmt.Printf("%04d_%s\t%s\n", row.Version, row.Name, appliedAt)
+  }
+
+  return nil
+}
+
+// Parse optional integer argument.
+func parseIntArg(args []string, def int) (int, error) {
+  if len(args) == 0 {
+    return def, nil
+  }
+
+  val, err := strconv.Atoi(args[0])
+  if err != nil || val < 0 {
+    return 0, fmt.Errorf("invalid argument: %s", args[0])
+  }
+
+  return val, nil
+}
+
+// migrate command.
+//
+// Usage:
+//
+//   bookman migrate [up [version]]  # apply pending migrations
+//   bookman migrate down [count]    # revert migrations (default: 1)
+//   bookman migrate status          # show migration status
+func migrateCommand(ctx context.Context, config app.Config, args []string) error {
+  // get subcommand (default to "up")
+  sub := "up"
+  if len(args) > 0 {
+    sub, args = args[0], args[1:]
+  }
+
+  switch sub {
+  case "up":
+    target, err := parseIntArg(args, 0)
+    if err != nil {
+      return err
+    }
+
+    return migrateUp(ctx, config, target)
+  case "down":
+    n, err := parseIntArg(args, 1)
+    if err != nil {
+      return err
+    }
+
+    return migrateDown(ctx, config, n)
+  case "status":
+    return migrateStatus(ctx, config)
+  default:
+    return fmt.Errorf("unknown migrate command: %s", sub)
+  }
+}
@@ -0,0 +1,385 @@
+// Versioned database schema migrations.
+//
+// Migrations are embedded in the binary from the `sql/` directory.
+// Each migration consists of a `NNNN_name.up.sql` file and an optional
+// `NNNN_name.down.sql` file, where `NNNN` is the migration version.
+// Migrations without a down file cannot be reverted; in particular
+// the first migration (`0001_books`) has no down file because it
+// creates or upgrades the base `books` table, and reverting it would
+// drop every book.
+//
+// Applied migrations are recorded in the `bookman.schema_migrations`
+// table.  Migrations are applied while holding a session-level
+// advisory lock so that concurrent web server replicas do not race to
+// apply the same migration.
+package migrate
+
+import (
+  "context"
+  "embed"
+  "errors"
+  "fmt"
+  "github.com/jackc/pgx/v5"
+  "github.com/jackc/pgx/v5/pgxpool"
+  io_fs "io/fs"
+  "regexp"
+  "sort"
+  "strconv"
+  "time"
+)
+
+// Schema migration.
+type Migration struct {
+  Version int // migration version
+  Name string // migration name
+  Up string // SQL to apply migration
+  Down string // SQL to revert migration (empty if irreversible)
+}
+
+// Migration status.
+type Status struct {
+  Migration
+
+  // time the migration was applied, or nil if the migration has not
+  // been applied
+  AppliedAt *time.Time
+}
+
+// Returned by Down() when a migration has no down file.
+var ErrIrreversible = errors.New("migration cannot be reverted")
+
+//go:embed sql/*.sql
+var sqlFs embed.FS
+
+// migration file name
+var fileRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Load migrations from the given filesystem.
+//
+// Returns a list of migrations sorted by version, or an error if a
+// file name is invalid, two migrations have the same version, or a
+// migration has a down file but no up file.
+func Load(fsys io_fs.FS) ([]Migration, error) {
+  // read file names
+  paths, err := io_fs.Glob(fsys, "*.sql")
+  if err != nil {
+    return nil, err
+  }
+
+  // build map of version to migration
+  migrations := map[int]*Migration{}
+  for _, path := range(paths) {
+    // parse file name
+    md := fileRe.FindStringSubmatch(path)
+    if md == nil {
+      return nil, fmt.Errorf("%s: invalid migration file name", path)
+    }
+
+    // parse version
+    version, err := strconv.Atoi(md[1])
+    if err != nil {
+      return nil, fmt.Errorf("%s: %w", path, err)
+    }
+
+    // read file
+    data, err := io_fs.ReadFile(fsys, path)
+    if err != nil {
+      return nil, err
+    }
+
+    // get migration, check name
+    m, ok := migrations[version]
+    if !ok {
+      m = &Migration { Version: version, Name: md[2] }
+      migrations[version] = m
+    } else if m.Name != md[2] {
+      return nil, fmt.Errorf("%s: duplicate migration version %d", path, version)
+    }
+
+    // set migration SQL
+    if md[3] == "up" {
+      m.Up = string(data)
+    } else {
+      m.Down = string(data)
+    }
+  }
+
+  // build sorted list of migrations
+  r := make([]Migration, 0, len(migrations))
+  for _, m := range(migrations) {
+    if m.Up == "" {
+      return nil, fmt.Errorf("migration %d (%s): missing up file", m.Version, m.Name)
+    }
+
+    r = append(r, *m)
+  }
+  sort.Slice(r, func(i, j int) bool { return r[i].Version < r[j].Version })
+
+  // return success
+  return r, nil
+}
+
+// Get list of embedded migrations, sorted by version.
+func Migrations() ([]Migration, error) {
+  // get sql directory
+  fsys, err := io_fs.Sub(sqlFs, "sql")
+  if err != nil {
+    return nil, err
+  }
+
+  return Load(fsys)
+}
+
+// Advisory lock key used to serialize migrations (arbitrary value;
+// "bookman" as a big-endian integer).
+const lockKey int64 = 0x626f6f6b6d616e
+
+// create migrations table
+const createTableSql = `
+  CREATE TABLE IF NOT EXISTS bookman.schema_migrations (
+    version INT PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+  );
+
+  COMMENT ON TABLE bookman.schema_migrations IS 'Applied schema migrations';
+`
+
+// Database schema migrator.
+type Migrator struct {
+  // database pool
+  Pool *pgxpool.Pool
+
+  // If not empty, then migrations are applied as this role so that
+  // new database objects are owned by it.
+  Role string
+
+  // list of migrations, sorted by version
+  Migrations []Migration
+
+  // If not nil, then called before each migration is applied or
+  // reverted.
+  Log func(dir string, m Migration)
+}
+
+// Maximum time to wait for the migration advisory lock to be released.
+const unlockTimeout = 10 * time.Second
+
+// Run function on a dedicated connection while holding the migration
+// advisory lock.
+//
+// The lock is released with a separate context so that it is released
+// even if ctx is cancelled.  If the lock cannot be released, then the
+// connection is closed rather than returned to the pool, because a
+// pooled connection which still holds the session-level lock would
+// block every later migrator.
+func (mr *Migrator) withLock(ctx context.Context, fn func(*pgx.Conn) error) (err error) {
+  // acquire connection
+  conn, err := mr.Pool.Acquire(ctx)
+  if err != nil {
+    return err
+  }
+
+  // acquire advisory lock (blocks until other migrators are done)
+  if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+    conn.Release()
+    return fmt.Errorf("pg_advisory_lock(): %w", err)
+  }
+
+  // release advisory lock and connection on return
+  defer func() {
+    unlockCtx, cancel := context.WithTimeout(context.Background(), unlockTimeout)
+    defer cancel()
+
+    if _, unlockErr := conn.Exec(unlockCtx, "SELECT pg_advisory_unlock($1)", lockKey); unlockErr != nil {
+      // close connection so the lock is dropped with the session
+      // (releasing a closed connection removes it from the pool)
+      conn.Conn().Close(unlockCtx)
+
+      if err == nil {
+        err = fmt.Errorf("pg_advisory_unlock(): %w", unlockErr)
+      }
+    }
+
+    conn.Release()
+  }()
+
+  // call function
+  return fn(conn.Conn())
+}
+
+// Run function in a transaction as the migration role.
+func (mr *Migrator) inTx(ctx context.Context, conn *pgx.Conn, fn func(pgx.Tx) error) error {
+  return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+    // set role
+    if mr.Role != "" {
+      sql := "SET LOCAL ROLE " + pgx.Identifier{mr.Role}.Sanitize()
+      if _, err := tx.Exec(ctx, sql); err != nil {
+        return fmt.Errorf("SET ROLE: %w", err)
+      }
+    }
+
+    return fn(tx)
+  })
+}
+
+// Get map of applied migration versions to application times.
+//
+// Creates the migrations table if it does not exist.
+func (mr *Migrator) applied(ctx context.Context, conn *pgx.Conn) (map[int]time.Time, error) {
+  // create migrations table
+  if err := mr.inTx(ctx, conn, func(tx pgx.Tx) error {
+    _, err := tx.Exec(ctx, createTableSql)
+    return err
+  }); err != nil {
+    return nil, fmt.Errorf("create schema_migrations: %w", err)
+  }
+
+  // read applied migrations
+  rows, err := conn.Query(ctx, "SELECT version, applied_at FROM bookman.schema_migrations")
+  if err != nil {
+    return nil, err
+  }
+
+  // build map
+  r := map[int]time.Time{}
+  var version int
+  var appliedAt time.Time
+  if _, err := pgx.ForEachRow(rows, []any { &version, &appliedAt }, func() error {
+    r[version] = appliedAt
+    return nil
+  }); err != nil {
+    return nil, err
+  }
+
+  // return success
+  return r, nil
+}
+
+// Get status of all migrations.
+func (mr *Migrator) Status(ctx context.Context) ([]Status, error) {
+  var r []Status
+
+  err := mr.withLock(ctx, func(conn *pgx.Conn) error {
+    // get applied migrations
+    applied, err := mr.applied(ctx, conn)
+    if err != nil {
+      return err
+    }
+
+    // build status list
+    for _, m := range(mr.Migrations) {
+      st := Status { Migration: m }
+      if t, ok := applied[m.Version]; ok {
+        st.AppliedAt = &t
+      }
+      r = append(r, st)
+    }
+
+    return nil
+  })
+
+  return r, err
+}
+
+// Apply pending migrations up to and including the given version.  If
+// `target` is zero, then all pending migrations are applied.
+//
+// Each migration is applied in its own transaction.  Returns the
+// number of applied migrations.
+func (mr *Migrator) Up(ctx context.Context, target int) (int, error) {
+  count := 0
+
+  err := mr.withLock(ctx, func(conn *pgx.Conn) error {
+    // get applied migrations
+    applied, err := mr.applied(ctx, conn)
+    if err != nil {
+      return err
+    }
+
+    for _, m := range(mr.Migrations) {
+      // skip applied migrations and migrations after target
+      if _, ok := applied[m.Version]; ok {
+        continue
+      } else if target > 0 && m.Version > target {
+        break
+      }
+
+      // log migration
+      if mr.Log != nil {
+        mr.Log("up", m)
+      }
+
+      // apply migration, record version
+      if err := mr.inTx(ctx, conn, func(tx pgx.Tx) error {
+        if _, err := tx.Exec(ctx, m.Up); err != nil {
+          return err
+        }
+
+        _, err := tx.Exec(ctx, "INSERT INTO bookman.schema_migrations(version, name) VALUES ($1, $2)", m.Version, m.Name)
+        return err
+      }); err != nil {
+        return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+      }
+
+      count += 1
+    }
+
+    return nil
+  })
+
+  return count, err
+}
+
+// Revert the given number of most recently applied migrations.
+//
+// Each migration is reverted in its own transaction.  Returns
+// ErrIrreversible if a migration has no down file.  Returns the number
+// of reverted migrations.
+func (mr *Migrator) Down(ctx context.Context, n int) (int, error) {
+  count := 0
+
+  err := mr.withLock(ctx, func(conn *pgx.Conn) error {
+    // get applied migrations
+    applied, err := mr.applied(ctx, conn)
+    if err != nil {
+      return err
+    }
+
+    for i := len(mr.Migrations) - 1; i >= 0 && count < n; i-- {
+      // skip migrations which have not been applied
+      m := mr.Migrations[i]
+      if _, ok := applied[m.Version]; !ok {
+        continue
+      }
+
+      // check for down migration
+      if m.Down == "" {
+        return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, ErrIrreversible)
+      }
+
+      // log migration
+      if mr.Log != nil {
+        mr.Log("down", m)
+      }
+
+      // revert migration, remove version
+      if err := mr.inTx(ctx, conn, func(tx pgx.Tx) error {
+        if _, err := tx.Exec(ctx, m.Down); err != nil {
+          return err
+        }
+
+        _, err := tx.Exec(ctx, "DELETE FROM bookman.schema_migrations WHERE version = $1", m.Version)
+        return err
+      }); err != nil {
+        return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+      }
+
+      count += 1
+    }
+
+    return nil
+  })
+
+  return count, err
+}
@@ -0,0 +1,83 @@
+package migrate
+
+import (
+  "testing"
+  "testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    fsys := fstest.MapFS {
+      "0002_bar.up.sql": &fstest.MapFile { Data: []byte("bar up") },
+      "0001_foo.up.sql": &fstest.MapFile { Data: []byte("foo up") },
+      "0001_foo.down.sql": &fstest.MapFile { Data: []byte("foo down") },
+      "0010_baz.up.sql": &fstest.MapFile { Data: []byte("baz up") },
+    }
+
+    got, err := Load(fsys)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    exp := []Migration {
+      Migration { Version: 1, Name: "foo", Up: "foo up", Down: "foo down" },
+      Migration { Version: 2, Name: "bar", Up: "bar up" },
+      Migration { Version: 10, Name: "baz", Up: "baz up" },
+    }
+
+    if len(got) != len(exp) {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+
+    for i := range(exp) {
+      if got[i] != exp[i] {
+        t.Fatalf("got %#v, exp %#v", got[i], exp[i])
+      }
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    tests := []struct {
+      name string // test name
+      fsys fstest.MapFS // migration files
+    } {{
+      name: "bad name",
+      fsys: fstest.MapFS {
+        "foo.sql": &fstest.MapFile { Data: []byte("foo") },
+      },
+    }, {
+      name: "duplicate version",
+      fsys: fstest.MapFS {
+        "0001_foo.up.sql": &fstest.MapFile { Data: []byte("foo") },
+        "0001_bar.up.sql": &fstest.MapFile { Data: []byte("bar") },
+      },
+    }, {
+      name: "missing up",
+      fsys: fstest.MapFS {
+        "0001_foo.down.sql": &fstest.MapFile { Data: []byte("foo") },
+      },
+    }}
+
+    for _, test := range(tests) {
+      t.Run(test.name, func(t *testing.T) {
+        if got, err := Load(test.fsys); err == nil {
+          t.Fatalf("got %#v, exp err", got)
+        }
+      })
+    }
+  })
+}
+
+func TestMigrations(t *testing.T) {
+  migrations, err := Migrations()
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // check that versions are sequential, starting at 1
+  for i, m := range(migrations) {
+    if m.Version != i + 1 {
+      t.Fatalf("migration %s: got version %d, exp %d", m.Name, m.Version, i + 1)
+    }
+  }
+}
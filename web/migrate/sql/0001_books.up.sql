@@ -0,0 +1,68 @@
+--
+-- Create the books table if it does not exist, then add the columns
+-- which were added after the initial release.
+--
+-- The books table is normally created by `db/scripts/create.sh` when
+-- the database container is first initialized, so this migration only
+-- upgrades existing databases.
+--
+-- This migration has no down file and cannot be reverted, because
+-- reverting it would drop the books table and every book in it.
+--
+
+CREATE TABLE IF NOT EXISTS bookman.books (
+  -- book ID
+  id INT PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+
+  -- book name
+  name TEXT UNIQUE NOT NULL CHECK (LENGTH(name) > 0),
+
+  -- book author
+  author TEXT NOT NULL CHECK (LENGTH(author) > 0),
+
+  -- book content
+  body TEXT NOT NULL,
+
+  -- fts vector
+  ts_vec tsvector GENERATED ALWAYS AS (to_tsvector('english',
+    COALESCE(name, '') || ' ' ||
+    COALESCE(author, '') || ' ' ||
+    COALESCE(body, ''))
+  ) STORED
+);
+
+-- add creation time
+ALTER TABLE bookman.books
+  ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- add deletion time
+ALTER TABLE bookman.books
+  ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- create fts index, unless one was already created by the seed data
+DO $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1
+      FROM pg_indexes
+     WHERE schemaname = 'bookman'
+       AND tablename = 'books'
+       AND indexdef ILIKE '%USING gin%(ts_vec)%'
+  ) THEN
+    CREATE INDEX books_ts_vec_idx ON bookman.books USING GIN (ts_vec);
+  END IF;
+END
+$$;
+
+-- document table and columns
+COMMENT ON TABLE bookman.books IS 'Books';
+COMMENT ON COLUMN bookman.books.id IS 'Book ID';
+COMMENT ON COLUMN bookman.books.name IS 'Book title';
+COMMENT ON COLUMN bookman.books.author IS 'Author name';
+COMMENT ON COLUMN bookman.books.body IS 'Book contents';
+COMMENT ON COLUMN bookman.books.ts_vec IS 'Book FTS vector';
+COMMENT ON COLUMN bookman.books.created_at IS 'Time book was created';
+COMMENT ON COLUMN bookman.books.deleted_at IS 'Time book was moved to trash, or NULL';
+
+-- set privileges
+GRANT SELECT, INSERT, UPDATE, DELETE ON bookman.books TO bookman_web;
@@ -0,0 +1,3 @@
+ALTER TABLE bookman.books
+  DROP COLUMN release_date,
+  DROP COLUMN language;
@@ -0,0 +1,12 @@
+--
+-- Add release date and language columns to books table.
+--
+-- Populated from the Project Gutenberg header of uploaded books.
+--
+
+ALTER TABLE bookman.books
+  ADD COLUMN release_date TEXT NOT NULL DEFAULT '',
+  ADD COLUMN language TEXT NOT NULL DEFAULT '';
+
+COMMENT ON COLUMN bookman.books.release_date IS 'Release date, or empty if unknown';
+COMMENT ON COLUMN bookman.books.language IS 'Language name, or empty if unknown';
@@ -0,0 +1,5 @@
+DROP TRIGGER books_sync_authors ON bookman.books;
+DROP FUNCTION bookman.sync_book_authors();
+DROP TABLE bookman.book_authors;
+DROP TABLE bookman.authors;
+DROP FUNCTION bookman.author_sort_name(TEXT);
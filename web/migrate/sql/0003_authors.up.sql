@@ -0,0 +1,106 @@
+--
+-- Add authors table and many-to-many link between books and authors.
+--
+-- The books.author column is kept as the display text for each book.
+-- It contains one or more author names separated by semicolons, and
+-- the links in book_authors are kept in sync with it by a trigger, so
+-- uploads and edits do not need to manage authors explicitly.
+--
+
+-- Get sort name for author name.
+--
+-- Names which already contain a comma (e.g. "Twain, Mark") and single
+-- word names are returned unchanged.  Otherwise the last word is moved
+-- to the front (e.g. "Mark Twain" -> "Twain, Mark").
+CREATE FUNCTION bookman.author_sort_name(name TEXT) RETURNS TEXT
+  LANGUAGE sql IMMUTABLE STRICT AS $$
+  SELECT CASE WHEN s LIKE '%,%' OR s NOT LIKE '% %' THEN s
+              ELSE regexp_replace(s, '^(.*) (\S+)$', '\2, \1')
+         END
+    FROM (SELECT regexp_replace(TRIM(name), '\s+', ' ', 'g') AS s) normalized
+$$;
+
+CREATE TABLE bookman.authors (
+  -- author ID
+  id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+
+  -- author name
+  name TEXT NOT NULL CHECK (LENGTH(name) > 0),
+
+  -- author sort name
+  sort_name TEXT NOT NULL CHECK (LENGTH(sort_name) > 0),
+
+  -- creation time
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- authors are identified by their sort name, so "Mark Twain" and
+-- "Twain, Mark" are the same author
+CREATE UNIQUE INDEX authors_sort_name_idx ON bookman.authors (LOWER(sort_name));
+
+CREATE TABLE bookman.book_authors (
+  -- book ID
+  book_id INT NOT NULL REFERENCES bookman.books(id) ON DELETE CASCADE,
+
+  -- author ID
+  author_id INT NOT NULL REFERENCES bookman.authors(id) ON DELETE CASCADE,
+
+  -- position of author in list of book authors
+  position INT NOT NULL,
+
+  PRIMARY KEY (book_id, author_id)
+);
+
+CREATE INDEX book_authors_author_id_idx ON bookman.book_authors (author_id);
+
+-- Sync book_authors links for a book with its author column.
+CREATE FUNCTION bookman.sync_book_authors() RETURNS TRIGGER
+  LANGUAGE plpgsql AS $$
+BEGIN
+  -- remove existing links
+  DELETE FROM bookman.book_authors WHERE book_id = NEW.id;
+
+  -- add missing authors
+  INSERT INTO bookman.authors(name, sort_name)
+  SELECT DISTINCT ON (LOWER(bookman.author_sort_name(name)))
+         regexp_replace(name, '\s+', ' ', 'g'),
+         bookman.author_sort_name(name)
+    FROM (SELECT TRIM(name) AS name FROM unnest(string_to_array(NEW.author, ';')) name) names
+   WHERE name <> ''
+      ON CONFLICT ((LOWER(sort_name))) DO NOTHING;
+
+  -- add links
+  INSERT INTO bookman.book_authors(book_id, author_id, position)
+  SELECT NEW.id, authors.id, MIN(names.position)
+    FROM unnest(string_to_array(NEW.author, ';')) WITH ORDINALITY AS names(name, position)
+    JOIN bookman.authors authors
+      ON (LOWER(authors.sort_name) = LOWER(bookman.author_sort_name(names.name)))
+   WHERE TRIM(names.name) <> ''
+   GROUP BY authors.id;
+
+  RETURN NEW;
+END
+$$;
+
+CREATE TRIGGER books_sync_authors
+  AFTER INSERT OR UPDATE OF author ON bookman.books
+  FOR EACH ROW EXECUTE FUNCTION bookman.sync_book_authors();
+
+-- link existing books
+UPDATE bookman.books SET author = author;
+
+-- document tables and columns
+COMMENT ON TABLE bookman.authors IS 'Authors';
+COMMENT ON COLUMN bookman.authors.id IS 'Author ID';
+COMMENT ON COLUMN bookman.authors.name IS 'Author name';
+COMMENT ON COLUMN bookman.authors.sort_name IS 'Author sort name (e.g. "Twain, Mark")';
+COMMENT ON COLUMN bookman.authors.created_at IS 'Time author was created';
+
+COMMENT ON TABLE bookman.book_authors IS 'Book authors';
+COMMENT ON COLUMN bookman.book_authors.book_id IS 'Book ID';
+COMMENT ON COLUMN bookman.book_authors.author_id IS 'Author ID';
+COMMENT ON COLUMN bookman.book_authors.position IS 'Position of author in book author list';
+
+-- set privileges
+GRANT SELECT, INSERT, UPDATE, DELETE ON bookman.authors TO bookman_web;
+GRANT SELECT, INSERT, UPDATE, DELETE ON bookman.book_authors TO bookman_web;
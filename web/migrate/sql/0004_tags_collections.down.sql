@@ -0,0 +1,4 @@
+DROP TABLE bookman.collection_books;
+DROP TABLE bookman.collections;
+DROP TABLE bookman.book_tags;
+DROP TABLE bookman.tags;
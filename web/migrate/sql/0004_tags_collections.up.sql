@@ -0,0 +1,88 @@
+--
+-- Add tags and collections.
+--
+-- Tags are unordered labels which may be attached to any number of
+-- books.  Collections (shelves) are named, ordered lists of books.
+--
+-- Tags and collections are identified by their case-insensitive name,
+-- and are created when a book is first added to them.
+--
+
+CREATE TABLE bookman.tags (
+  -- tag ID
+  id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+
+  -- tag name
+  name TEXT NOT NULL CHECK (LENGTH(name) > 0),
+
+  -- creation time
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX tags_name_idx ON bookman.tags (LOWER(name));
+
+CREATE TABLE bookman.book_tags (
+  -- book ID
+  book_id INT NOT NULL REFERENCES bookman.books(id) ON DELETE CASCADE,
+
+  -- tag ID
+  tag_id INT NOT NULL REFERENCES bookman.tags(id) ON DELETE CASCADE,
+
+  PRIMARY KEY (book_id, tag_id)
+);
+
+CREATE INDEX book_tags_tag_id_idx ON bookman.book_tags (tag_id);
+
+CREATE TABLE bookman.collections (
+  -- collection ID
+  id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+
+  -- collection name
+  name TEXT NOT NULL CHECK (LENGTH(name) > 0),
+
+  -- creation time
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX collections_name_idx ON bookman.collections (LOWER(name));
+
+CREATE TABLE bookman.collection_books (
+  -- collection ID
+  collection_id INT NOT NULL REFERENCES bookman.collections(id) ON DELETE CASCADE,
+
+  -- book ID
+  book_id INT NOT NULL REFERENCES bookman.books(id) ON DELETE CASCADE,
+
+  -- position of book in collection
+  position INT NOT NULL,
+
+  PRIMARY KEY (collection_id, book_id)
+);
+
+CREATE INDEX collection_books_book_id_idx ON bookman.collection_books (book_id);
+
+-- document tables and columns
+COMMENT ON TABLE bookman.tags IS 'Tags';
+COMMENT ON COLUMN bookman.tags.id IS 'Tag ID';
+COMMENT ON COLUMN bookman.tags.name IS 'Tag name';
+COMMENT ON COLUMN bookman.tags.created_at IS 'Time tag was created';
+
+COMMENT ON TABLE bookman.book_tags IS 'Book tags';
+COMMENT ON COLUMN bookman.book_tags.book_id IS 'Book ID';
+COMMENT ON COLUMN bookman.book_tags.tag_id IS 'Tag ID';
+
+COMMENT ON TABLE bookman.collections IS 'Collections (shelves)';
+COMMENT ON COLUMN bookman.collections.id IS 'Collection ID';
+COMMENT ON COLUMN bookman.collections.name IS 'Collection name';
+COMMENT ON COLUMN bookman.collections.created_at IS 'Time collection was created';
+
+COMMENT ON TABLE bookman.collection_books IS 'Books in collections';
+COMMENT ON COLUMN bookman.collection_books.collection_id IS 'Collection ID';
+COMMENT ON COLUMN bookman.collection_books.book_id IS 'Book ID';
+COMMENT ON COLUMN bookman.collection_books.position IS 'Position of book in collection';
+
+-- set privileges
+GRANT SELECT, INSERT, UPDATE, DELETE ON bookman.tags TO bookman_web;
+GRANT SELECT, INSERT, UPDATE, DELETE ON bookman.book_tags TO bookman_web;
+GRANT SELECT, INSERT, UPDATE, DELETE ON bookman.collections TO bookman_web;
+GRANT SELECT, INSERT, UPDATE, DELETE ON bookman.collection_books TO bookman_web;
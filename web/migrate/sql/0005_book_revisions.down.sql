@@ -0,0 +1,3 @@
+DROP TRIGGER books_add_revision ON bookman.books;
+DROP FUNCTION bookman.add_book_revision();
+DROP TABLE bookman.book_revisions;
@@ -0,0 +1,87 @@
+--
+-- Add revision history of book names, authors, and contents.
+--
+-- A revision is added by a trigger whenever a book is created or its
+-- name, author, or body changes, so uploads and edits do not need to
+-- record revisions explicitly.
+--
+-- The body of a revision is only stored if it changed; the body of
+-- any revision is the most recent stored body at or before it.
+--
+-- The actor is read from the `bookman.actor` setting, which the web
+-- interface sets at the start of each transaction.
+--
+
+CREATE TABLE bookman.book_revisions (
+  -- revision ID
+  id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+
+  -- book ID
+  book_id INT NOT NULL REFERENCES bookman.books(id) ON DELETE CASCADE,
+
+  -- book name
+  name TEXT NOT NULL,
+
+  -- book author
+  author TEXT NOT NULL,
+
+  -- book content, or NULL if unchanged from the previous revision
+  body TEXT,
+
+  -- user or client which made the change (may be empty)
+  actor TEXT NOT NULL DEFAULT '',
+
+  -- creation time
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX book_revisions_book_id_idx ON bookman.book_revisions (book_id, id);
+
+-- Add revision for a new or changed book.
+CREATE FUNCTION bookman.add_book_revision() RETURNS TRIGGER
+  LANGUAGE plpgsql AS $$
+BEGIN
+  -- skip updates which do not change the name, author, or body
+  IF TG_OP = 'UPDATE' AND
+     NEW.name = OLD.name AND
+     NEW.author = OLD.author AND
+     NEW.body = OLD.body THEN
+    RETURN NEW;
+  END IF;
+
+  -- add revision
+  INSERT INTO bookman.book_revisions(book_id, name, author, body, actor)
+  VALUES (
+    NEW.id,
+    NEW.name,
+    NEW.author,
+    CASE WHEN TG_OP = 'INSERT' OR NEW.body <> OLD.body THEN NEW.body END,
+    COALESCE(current_setting('bookman.actor', true), '')
+  );
+
+  RETURN NEW;
+END
+$$;
+
+CREATE TRIGGER books_add_revision
+  AFTER INSERT OR UPDATE OF name, author, body ON bookman.books
+  FOR EACH ROW EXECUTE FUNCTION bookman.add_book_revision();
+
+-- add initial revision for existing books
+INSERT INTO bookman.book_revisions(book_id, name, author, body, created_at)
+SELECT id, name, author, body, created_at
+  FROM bookman.books
+ ORDER BY id;
+
+-- document table and columns
+COMMENT ON TABLE bookman.book_revisions IS 'Book revisions';
+COMMENT ON COLUMN bookman.book_revisions.id IS 'Revision ID';
+COMMENT ON COLUMN bookman.book_revisions.book_id IS 'Book ID';
+COMMENT ON COLUMN bookman.book_revisions.name IS 'Book title';
+COMMENT ON COLUMN bookman.book_revisions.author IS 'Author name';
+COMMENT ON COLUMN bookman.book_revisions.body IS 'Book contents, or NULL if unchanged from previous revision';
+COMMENT ON COLUMN bookman.book_revisions.actor IS 'User or client which made the change, or empty if unknown';
+COMMENT ON COLUMN bookman.book_revisions.created_at IS 'Time revision was created';
+
+-- set privileges
+GRANT SELECT, INSERT, UPDATE, DELETE ON bookman.book_revisions TO bookman_web;
@@ -0,0 +1,12 @@
+DROP TRIGGER books_prune_body_signature ON bookman.books;
+DROP FUNCTION bookman.prune_body_signature();
+DROP TABLE bookman.body_signatures;
+
+DROP TRIGGER books_set_body_sha256 ON bookman.books;
+DROP FUNCTION bookman.set_book_body_sha256();
+
+ALTER TABLE bookman.books
+  DROP COLUMN body_sha256;
+
+DROP FUNCTION bookman.body_sha256(TEXT);
+DROP FUNCTION bookman.normalize_body(TEXT);
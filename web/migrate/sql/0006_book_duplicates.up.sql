@@ -0,0 +1,108 @@
+--
+-- Add normalized SHA-256 hash of book contents to books table, and a
+-- cache of book signatures for near-duplicate detection.
+--
+-- The hash is set by a trigger whenever a book is added or its body
+-- changes, so uploads and edits do not need to set it explicitly.  The
+-- body is normalized before it is hashed, so copies of a book which
+-- differ only in line endings, trailing whitespace, or Unicode
+-- normalization have the same hash.
+--
+-- Signatures are keyed by body hash, so books with identical contents
+-- share a signature, and a signature never needs to be updated:
+-- changing the body of a book changes its hash.  Signatures are added
+-- by the web interface whenever a book is added or its body changes,
+-- and signatures of bodies which no longer belong to any book are
+-- removed by a trigger.
+--
+
+-- Normalize book body for hashing.
+--
+-- Normalizes Unicode to NFC and line endings to LF, then removes
+-- trailing spaces and tabs from each line and trailing line breaks
+-- from the body.  Must match model.NormalizeBody().
+CREATE FUNCTION bookman.normalize_body(body TEXT) RETURNS TEXT
+  LANGUAGE sql IMMUTABLE STRICT AS $$
+  SELECT rtrim(
+    regexp_replace(
+      replace(replace(normalize(body, NFC), E'\r\n', E'\n'), E'\r', E'\n'),
+      E'[ \t]+(\n|$)', E'\\1', 'g'
+    ),
+    E'\n'
+  )
+$$;
+
+-- Get hex-encoded SHA-256 hash of normalized book body.  Must match
+-- model.BodyHash().
+CREATE FUNCTION bookman.body_sha256(body TEXT) RETURNS TEXT
+  LANGUAGE sql IMMUTABLE STRICT AS $$
+  SELECT encode(sha256(convert_to(bookman.normalize_body(body), 'UTF8')), 'hex')
+$$;
+
+ALTER TABLE bookman.books
+  ADD COLUMN body_sha256 TEXT NOT NULL DEFAULT '';
+
+-- Set body hash of a new or changed book.
+CREATE FUNCTION bookman.set_book_body_sha256() RETURNS TRIGGER
+  LANGUAGE plpgsql AS $$
+BEGIN
+  NEW.body_sha256 := bookman.body_sha256(NEW.body);
+  RETURN NEW;
+END
+$$;
+
+CREATE TRIGGER books_set_body_sha256
+  BEFORE INSERT OR UPDATE OF body ON bookman.books
+  FOR EACH ROW EXECUTE FUNCTION bookman.set_book_body_sha256();
+
+-- set body hash of existing books
+UPDATE bookman.books
+   SET body_sha256 = bookman.body_sha256(body);
+
+CREATE INDEX books_body_sha256_idx ON bookman.books (body_sha256);
+
+COMMENT ON COLUMN bookman.books.body_sha256 IS 'Hex-encoded SHA-256 hash of normalized book contents';
+
+CREATE TABLE bookman.body_signatures (
+  -- hex-encoded SHA-256 hash of normalized book contents
+  body_sha256 TEXT PRIMARY KEY,
+
+  -- MinHash signature of book contents (empty if the book contains no
+  -- words)
+  signature BIGINT[] NOT NULL
+);
+
+-- Remove signature of the previous body of a changed or removed book,
+-- if no other book has the same body.
+CREATE FUNCTION bookman.prune_body_signature() RETURNS TRIGGER
+  LANGUAGE plpgsql AS $$
+BEGIN
+  -- skip updates which do not change the body hash
+  IF TG_OP = 'UPDATE' AND NEW.body_sha256 = OLD.body_sha256 THEN
+    RETURN NULL;
+  END IF;
+
+  -- remove signature
+  DELETE FROM bookman.body_signatures sigs
+   WHERE sigs.body_sha256 = OLD.body_sha256
+     AND NOT EXISTS (
+       SELECT 1
+         FROM bookman.books books
+        WHERE books.body_sha256 = OLD.body_sha256
+     );
+
+  RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER books_prune_body_signature
+  AFTER UPDATE OF body OR DELETE ON bookman.books
+  FOR EACH ROW EXECUTE FUNCTION bookman.prune_body_signature();
+
+-- document table and columns
+COMMENT ON TABLE bookman.body_signatures IS 'MinHash signatures of book contents, for near-duplicate detection';
+COMMENT ON COLUMN bookman.body_signatures.body_sha256 IS 'Hex-encoded SHA-256 hash of normalized book contents';
+COMMENT ON COLUMN bookman.body_signatures.signature IS 'MinHash signature of book contents';
+
+-- set privileges
+GRANT SELECT, INSERT, DELETE ON bookman.body_signatures TO bookman_web;
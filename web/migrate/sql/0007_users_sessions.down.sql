@@ -0,0 +1,2 @@
+DROP TABLE bookman.sessions;
+DROP TABLE bookman.users;
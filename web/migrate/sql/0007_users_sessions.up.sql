@@ -0,0 +1,59 @@
+--
+-- Add user accounts and login sessions.
+--
+-- Passwords are stored as bcrypt hashes.  Sessions are identified by a
+-- random token which is stored in a cookie; only the SHA-256 hash of
+-- the token is stored, so the contents of the sessions table cannot be
+-- used to log in.
+--
+
+CREATE TABLE bookman.users (
+  -- user ID
+  id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+
+  -- user name
+  name TEXT NOT NULL CHECK (LENGTH(name) > 0),
+
+  -- bcrypt password hash
+  password_hash TEXT NOT NULL,
+
+  -- creation time
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- user names are case-insensitive
+CREATE UNIQUE INDEX users_name_idx ON bookman.users (LOWER(name));
+
+CREATE TABLE bookman.sessions (
+  -- hex-encoded SHA-256 hash of session token
+  token_sha256 TEXT PRIMARY KEY,
+
+  -- user ID
+  user_id INT NOT NULL REFERENCES bookman.users(id) ON DELETE CASCADE,
+
+  -- creation time
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  -- expiration time
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX sessions_user_id_idx ON bookman.sessions (user_id);
+CREATE INDEX sessions_expires_at_idx ON bookman.sessions (expires_at);
+
+-- document tables and columns
+COMMENT ON TABLE bookman.users IS 'User accounts';
+COMMENT ON COLUMN bookman.users.id IS 'User ID';
+COMMENT ON COLUMN bookman.users.name IS 'User name';
+COMMENT ON COLUMN bookman.users.password_hash IS 'bcrypt password hash';
+COMMENT ON COLUMN bookman.users.created_at IS 'Time user was created';
+
+COMMENT ON TABLE bookman.sessions IS 'Login sessions';
+COMMENT ON COLUMN bookman.sessions.token_sha256 IS 'Hex-encoded SHA-256 hash of session token';
+COMMENT ON COLUMN bookman.sessions.user_id IS 'User ID';
+COMMENT ON COLUMN bookman.sessions.created_at IS 'Time session was created';
+COMMENT ON COLUMN bookman.sessions.expires_at IS 'Time session expires';
+
+-- set privileges
+GRANT SELECT, INSERT, UPDATE, DELETE ON bookman.users TO bookman_web;
+GRANT SELECT, INSERT, UPDATE, DELETE ON bookman.sessions TO bookman_web;
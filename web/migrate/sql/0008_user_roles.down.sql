@@ -0,0 +1,2 @@
+ALTER TABLE bookman.users
+  DROP COLUMN role;
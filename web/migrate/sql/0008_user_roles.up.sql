@@ -0,0 +1,18 @@
+--
+-- Add user roles.
+--
+-- Viewers can search and read books, editors can also upload and edit
+-- books, and admins can also delete books and manage users.
+--
+-- Existing users could already make any change, so they are made
+-- admins.  New users are viewers unless a role is given.
+--
+
+ALTER TABLE bookman.users
+  ADD COLUMN role TEXT NOT NULL DEFAULT 'admin'
+    CHECK (role IN ('viewer', 'editor', 'admin'));
+
+ALTER TABLE bookman.users
+  ALTER COLUMN role SET DEFAULT 'viewer';
+
+COMMENT ON COLUMN bookman.users.role IS 'User role (viewer, editor, or admin)';
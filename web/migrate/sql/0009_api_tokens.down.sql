@@ -0,0 +1 @@
+DROP TABLE bookman.api_tokens;
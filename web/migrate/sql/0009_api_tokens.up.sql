@@ -0,0 +1,49 @@
+--
+-- Add personal API tokens.
+--
+-- API tokens let scripts authenticate with an `Authorization: Bearer`
+-- header instead of a session cookie.  As with sessions, only the
+-- SHA-256 hash of each token is stored.
+--
+
+CREATE TABLE bookman.api_tokens (
+  -- token ID
+  id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+
+  -- user ID
+  user_id INT NOT NULL REFERENCES bookman.users(id) ON DELETE CASCADE,
+
+  -- token name (e.g. "upload script")
+  name TEXT NOT NULL CHECK (LENGTH(name) > 0),
+
+  -- hex-encoded SHA-256 hash of token
+  token_sha256 TEXT NOT NULL UNIQUE,
+
+  -- token scope
+  scope TEXT NOT NULL CHECK (scope IN ('read', 'write')),
+
+  -- creation time
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  -- expiration time
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+
+  -- time token was last used, or NULL if it has not been used
+  last_used_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX api_tokens_user_id_idx ON bookman.api_tokens (user_id);
+
+-- document table and columns
+COMMENT ON TABLE bookman.api_tokens IS 'Personal API tokens';
+COMMENT ON COLUMN bookman.api_tokens.id IS 'Token ID';
+COMMENT ON COLUMN bookman.api_tokens.user_id IS 'User ID';
+COMMENT ON COLUMN bookman.api_tokens.name IS 'Token name';
+COMMENT ON COLUMN bookman.api_tokens.token_sha256 IS 'Hex-encoded SHA-256 hash of token';
+COMMENT ON COLUMN bookman.api_tokens.scope IS 'Token scope (read or write)';
+COMMENT ON COLUMN bookman.api_tokens.created_at IS 'Time token was created';
+COMMENT ON COLUMN bookman.api_tokens.expires_at IS 'Time token expires';
+COMMENT ON COLUMN bookman.api_tokens.last_used_at IS 'Time token was last used';
+
+-- set privileges
+GRANT SELECT, INSERT, UPDATE, DELETE ON bookman.api_tokens TO bookman_web;
@@ -0,0 +1,59 @@
+package model
+
+import (
+  "context"
+  "fmt"
+  "github.com/jackc/pgx/v5"
+  "github.com/jackc/pgx/v5/pgxpool"
+  _ "embed"
+)
+
+// actor context key type
+//
+// (note: named type so that the key does not collide with other
+// struct{} context keys)
+type actorCtxKey struct{}
+
+// Create context which records changes made with it as being made by
+// the given actor (e.g. a user name or client address).
+//
+// The actor is saved in the revision history of changed books.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+  return context.WithValue(ctx, actorCtxKey{}, actor)
+}
+
+// Get actor from context, or an empty string if the context does not
+// have an actor.
+func ActorFromContext(ctx context.Context) string {
+  actor, _ := ctx.Value(actorCtxKey{}).(string)
+  return actor
+}
+
+//go:embed sql/set-actor.sql
+var setActorSql string
+
+// Begin transaction and set the actor for the revision history trigger
+// to the actor from the given context.
+//
+// The caller must commit or roll back the returned transaction.
+func begin(ctx context.Context, pool *pgxpool.Pool) (pgx.Tx, error) {
+  // begin transaction
+  tx, err := pool.Begin(ctx)
+  if err != nil {
+    return nil, err
+  }
+
+  // build query args
+  args := pgx.NamedArgs {
+    "actor": ActorFromContext(ctx),
+  }
+
+  // set actor
+  if _, err := tx.Exec(ctx, setActorSql, args); err != nil {
+    tx.Rollback(ctx)
+    return nil, fmt.Errorf("Exec(): %w", err)
+  }
+
+  // return success
+  return tx, nil
+}
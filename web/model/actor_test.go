@@ -0,0 +1,29 @@
+package model
+
+import (
+  "context"
+  "testing"
+)
+
+func TestActorFromContext(t *testing.T) {
+  t.Run("missing", func(t *testing.T) {
+    if got := ActorFromContext(context.Background()); got != "" {
+      t.Fatalf("got %q, exp \"\"", got)
+    }
+  })
+
+  t.Run("actor", func(t *testing.T) {
+    ctx := ContextWithActor(context.Background(), "127.0.0.1")
+    if got, exp := ActorFromContext(ctx), "127.0.0.1"; got != exp {
+      t.Fatalf("got %q, exp %q", got, exp)
+    }
+  })
+
+  t.Run("other struct{} key", func(t *testing.T) {
+    var key struct{}
+    ctx := context.WithValue(context.Background(), key, "foo")
+    if got := ActorFromContext(ctx); got != "" {
+      t.Fatalf("got %q, exp \"\"", got)
+    }
+  })
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+  "encoding/base64"
+  "encoding/json"
+  "time"
+)
+
+// Position of the last book in a page of search results.
+//
+// Encoded as an opaque URL-safe string and passed back to Search() in
+// order to fetch the next page of results.
+type searchCursor struct {
+  Sort SearchSort `json:"s"` // sort order of results
+  Id int `json:"i"` // book ID
+  Text string `json:"t,omitempty"` // text sort key (name and author sort)
+  Rank float64 `json:"r,omitempty"` // search rank (rank sort)
+  Time time.Time `json:"c"` // creation time (created sort)
+}
+
+// Encode cursor as string.
+func (c searchCursor) String() string {
+  // encode cursor as JSON
+  // (note: cannot fail, all fields are JSON-safe)
+  data, _ := json.Marshal(c)
+
+  // return url-safe base64-encoded JSON
+  return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode cursor string for the given sort order.
+//
+// Returns a ValidationError if the cursor string is invalid or if the
+// cursor was created for a different sort order.
+func parseSearchCursor(s string, sort SearchSort) (searchCursor, error) {
+  var c searchCursor
+
+  // decode base64
+  data, err := base64.RawURLEncoding.DecodeString(s)
+  if err != nil {
+    return c, &ValidationError { Field: "cursor", Message: "invalid cursor" }
+  }
+
+  // decode json
+  if err := json.Unmarshal(data, &c); err != nil {
+    return c, &ValidationError { Field: "cursor", Message: "invalid cursor" }
+  }
+
+  // check sort order
+  if c.Sort != sort {
+    return c, &ValidationError { Field: "cursor", Message: "cursor does not match sort order" }
+  }
+
+  // return success
+  return c, nil
+}
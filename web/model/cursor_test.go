@@ -0,0 +1,56 @@
+package model
+
+import (
+  "errors"
+  "strconv"
+  "testing"
+  "time"
+)
+
+func TestSearchCursor(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    tests := []searchCursor {
+      searchCursor { Sort: SortName, Id: 1, Text: "foo" },
+      searchCursor { Sort: SortAuthor, Id: 2, Text: "twain, mark" },
+      searchCursor { Sort: SortAuthor, Id: 5, Text: "anonymous" },
+      searchCursor { Sort: SortRank, Id: 3, Rank: 0.1 },
+      searchCursor { Sort: SortCreated, Id: 4, Time: time.Date(2023, 5, 1, 12, 34, 56, 789000, time.UTC) },
+    }
+
+    for _, exp := range(tests) {
+      t.Run(string(exp.Sort) + " " + strconv.Itoa(exp.Id), func(t *testing.T) {
+        got, err := parseSearchCursor(exp.String(), exp.Sort)
+        if err != nil {
+          t.Fatal(err)
+        }
+
+        if got.Sort != exp.Sort || got.Id != exp.Id || got.Text != exp.Text || got.Rank != exp.Rank || !got.Time.Equal(exp.Time) {
+          t.Fatalf("got %#v, exp %#v", got, exp)
+        }
+      })
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    tests := []struct {
+      name string // test name
+      val string // cursor string
+      sort SearchSort // sort order
+    } {
+      { "base64", "!!!", SortName },
+      { "json", "Zm9v", SortName },
+      { "sort", searchCursor { Sort: SortName, Id: 1 }.String(), SortRank },
+    }
+
+    for _, test := range(tests) {
+      t.Run(test.name, func(t *testing.T) {
+        _, err := parseSearchCursor(test.val, test.sort)
+
+        var verr *ValidationError
+        if !errors.As(err, &verr) {
+          t.Fatalf("got %v, exp ValidationError", err)
+        }
+      })
+    }
+  })
+}
@@ -2,10 +2,14 @@
 package model
 
 import (
+  "bookman/similar"
   "context"
+  "errors"
   "fmt"
   "github.com/jackc/pgx/v5"
   "github.com/jackc/pgx/v5/pgxpool"
+  "io"
+  "time"
   _ "embed"
 )
 
@@ -17,74 +21,154 @@ func NewDbModel() *DbModel {
   return &DbModel{}
 }
 
-//go:embed sql/list.sql
-var listSql string
-
 //go:embed sql/search.sql
 var searchSql string
 
-// Get a list of books.
+//go:embed sql/search-count.sql
+var searchCountSql string
+
+// search result row
+type searchRow struct {
+  Book
+
+  SortText string `db:"sort_text"` // text sort key
+  CreatedAt time.Time `db:"created_at"` // creation time
+}
+
+// Get cursor which points at this row.
+func (row searchRow) cursor(sort SearchSort) searchCursor {
+  c := searchCursor { Sort: sort, Id: row.Id }
+
+  switch sort {
+  case SortRank:
+    c.Rank = row.Rank
+  case SortCreated:
+    c.Time = row.CreatedAt
+  default:
+    c.Text = row.SortText
+  }
+
+  return c
+}
+
+// Get a page of books.
 //
-// If `q` is not empty, then the book name, content, and author are
-// matched against the search string, and the list of results is sorted
-// by relevance.
+// If `params.Query` is not empty, then the book name, content, and
+// author are matched against the search string.  If `params.Query` is
+// empty, then all books are matched.  Tag and collection filters in
+// the search string are applied in addition to the full-text search.
 //
-// If `q` is empty, then the return value is the full list of books,
-// sorted by name.
-func (*DbModel) Search(ctx context.Context, pool *pgxpool.Pool, q string) ([]Book, error) {
-  if len(q) > 0 {
-    // search books by query string
+// Results are paginated with a keyset cursor: the Next field of the
+// result is the position of the last book on the page, and passing it
+// back as `params.Cursor` returns the books which follow it.
+func (*DbModel) Search(ctx context.Context, pool *pgxpool.Pool, params SearchParams) (SearchResult, error) {
+  // split query string into full-text query and filters
+  query := parseSearchQuery(params.Query)
 
-    // build query args
-    args := pgx.NamedArgs {
-      "q": q,
+  // get sort order
+  sort := params.Sort
+  if sort == "" {
+    if len(query.Text) > 0 {
+      sort = SortRank
+    } else {
+      sort = SortName
     }
+  }
 
-    // exec query, get rows
-    rows, err := pool.Query(ctx, searchSql, args)
-    if err != nil {
-      return []Book{}, fmt.Errorf("Query(): %w", err)
-    }
+  // check sort order
+  switch sort {
+  case SortName, SortAuthor, SortRank, SortCreated:
+    // valid sort order
+  default:
+    return SearchResult{}, &ValidationError { Field: "sort", Message: "unknown sort order" }
+  }
 
-    // build results
-    books, err := pgx.CollectRows(rows, pgx.RowToStructByName[Book])
-    if err != nil {
-      return []Book{}, fmt.Errorf("CollectRows(): %w", err)
-    }
+  // get limit
+  limit := params.Limit
+  if limit <= 0 {
+    limit = DefaultSearchLimit
+  } else if limit > MaxSearchLimit {
+    limit = MaxSearchLimit
+  }
 
-    // return success
-    return books, nil
-  } else {
-    // list books by name
+  // build query args
+  // (note: fetch one extra row to check for a next page)
+  args := pgx.NamedArgs {
+    "q": query.Text,
+    "tags": query.Tags,
+    "collections": query.Collections,
+    "sort": string(sort),
+    "limit": limit + 1,
+    "headline": params.Snippets.String(),
+    "after_id": nil,
+    "after_text": nil,
+    "after_rank": nil,
+    "after_time": nil,
+  }
 
-    // exec query, get rows
-    rows, err := pool.Query(ctx, listSql)
+  // decode cursor, add cursor position to query args
+  if len(params.Cursor) > 0 {
+    c, err := parseSearchCursor(params.Cursor, sort)
     if err != nil {
-      return []Book{}, fmt.Errorf("Query(): %w", err)
+      return SearchResult{}, err
     }
 
-    // build results
-    books, err := pgx.CollectRows(rows, pgx.RowToStructByName[Book])
-    if err != nil {
-      return []Book{}, fmt.Errorf("CollectRows(): %w", err)
-    }
+    args["after_id"] = c.Id
+    args["after_text"] = c.Text
+    args["after_rank"] = c.Rank
+    args["after_time"] = c.Time
+  }
 
-    // return success
-    return books, nil
+  // exec query, get rows
+  rows, err := pool.Query(ctx, searchSql, args)
+  if err != nil {
+    return SearchResult{}, fmt.Errorf("Query(): %w", err)
+  }
+
+  // collect rows
+  matches, err := pgx.CollectRows(rows, pgx.RowToStructByName[searchRow])
+  if err != nil {
+    return SearchResult{}, fmt.Errorf("CollectRows(): %w", err)
+  }
+
+  // get total number of matches
+  var total int64
+  if err := pool.QueryRow(ctx, searchCountSql, args).Scan(&total); err != nil {
+    return SearchResult{}, fmt.Errorf("QueryRow(): %w", err)
   }
-}
 
-// book list item
-type FullBook struct {
-  Id int `db:"id" json:"id"` // book ID
-  Name string `db:"name" json:"name"` // book name
-  Author string `db:"author" json:"author"` // author name
-  Body string `db:"body" json:"body"` // book contents
+  // build result
+  r := SearchResult {
+    Books: make([]Book, 0, limit),
+    Total: total,
+  }
+
+  // check for next page
+  if len(matches) > limit {
+    matches = matches[:limit]
+    r.Next = matches[limit - 1].cursor(sort).String()
+  }
+
+  // add books to result
+  for _, row := range(matches) {
+    r.Books = append(r.Books, row.Book)
+  }
+
+  // return success
+  return r, nil
 }
 
 //go:embed sql/text.sql
 var textSql string
 
+// book body row
+type bookBody struct {
+  Id int `db:"id"` // book ID
+  Name string `db:"name"` // book name
+  Author string `db:"author"` // author name
+  Body string `db:"body"` // book contents
+}
+
 // Get body of given book.
 func (*DbModel) Body(ctx context.Context, pool *pgxpool.Pool, id int64) (string, error) {
   // build query args
@@ -99,9 +183,9 @@ func (*DbModel) Body(ctx context.Context, pool *pgxpool.Pool, id int64) (string,
   }
 
   // build results
-  book, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[FullBook])
+  book, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[bookBody])
   if err != nil {
-    return "", fmt.Errorf("CollectOneRow: %w", err)
+    return "", dbError(err)
   }
 
   // return success
@@ -111,36 +195,366 @@ func (*DbModel) Body(ctx context.Context, pool *pgxpool.Pool, id int64) (string,
 //go:embed sql/upload.sql
 var uploadSql string
 
-// Upload slice of books.
-func (*DbModel) Upload(ctx context.Context, pool *pgxpool.Pool, files []UploadedFile) error {
+//go:embed sql/upload-find.sql
+var uploadFindSql string
+
+//go:embed sql/upload-rename.sql
+var uploadRenameSql string
+
+//go:embed sql/replace-body.sql
+var replaceBodySql string
+
+// Replace body of given book within transaction.
+//
+// If restore is true, then the book is also restored from the trash.
+// Otherwise books in the trash are not replaced.
+//
+// Returns ErrNotFound if the book does not exist, or if it is in the
+// trash and restore is false.
+func replaceBody(ctx context.Context, tx pgx.Tx, id int64, body string, restore bool) (UploadResult, error) {
+  // build query args
+  args := pgx.NamedArgs {
+    "id": id,
+    "body": body,
+    "restore": restore,
+  }
+
+  // exec query
+  r := UploadResult { Status: UploadReplaced }
+  if err := tx.QueryRow(ctx, replaceBodySql, args).Scan(&r.Id, &r.Name); err != nil {
+    return UploadResult{}, dbError(err)
+  }
+
+  // cache signature of new body
+  if err := signBook(ctx, tx, int64(r.Id)); err != nil {
+    return UploadResult{}, err
+  }
+
+  // return success
+  return r, nil
+}
+
+//go:embed sql/sign-book.sql
+var signBookSql string
+
+//go:embed sql/add-signature.sql
+var addSignatureSql string
+
+// Convert signature to BIGINT[] database value.
+//
+// (note: values are converted bit-for-bit, because postgres has no
+// unsigned 64-bit integer type)
+func signatureToDb(sig similar.Signature) []int64 {
+  r := make([]int64, len(sig))
+  for i, v := range(sig) {
+    r[i] = int64(v)
+  }
+  return r
+}
+
+// Convert BIGINT[] database value to signature.
+func signatureFromDb(vals []int64) similar.Signature {
+  if len(vals) == 0 {
+    return nil
+  }
+
+  r := make(similar.Signature, len(vals))
+  for i, v := range(vals) {
+    r[i] = uint64(v)
+  }
+  return r
+}
+
+// Add signature to signature cache within transaction, unless the
+// cache already contains a signature for the given body hash.
+func addSignature(ctx context.Context, tx pgx.Tx, hash string, sig similar.Signature) error {
+  // build query args
+  args := pgx.NamedArgs {
+    "body_sha256": hash,
+    "signature": signatureToDb(sig),
+  }
+
+  // exec query
+  if _, err := tx.Exec(ctx, addSignatureSql, args); err != nil {
+    return dbError(err)
+  }
+
+  // return success
+  return nil
+}
+
+// Sign the body of the given book with similar.Sign() and add the
+// signature to the signature cache within transaction, unless the
+// cache already contains a signature for the body.
+//
+// Called whenever a book is added or its body changes, so that
+// Duplicates() does not need to read and sign bodies.  Signatures of
+// bodies which no longer belong to any book are removed by a trigger.
+func signBook(ctx context.Context, tx pgx.Tx, id int64) error {
+  // get body hash and body, unless the body is already signed
+  var hash, body string
+  err := tx.QueryRow(ctx, signBookSql, pgx.NamedArgs { "id": id }).Scan(&hash, &body)
+  if errors.Is(err, pgx.ErrNoRows) {
+    return nil
+  } else if err != nil {
+    return fmt.Errorf("QueryRow(): %w", err)
+  }
+
+  // sign body, cache signature
+  return addSignature(ctx, tx, hash, similar.Sign(body))
+}
+
+//go:embed sql/unsigned-bodies.sql
+var unsignedBodiesSql string
+
+// Sign the bodies without a cached signature within transaction,
+// including the bodies of books in the trash.
+//
+// Each body is read and signed with similar.Sign() once, and bodies
+// are read one at a time, so only the signatures are held in memory.
+// Returns the signatures by body hash.  The signature cache is not
+// changed.
+func signUnsignedBodies(ctx context.Context, tx pgx.Tx) (map[string]similar.Signature, error) {
+  // exec query
+  rows, err := tx.Query(ctx, unsignedBodiesSql)
+  if err != nil {
+    return nil, fmt.Errorf("Query(): %w", err)
+  }
+  defer rows.Close()
+
+  // sign bodies
+  sigs := map[string]similar.Signature{}
+  for rows.Next() {
+    var hash, body string
+    if err := rows.Scan(&hash, &body); err != nil {
+      return nil, fmt.Errorf("Scan(): %w", err)
+    }
+
+    sigs[hash] = similar.Sign(body)
+  }
+  if err := rows.Err(); err != nil {
+    return nil, fmt.Errorf("Next(): %w", err)
+  }
+
+  // return success
+  return sigs, nil
+}
+
+//go:embed sql/upload-find-body.sql
+var uploadFindBodySql string
+
+// Check for an existing book other than the given book with the same
+// body, using the duplicate policy from the given options.
+//
+// Returns a skipped result for the existing book and true if the
+// uploaded book should be skipped, or ErrDuplicateBody if the upload
+// should fail.
+func checkDuplicateBody(ctx context.Context, tx pgx.Tx, body string, excludeId int64, opts UploadOptions) (UploadResult, bool, error) {
+  if opts.Duplicates == DuplicateAllow {
+    return UploadResult{}, false, nil
+  }
+
+  // build query args
+  args := pgx.NamedArgs {
+    "body": body,
+    "exclude_id": excludeId,
+  }
+
+  // find existing book with the same body
+  r := UploadResult { Status: UploadSkipped }
+  err := tx.QueryRow(ctx, uploadFindBodySql, args).Scan(&r.Id, &r.Name)
+  if errors.Is(err, pgx.ErrNoRows) {
+    return UploadResult{}, false, nil
+  } else if err != nil {
+    return UploadResult{}, false, fmt.Errorf("QueryRow(): %w", err)
+  }
+
+  // resolve duplicate
+  if opts.Duplicates == DuplicateSkip {
+    return r, true, nil
+  }
+  return UploadResult{}, false, fmt.Errorf("%w: %s", ErrDuplicateBody, r.Name)
+}
+
+// Upload book within transaction, resolving name conflicts with the
+// conflict policy and duplicate contents with the duplicate policy
+// from the given options.
+func uploadFile(ctx context.Context, tx pgx.Tx, file UploadedFile, opts UploadOptions) (UploadResult, error) {
+  // replace body of given book
+  // (note: name of replacement file is not used)
+  if opts.ReplaceId != 0 {
+    if r, skip, err := checkDuplicateBody(ctx, tx, file.Body, opts.ReplaceId, opts); err != nil || skip {
+      return r, err
+    }
+    return replaceBody(ctx, tx, opts.ReplaceId, file.Body, false)
+  }
+
+  // check name
+  if err := validateName(file.Name); err != nil {
+    return UploadResult{}, err
+  }
+
+  // build query args
+  args := pgx.NamedArgs {
+    "name": file.Name,
+    "author": file.Author,
+    "release_date": file.ReleaseDate,
+    "language": file.Language,
+    "body": file.Body,
+  }
+
+  // check for existing book with the same name
+  r := UploadResult { Name: file.Name, Status: UploadCreated }
+  var existingId int64
+  err := tx.QueryRow(ctx, uploadFindSql, args).Scan(&existingId)
+  if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+    return UploadResult{}, fmt.Errorf("QueryRow(): %w", err)
+  } else if err == nil {
+    // resolve name conflict
+    switch opts.Conflict {
+    case ConflictSkip:
+      return UploadResult { Id: int(existingId), Name: file.Name, Status: UploadSkipped }, nil
+    case ConflictReplace:
+      if r, skip, err := checkDuplicateBody(ctx, tx, file.Body, existingId, opts); err != nil || skip {
+        return r, err
+      }
+      return replaceBody(ctx, tx, existingId, file.Body, true)
+    case ConflictRename:
+      // get unused numbered name
+      err := tx.QueryRow(ctx, uploadRenameSql, args).Scan(&r.Name)
+      if errors.Is(err, pgx.ErrNoRows) {
+        return UploadResult{}, fmt.Errorf("%w: %s", ErrDuplicate, file.Name)
+      } else if err != nil {
+        return UploadResult{}, fmt.Errorf("QueryRow(): %w", err)
+      }
+
+      args["name"] = r.Name
+      r.Status = UploadRenamed
+    default:
+      return UploadResult{}, fmt.Errorf("%w: %s", ErrDuplicate, file.Name)
+    }
+  }
+
+  // check for existing book with the same body
+  if r, skip, err := checkDuplicateBody(ctx, tx, file.Body, 0, opts); err != nil || skip {
+    return r, err
+  }
+
+  // add book
+  if err := tx.QueryRow(ctx, uploadSql, args).Scan(&r.Id); err != nil {
+    return UploadResult{}, dbError(err)
+  }
+
+  // cache signature of body
+  if err := signBook(ctx, tx, int64(r.Id)); err != nil {
+    return UploadResult{}, err
+  }
+
+  // return success
+  return r, nil
+}
+
+// Run function within a savepoint of the given transaction.
+//
+// If the function fails, then the savepoint is rolled back and a
+// failed result with the given name and the error is returned, so the
+// transaction can continue.  Only errors from creating or releasing
+// the savepoint are returned.
+func withSavepoint(ctx context.Context, tx pgx.Tx, name string, fn func(pgx.Tx) (UploadResult, error)) (UploadResult, error) {
+  // create savepoint
+  sp, err := tx.Begin(ctx)
+  if err != nil {
+    return UploadResult{}, err
+  }
+
+  // call function
+  r, err := fn(sp)
+  if err != nil {
+    // roll back to savepoint
+    if rollbackErr := sp.Rollback(ctx); rollbackErr != nil {
+      return UploadResult{}, rollbackErr
+    }
+
+    // return failure as result
+    return UploadResult { Name: name, Status: UploadFailed, Err: err }, nil
+  }
+
+  // release savepoint, return result
+  return r, sp.Commit(ctx)
+}
+
+// Upload book within a savepoint of the given transaction.
+//
+// If the upload fails, then the savepoint is rolled back and the error
+// is returned in the result, so the transaction can continue.  Only
+// errors from creating or releasing the savepoint are returned.
+func uploadFileSavepoint(ctx context.Context, tx pgx.Tx, file UploadedFile, opts UploadOptions) (UploadResult, error) {
+  return withSavepoint(ctx, tx, file.Name, func(sp pgx.Tx) (UploadResult, error) {
+    return uploadFile(ctx, sp, file, opts)
+  })
+}
+
+// Upload books from the given source in a single transaction.
+//
+// Each book is read from the source and inserted before the next book
+// is read, so only one book is held in memory at a time.
+func (*DbModel) Upload(ctx context.Context, pool *pgxpool.Pool, src UploadSource, opts UploadOptions) ([]UploadResult, error) {
+  // check options
+  if err := validateUploadOptions(opts); err != nil {
+    return nil, err
+  }
+
   // begin transaction
-  tx, err := pool.Begin(ctx)
+  tx, err := begin(ctx, pool)
   if err != nil {
-    return err
+    return nil, err
   }
+  defer tx.Rollback(ctx)
 
-  for i := range(files) {
-    // build query args
-    args := pgx.NamedArgs {
-      "name": files[i].Name,
-      "body": files[i].Body,
+  // upload files
+  var results []UploadResult
+  for {
+    // read next file
+    file, err := src.Next()
+    if err == io.EOF {
+      break
+    } else if err != nil {
+      return nil, err
+    }
+
+    // check for more than one replacement file
+    if err := validateReplaceCount(opts, len(results) + 1); err != nil {
+      return nil, err
     }
 
     // upload file
-    _, err := tx.Exec(ctx, uploadSql, args)
+    var r UploadResult
+    if opts.Partial {
+      r, err = uploadFileSavepoint(ctx, tx, file, opts)
+    } else {
+      r, err = uploadFile(ctx, tx, file, opts)
+    }
     if err != nil {
-      // rollback transaction
-      if rollback_err := tx.Rollback(ctx); rollback_err != nil {
-        // FIXME: should probably just log the rollback error
-        return rollback_err
-      } else {
-        return err
-      }
+      return nil, err
     }
+
+    results = append(results, r)
   }
 
-  // commit changes, return result
-  return tx.Commit(ctx)
+  // check for missing replacement file
+  if opts.ReplaceId != 0 {
+    if err := validateReplaceCount(opts, len(results)); err != nil {
+      return nil, err
+    }
+  }
+
+  // commit changes
+  if err := tx.Commit(ctx); err != nil {
+    return nil, err
+  }
+
+  // return success
+  return results, nil
 }
 
 //go:embed sql/edit.sql
@@ -148,6 +562,14 @@ var editSql string
 
 // Set the name and author of the given book.
 func (*DbModel) Edit(ctx context.Context, pool *pgxpool.Pool, id int64, name, author string) error {
+  // check name and author
+  if err := validateName(name); err != nil {
+    return err
+  }
+  if err := validateAuthor(author); err != nil {
+    return err
+  }
+
   // build query args
   args := pgx.NamedArgs {
     "id": id,
@@ -155,7 +577,1316 @@ func (*DbModel) Edit(ctx context.Context, pool *pgxpool.Pool, id int64, name, au
     "author": author,
   }
 
+  // begin transaction
+  tx, err := begin(ctx, pool)
+  if err != nil {
+    return err
+  }
+  defer tx.Rollback(ctx)
+
+  // exec query
+  if err := checkRowsAffected(tx.Exec(ctx, editSql, args)); err != nil {
+    return err
+  }
+
+  // commit changes, return result
+  return tx.Commit(ctx)
+}
+
+//go:embed sql/delete.sql
+var deleteSql string
+
+// Move the given book to the trash.
+func (*DbModel) Delete(ctx context.Context, pool *pgxpool.Pool, id int64) error {
+  // build query args
+  args := pgx.NamedArgs {
+    "id": id,
+  }
+
+  // exec query
+  return checkRowsAffected(pool.Exec(ctx, deleteSql, args))
+}
+
+//go:embed sql/restore.sql
+var restoreSql string
+
+// Restore the given book from the trash.
+func (*DbModel) Restore(ctx context.Context, pool *pgxpool.Pool, id int64) error {
+  // build query args
+  args := pgx.NamedArgs {
+    "id": id,
+  }
+
+  // exec query
+  return checkRowsAffected(pool.Exec(ctx, restoreSql, args))
+}
+
+//go:embed sql/purge.sql
+var purgeSql string
+
+// Permanently remove the given book from the trash.
+func (*DbModel) Purge(ctx context.Context, pool *pgxpool.Pool, id int64) error {
+  // build query args
+  args := pgx.NamedArgs {
+    "id": id,
+  }
+
   // exec query
-  _, err := pool.Exec(ctx, editSql, args)
-  return err
+  return checkRowsAffected(pool.Exec(ctx, purgeSql, args))
+}
+
+//go:embed sql/trash.sql
+var trashSql string
+
+// Get a list of books in the trash, sorted by deletion time in
+// descending order.
+func (*DbModel) Trash(ctx context.Context, pool *pgxpool.Pool) ([]Book, error) {
+  // exec query, get rows
+  rows, err := pool.Query(ctx, trashSql)
+  if err != nil {
+    return []Book{}, fmt.Errorf("Query(): %w", err)
+  }
+
+  // build results
+  books, err := pgx.CollectRows(rows, pgx.RowToStructByName[Book])
+  if err != nil {
+    return []Book{}, fmt.Errorf("CollectRows(): %w", err)
+  }
+
+  // return success
+  return books, nil
+}
+
+//go:embed sql/get.sql
+var getSql string
+
+// Get metadata and body of given book.
+func (*DbModel) Get(ctx context.Context, pool *pgxpool.Pool, id int64) (FullBook, error) {
+  // build query args
+  args := pgx.NamedArgs {
+    "id": id,
+  }
+
+  // exec query, get rows
+  rows, err := pool.Query(ctx, getSql, args)
+  if err != nil {
+    return FullBook{}, fmt.Errorf("Query(): %w", err)
+  }
+
+  // build result
+  book, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[FullBook])
+  if err != nil {
+    return FullBook{}, dbError(err)
+  }
+
+  // return success
+  return book, nil
+}
+
+//go:embed sql/authors.sql
+var authorsSql string
+
+// Get a list of authors with at least one book which is not in the
+// trash, sorted by sort name.
+func (*DbModel) Authors(ctx context.Context, pool *pgxpool.Pool) ([]Author, error) {
+  // exec query, get rows
+  rows, err := pool.Query(ctx, authorsSql)
+  if err != nil {
+    return []Author{}, fmt.Errorf("Query(): %w", err)
+  }
+
+  // build results
+  authors, err := pgx.CollectRows(rows, pgx.RowToStructByName[Author])
+  if err != nil {
+    return []Author{}, fmt.Errorf("CollectRows(): %w", err)
+  }
+
+  // return success
+  return authors, nil
+}
+
+//go:embed sql/author.sql
+var authorSql string
+
+//go:embed sql/author-books.sql
+var authorBooksSql string
+
+// Get the given author and their books, excluding books in the trash.
+func (*DbModel) Author(ctx context.Context, pool *pgxpool.Pool, id int64) (AuthorDetail, error) {
+  // build query args
+  args := pgx.NamedArgs {
+    "id": id,
+  }
+
+  // get author
+  rows, err := pool.Query(ctx, authorSql, args)
+  if err != nil {
+    return AuthorDetail{}, fmt.Errorf("Query(): %w", err)
+  }
+  author, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[Author])
+  if errors.Is(err, pgx.ErrNoRows) {
+    return AuthorDetail{}, ErrAuthorNotFound
+  } else if err != nil {
+    return AuthorDetail{}, fmt.Errorf("CollectOneRow(): %w", err)
+  }
+
+  // get books
+  rows, err = pool.Query(ctx, authorBooksSql, args)
+  if err != nil {
+    return AuthorDetail{}, fmt.Errorf("Query(): %w", err)
+  }
+  books, err := pgx.CollectRows(rows, pgx.RowToStructByName[Book])
+  if err != nil {
+    return AuthorDetail{}, fmt.Errorf("CollectRows(): %w", err)
+  }
+
+  // return success
+  return AuthorDetail { Author: author, Books: books }, nil
+}
+
+//go:embed sql/merge-authors-lock.sql
+var mergeAuthorsLockSql string
+
+//go:embed sql/merge-authors.sql
+var mergeAuthorsSql string
+
+//go:embed sql/delete-authors.sql
+var deleteAuthorsSql string
+
+// Merge the given duplicate authors into the author with the given ID.
+func (*DbModel) MergeAuthors(ctx context.Context, pool *pgxpool.Pool, id int64, dups []int64) error {
+  // check duplicate author IDs
+  if len(dups) == 0 {
+    return &ValidationError { Field: "merge", Message: "must not be empty" }
+  }
+  ids := map[int64]bool { id: true }
+  for _, dup := range(dups) {
+    if dup == id {
+      return &ValidationError { Field: "merge", Message: "must not contain author" }
+    }
+    ids[dup] = true
+  }
+
+  // build query args
+  args := pgx.NamedArgs {
+    "id": id,
+    "dups": dups,
+  }
+
+  // begin transaction
+  tx, err := begin(ctx, pool)
+  if err != nil {
+    return err
+  }
+  defer tx.Rollback(ctx)
+
+  // lock authors, check that they exist
+  rows, err := tx.Query(ctx, mergeAuthorsLockSql, args)
+  if err != nil {
+    return fmt.Errorf("Query(): %w", err)
+  }
+  found, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+  if err != nil {
+    return fmt.Errorf("CollectRows(): %w", err)
+  } else if len(found) != len(ids) {
+    return ErrAuthorNotFound
+  }
+
+  // re-point books to author
+  if _, err := tx.Exec(ctx, mergeAuthorsSql, args); err != nil {
+    return dbError(err)
+  }
+
+  // remove duplicate authors
+  if _, err := tx.Exec(ctx, deleteAuthorsSql, args); err != nil {
+    return dbError(err)
+  }
+
+  // commit changes, return result
+  return tx.Commit(ctx)
+}
+
+//go:embed sql/labels.sql
+var labelsSql string
+
+// Get tags and collections of given book.
+func (*DbModel) Labels(ctx context.Context, pool *pgxpool.Pool, id int64) (Labels, error) {
+  // build query args
+  args := pgx.NamedArgs {
+    "id": id,
+  }
+
+  // exec query, get rows
+  rows, err := pool.Query(ctx, labelsSql, args)
+  if err != nil {
+    return Labels{}, fmt.Errorf("Query(): %w", err)
+  }
+
+  // build result
+  labels, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[Labels])
+  if err != nil {
+    return Labels{}, dbError(err)
+  }
+
+  // return success
+  return labels, nil
+}
+
+//go:embed sql/tags.sql
+var tagsSql string
+
+// Get a list of tags with at least one book which is not in the trash,
+// sorted by name.
+func (*DbModel) Tags(ctx context.Context, pool *pgxpool.Pool) ([]Tag, error) {
+  // exec query, get rows
+  rows, err := pool.Query(ctx, tagsSql)
+  if err != nil {
+    return []Tag{}, fmt.Errorf("Query(): %w", err)
+  }
+
+  // build results
+  tags, err := pgx.CollectRows(rows, pgx.RowToStructByName[Tag])
+  if err != nil {
+    return []Tag{}, fmt.Errorf("CollectRows(): %w", err)
+  }
+
+  // return success
+  return tags, nil
+}
+
+// Exec query which adds book to named tag or collection and returns the
+// book ID, or no rows if the book does not exist.
+func addLabel(ctx context.Context, pool *pgxpool.Pool, sql string, args pgx.NamedArgs) error {
+  // exec query, get rows
+  rows, err := pool.Query(ctx, sql, args)
+  if err != nil {
+    return fmt.Errorf("Query(): %w", err)
+  }
+
+  // check for book
+  if _, err := pgx.CollectOneRow(rows, pgx.RowTo[int32]); err != nil {
+    return dbError(err)
+  }
+
+  // return success
+  return nil
+}
+
+//go:embed sql/add-tag.sql
+var addTagSql string
+
+// Add tag to given book.
+func (*DbModel) AddTag(ctx context.Context, pool *pgxpool.Pool, id int64, tag string) error {
+  // check tag
+  tag, err := normalizeLabel("tag", tag)
+  if err != nil {
+    return err
+  }
+
+  // build query args
+  args := pgx.NamedArgs {
+    "id": id,
+    "tag": tag,
+  }
+
+  // exec query
+  return addLabel(ctx, pool, addTagSql, args)
+}
+
+//go:embed sql/remove-tag.sql
+var removeTagSql string
+
+// Remove tag from given book.
+func (*DbModel) RemoveTag(ctx context.Context, pool *pgxpool.Pool, id int64, tag string) error {
+  // check tag
+  tag, err := normalizeLabel("tag", tag)
+  if err != nil {
+    return err
+  }
+
+  // build query args
+  args := pgx.NamedArgs {
+    "id": id,
+    "tag": tag,
+  }
+
+  // exec query
+  return notFoundAs(checkRowsAffected(pool.Exec(ctx, removeTagSql, args)), ErrTagNotFound)
+}
+
+//go:embed sql/collections.sql
+var collectionsSql string
+
+// Get a list of collections, sorted by name.
+func (*DbModel) Collections(ctx context.Context, pool *pgxpool.Pool) ([]Collection, error) {
+  // exec query, get rows
+  rows, err := pool.Query(ctx, collectionsSql)
+  if err != nil {
+    return []Collection{}, fmt.Errorf("Query(): %w", err)
+  }
+
+  // build results
+  collections, err := pgx.CollectRows(rows, pgx.RowToStructByName[Collection])
+  if err != nil {
+    return []Collection{}, fmt.Errorf("CollectRows(): %w", err)
+  }
+
+  // return success
+  return collections, nil
+}
+
+//go:embed sql/collection.sql
+var collectionSql string
+
+//go:embed sql/collection-books.sql
+var collectionBooksSql string
+
+// Get the given collection and its books, excluding books in the
+// trash.
+func (*DbModel) Collection(ctx context.Context, pool *pgxpool.Pool, id int64) (CollectionDetail, error) {
+  // build query args
+  args := pgx.NamedArgs {
+    "id": id,
+  }
+
+  // get collection
+  rows, err := pool.Query(ctx, collectionSql, args)
+  if err != nil {
+    return CollectionDetail{}, fmt.Errorf("Query(): %w", err)
+  }
+  collection, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[Collection])
+  if errors.Is(err, pgx.ErrNoRows) {
+    return CollectionDetail{}, ErrCollectionNotFound
+  } else if err != nil {
+    return CollectionDetail{}, fmt.Errorf("CollectOneRow(): %w", err)
+  }
+
+  // get books
+  rows, err = pool.Query(ctx, collectionBooksSql, args)
+  if err != nil {
+    return CollectionDetail{}, fmt.Errorf("Query(): %w", err)
+  }
+  books, err := pgx.CollectRows(rows, pgx.RowToStructByName[Book])
+  if err != nil {
+    return CollectionDetail{}, fmt.Errorf("CollectRows(): %w", err)
+  }
+
+  // return success
+  return CollectionDetail { Collection: collection, Books: books }, nil
+}
+
+//go:embed sql/add-to-collection.sql
+var addToCollectionSql string
+
+// Add given book to the end of the named collection.
+func (*DbModel) AddToCollection(ctx context.Context, pool *pgxpool.Pool, id int64, collection string) error {
+  // check collection
+  collection, err := normalizeLabel("collection", collection)
+  if err != nil {
+    return err
+  }
+
+  // build query args
+  args := pgx.NamedArgs {
+    "id": id,
+    "collection": collection,
+  }
+
+  // exec query
+  return addLabel(ctx, pool, addToCollectionSql, args)
+}
+
+//go:embed sql/remove-from-collection.sql
+var removeFromCollectionSql string
+
+// Remove given book from the named collection.
+func (*DbModel) RemoveFromCollection(ctx context.Context, pool *pgxpool.Pool, id int64, collection string) error {
+  // check collection
+  collection, err := normalizeLabel("collection", collection)
+  if err != nil {
+    return err
+  }
+
+  // build query args
+  args := pgx.NamedArgs {
+    "id": id,
+    "collection": collection,
+  }
+
+  // exec query
+  return notFoundAs(checkRowsAffected(pool.Exec(ctx, removeFromCollectionSql, args)), ErrCollectionNotFound)
+}
+
+//go:embed sql/sort-collection.sql
+var sortCollectionSql string
+
+// Reorder the books in the given collection.
+func (*DbModel) SortCollection(ctx context.Context, pool *pgxpool.Pool, id int64, books []int64) error {
+  // remove duplicate book IDs
+  // (note: non-nil so that an empty list is not sent as NULL)
+  ids := []int64{}
+  seen := map[int64]bool{}
+  for _, book := range(books) {
+    if !seen[book] {
+      seen[book] = true
+      ids = append(ids, book)
+    }
+  }
+
+  // build query args
+  args := pgx.NamedArgs {
+    "id": id,
+    "books": ids,
+  }
+
+  // exec query, get rows
+  // (note: checks for the collection rather than the number of rows
+  // affected, so that sorting an empty collection succeeds)
+  rows, err := pool.Query(ctx, sortCollectionSql, args)
+  if err != nil {
+    return fmt.Errorf("Query(): %w", err)
+  }
+
+  // check for collection
+  if _, err := pgx.CollectOneRow(rows, pgx.RowTo[int32]); err != nil {
+    return notFoundAs(dbError(err), ErrCollectionNotFound)
+  }
+
+  // return success
+  return nil
+}
+
+//go:embed sql/delete-collection.sql
+var deleteCollectionSql string
+
+// Delete the given collection.
+func (*DbModel) DeleteCollection(ctx context.Context, pool *pgxpool.Pool, id int64) error {
+  // build query args
+  args := pgx.NamedArgs {
+    "id": id,
+  }
+
+  // exec query
+  return notFoundAs(checkRowsAffected(pool.Exec(ctx, deleteCollectionSql, args)), ErrCollectionNotFound)
+}
+
+//go:embed sql/revisions.sql
+var revisionsSql string
+
+// Get revision history of given book, newest first.
+func (*DbModel) Revisions(ctx context.Context, pool *pgxpool.Pool, id int64) ([]Revision, error) {
+  // build query args
+  args := pgx.NamedArgs {
+    "id": id,
+  }
+
+  // exec query, get rows
+  rows, err := pool.Query(ctx, revisionsSql, args)
+  if err != nil {
+    return []Revision{}, fmt.Errorf("Query(): %w", err)
+  }
+
+  // build results
+  revisions, err := pgx.CollectRows(rows, pgx.RowToStructByName[Revision])
+  if err != nil {
+    return []Revision{}, fmt.Errorf("CollectRows(): %w", err)
+  }
+
+  // every book has at least one revision, so no revisions means that
+  // the book does not exist or is in the trash
+  if len(revisions) == 0 {
+    return []Revision{}, ErrNotFound
+  }
+
+  // return success
+  return revisions, nil
+}
+
+//go:embed sql/revision.sql
+var revisionSql string
+
+// Get given revision of given book, including the book body as of the
+// revision.
+func (*DbModel) Revision(ctx context.Context, pool *pgxpool.Pool, id, rev int64) (RevisionDetail, error) {
+  // build query args
+  args := pgx.NamedArgs {
+    "id": id,
+    "revision": rev,
+  }
+
+  // exec query, get rows
+  rows, err := pool.Query(ctx, revisionSql, args)
+  if err != nil {
+    return RevisionDetail{}, fmt.Errorf("Query(): %w", err)
+  }
+
+  // build result
+  revision, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[RevisionDetail])
+  if err != nil {
+    return RevisionDetail{}, notFoundAs(dbError(err), ErrRevisionNotFound)
+  }
+
+  // return success
+  return revision, nil
+}
+
+//go:embed sql/revert.sql
+var revertSql string
+
+// Restore the name, author, and body of the given book from the given
+// revision.
+func (*DbModel) Revert(ctx context.Context, pool *pgxpool.Pool, id, rev int64) error {
+  // build query args
+  args := pgx.NamedArgs {
+    "id": id,
+    "revision": rev,
+  }
+
+  // begin transaction
+  tx, err := begin(ctx, pool)
+  if err != nil {
+    return err
+  }
+  defer tx.Rollback(ctx)
+
+  // exec query
+  if err := checkRowsAffected(tx.Exec(ctx, revertSql, args)); err != nil {
+    return notFoundAs(err, ErrRevisionNotFound)
+  }
+
+  // cache signature of restored body
+  if err := signBook(ctx, tx, id); err != nil {
+    return err
+  }
+
+  // commit changes, return result
+  return tx.Commit(ctx)
+}
+
+//go:embed sql/body-hashes.sql
+var bodyHashesSql string
+
+// Get the distinct body hashes of all books, including books in the
+// trash.
+func (*DbModel) BodyHashes(ctx context.Context, pool *pgxpool.Pool) ([]string, error) {
+  // exec query, get rows
+  rows, err := pool.Query(ctx, bodyHashesSql)
+  if err != nil {
+    return []string{}, fmt.Errorf("Query(): %w", err)
+  }
+
+  // build results
+  hashes, err := pgx.CollectRows(rows, pgx.RowTo[string])
+  if err != nil {
+    return []string{}, fmt.Errorf("CollectRows(): %w", err)
+  }
+
+  // return success
+  return hashes, nil
+}
+
+//go:embed sql/library-books.sql
+var libraryBooksSql string
+
+//go:embed sql/library-collections.sql
+var libraryCollectionsSql string
+
+//go:embed sql/library-bodies.sql
+var libraryBodiesSql string
+
+// Export metadata and contents of all books, including books in the
+// trash, to the given writer.
+func (*DbModel) ExportLibrary(ctx context.Context, pool *pgxpool.Pool, w LibraryWriter) error {
+  // begin read-only transaction
+  // (note: repeatable read, so that the metadata and bodies are read
+  // from the same snapshot)
+  tx, err := pool.BeginTx(ctx, pgx.TxOptions {
+    IsoLevel: pgx.RepeatableRead,
+    AccessMode: pgx.ReadOnly,
+  })
+  if err != nil {
+    return fmt.Errorf("BeginTx(): %w", err)
+  }
+  defer tx.Rollback(ctx)
+
+  // get books
+  rows, err := tx.Query(ctx, libraryBooksSql)
+  if err != nil {
+    return fmt.Errorf("Query(): %w", err)
+  }
+  books, err := pgx.CollectRows(rows, pgx.RowToStructByName[LibraryBook])
+  if err != nil {
+    return fmt.Errorf("CollectRows(): %w", err)
+  }
+
+  // get collections
+  rows, err = tx.Query(ctx, libraryCollectionsSql)
+  if err != nil {
+    return fmt.Errorf("Query(): %w", err)
+  }
+  collections, err := pgx.CollectRows(rows, pgx.RowToStructByName[LibraryCollection])
+  if err != nil {
+    return fmt.Errorf("CollectRows(): %w", err)
+  }
+
+  // write metadata
+  if err := w.WriteLibrary(Library { Books: books, Collections: collections }); err != nil {
+    return err
+  }
+
+  // get bodies
+  rows, err = tx.Query(ctx, libraryBodiesSql)
+  if err != nil {
+    return fmt.Errorf("Query(): %w", err)
+  }
+  defer rows.Close()
+
+  // write bodies, one at a time
+  for rows.Next() {
+    var id int
+    var body string
+    if err := rows.Scan(&id, &body); err != nil {
+      return fmt.Errorf("Scan(): %w", err)
+    }
+
+    if err := w.WriteBody(id, body); err != nil {
+      return err
+    }
+  }
+
+  // check for error
+  if err := rows.Err(); err != nil {
+    return fmt.Errorf("Next(): %w", err)
+  }
+
+  // return success
+  return nil
+}
+
+//go:embed sql/library-set-created.sql
+var librarySetCreatedSql string
+
+//go:embed sql/library-trash.sql
+var libraryTrashSql string
+
+// Restore book within transaction.
+//
+// The book is uploaded with the given conflict policy.  Tags are added
+// to created, renamed, and replaced books, and the creation time of
+// created and renamed books is set from the book metadata.
+func restoreBook(ctx context.Context, tx pgx.Tx, book LibraryBook, body string, opts UploadOptions) (UploadResult, error) {
+  // upload book
+  r, err := uploadFile(ctx, tx, UploadedFile {
+    Name: book.Name,
+    Author: book.Author,
+    ReleaseDate: book.ReleaseDate,
+    Language: book.Language,
+    Body: body,
+  }, opts)
+  if err != nil || r.Status == UploadSkipped {
+    return r, err
+  }
+
+  // add tags
+  for _, tag := range(book.Tags) {
+    tag, err := normalizeLabel("tag", tag)
+    if err != nil {
+      return UploadResult{}, err
+    }
+
+    args := pgx.NamedArgs { "id": r.Id, "tag": tag }
+    if _, err := tx.Exec(ctx, addTagSql, args); err != nil {
+      return UploadResult{}, dbError(err)
+    }
+  }
+
+  // set creation time of new book
+  if r.Status != UploadReplaced {
+    args := pgx.NamedArgs { "id": r.Id, "created_at": book.CreatedAt }
+    if err := checkRowsAffected(tx.Exec(ctx, librarySetCreatedSql, args)); err != nil {
+      return UploadResult{}, err
+    }
+  }
+
+  // return success
+  return r, nil
+}
+
+// Restore books from the given source in a single transaction.
+//
+// Each book is read from the source and restored before the next book
+// is read, so only one book is held in memory at a time.  Books are
+// added to collections, and books which were in the trash are moved to
+// the trash, after all books have been restored.
+func (*DbModel) RestoreLibrary(ctx context.Context, pool *pgxpool.Pool, src LibrarySource, opts UploadOptions) ([]UploadResult, error) {
+  // check options
+  if err := validateUploadOptions(opts); err != nil {
+    return nil, err
+  } else if opts.ReplaceId != 0 {
+    return nil, &ValidationError { Field: "id", Message: "not supported for restore" }
+  }
+
+  // begin transaction
+  tx, err := begin(ctx, pool)
+  if err != nil {
+    return nil, err
+  }
+  defer tx.Rollback(ctx)
+
+  // restore books
+  var results []UploadResult
+  ids := map[int]int{} // map of exported book ID to restored book ID
+  trash := map[int]time.Time{} // deletion time of new books which were in the trash, by restored book ID
+  for {
+    // read next book
+    book, body, err := src.Next()
+    if err == io.EOF {
+      break
+    } else if err != nil {
+      return nil, err
+    }
+
+    // restore book
+    var r UploadResult
+    if opts.Partial {
+      r, err = withSavepoint(ctx, tx, book.Name, func(sp pgx.Tx) (UploadResult, error) {
+        return restoreBook(ctx, sp, book, body, opts)
+      })
+    } else {
+      r, err = restoreBook(ctx, tx, book, body, opts)
+    }
+    if err != nil {
+      return nil, err
+    }
+    results = append(results, r)
+
+    // map exported ID to restored ID
+    switch r.Status {
+    case UploadCreated, UploadRenamed:
+      ids[book.Id] = r.Id
+      if book.DeletedAt != nil {
+        trash[r.Id] = *book.DeletedAt
+      }
+    case UploadReplaced:
+      ids[book.Id] = r.Id
+    }
+  }
+
+  // add restored books to collections
+  for _, c := range(src.Library().Collections) {
+    name, err := normalizeLabel("collection", c.Name)
+    if err != nil {
+      return nil, err
+    }
+
+    for _, id := range(c.Books) {
+      if newId, ok := ids[id]; ok {
+        args := pgx.NamedArgs { "id": newId, "collection": name }
+        if _, err := tx.Exec(ctx, addToCollectionSql, args); err != nil {
+          return nil, dbError(err)
+        }
+      }
+    }
+  }
+
+  // move new books which were in the trash to the trash
+  for id, deletedAt := range(trash) {
+    args := pgx.NamedArgs { "id": id, "deleted_at": deletedAt }
+    if _, err := tx.Exec(ctx, libraryTrashSql, args); err != nil {
+      return nil, dbError(err)
+    }
+  }
+
+  // commit changes
+  if err := tx.Commit(ctx); err != nil {
+    return nil, err
+  }
+
+  // return success
+  return results, nil
+}
+
+//go:embed sql/duplicates.sql
+var duplicatesSql string
+
+// Find books with identical or similar contents, excluding books in
+// the trash.
+//
+// Books with identical contents are grouped by body hash.  The
+// signature of each group is added to a similarity index, so near
+// duplicates are found without comparing every pair of books.
+//
+// Signatures are read from the signature cache.  Bodies without a
+// cached signature are signed in memory by signUnsignedBodies(), but
+// the signatures are not cached, so the report does not change the
+// database.
+func (*DbModel) Duplicates(ctx context.Context, pool *pgxpool.Pool, threshold float64) (Duplicates, error) {
+  // check threshold
+  if threshold == 0 {
+    threshold = DefaultDuplicateThreshold
+  } else if threshold < 0 || threshold > 1 {
+    return Duplicates{}, &ValidationError { Field: "threshold", Message: "must be between 0 and 1" }
+  }
+
+  // begin read-only transaction
+  // (note: repeatable read, so that the books and bodies are read from
+  // the same snapshot)
+  tx, err := pool.BeginTx(ctx, pgx.TxOptions {
+    IsoLevel: pgx.RepeatableRead,
+    AccessMode: pgx.ReadOnly,
+  })
+  if err != nil {
+    return Duplicates{}, fmt.Errorf("BeginTx(): %w", err)
+  }
+  defer tx.Rollback(ctx)
+
+  // sign bodies without a cached signature
+  newSigs, err := signUnsignedBodies(ctx, tx)
+  if err != nil {
+    return Duplicates{}, err
+  }
+
+  // exec query
+  rows, err := tx.Query(ctx, duplicatesSql)
+  if err != nil {
+    return Duplicates{}, fmt.Errorf("Query(): %w", err)
+  }
+  defer rows.Close()
+
+  // group books by body hash, get signature of first book in each
+  // group
+  var hashes []string // body hashes, in order of first book
+  groups := map[string][]Book{} // books, by body hash
+  index := similar.NewIndex()
+  for rows.Next() {
+    var book Book
+    var hash string
+    var vals []int64
+    if err := rows.Scan(&book.Id, &book.Name, &book.Author, &hash, &vals); err != nil {
+      return Duplicates{}, fmt.Errorf("Scan(): %w", err)
+    }
+
+    if _, ok := groups[hash]; !ok {
+      hashes = append(hashes, hash)
+
+      // get cached signature, or signature signed above
+      sig, ok := newSigs[hash]
+      if !ok {
+        sig = signatureFromDb(vals)
+      }
+
+      index.Add(book.Id, sig)
+    }
+    groups[hash] = append(groups[hash], book)
+  }
+  if err := rows.Err(); err != nil {
+    return Duplicates{}, fmt.Errorf("Next(): %w", err)
+  }
+
+  // build exact duplicate groups
+  r := Duplicates { Exact: []DuplicateGroup{}, Near: []DuplicatePair{} }
+  books := map[int]Book{} // first book in each group, by ID
+  for _, hash := range(hashes) {
+    group := groups[hash]
+    books[group[0].Id] = group[0]
+    if len(group) > 1 {
+      r.Exact = append(r.Exact, DuplicateGroup { BodySha256: hash, Books: group })
+    }
+  }
+
+  // build near duplicate pairs
+  for _, p := range(index.Pairs(threshold)) {
+    r.Near = append(r.Near, DuplicatePair {
+      Similarity: p.Similarity,
+      Books: [2]Book { books[p.A], books[p.B] },
+    })
+  }
+
+  // return success
+  return r, nil
+}
+
+//go:embed sql/add-user.sql
+var addUserSql string
+
+// Add user with the given name, role, and password.
+func (*DbModel) AddUser(ctx context.Context, pool *pgxpool.Pool, name string, role Role, password string) (User, error) {
+  // check name and role
+  if err := validateUserName(name); err != nil {
+    return User{}, err
+  }
+  if err := validateRole(role); err != nil {
+    return User{}, err
+  }
+
+  // check and hash password
+  hash, err := hashPassword(password)
+  if err != nil {
+    return User{}, err
+  }
+
+  // build query args
+  args := pgx.NamedArgs {
+    "name": name,
+    "role": role,
+    "password_hash": hash,
+  }
+
+  // exec query, get user
+  rows, err := pool.Query(ctx, addUserSql, args)
+  if err != nil {
+    return User{}, fmt.Errorf("Query(): %w", err)
+  }
+  user, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[User])
+  if err = dbError(err); errors.Is(err, ErrDuplicate) {
+    return User{}, fmt.Errorf("%w: %s", ErrDuplicateUser, name)
+  } else if err != nil {
+    return User{}, err
+  }
+
+  // return success
+  return user, nil
+}
+
+//go:embed sql/users.sql
+var usersSql string
+
+// Get all users, sorted by name.
+func (*DbModel) Users(ctx context.Context, pool *pgxpool.Pool) ([]User, error) {
+  // exec query, get rows
+  rows, err := pool.Query(ctx, usersSql)
+  if err != nil {
+    return []User{}, fmt.Errorf("Query(): %w", err)
+  }
+
+  // build results
+  users, err := pgx.CollectRows(rows, pgx.RowToStructByName[User])
+  if err != nil {
+    return []User{}, fmt.Errorf("CollectRows(): %w", err)
+  }
+
+  // return success
+  return users, nil
+}
+
+//go:embed sql/set-role.sql
+var setRoleSql string
+
+// Set role of the given user.
+func (*DbModel) SetRole(ctx context.Context, pool *pgxpool.Pool, name string, role Role) error {
+  // check role
+  if err := validateRole(role); err != nil {
+    return err
+  }
+
+  // set role
+  var id int
+  args := pgx.NamedArgs { "name": name, "role": role }
+  if err := pool.QueryRow(ctx, setRoleSql, args).Scan(&id); err != nil {
+    return notFoundAs(dbError(err), ErrUserNotFound)
+  }
+
+  // return success
+  return nil
+}
+
+//go:embed sql/delete-user.sql
+var deleteUserSql string
+
+// Remove the given user and their sessions.
+func (*DbModel) DeleteUser(ctx context.Context, pool *pgxpool.Pool, name string) error {
+  // remove user (sessions are removed by cascade)
+  var id int
+  if err := pool.QueryRow(ctx, deleteUserSql, pgx.NamedArgs { "name": name }).Scan(&id); err != nil {
+    return notFoundAs(dbError(err), ErrUserNotFound)
+  }
+
+  // return success
+  return nil
+}
+
+//go:embed sql/set-password.sql
+var setPasswordSql string
+
+//go:embed sql/delete-user-sessions.sql
+var deleteUserSessionsSql string
+
+// Set password of the given user and remove all of their sessions.
+func (*DbModel) SetPassword(ctx context.Context, pool *pgxpool.Pool, name, password string) error {
+  // check and hash password
+  hash, err := hashPassword(password)
+  if err != nil {
+    return err
+  }
+
+  // begin transaction
+  tx, err := pool.Begin(ctx)
+  if err != nil {
+    return err
+  }
+  defer tx.Rollback(ctx)
+
+  // set password, get user ID
+  var id int
+  args := pgx.NamedArgs { "name": name, "password_hash": hash }
+  if err := tx.QueryRow(ctx, setPasswordSql, args).Scan(&id); err != nil {
+    return notFoundAs(dbError(err), ErrUserNotFound)
+  }
+
+  // remove sessions
+  if _, err := tx.Exec(ctx, deleteUserSessionsSql, pgx.NamedArgs { "user_id": id }); err != nil {
+    return dbError(err)
+  }
+
+  // commit changes
+  return tx.Commit(ctx)
+}
+
+//go:embed sql/login.sql
+var loginSql string
+
+//go:embed sql/delete-expired-sessions.sql
+var deleteExpiredSessionsSql string
+
+//go:embed sql/add-session.sql
+var addSessionSql string
+
+// login query result row
+type loginRow struct {
+  User
+
+  PasswordHash string `db:"password_hash"` // bcrypt password hash
+}
+
+// Check user name and password, then create a session which expires
+// after the given duration.  Expired sessions are removed.
+func (*DbModel) Login(ctx context.Context, pool *pgxpool.Pool, name, password string, ttl time.Duration) (Session, error) {
+  // get user and password hash
+  // (note: a missing user is checked against a dummy hash, so that
+  // failed logins take the same time whether or not the user exists)
+  rows, err := pool.Query(ctx, loginSql, pgx.NamedArgs { "name": name })
+  if err != nil {
+    return Session{}, fmt.Errorf("Query(): %w", err)
+  }
+  row, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[loginRow])
+  if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+    return Session{}, dbError(err)
+  }
+
+  // check password
+  if !checkPassword(row.PasswordHash, password) {
+    return Session{}, ErrInvalidLogin
+  }
+
+  // generate session token
+  token, err := newToken()
+  if err != nil {
+    return Session{}, err
+  }
+  s := Session {
+    Token: token,
+    User: row.User,
+    ExpiresAt: time.Now().Add(ttl),
+  }
+
+  // begin transaction
+  tx, err := pool.Begin(ctx)
+  if err != nil {
+    return Session{}, err
+  }
+  defer tx.Rollback(ctx)
+
+  // remove expired sessions
+  if _, err := tx.Exec(ctx, deleteExpiredSessionsSql); err != nil {
+    return Session{}, dbError(err)
+  }
+
+  // add session
+  args := pgx.NamedArgs {
+    "token_sha256": tokenHash(token),
+    "user_id": s.User.Id,
+    "expires_at": s.ExpiresAt,
+  }
+  if _, err := tx.Exec(ctx, addSessionSql, args); err != nil {
+    return Session{}, dbError(err)
+  }
+
+  // commit changes
+  if err := tx.Commit(ctx); err != nil {
+    return Session{}, err
+  }
+
+  // return success
+  return s, nil
+}
+
+//go:embed sql/logout.sql
+var logoutSql string
+
+// Remove the session with the given token.
+func (*DbModel) Logout(ctx context.Context, pool *pgxpool.Pool, token string) error {
+  _, err := pool.Exec(ctx, logoutSql, pgx.NamedArgs { "token_sha256": tokenHash(token) })
+  return dbError(err)
+}
+
+//go:embed sql/session-user.sql
+var sessionUserSql string
+
+// Get the user of the session with the given token.
+func (*DbModel) SessionUser(ctx context.Context, pool *pgxpool.Pool, token string) (User, error) {
+  rows, err := pool.Query(ctx, sessionUserSql, pgx.NamedArgs { "token_sha256": tokenHash(token) })
+  if err != nil {
+    return User{}, fmt.Errorf("Query(): %w", err)
+  }
+  user, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[User])
+  if err != nil {
+    return User{}, notFoundAs(dbError(err), ErrSessionNotFound)
+  }
+
+  return user, nil
+}
+
+//go:embed sql/add-token.sql
+var addTokenSql string
+
+// Create API token for the given user.
+func (*DbModel) CreateToken(ctx context.Context, pool *pgxpool.Pool, userId int, name string, scope TokenScope, ttl time.Duration) (ApiToken, error) {
+  // check name, scope, and lifetime
+  if err := validateToken(name, scope, ttl); err != nil {
+    return ApiToken{}, err
+  }
+
+  // generate token
+  token, err := newApiToken()
+  if err != nil {
+    return ApiToken{}, err
+  }
+
+  // build query args
+  args := pgx.NamedArgs {
+    "user_id": userId,
+    "name": name,
+    "token_sha256": tokenHash(token),
+    "scope": scope,
+    "expires_at": time.Now().Add(ttl),
+  }
+
+  // exec query, get token
+  rows, err := pool.Query(ctx, addTokenSql, args)
+  if err != nil {
+    return ApiToken{}, fmt.Errorf("Query(): %w", err)
+  }
+  t, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[ApiToken])
+  if err != nil {
+    return ApiToken{}, notFoundAs(dbError(err), ErrUserNotFound)
+  }
+
+  // return success
+  t.Token = token
+  return t, nil
+}
+
+//go:embed sql/tokens.sql
+var tokensSql string
+
+// Get API tokens of the given user, newest first.
+func (*DbModel) Tokens(ctx context.Context, pool *pgxpool.Pool, userId int) ([]ApiToken, error) {
+  // exec query, get rows
+  rows, err := pool.Query(ctx, tokensSql, pgx.NamedArgs { "user_id": userId })
+  if err != nil {
+    return []ApiToken{}, fmt.Errorf("Query(): %w", err)
+  }
+
+  // build results
+  tokens, err := pgx.CollectRows(rows, pgx.RowToStructByName[ApiToken])
+  if err != nil {
+    return []ApiToken{}, fmt.Errorf("CollectRows(): %w", err)
+  }
+
+  // return success
+  return tokens, nil
+}
+
+//go:embed sql/revoke-token.sql
+var revokeTokenSql string
+
+// Revoke API token of the given user.
+func (*DbModel) RevokeToken(ctx context.Context, pool *pgxpool.Pool, userId int, id int64) error {
+  var got int
+  args := pgx.NamedArgs { "user_id": userId, "id": id }
+  if err := pool.QueryRow(ctx, revokeTokenSql, args).Scan(&got); err != nil {
+    return notFoundAs(dbError(err), ErrTokenNotFound)
+  }
+
+  // return success
+  return nil
+}
+
+//go:embed sql/token-user.sql
+var tokenUserSql string
+
+// token user query result row
+type tokenUserRow struct {
+  ApiToken
+
+  UserId int `db:"user_id"` // user ID
+  UserName string `db:"user_name"` // user name
+  UserRole Role `db:"user_role"` // user role
+  UserCreatedAt time.Time `db:"user_created_at"` // user creation time
+}
+
+// Get the given API token and its user, and record that the token was
+// used.
+func (*DbModel) TokenUser(ctx context.Context, pool *pgxpool.Pool, token string) (ApiToken, User, error) {
+  // exec query, get row
+  rows, err := pool.Query(ctx, tokenUserSql, pgx.NamedArgs { "token_sha256": tokenHash(token) })
+  if err != nil {
+    return ApiToken{}, User{}, fmt.Errorf("Query(): %w", err)
+  }
+  row, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[tokenUserRow])
+  if err != nil {
+    return ApiToken{}, User{}, notFoundAs(dbError(err), ErrTokenNotFound)
+  }
+
+  // build user
+  user := User {
+    Id: row.UserId,
+    Name: row.UserName,
+    Role: row.UserRole,
+    CreatedAt: row.UserCreatedAt,
+  }
+
+  // return success
+  return row.ApiToken, user, nil
+}
+
+// Add the signatures of all books whose body has no cached signature
+// to the signature cache, including books in the trash.  Returns the
+// number of signatures added.
+func (*DbModel) SignBodies(ctx context.Context, pool *pgxpool.Pool) (int, error) {
+  // begin transaction
+  tx, err := begin(ctx, pool)
+  if err != nil {
+    return 0, err
+  }
+  defer tx.Rollback(ctx)
+
+  // sign bodies without a cached signature
+  sigs, err := signUnsignedBodies(ctx, tx)
+  if err != nil {
+    return 0, err
+  }
+
+  // cache signatures
+  for hash, sig := range(sigs) {
+    if err := addSignature(ctx, tx, hash, sig); err != nil {
+      return 0, err
+    }
+  }
+
+  // commit changes, return result
+  if err := tx.Commit(ctx); err != nil {
+    return 0, err
+  }
+  return len(sigs), nil
 }
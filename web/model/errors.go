@@ -0,0 +1,213 @@
+package model
+
+import (
+  "errors"
+  "fmt"
+  "github.com/jackc/pgx/v5"
+  "github.com/jackc/pgx/v5/pgconn"
+  "strings"
+  "unicode/utf8"
+)
+
+// Returned when the requested book does not exist.
+var ErrNotFound = errors.New("not found")
+
+// Returned when the requested author does not exist.
+//
+// Wraps ErrNotFound, so errors.Is(err, ErrNotFound) is also true.
+var ErrAuthorNotFound = fmt.Errorf("author %w", ErrNotFound)
+
+// Returned when the requested tag does not exist or is not attached
+// to the given book.
+//
+// Wraps ErrNotFound, so errors.Is(err, ErrNotFound) is also true.
+var ErrTagNotFound = fmt.Errorf("tag %w", ErrNotFound)
+
+// Returned when the requested collection does not exist or does not
+// contain the given book.
+//
+// Wraps ErrNotFound, so errors.Is(err, ErrNotFound) is also true.
+var ErrCollectionNotFound = fmt.Errorf("collection %w", ErrNotFound)
+
+// Returned when the requested revision does not exist or belongs to
+// another book.
+//
+// Wraps ErrNotFound, so errors.Is(err, ErrNotFound) is also true.
+var ErrRevisionNotFound = fmt.Errorf("revision %w", ErrNotFound)
+
+// Returned when the requested user does not exist.
+//
+// Wraps ErrNotFound, so errors.Is(err, ErrNotFound) is also true.
+var ErrUserNotFound = fmt.Errorf("user %w", ErrNotFound)
+
+// Returned when a session does not exist or has expired.
+//
+// Wraps ErrNotFound, so errors.Is(err, ErrNotFound) is also true.
+var ErrSessionNotFound = fmt.Errorf("session %w", ErrNotFound)
+
+// Returned when an API token does not exist, has expired, or belongs
+// to another user.
+//
+// Wraps ErrNotFound, so errors.Is(err, ErrNotFound) is also true.
+var ErrTokenNotFound = fmt.Errorf("API token %w", ErrNotFound)
+
+// Returned when a book name is already in use.
+var ErrDuplicate = errors.New("duplicate name")
+
+// Returned when an uploaded book has the same contents as an existing
+// book.
+var ErrDuplicateBody = errors.New("duplicate body")
+
+// Returned when a user name is already in use.
+//
+// Wraps ErrDuplicate, so errors.Is(err, ErrDuplicate) is also true.
+var ErrDuplicateUser = fmt.Errorf("user %w", ErrDuplicate)
+
+// Returned when a user name or password is incorrect.
+var ErrInvalidLogin = errors.New("invalid user name or password")
+
+// Returned when a value is missing or invalid.
+type ValidationError struct {
+  Field string // name of invalid field
+  Message string // description of problem
+}
+
+// Get validation error message.
+func (e *ValidationError) Error() string {
+  return fmt.Sprintf("invalid %s: %s", e.Field, e.Message)
+}
+
+// postgres error codes
+//
+// ref: https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+  pgNotNullViolation = "23502"
+  pgUniqueViolation = "23505"
+  pgCheckViolation = "23514"
+)
+
+// Convert database error to model error.
+//
+// Maps pgx.ErrNoRows to ErrNotFound, unique constraint violations to
+// ErrDuplicate, and check and not-null constraint violations to a
+// ValidationError.  Other errors are returned unchanged.
+func dbError(err error) error {
+  if err == nil {
+    return nil
+  }
+
+  // map missing rows to ErrNotFound
+  if errors.Is(err, pgx.ErrNoRows) {
+    return ErrNotFound
+  }
+
+  // map constraint violations
+  var pgErr *pgconn.PgError
+  if errors.As(err, &pgErr) {
+    switch pgErr.Code {
+    case pgUniqueViolation:
+      return fmt.Errorf("%w: %s", ErrDuplicate, pgErr.Detail)
+    case pgCheckViolation, pgNotNullViolation:
+      field := pgErr.ColumnName
+      if field == "" {
+        field = pgErr.ConstraintName
+      }
+
+      return &ValidationError { Field: field, Message: pgErr.Message }
+    }
+  }
+
+  // return other errors unchanged
+  return err
+}
+
+// Return ErrNotFound if the command tag indicates that no rows were
+// changed.
+func checkRowsAffected(tag pgconn.CommandTag, err error) error {
+  if err != nil {
+    return dbError(err)
+  } else if tag.RowsAffected() == 0 {
+    return ErrNotFound
+  }
+
+  return nil
+}
+
+// Replace ErrNotFound with the given, more specific, not found error.
+// Other errors are returned unchanged.
+func notFoundAs(err, notFound error) error {
+  if errors.Is(err, ErrNotFound) {
+    return notFound
+  }
+
+  return err
+}
+
+// Check that a book name is not empty.
+func validateName(name string) error {
+  if len(name) == 0 {
+    return &ValidationError { Field: "name", Message: "must not be empty" }
+  }
+
+  return nil
+}
+
+// Check that an author name is not empty.
+func validateAuthor(author string) error {
+  if len(author) == 0 {
+    return &ValidationError { Field: "author", Message: "must not be empty" }
+  }
+
+  return nil
+}
+
+// Check upload options.
+func validateUploadOptions(opts UploadOptions) error {
+  // check conflict policy
+  switch opts.Conflict {
+  case "", ConflictFail, ConflictSkip, ConflictReplace, ConflictRename:
+    // valid conflict policy
+  default:
+    return &ValidationError { Field: "conflict", Message: "unknown conflict policy" }
+  }
+
+  // check duplicate policy
+  switch opts.Duplicates {
+  case "", DuplicateFail, DuplicateSkip, DuplicateAllow:
+    // valid duplicate policy
+  default:
+    return &ValidationError { Field: "duplicates", Message: "unknown duplicate policy" }
+  }
+
+  return nil
+}
+
+// Check that a replacement upload (an upload with a non-zero
+// `UploadOptions.ReplaceId`) contains exactly one book.
+func validateReplaceCount(opts UploadOptions, count int) error {
+  if opts.ReplaceId != 0 && count != 1 {
+    return &ValidationError { Field: "id", Message: "must upload exactly one file" }
+  }
+
+  return nil
+}
+
+// Maximum length of tag and collection names, in characters.
+const maxLabelLength = 100
+
+// Normalize tag or collection name by collapsing whitespace, then check
+// that it is not empty and not too long.
+//
+// Returns the normalized name, or a ValidationError for the given
+// field.
+func normalizeLabel(field, name string) (string, error) {
+  name = strings.Join(strings.Fields(name), " ")
+
+  if len(name) == 0 {
+    return "", &ValidationError { Field: field, Message: "must not be empty" }
+  } else if utf8.RuneCountInString(name) > maxLabelLength {
+    return "", &ValidationError { Field: field, Message: fmt.Sprintf("must not be longer than %d characters", maxLabelLength) }
+  }
+
+  return name, nil
+}
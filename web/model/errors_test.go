@@ -0,0 +1,158 @@
+package model
+
+import (
+  "errors"
+  "github.com/jackc/pgx/v5"
+  "github.com/jackc/pgx/v5/pgconn"
+  "strings"
+  "testing"
+)
+
+func TestDbError(t *testing.T) {
+  // check validation error
+  isValidation := func(err error) bool {
+    var verr *ValidationError
+    return errors.As(err, &verr)
+  }
+
+  tests := []struct {
+    name string // test name
+    err error // database error
+    check func(error) bool // check mapped error
+  } {{
+    name: "nil",
+    err: nil,
+    check: func(err error) bool { return err == nil },
+  }, {
+    name: "no rows",
+    err: pgx.ErrNoRows,
+    check: func(err error) bool { return errors.Is(err, ErrNotFound) },
+  }, {
+    name: "unique violation",
+    err: &pgconn.PgError { Code: "23505", Detail: "Key (name)=(foo) already exists." },
+    check: func(err error) bool { return errors.Is(err, ErrDuplicate) },
+  }, {
+    name: "check violation",
+    err: &pgconn.PgError { Code: "23514", ConstraintName: "books_name_check" },
+    check: isValidation,
+  }, {
+    name: "not null violation",
+    err: &pgconn.PgError { Code: "23502", ColumnName: "name" },
+    check: isValidation,
+  }, {
+    name: "other pg error",
+    err: &pgconn.PgError { Code: "42P01" },
+    check: func(err error) bool {
+      return !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrDuplicate) && !isValidation(err)
+    },
+  }, {
+    name: "other error",
+    err: errors.New("some error"),
+    check: func(err error) bool { return err != nil && !isValidation(err) },
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      got := dbError(test.err)
+      if !test.check(got) {
+        t.Fatalf("unexpected error: %v", got)
+      }
+    })
+  }
+}
+
+func TestValidateName(t *testing.T) {
+  if err := validateName("foo"); err != nil {
+    t.Fatal(err)
+  }
+
+  if err := validateName(""); err == nil {
+    t.Fatal("got success, exp err")
+  }
+}
+
+func TestNormalizeLabel(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    got, err := normalizeLabel("tag", "  science \t fiction ")
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if got != "science fiction" {
+      t.Fatalf("got %q, exp \"science fiction\"", got)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    for _, s := range([]string { "", "   ", strings.Repeat("x", maxLabelLength + 1) }) {
+      if _, err := normalizeLabel("tag", s); err == nil {
+        t.Fatalf("%q: got success, exp err", s)
+      }
+    }
+  })
+}
+
+func TestValidateUploadOptions(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    opts UploadOptions // upload options
+    ok bool // expect success?
+  } {
+    { "default", UploadOptions {}, true },
+    { "rename", UploadOptions { Conflict: ConflictRename }, true },
+    { "unknown conflict", UploadOptions { Conflict: "foo" }, false },
+    { "allow duplicates", UploadOptions { Duplicates: DuplicateAllow }, true },
+    { "unknown duplicates", UploadOptions { Duplicates: "foo" }, false },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      err := validateUploadOptions(test.opts)
+      if test.ok && err != nil {
+        t.Fatal(err)
+      } else if !test.ok && err == nil {
+        t.Fatal("got success, exp err")
+      }
+    })
+  }
+}
+
+func TestValidateReplaceCount(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    opts UploadOptions // upload options
+    count int // number of uploaded books
+    ok bool // expect success?
+  } {
+    { "not replacement", UploadOptions {}, 2, true },
+    { "one", UploadOptions { ReplaceId: 1 }, 1, true },
+    { "none", UploadOptions { ReplaceId: 1 }, 0, false },
+    { "two", UploadOptions { ReplaceId: 1 }, 2, false },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      err := validateReplaceCount(test.opts, test.count)
+      if test.ok && err != nil {
+        t.Fatal(err)
+      } else if !test.ok && err == nil {
+        t.Fatal("got success, exp err")
+      }
+    })
+  }
+}
+
+func TestNotFoundAs(t *testing.T) {
+  if got := notFoundAs(ErrNotFound, ErrTagNotFound); got != ErrTagNotFound {
+    t.Fatalf("got %v, exp %v", got, ErrTagNotFound)
+  }
+
+  if got := notFoundAs(nil, ErrTagNotFound); got != nil {
+    t.Fatalf("got %v, exp nil", got)
+  }
+
+  exp := errors.New("some error")
+  if got := notFoundAs(exp, ErrTagNotFound); got != exp {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+}
@@ -3,11 +3,15 @@ package model
 import (
   "context"
   "github.com/jackc/pgx/v5/pgxpool"
+  "io"
+  "time"
 )
 
 // Mock result from Search() method.
 type MockSearchResult struct {
   Books []Book
+  Next  string
+  Total int64
   Err   error
 }
 
@@ -17,25 +21,387 @@ type MockBodyResult struct {
   Err  error
 }
 
+// Mock result from Get() method
+type MockGetResult struct {
+  Book FullBook
+  Err  error
+}
+
+// Mock result from Upload() method
+type MockUploadResult struct {
+  Results []UploadResult
+  Err  error
+}
+
+// Mock result from Authors() method
+type MockAuthorsResult struct {
+  Authors []Author
+  Err  error
+}
+
+// Mock result from Author() method
+type MockAuthorResult struct {
+  Author AuthorDetail
+  Err  error
+}
+
+// Mock result from Labels() method
+type MockLabelsResult struct {
+  Labels Labels
+  Err  error
+}
+
+// Mock result from Tags() method
+type MockTagsResult struct {
+  Tags []Tag
+  Err  error
+}
+
+// Mock result from Collections() method
+type MockCollectionsResult struct {
+  Collections []Collection
+  Err  error
+}
+
+// Mock result from Collection() method
+type MockCollectionResult struct {
+  Collection CollectionDetail
+  Err  error
+}
+
+// Mock result from Revisions() method
+type MockRevisionsResult struct {
+  Revisions []Revision
+  Err  error
+}
+
+// Mock result from Revision() method
+type MockRevisionResult struct {
+  Revision RevisionDetail
+  Err  error
+}
+
+// Mock result from BodyHashes() method
+type MockBodyHashesResult struct {
+  Hashes []string
+  Err  error
+}
+
+// Mock result from ExportLibrary() method
+type MockExportLibraryResult struct {
+  Library Library
+  Bodies map[int]string // book bodies, by book ID
+  Err  error
+}
+
+// Mock result from Duplicates() method
+type MockDuplicatesResult struct {
+  Duplicates Duplicates
+  Err  error
+}
+
+// Mock result from AddUser() and SessionUser() methods
+type MockUserResult struct {
+  User User
+  Err  error
+}
+
+// Mock result from Users() method
+type MockUsersResult struct {
+  Users []User
+  Err  error
+}
+
+// Mock result from Login() method
+type MockLoginResult struct {
+  Session Session
+  Err  error
+}
+
+// Mock result from CreateToken() method
+type MockTokenResult struct {
+  Token ApiToken
+  Err  error
+}
+
+// Mock result from Tokens() method
+type MockTokensResult struct {
+  Tokens []ApiToken
+  Err  error
+}
+
+// Mock result from TokenUser() method
+type MockTokenUserResult struct {
+  Token ApiToken
+  User User
+  Err  error
+}
+
+// Mock result from SignBodies() method
+type MockSignBodiesResult struct {
+  Count int
+  Err  error
+}
+
 type MockModel struct {
   SearchResult MockSearchResult // Search() method result
   BodyResult MockBodyResult // Body() method result
-  UploadResult error // Upload() method result
+  GetResult MockGetResult // Get() method result
+  UploadResult MockUploadResult // Upload() method result
   EditResult error // Edit() method result
+  DeleteResult error // Delete() method result
+  RestoreResult error // Restore() method result
+  PurgeResult error // Purge() method result
+  TrashResult MockSearchResult // Trash() method result
+  AuthorsResult MockAuthorsResult // Authors() method result
+  AuthorResult MockAuthorResult // Author() method result
+  MergeAuthorsResult error // MergeAuthors() method result
+  LabelsResult MockLabelsResult // Labels() method result
+  TagsResult MockTagsResult // Tags() method result
+  AddTagResult error // AddTag() method result
+  RemoveTagResult error // RemoveTag() method result
+  CollectionsResult MockCollectionsResult // Collections() method result
+  CollectionResult MockCollectionResult // Collection() method result
+  AddToCollectionResult error // AddToCollection() method result
+  RemoveFromCollectionResult error // RemoveFromCollection() method result
+  SortCollectionResult error // SortCollection() method result
+  DeleteCollectionResult error // DeleteCollection() method result
+  RevisionsResult MockRevisionsResult // Revisions() method result
+
+  // Revision() method results, by revision ID.  Returns
+  // ErrRevisionNotFound for revisions which are not in the map.
+  RevisionResults map[int64]MockRevisionResult
+
+  RevertResult error // Revert() method result
+  BodyHashesResult MockBodyHashesResult // BodyHashes() method result
+  ExportLibraryResult MockExportLibraryResult // ExportLibrary() method result
+  RestoreLibraryResult MockUploadResult // RestoreLibrary() method result
+  DuplicatesResult MockDuplicatesResult // Duplicates() method result
+  SignBodiesResult MockSignBodiesResult // SignBodies() method result
+  AddUserResult MockUserResult // AddUser() method result
+  UsersResult MockUsersResult // Users() method result
+  SetRoleResult error // SetRole() method result
+  DeleteUserResult error // DeleteUser() method result
+  SetPasswordResult error // SetPassword() method result
+  LoginResult MockLoginResult // Login() method result
+  LogoutResult error // Logout() method result
+  SessionUserResult MockUserResult // SessionUser() method result
+  CreateTokenResult MockTokenResult // CreateToken() method result
+  TokensResult MockTokensResult // Tokens() method result
+  RevokeTokenResult error // RevokeToken() method result
+  TokenUserResult MockTokenUserResult // TokenUser() method result
 }
 
-func (m *MockModel) Search(_ context.Context, _ *pgxpool.Pool, _ string) ([]Book, error) {
-  return m.SearchResult.Books, m.SearchResult.Err
+func (m *MockModel) Search(_ context.Context, _ *pgxpool.Pool, _ SearchParams) (SearchResult, error) {
+  return SearchResult {
+    Books: m.SearchResult.Books,
+    Next: m.SearchResult.Next,
+    Total: m.SearchResult.Total,
+  }, m.SearchResult.Err
 }
 
 func (m *MockModel) Body(_ context.Context, _ *pgxpool.Pool, _ int64) (string, error) {
   return m.BodyResult.Body, m.BodyResult.Err
 }
 
-func (m *MockModel) Upload(_ context.Context, _ *pgxpool.Pool, _ []UploadedFile) error {
-  return m.UploadResult
+func (m *MockModel) Get(_ context.Context, _ *pgxpool.Pool, _ int64) (FullBook, error) {
+  return m.GetResult.Book, m.GetResult.Err
+}
+
+// Reads all books from the source, then returns the mock result.
+// Errors from the source are returned unchanged.
+func (m *MockModel) Upload(_ context.Context, _ *pgxpool.Pool, src UploadSource, _ UploadOptions) ([]UploadResult, error) {
+  for {
+    if _, err := src.Next(); err == io.EOF {
+      break
+    } else if err != nil {
+      return nil, err
+    }
+  }
+
+  return m.UploadResult.Results, m.UploadResult.Err
 }
 
 func (m *MockModel) Edit(_ context.Context, _ *pgxpool.Pool, _ int64, _, _ string) error {
   return m.EditResult
 }
+
+func (m *MockModel) Delete(_ context.Context, _ *pgxpool.Pool, _ int64) error {
+  return m.DeleteResult
+}
+
+func (m *MockModel) Restore(_ context.Context, _ *pgxpool.Pool, _ int64) error {
+  return m.RestoreResult
+}
+
+func (m *MockModel) Purge(_ context.Context, _ *pgxpool.Pool, _ int64) error {
+  return m.PurgeResult
+}
+
+func (m *MockModel) Trash(_ context.Context, _ *pgxpool.Pool) ([]Book, error) {
+  return m.TrashResult.Books, m.TrashResult.Err
+}
+
+func (m *MockModel) Authors(_ context.Context, _ *pgxpool.Pool) ([]Author, error) {
+  return m.AuthorsResult.Authors, m.AuthorsResult.Err
+}
+
+func (m *MockModel) Author(_ context.Context, _ *pgxpool.Pool, _ int64) (AuthorDetail, error) {
+  return m.AuthorResult.Author, m.AuthorResult.Err
+}
+
+func (m *MockModel) MergeAuthors(_ context.Context, _ *pgxpool.Pool, _ int64, _ []int64) error {
+  return m.MergeAuthorsResult
+}
+
+func (m *MockModel) Labels(_ context.Context, _ *pgxpool.Pool, _ int64) (Labels, error) {
+  return m.LabelsResult.Labels, m.LabelsResult.Err
+}
+
+func (m *MockModel) Tags(_ context.Context, _ *pgxpool.Pool) ([]Tag, error) {
+  return m.TagsResult.Tags, m.TagsResult.Err
+}
+
+func (m *MockModel) AddTag(_ context.Context, _ *pgxpool.Pool, _ int64, _ string) error {
+  return m.AddTagResult
+}
+
+func (m *MockModel) RemoveTag(_ context.Context, _ *pgxpool.Pool, _ int64, _ string) error {
+  return m.RemoveTagResult
+}
+
+func (m *MockModel) Collections(_ context.Context, _ *pgxpool.Pool) ([]Collection, error) {
+  return m.CollectionsResult.Collections, m.CollectionsResult.Err
+}
+
+func (m *MockModel) Collection(_ context.Context, _ *pgxpool.Pool, _ int64) (CollectionDetail, error) {
+  return m.CollectionResult.Collection, m.CollectionResult.Err
+}
+
+func (m *MockModel) AddToCollection(_ context.Context, _ *pgxpool.Pool, _ int64, _ string) error {
+  return m.AddToCollectionResult
+}
+
+func (m *MockModel) RemoveFromCollection(_ context.Context, _ *pgxpool.Pool, _ int64, _ string) error {
+  return m.RemoveFromCollectionResult
+}
+
+func (m *MockModel) SortCollection(_ context.Context, _ *pgxpool.Pool, _ int64, _ []int64) error {
+  return m.SortCollectionResult
+}
+
+func (m *MockModel) DeleteCollection(_ context.Context, _ *pgxpool.Pool, _ int64) error {
+  return m.DeleteCollectionResult
+}
+
+func (m *MockModel) Revisions(_ context.Context, _ *pgxpool.Pool, _ int64) ([]Revision, error) {
+  return m.RevisionsResult.Revisions, m.RevisionsResult.Err
+}
+
+func (m *MockModel) Revision(_ context.Context, _ *pgxpool.Pool, _, rev int64) (RevisionDetail, error) {
+  if r, ok := m.RevisionResults[rev]; ok {
+    return r.Revision, r.Err
+  }
+
+  return RevisionDetail{}, ErrRevisionNotFound
+}
+
+func (m *MockModel) Revert(_ context.Context, _ *pgxpool.Pool, _, _ int64) error {
+  return m.RevertResult
+}
+
+func (m *MockModel) BodyHashes(_ context.Context, _ *pgxpool.Pool) ([]string, error) {
+  return m.BodyHashesResult.Hashes, m.BodyHashesResult.Err
+}
+
+// Writes the mock library and bodies to the writer, or returns the
+// mock error.  Errors from the writer are returned unchanged.
+func (m *MockModel) ExportLibrary(_ context.Context, _ *pgxpool.Pool, w LibraryWriter) error {
+  if m.ExportLibraryResult.Err != nil {
+    return m.ExportLibraryResult.Err
+  }
+
+  // write metadata
+  if err := w.WriteLibrary(m.ExportLibraryResult.Library); err != nil {
+    return err
+  }
+
+  // write bodies
+  for _, book := range(m.ExportLibraryResult.Library.Books) {
+    if err := w.WriteBody(book.Id, m.ExportLibraryResult.Bodies[book.Id]); err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
+
+// Reads all books from the source, then returns the mock result.
+// Errors from the source are returned unchanged.
+func (m *MockModel) RestoreLibrary(_ context.Context, _ *pgxpool.Pool, src LibrarySource, _ UploadOptions) ([]UploadResult, error) {
+  for {
+    if _, _, err := src.Next(); err == io.EOF {
+      break
+    } else if err != nil {
+      return nil, err
+    }
+  }
+
+  return m.RestoreLibraryResult.Results, m.RestoreLibraryResult.Err
+}
+
+func (m *MockModel) Duplicates(_ context.Context, _ *pgxpool.Pool, _ float64) (Duplicates, error) {
+  return m.DuplicatesResult.Duplicates, m.DuplicatesResult.Err
+}
+
+func (m *MockModel) AddUser(_ context.Context, _ *pgxpool.Pool, _ string, _ Role, _ string) (User, error) {
+  return m.AddUserResult.User, m.AddUserResult.Err
+}
+
+func (m *MockModel) Users(_ context.Context, _ *pgxpool.Pool) ([]User, error) {
+  return m.UsersResult.Users, m.UsersResult.Err
+}
+
+func (m *MockModel) SetRole(_ context.Context, _ *pgxpool.Pool, _ string, _ Role) error {
+  return m.SetRoleResult
+}
+
+func (m *MockModel) DeleteUser(_ context.Context, _ *pgxpool.Pool, _ string) error {
+  return m.DeleteUserResult
+}
+
+func (m *MockModel) SetPassword(_ context.Context, _ *pgxpool.Pool, _, _ string) error {
+  return m.SetPasswordResult
+}
+
+func (m *MockModel) Login(_ context.Context, _ *pgxpool.Pool, _, _ string, _ time.Duration) (Session, error) {
+  return m.LoginResult.Session, m.LoginResult.Err
+}
+
+func (m *MockModel) Logout(_ context.Context, _ *pgxpool.Pool, _ string) error {
+  return m.LogoutResult
+}
+
+func (m *MockModel) SessionUser(_ context.Context, _ *pgxpool.Pool, _ string) (User, error) {
+  return m.SessionUserResult.User, m.SessionUserResult.Err
+}
+
+func (m *MockModel) CreateToken(_ context.Context, _ *pgxpool.Pool, _ int, _ string, _ TokenScope, _ time.Duration) (ApiToken, error) {
+  return m.CreateTokenResult.Token, m.CreateTokenResult.Err
+}
+
+func (m *MockModel) Tokens(_ context.Context, _ *pgxpool.Pool, _ int) ([]ApiToken, error) {
+  return m.TokensResult.Tokens, m.TokensResult.Err
+}
+
+func (m *MockModel) RevokeToken(_ context.Context, _ *pgxpool.Pool, _ int, _ int64) error {
+  return m.RevokeTokenResult
+}
+
+func (m *MockModel) TokenUser(_ context.Context, _ *pgxpool.Pool, _ string) (ApiToken, User, error) {
+  return m.TokenUserResult.Token, m.TokenUserResult.User, m.TokenUserResult.Err
+}
+
+func (m *MockModel) SignBodies(_ context.Context, _ *pgxpool.Pool) (int, error) {
+  return m.SignBodiesResult.Count, m.SignBodiesResult.Err
+}
@@ -3,21 +3,29 @@ package model
 import (
   "context"
   "errors"
+  "io"
   "testing"
   "reflect"
+  "time"
 )
 
 func TestMockModelSearch(t *testing.T) {
   t.Run("pass", func(t *testing.T) {
-    exp := []Book { Book { Name: "foo" } }
+    exp := SearchResult {
+      Books: []Book { Book { Name: "foo" } },
+      Next: "bar",
+      Total: 2,
+    }
 
     m := &MockModel {
       SearchResult: MockSearchResult {
-        Books: exp,
+        Books: exp.Books,
+        Next: exp.Next,
+        Total: exp.Total,
       },
     }
 
-    got, err := m.Search(context.Background(), nil, "")
+    got, err := m.Search(context.Background(), nil, SearchParams{})
     if err != nil {
       t.Fatal(err)
     }
@@ -34,7 +42,7 @@ func TestMockModelSearch(t *testing.T) {
       },
     }
 
-    got, err := m.Search(context.Background(), nil, "")
+    got, err := m.Search(context.Background(), nil, SearchParams{})
     if err == nil {
       t.Fatalf("got %#v, exp err", got)
     }
@@ -75,24 +83,89 @@ func TestMockModelBody(t *testing.T) {
   })
 }
 
+func TestMockModelGet(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    exp := FullBook { Id: 1, Name: "foo", Body: "bar" }
+
+    m := &MockModel {
+      GetResult: MockGetResult {
+        Book: exp,
+      },
+    }
+
+    got, err := m.Get(context.Background(), nil, 1)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if got != exp {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      GetResult: MockGetResult {
+        Err: errors.New("some error"),
+      },
+    }
+
+    got, err := m.Get(context.Background(), nil, 1)
+    if err == nil {
+      t.Fatalf("got %#v, exp err", got)
+    }
+  })
+}
+
 func TestMockModelUpload(t *testing.T) {
   t.Run("pass", func(t *testing.T) {
-    m := &MockModel {}
+    exp := []UploadResult { UploadResult { Id: 1, Name: "foo", Status: UploadCreated } }
+
+    m := &MockModel {
+      UploadResult: MockUploadResult {
+        Results: exp,
+      },
+    }
 
-    if err := m.Upload(context.Background(), nil, []UploadedFile{}); err != nil {
+    got, err := m.Upload(context.Background(), nil, NewFileSource(nil), UploadOptions{})
+    if err != nil {
       t.Fatal(err)
     }
+
+    if !reflect.DeepEqual(got, exp) {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
   })
 
   t.Run("fail", func(t *testing.T) {
     m := &MockModel {
-      UploadResult: errors.New("some error"),
+      UploadResult: MockUploadResult {
+        Err: errors.New("some error"),
+      },
     }
 
-    if err := m.Upload(context.Background(), nil, []UploadedFile{}); err == nil {
+    if _, err := m.Upload(context.Background(), nil, NewFileSource(nil), UploadOptions{}); err == nil {
       t.Fatal("got success, exp err")
     }
   })
+
+  t.Run("source error", func(t *testing.T) {
+    m := &MockModel {}
+
+    exp := errors.New("some error")
+    if _, err := m.Upload(context.Background(), nil, errorSource { exp }, UploadOptions{}); err != exp {
+      t.Fatalf("got %v, exp %v", err, exp)
+    }
+  })
+}
+
+// Upload source which always fails.
+type errorSource struct {
+  err error // error returned by Next()
+}
+
+func (s errorSource) Next() (UploadedFile, error) {
+  return UploadedFile{}, s.err
 }
 
 func TestMockModelEdit(t *testing.T) {
@@ -114,3 +187,913 @@ func TestMockModelEdit(t *testing.T) {
     }
   })
 }
+
+func TestMockModelDelete(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    m := &MockModel {}
+
+    if err := m.Delete(context.Background(), nil, 1); err != nil {
+      t.Fatal(err)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      DeleteResult: errors.New("some error"),
+    }
+
+    if err := m.Delete(context.Background(), nil, 1); err == nil {
+      t.Fatal("got success, exp err")
+    }
+  })
+}
+
+func TestMockModelRestore(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    m := &MockModel {}
+
+    if err := m.Restore(context.Background(), nil, 1); err != nil {
+      t.Fatal(err)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      RestoreResult: errors.New("some error"),
+    }
+
+    if err := m.Restore(context.Background(), nil, 1); err == nil {
+      t.Fatal("got success, exp err")
+    }
+  })
+}
+
+func TestMockModelPurge(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    m := &MockModel {}
+
+    if err := m.Purge(context.Background(), nil, 1); err != nil {
+      t.Fatal(err)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      PurgeResult: errors.New("some error"),
+    }
+
+    if err := m.Purge(context.Background(), nil, 1); err == nil {
+      t.Fatal("got success, exp err")
+    }
+  })
+}
+
+func TestMockModelTrash(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    exp := []Book { Book { Name: "foo" } }
+
+    m := &MockModel {
+      TrashResult: MockSearchResult {
+        Books: exp,
+      },
+    }
+
+    got, err := m.Trash(context.Background(), nil)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if !reflect.DeepEqual(got, exp) {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      TrashResult: MockSearchResult {
+        Err: errors.New("some error"),
+      },
+    }
+
+    got, err := m.Trash(context.Background(), nil)
+    if err == nil {
+      t.Fatalf("got %#v, exp err", got)
+    }
+  })
+}
+
+func TestMockModelAuthors(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    exp := []Author { Author { Id: 1, Name: "Mark Twain", SortName: "Twain, Mark", NumBooks: 2 } }
+
+    m := &MockModel {
+      AuthorsResult: MockAuthorsResult {
+        Authors: exp,
+      },
+    }
+
+    got, err := m.Authors(context.Background(), nil)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if !reflect.DeepEqual(got, exp) {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      AuthorsResult: MockAuthorsResult {
+        Err: errors.New("some error"),
+      },
+    }
+
+    got, err := m.Authors(context.Background(), nil)
+    if err == nil {
+      t.Fatalf("got %#v, exp err", got)
+    }
+  })
+}
+
+func TestMockModelAuthor(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    exp := AuthorDetail {
+      Author: Author { Id: 1, Name: "Mark Twain", SortName: "Twain, Mark", NumBooks: 1 },
+      Books: []Book { Book { Id: 2, Name: "Roughing It", Author: "Mark Twain" } },
+    }
+
+    m := &MockModel {
+      AuthorResult: MockAuthorResult {
+        Author: exp,
+      },
+    }
+
+    got, err := m.Author(context.Background(), nil, 1)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if !reflect.DeepEqual(got, exp) {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      AuthorResult: MockAuthorResult {
+        Err: ErrAuthorNotFound,
+      },
+    }
+
+    got, err := m.Author(context.Background(), nil, 1)
+    if err == nil {
+      t.Fatalf("got %#v, exp err", got)
+    }
+  })
+}
+
+func TestMockModelMergeAuthors(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    m := &MockModel {}
+
+    if err := m.MergeAuthors(context.Background(), nil, 1, []int64 { 2, 3 }); err != nil {
+      t.Fatal(err)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      MergeAuthorsResult: errors.New("some error"),
+    }
+
+    if err := m.MergeAuthors(context.Background(), nil, 1, []int64 { 2, 3 }); err == nil {
+      t.Fatal("got success, exp err")
+    }
+  })
+}
+
+func TestMockModelLabels(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    exp := Labels { Tags: []string { "poetry" }, Collections: []string { "to read" } }
+
+    m := &MockModel {
+      LabelsResult: MockLabelsResult {
+        Labels: exp,
+      },
+    }
+
+    got, err := m.Labels(context.Background(), nil, 1)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if !reflect.DeepEqual(got, exp) {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      LabelsResult: MockLabelsResult {
+        Err: errors.New("some error"),
+      },
+    }
+
+    got, err := m.Labels(context.Background(), nil, 1)
+    if err == nil {
+      t.Fatalf("got %#v, exp err", got)
+    }
+  })
+}
+
+func TestMockModelTags(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    exp := []Tag { Tag { Id: 1, Name: "poetry", NumBooks: 2 } }
+
+    m := &MockModel {
+      TagsResult: MockTagsResult {
+        Tags: exp,
+      },
+    }
+
+    got, err := m.Tags(context.Background(), nil)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if !reflect.DeepEqual(got, exp) {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      TagsResult: MockTagsResult {
+        Err: errors.New("some error"),
+      },
+    }
+
+    got, err := m.Tags(context.Background(), nil)
+    if err == nil {
+      t.Fatalf("got %#v, exp err", got)
+    }
+  })
+}
+
+func TestMockModelCollections(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    exp := []Collection { Collection { Id: 1, Name: "to read", NumBooks: 2 } }
+
+    m := &MockModel {
+      CollectionsResult: MockCollectionsResult {
+        Collections: exp,
+      },
+    }
+
+    got, err := m.Collections(context.Background(), nil)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if !reflect.DeepEqual(got, exp) {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      CollectionsResult: MockCollectionsResult {
+        Err: errors.New("some error"),
+      },
+    }
+
+    got, err := m.Collections(context.Background(), nil)
+    if err == nil {
+      t.Fatalf("got %#v, exp err", got)
+    }
+  })
+}
+
+func TestMockModelCollection(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    exp := CollectionDetail {
+      Collection: Collection { Id: 1, Name: "to read", NumBooks: 1 },
+      Books: []Book { Book { Id: 2, Name: "foo" } },
+    }
+
+    m := &MockModel {
+      CollectionResult: MockCollectionResult {
+        Collection: exp,
+      },
+    }
+
+    got, err := m.Collection(context.Background(), nil, 1)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if !reflect.DeepEqual(got, exp) {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      CollectionResult: MockCollectionResult {
+        Err: ErrCollectionNotFound,
+      },
+    }
+
+    got, err := m.Collection(context.Background(), nil, 1)
+    if err == nil {
+      t.Fatalf("got %#v, exp err", got)
+    }
+  })
+}
+
+func TestMockModelRevisions(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    exp := []Revision { Revision { Id: 2, BookId: 1, Name: "foo" } }
+
+    m := &MockModel {
+      RevisionsResult: MockRevisionsResult {
+        Revisions: exp,
+      },
+    }
+
+    got, err := m.Revisions(context.Background(), nil, 1)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if !reflect.DeepEqual(got, exp) {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      RevisionsResult: MockRevisionsResult {
+        Err: ErrNotFound,
+      },
+    }
+
+    got, err := m.Revisions(context.Background(), nil, 1)
+    if err == nil {
+      t.Fatalf("got %#v, exp err", got)
+    }
+  })
+}
+
+func TestMockModelRevision(t *testing.T) {
+  exp := RevisionDetail {
+    Revision: Revision { Id: 2, BookId: 1, Name: "foo" },
+    Body: "bar",
+  }
+
+  m := &MockModel {
+    RevisionResults: map[int64]MockRevisionResult {
+      2: MockRevisionResult { Revision: exp },
+      3: MockRevisionResult { Err: errors.New("some error") },
+    },
+  }
+
+  t.Run("pass", func(t *testing.T) {
+    got, err := m.Revision(context.Background(), nil, 1, 2)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if !reflect.DeepEqual(got, exp) {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    got, err := m.Revision(context.Background(), nil, 1, 3)
+    if err == nil {
+      t.Fatalf("got %#v, exp err", got)
+    }
+  })
+
+  t.Run("missing", func(t *testing.T) {
+    got, err := m.Revision(context.Background(), nil, 1, 4)
+    if !errors.Is(err, ErrRevisionNotFound) {
+      t.Fatalf("got (%#v, %v), exp ErrRevisionNotFound", got, err)
+    }
+  })
+}
+
+func TestMockModelBodyHashes(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    exp := []string { BodyHash("foo") }
+
+    m := &MockModel {
+      BodyHashesResult: MockBodyHashesResult {
+        Hashes: exp,
+      },
+    }
+
+    got, err := m.BodyHashes(context.Background(), nil)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if !reflect.DeepEqual(got, exp) {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      BodyHashesResult: MockBodyHashesResult {
+        Err: errors.New("some error"),
+      },
+    }
+
+    got, err := m.BodyHashes(context.Background(), nil)
+    if err == nil {
+      t.Fatalf("got %#v, exp err", got)
+    }
+  })
+}
+
+// Library writer which records written books.
+type recordWriter struct {
+  lib Library // written library
+  bodies map[int]string // written bodies, by book ID
+  err error // error returned by WriteBody()
+}
+
+func (w *recordWriter) WriteLibrary(lib Library) error {
+  w.lib = lib
+  return nil
+}
+
+func (w *recordWriter) WriteBody(id int, body string) error {
+  w.bodies[id] = body
+  return w.err
+}
+
+func TestMockModelExportLibrary(t *testing.T) {
+  lib := Library {
+    Books: []LibraryBook { LibraryBook { Id: 1, Name: "foo" } },
+    Collections: []LibraryCollection { LibraryCollection { Name: "bar", Books: []int { 1 } } },
+  }
+
+  t.Run("pass", func(t *testing.T) {
+    m := &MockModel {
+      ExportLibraryResult: MockExportLibraryResult {
+        Library: lib,
+        Bodies: map[int]string { 1: "foo body" },
+      },
+    }
+
+    w := &recordWriter { bodies: map[int]string{} }
+    if err := m.ExportLibrary(context.Background(), nil, w); err != nil {
+      t.Fatal(err)
+    }
+
+    if !reflect.DeepEqual(w.lib, lib) {
+      t.Fatalf("got %#v, exp %#v", w.lib, lib)
+    }
+
+    if got := w.bodies[1]; got != "foo body" {
+      t.Fatalf("got \"%s\", exp \"foo body\"", got)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      ExportLibraryResult: MockExportLibraryResult {
+        Err: errors.New("some error"),
+      },
+    }
+
+    if err := m.ExportLibrary(context.Background(), nil, &recordWriter {}); err == nil {
+      t.Fatal("got success, exp err")
+    }
+  })
+
+  t.Run("writer error", func(t *testing.T) {
+    m := &MockModel {
+      ExportLibraryResult: MockExportLibraryResult { Library: lib },
+    }
+
+    exp := errors.New("some error")
+    w := &recordWriter { bodies: map[int]string{}, err: exp }
+    if err := m.ExportLibrary(context.Background(), nil, w); err != exp {
+      t.Fatalf("got %v, exp %v", err, exp)
+    }
+  })
+}
+
+// Library source which returns no books, then the given error.
+type emptyLibrarySource struct {
+  err error // error returned by Next()
+}
+
+func (s emptyLibrarySource) Library() Library {
+  return Library{}
+}
+
+func (s emptyLibrarySource) Next() (LibraryBook, string, error) {
+  return LibraryBook{}, "", s.err
+}
+
+func TestMockModelRestoreLibrary(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    exp := []UploadResult { UploadResult { Id: 1, Name: "foo", Status: UploadCreated } }
+
+    m := &MockModel {
+      RestoreLibraryResult: MockUploadResult {
+        Results: exp,
+      },
+    }
+
+    got, err := m.RestoreLibrary(context.Background(), nil, emptyLibrarySource { io.EOF }, UploadOptions{})
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if !reflect.DeepEqual(got, exp) {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      RestoreLibraryResult: MockUploadResult {
+        Err: errors.New("some error"),
+      },
+    }
+
+    if _, err := m.RestoreLibrary(context.Background(), nil, emptyLibrarySource { io.EOF }, UploadOptions{}); err == nil {
+      t.Fatal("got success, exp err")
+    }
+  })
+
+  t.Run("source error", func(t *testing.T) {
+    m := &MockModel {}
+
+    exp := errors.New("some error")
+    if _, err := m.RestoreLibrary(context.Background(), nil, emptyLibrarySource { exp }, UploadOptions{}); err != exp {
+      t.Fatalf("got %v, exp %v", err, exp)
+    }
+  })
+}
+
+func TestMockModelLabelMethods(t *testing.T) {
+  // methods which only return an error
+  tests := []struct {
+    name string // test name
+    call func(*MockModel) error // call method
+    fail func(*MockModel) // set method error
+  } {{
+    name: "AddTag",
+    call: func(m *MockModel) error { return m.AddTag(context.Background(), nil, 1, "foo") },
+    fail: func(m *MockModel) { m.AddTagResult = errors.New("some error") },
+  }, {
+    name: "RemoveTag",
+    call: func(m *MockModel) error { return m.RemoveTag(context.Background(), nil, 1, "foo") },
+    fail: func(m *MockModel) { m.RemoveTagResult = errors.New("some error") },
+  }, {
+    name: "AddToCollection",
+    call: func(m *MockModel) error { return m.AddToCollection(context.Background(), nil, 1, "foo") },
+    fail: func(m *MockModel) { m.AddToCollectionResult = errors.New("some error") },
+  }, {
+    name: "RemoveFromCollection",
+    call: func(m *MockModel) error { return m.RemoveFromCollection(context.Background(), nil, 1, "foo") },
+    fail: func(m *MockModel) { m.RemoveFromCollectionResult = errors.New("some error") },
+  }, {
+    name: "SortCollection",
+    call: func(m *MockModel) error { return m.SortCollection(context.Background(), nil, 1, []int64 { 2, 3 }) },
+    fail: func(m *MockModel) { m.SortCollectionResult = errors.New("some error") },
+  }, {
+    name: "DeleteCollection",
+    call: func(m *MockModel) error { return m.DeleteCollection(context.Background(), nil, 1) },
+    fail: func(m *MockModel) { m.DeleteCollectionResult = errors.New("some error") },
+  }, {
+    name: "Revert",
+    call: func(m *MockModel) error { return m.Revert(context.Background(), nil, 1, 2) },
+    fail: func(m *MockModel) { m.RevertResult = errors.New("some error") },
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      t.Run("pass", func(t *testing.T) {
+        m := &MockModel {}
+        if err := test.call(m); err != nil {
+          t.Fatal(err)
+        }
+      })
+
+      t.Run("fail", func(t *testing.T) {
+        m := &MockModel {}
+        test.fail(m)
+        if err := test.call(m); err == nil {
+          t.Fatal("got success, exp err")
+        }
+      })
+    })
+  }
+}
+
+func TestMockModelDuplicates(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    exp := Duplicates {
+      Exact: []DuplicateGroup {
+        DuplicateGroup {
+          BodySha256: BodyHash("foo"),
+          Books: []Book { Book { Id: 1, Name: "foo" }, Book { Id: 2, Name: "bar" } },
+        },
+      },
+      Near: []DuplicatePair {},
+    }
+
+    m := &MockModel {
+      DuplicatesResult: MockDuplicatesResult {
+        Duplicates: exp,
+      },
+    }
+
+    got, err := m.Duplicates(context.Background(), nil, 0)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if !reflect.DeepEqual(got, exp) {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      DuplicatesResult: MockDuplicatesResult {
+        Err: errors.New("some error"),
+      },
+    }
+
+    if _, err := m.Duplicates(context.Background(), nil, 0); err == nil {
+      t.Fatal("got success, exp err")
+    }
+  })
+}
+
+func TestMockModelAddUser(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    exp := User { Id: 1, Name: "alice", Role: RoleEditor }
+    m := &MockModel {
+      AddUserResult: MockUserResult { User: exp },
+    }
+
+    got, err := m.AddUser(context.Background(), nil, "alice", RoleEditor, "password")
+    if err != nil {
+      t.Fatal(err)
+    } else if got != exp {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      AddUserResult: MockUserResult { Err: ErrDuplicateUser },
+    }
+
+    if _, err := m.AddUser(context.Background(), nil, "alice", RoleEditor, "password"); err == nil {
+      t.Fatal("got success, exp err")
+    }
+  })
+}
+
+func TestMockModelUsers(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    exp := []User {
+      User { Id: 1, Name: "alice", Role: RoleAdmin },
+      User { Id: 2, Name: "bob", Role: RoleViewer },
+    }
+    m := &MockModel {
+      UsersResult: MockUsersResult { Users: exp },
+    }
+
+    got, err := m.Users(context.Background(), nil)
+    if err != nil {
+      t.Fatal(err)
+    } else if !reflect.DeepEqual(got, exp) {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      UsersResult: MockUsersResult { Err: errors.New("some error") },
+    }
+
+    if _, err := m.Users(context.Background(), nil); err == nil {
+      t.Fatal("got success, exp err")
+    }
+  })
+}
+
+func TestMockModelSetRole(t *testing.T) {
+  m := &MockModel { SetRoleResult: ErrUserNotFound }
+
+  if err := m.SetRole(context.Background(), nil, "alice", RoleAdmin); err == nil {
+    t.Fatal("got success, exp err")
+  }
+}
+
+func TestMockModelDeleteUser(t *testing.T) {
+  m := &MockModel { DeleteUserResult: ErrUserNotFound }
+
+  if err := m.DeleteUser(context.Background(), nil, "alice"); err == nil {
+    t.Fatal("got success, exp err")
+  }
+}
+
+func TestMockModelSetPassword(t *testing.T) {
+  m := &MockModel { SetPasswordResult: ErrUserNotFound }
+
+  if err := m.SetPassword(context.Background(), nil, "alice", "password"); err == nil {
+    t.Fatal("got success, exp err")
+  }
+}
+
+func TestMockModelLogin(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    exp := Session { Token: "token", User: User { Id: 1, Name: "alice" } }
+    m := &MockModel {
+      LoginResult: MockLoginResult { Session: exp },
+    }
+
+    got, err := m.Login(context.Background(), nil, "alice", "password", time.Hour)
+    if err != nil {
+      t.Fatal(err)
+    } else if got != exp {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      LoginResult: MockLoginResult { Err: ErrInvalidLogin },
+    }
+
+    if _, err := m.Login(context.Background(), nil, "alice", "password", time.Hour); err == nil {
+      t.Fatal("got success, exp err")
+    }
+  })
+}
+
+func TestMockModelLogout(t *testing.T) {
+  m := &MockModel { LogoutResult: errors.New("some error") }
+
+  if err := m.Logout(context.Background(), nil, "token"); err == nil {
+    t.Fatal("got success, exp err")
+  }
+}
+
+func TestMockModelSessionUser(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    exp := User { Id: 1, Name: "alice" }
+    m := &MockModel {
+      SessionUserResult: MockUserResult { User: exp },
+    }
+
+    got, err := m.SessionUser(context.Background(), nil, "token")
+    if err != nil {
+      t.Fatal(err)
+    } else if got != exp {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      SessionUserResult: MockUserResult { Err: ErrSessionNotFound },
+    }
+
+    if _, err := m.SessionUser(context.Background(), nil, "token"); err == nil {
+      t.Fatal("got success, exp err")
+    }
+  })
+}
+
+func TestMockModelCreateToken(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    exp := ApiToken { Id: 1, Name: "upload script", Scope: ScopeWrite, Token: "token" }
+    m := &MockModel {
+      CreateTokenResult: MockTokenResult { Token: exp },
+    }
+
+    got, err := m.CreateToken(context.Background(), nil, 1, "upload script", ScopeWrite, time.Hour)
+    if err != nil {
+      t.Fatal(err)
+    } else if !reflect.DeepEqual(got, exp) {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      CreateTokenResult: MockTokenResult { Err: errors.New("some error") },
+    }
+
+    if _, err := m.CreateToken(context.Background(), nil, 1, "upload script", ScopeWrite, time.Hour); err == nil {
+      t.Fatal("got success, exp err")
+    }
+  })
+}
+
+func TestMockModelTokens(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    exp := []ApiToken { ApiToken { Id: 1, Name: "upload script", Scope: ScopeWrite } }
+    m := &MockModel {
+      TokensResult: MockTokensResult { Tokens: exp },
+    }
+
+    got, err := m.Tokens(context.Background(), nil, 1)
+    if err != nil {
+      t.Fatal(err)
+    } else if !reflect.DeepEqual(got, exp) {
+      t.Fatalf("got %#v, exp %#v", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      TokensResult: MockTokensResult { Err: errors.New("some error") },
+    }
+
+    if _, err := m.Tokens(context.Background(), nil, 1); err == nil {
+      t.Fatal("got success, exp err")
+    }
+  })
+}
+
+func TestMockModelRevokeToken(t *testing.T) {
+  m := &MockModel { RevokeTokenResult: ErrTokenNotFound }
+
+  if err := m.RevokeToken(context.Background(), nil, 1, 2); err == nil {
+    t.Fatal("got success, exp err")
+  }
+}
+
+func TestMockModelTokenUser(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    expToken := ApiToken { Id: 1, Name: "upload script", Scope: ScopeWrite }
+    expUser := User { Id: 1, Name: "alice", Role: RoleEditor }
+    m := &MockModel {
+      TokenUserResult: MockTokenUserResult { Token: expToken, User: expUser },
+    }
+
+    gotToken, gotUser, err := m.TokenUser(context.Background(), nil, "token")
+    if err != nil {
+      t.Fatal(err)
+    } else if !reflect.DeepEqual(gotToken, expToken) {
+      t.Fatalf("got %#v, exp %#v", gotToken, expToken)
+    } else if gotUser != expUser {
+      t.Fatalf("got %#v, exp %#v", gotUser, expUser)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      TokenUserResult: MockTokenUserResult { Err: ErrTokenNotFound },
+    }
+
+    if _, _, err := m.TokenUser(context.Background(), nil, "token"); err == nil {
+      t.Fatal("got success, exp err")
+    }
+  })
+}
+
+func TestMockModelSignBodies(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    m := &MockModel {
+      SignBodiesResult: MockSignBodiesResult { Count: 3 },
+    }
+
+    got, err := m.SignBodies(context.Background(), nil)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if got != 3 {
+      t.Fatalf("got %d, exp 3", got)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &MockModel {
+      SignBodiesResult: MockSignBodiesResult {
+        Err: errors.New("some error"),
+      },
+    }
+
+    if _, err := m.SignBodies(context.Background(), nil); err == nil {
+      t.Fatal("got success, exp err")
+    }
+  })
+}
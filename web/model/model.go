@@ -3,7 +3,14 @@ package model
 
 import (
   "context"
+  "crypto/sha256"
+  "encoding/hex"
+  "fmt"
   "github.com/jackc/pgx/v5/pgxpool"
+  "golang.org/x/text/unicode/norm"
+  "io"
+  "strings"
+  "time"
   _ "embed"
 )
 
@@ -13,32 +20,695 @@ type Book struct {
   Name string `db:"name" json:"name"` // book name
   Author string `db:"author" json:"author"` // author name
   Rank float64 `db:"rank" json:"rank"` // search result rank
+
+  // Fragments of the book body around the matched search terms, or
+  // empty if there is no search query.  Matched terms are wrapped in
+  // SnippetStart and SnippetStop, and fragments are separated by
+  // SnippetDelimiter.
+  Snippet string `db:"snippet" json:"snippet,omitempty"`
+}
+
+// Marks the start of a matched term in a search result snippet
+// (U+E000, private use).
+const SnippetStart = "\ue000"
+
+// Marks the end of a matched term in a search result snippet (U+E001,
+// private use).
+const SnippetStop = "\ue001"
+
+// Separates fragments in a search result snippet.
+const SnippetDelimiter = " … "
+
+// Search result snippet options.
+type SnippetOptions struct {
+  // Maximum number of fragments.  If zero, then a single snippet is
+  // taken from the best match.
+  MaxFragments int
+
+  // Maximum number of words per fragment.  Defaults to 15 if less
+  // than 2.
+  MaxWords int
+}
+
+// Get ts_headline() options string.
+func (o SnippetOptions) String() string {
+  // get maximum and minimum number of words
+  maxWords := o.MaxWords
+  if maxWords < 2 {
+    maxWords = 15
+  }
+  minWords := (maxWords + 1) / 2
+  if minWords >= maxWords {
+    minWords = maxWords - 1
+  }
+
+  return fmt.Sprintf(
+    "MaxFragments=%d, MaxWords=%d, MinWords=%d, StartSel=%s, StopSel=%s, FragmentDelimiter=\"%s\"",
+    o.MaxFragments, maxWords, minWords, SnippetStart, SnippetStop, SnippetDelimiter,
+  )
+}
+
+// Book metadata and contents.
+type FullBook struct {
+  Id int `db:"id" json:"id"` // book ID
+  Name string `db:"name" json:"name"` // book name
+  Author string `db:"author" json:"author"` // author name
+  ReleaseDate string `db:"release_date" json:"release_date"` // release date (may be empty)
+  Language string `db:"language" json:"language"` // language name or code (may be empty)
+  CreatedAt time.Time `db:"created_at" json:"created_at"` // time book was created
+  Body string `db:"body" json:"body"` // book contents
+}
+
+// Search result sort order.
+type SearchSort string
+
+const (
+  SortName SearchSort = "name" // sort by name
+  SortAuthor SearchSort = "author" // sort by sort name of first author (e.g. "Twain, Mark"), then by ID
+  SortRank SearchSort = "rank" // sort by relevance, most relevant first
+  SortCreated SearchSort = "created" // sort by creation time, newest first
+)
+
+// Default number of results per page of search results.
+const DefaultSearchLimit = 50
+
+// Maximum number of results per page of search results.
+const MaxSearchLimit = 500
+
+// Search parameters.
+type SearchParams struct {
+  // Search query string.  If empty, then all books are matched.
+  //
+  // The query string may contain `tag:name` and `collection:name`
+  // filters, which limit the results to books with all of the given
+  // tags and in all of the given collections.  Names which contain
+  // spaces must be quoted (e.g. `tag:"science fiction"`).
+  Query string
+
+  // Sort order.  If empty, then results are sorted by relevance if
+  // there is a query string, or by name otherwise.
+  Sort SearchSort
+
+  // Maximum number of results.  If zero, then DefaultSearchLimit is
+  // used.  Values larger than MaxSearchLimit are clamped.
+  Limit int
+
+  // Cursor from the Next field of the previous page of results.  If
+  // empty, then the first page of results is returned.
+  Cursor string
+
+  // Search result snippet options.
+  Snippets SnippetOptions
+}
+
+// Page of search results.
+type SearchResult struct {
+  Books []Book `json:"books"` // page of matching books
+  Next string `json:"next"` // cursor for next page, or empty if this is the last page
+  Total int64 `json:"total"` // total number of matching books
 }
 
 // uploaded file data
 type UploadedFile struct {
   Name string // book name
+  Author string // author name (optional, defaults to "Unknown Author")
+  ReleaseDate string // release date (optional)
+  Language string // language name (optional)
   Body string // book contents
+
+  // Detected character encoding of the uploaded file (e.g. "utf-8").
+  // Reported in upload results, but not stored.
+  Encoding string
+}
+
+// Normalize book body for duplicate detection.
+//
+// Normalizes Unicode to NFC and line endings to "\n", then removes
+// trailing spaces and tabs from each line and trailing line breaks
+// from the body.  Matches the `bookman.normalize_body()` database
+// function.
+func NormalizeBody(body string) string {
+  // normalize unicode and line endings
+  body = norm.NFC.String(body)
+  body = strings.ReplaceAll(body, "\r\n", "\n")
+  body = strings.ReplaceAll(body, "\r", "\n")
+
+  // remove trailing whitespace from each line
+  lines := strings.Split(body, "\n")
+  for i, line := range(lines) {
+    lines[i] = strings.TrimRight(line, " \t")
+  }
+
+  // remove trailing line breaks
+  return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// Get the hex-encoded SHA-256 hash of the given book body, normalized
+// with NormalizeBody().
+//
+// Matches the `body_sha256` column of the books table, which is set
+// from the stored body by a trigger.
+func BodyHash(body string) string {
+  sum := sha256.Sum256([]byte(NormalizeBody(body)))
+  return hex.EncodeToString(sum[:])
+}
+
+// Source of books for Upload().
+//
+// Books are read from the source one at a time as they are uploaded,
+// so the whole upload does not need to be held in memory.
+type UploadSource interface {
+  // Get next book.  Returns io.EOF if there are no more books.  Any
+  // other error fails the upload.
+  Next() (UploadedFile, error)
+}
+
+// Upload source which returns books from a slice.
+type fileSource struct {
+  files []UploadedFile // remaining books
+}
+
+// Create upload source which returns the given books.
+func NewFileSource(files []UploadedFile) UploadSource {
+  return &fileSource { files: files }
+}
+
+// Get next book.  Returns io.EOF if there are no more books.
+func (s *fileSource) Next() (UploadedFile, error) {
+  if len(s.files) == 0 {
+    return UploadedFile{}, io.EOF
+  }
+
+  file := s.files[0]
+  s.files = s.files[1:]
+  return file, nil
+}
+
+// Upload conflict policy: the action taken when an uploaded book has
+// the same name as an existing book.
+type ConflictPolicy string
+
+const (
+  ConflictFail ConflictPolicy = "fail" // fail the upload
+  ConflictSkip ConflictPolicy = "skip" // skip the uploaded book, keep the existing book
+  ConflictReplace ConflictPolicy = "replace" // replace the body of the existing book, keep its metadata
+  ConflictRename ConflictPolicy = "rename" // add the uploaded book with a numbered name (e.g. "Foo (2)")
+)
+
+// Action taken when an uploaded book has the same contents as an
+// existing book.
+type DuplicatePolicy string
+
+const (
+  DuplicateFail DuplicatePolicy = "fail" // fail the upload
+  DuplicateSkip DuplicatePolicy = "skip" // skip the uploaded book, keep the existing book
+  DuplicateAllow DuplicatePolicy = "allow" // add the uploaded book anyway
+)
+
+// Upload options.
+type UploadOptions struct {
+  // Conflict policy.  Defaults to ConflictFail if empty.
+  //
+  // Books in the trash count as conflicts.  Replacing the body of a
+  // book in the trash also restores it from the trash.
+  Conflict ConflictPolicy
+
+  // ID of existing book whose body is replaced by the uploaded book.
+  // The metadata of the existing book is kept, and the conflict policy
+  // is ignored.
+  //
+  // If non-zero, then exactly one book must be uploaded.
+  ReplaceId int64
+
+  // Duplicate policy.  Defaults to DuplicateFail if empty.
+  //
+  // Books are duplicates if the SHA-256 hashes of their bodies match
+  // (see BodyHash()).  Books in the trash count as duplicates.  The
+  // duplicate policy is checked after the conflict policy, and also
+  // applies to replaced bodies.
+  Duplicates DuplicatePolicy
+
+  // Allow partial success?  If true, then each book is uploaded in a
+  // separate savepoint, and books which fail are reported with their
+  // error instead of failing the whole upload.
+  Partial bool
+}
+
+// Outcome of uploading a book.
+type UploadStatus string
+
+const (
+  UploadCreated UploadStatus = "created" // book was added
+  UploadRenamed UploadStatus = "renamed" // book was added with a numbered name
+  UploadReplaced UploadStatus = "replaced" // body of existing book was replaced
+  UploadSkipped UploadStatus = "skipped" // book was not added because the name or contents are in use
+  UploadFailed UploadStatus = "failed" // book was not added because of an error (partial uploads only)
+)
+
+// Result of uploading a book.
+type UploadResult struct {
+  Id int `json:"id"` // ID of added, replaced, or skipped existing book, or 0 if failed
+  Name string `json:"name"` // name of book
+  Status UploadStatus `json:"status"` // outcome
+  Err error `json:"-"` // error, if failed
+}
+
+// Author and number of books by author.
+type Author struct {
+  Id int `db:"id" json:"id"` // author ID
+  Name string `db:"name" json:"name"` // author name
+  SortName string `db:"sort_name" json:"sort_name"` // sort name (e.g. "Twain, Mark")
+  NumBooks int64 `db:"num_books" json:"num_books"` // number of books, excluding books in the trash
+}
+
+// Author and books by author.
+type AuthorDetail struct {
+  Author
+  Books []Book `db:"-" json:"books"` // books by author, sorted by name
+}
+
+// Tag and number of books with tag.
+type Tag struct {
+  Id int `db:"id" json:"id"` // tag ID
+  Name string `db:"name" json:"name"` // tag name
+  NumBooks int64 `db:"num_books" json:"num_books"` // number of books, excluding books in the trash
+}
+
+// Collection and number of books in collection.
+type Collection struct {
+  Id int `db:"id" json:"id"` // collection ID
+  Name string `db:"name" json:"name"` // collection name
+  NumBooks int64 `db:"num_books" json:"num_books"` // number of books, excluding books in the trash
+}
+
+// Collection and books in collection.
+type CollectionDetail struct {
+  Collection
+  Books []Book `db:"-" json:"books"` // books in collection, in collection order
+}
+
+// Tags and collections of a book.
+type Labels struct {
+  Tags []string `db:"tags" json:"tags"` // tag names, sorted by name
+  Collections []string `db:"collections" json:"collections"` // collection names, sorted by name
+}
+
+// Book revision.
+type Revision struct {
+  Id int `db:"id" json:"id"` // revision ID
+  BookId int `db:"book_id" json:"book_id"` // book ID
+  Name string `db:"name" json:"name"` // book name
+  Author string `db:"author" json:"author"` // author name
+  BodyChanged bool `db:"body_changed" json:"body_changed"` // true if the body changed in this revision
+  Actor string `db:"actor" json:"actor"` // user or client which made the change (may be empty)
+  CreatedAt time.Time `db:"created_at" json:"created_at"` // time of change
+}
+
+// Book revision and book contents as of the revision.
+type RevisionDetail struct {
+  Revision
+  Body string `db:"body" json:"body"` // book contents
+}
+
+// Book metadata in a library export.
+type LibraryBook struct {
+  Id int `db:"id" json:"id"` // book ID in the exported library
+  Name string `db:"name" json:"name"` // book name
+  Author string `db:"author" json:"author"` // author name
+  ReleaseDate string `db:"release_date" json:"release_date"` // release date, or empty if unknown
+  Language string `db:"language" json:"language"` // language name, or empty if unknown
+  Tags []string `db:"tags" json:"tags"` // tag names, sorted by name
+  CreatedAt time.Time `db:"created_at" json:"created_at"` // creation time
+  DeletedAt *time.Time `db:"deleted_at" json:"deleted_at"` // time book was moved to the trash, or nil if not in the trash
+  BodySha256 string `db:"body_sha256" json:"body_sha256"` // hex-encoded SHA-256 hash of book contents
+}
+
+// Collection in a library export.
+type LibraryCollection struct {
+  Name string `db:"name" json:"name"` // collection name
+  Books []int `db:"books" json:"books"` // IDs of books in collection, in collection order
+}
+
+// Metadata of all books and collections in a library, including books
+// in the trash.
+type Library struct {
+  Books []LibraryBook `json:"books"` // books, sorted by ID
+  Collections []LibraryCollection `json:"collections"` // collections, sorted by name
+}
+
+// Destination of ExportLibrary().
+type LibraryWriter interface {
+  // Write library metadata.  Called once, before any book bodies are
+  // written.
+  WriteLibrary(lib Library) error
+
+  // Write body of the given book.  Called once for each book, in the
+  // same order as the books in the library metadata.
+  WriteBody(id int, body string) error
+}
+
+// Source of books for RestoreLibrary().
+//
+// Books are read from the source one at a time as they are restored,
+// so the whole library does not need to be held in memory.
+type LibrarySource interface {
+  // Get library metadata.
+  Library() Library
+
+  // Get metadata and body of next book.  Returns io.EOF if there are
+  // no more books.  Any other error fails the restore.
+  Next() (LibraryBook, string, error)
+}
+
+// Default near-duplicate similarity threshold for Duplicates().
+const DefaultDuplicateThreshold = 0.8
+
+// Group of books with identical contents.
+type DuplicateGroup struct {
+  BodySha256 string `json:"body_sha256"` // hex-encoded SHA-256 hash of book contents
+  Books []Book `json:"books"` // books, sorted by ID
+}
+
+// Pair of books with similar contents.
+//
+// Each book is the book with the lowest ID among the books with
+// identical contents.
+type DuplicatePair struct {
+  Similarity float64 `json:"similarity"` // estimated similarity, from 0 to 1
+  Books [2]Book `json:"books"` // books, sorted by ID
+}
+
+// Duplicate book report.
+type Duplicates struct {
+  Exact []DuplicateGroup `json:"exact"` // groups of books with identical contents, sorted by lowest book ID
+  Near []DuplicatePair `json:"near"` // pairs of books with similar contents, sorted by similarity in descending order
 }
 
 // Book storage model interface.
 type Model interface {
-  // Get a list of books.
+  // Get a page of books.
   //
-  // If `q` is not empty, then the book name, content, and author are
-  // matched against the search string, and the list of results is sorted
-  // by relevance.
+  // If `params.Query` is not empty, then the book name, content, and
+  // author are matched against the search string.  If `params.Query`
+  // is empty, then all books are matched.
   //
-  // If `q` is empty, then the return value is the full list of books,
-  // sorted by name.
-  Search(ctx context.Context, pool *pgxpool.Pool, q string) ([]Book, error)
+  // Returns a ValidationError if the sort order or cursor is invalid.
+  Search(ctx context.Context, pool *pgxpool.Pool, params SearchParams) (SearchResult, error)
 
   // Get body of given book.
+  //
+  // Returns ErrNotFound if the book does not exist or is in the trash.
   Body(ctx context.Context, pool *pgxpool.Pool, id int64) (string, error)
 
-  // Upload slice of books.
-  Upload(ctx context.Context, pool *pgxpool.Pool, files []UploadedFile) error
+  // Get metadata and body of given book.
+  //
+  // Returns ErrNotFound if the book does not exist or is in the trash.
+  Get(ctx context.Context, pool *pgxpool.Pool, id int64) (FullBook, error)
+
+  // Upload books from the given source in a single transaction.
+  //
+  // Name conflicts with existing books are resolved with the conflict
+  // policy from the given options.  Returns the outcome of each book,
+  // in the same order as they were read from the source.
+  //
+  // Errors from the source are returned unchanged.
+  //
+  // Returns ErrDuplicate if a book with the same name already exists
+  // and the conflict policy is ConflictFail, ErrDuplicateBody if a
+  // book with the same contents already exists and the duplicate
+  // policy is DuplicateFail, ErrNotFound if `opts.ReplaceId` is set
+  // and the book does not exist or is in the trash, or a
+  // ValidationError if a name or the options are invalid.
+  //
+  // If `opts.Partial` is true, then errors for individual books are
+  // reported in their result instead, and the remaining books are
+  // still uploaded.
+  Upload(ctx context.Context, pool *pgxpool.Pool, src UploadSource, opts UploadOptions) ([]UploadResult, error)
 
   // Set the name and author of the given book.
+  //
+  // Returns ErrNotFound if the book does not exist or is in the trash,
+  // ErrDuplicate if the name is already in use, or a ValidationError if
+  // the name or author is empty.
   Edit(ctx context.Context, pool *pgxpool.Pool, id int64, name, author string) error
+
+  // Move the given book to the trash.
+  //
+  // Books in the trash are hidden from Search() and Body() until they
+  // are restored with Restore() or permanently removed with Purge().
+  //
+  // Returns ErrNotFound if the book does not exist or is already in
+  // the trash.
+  Delete(ctx context.Context, pool *pgxpool.Pool, id int64) error
+
+  // Restore the given book from the trash.
+  //
+  // Returns ErrNotFound if the book is not in the trash.
+  Restore(ctx context.Context, pool *pgxpool.Pool, id int64) error
+
+  // Permanently remove the given book from the trash.
+  //
+  // Returns ErrNotFound if the book is not in the trash.
+  Purge(ctx context.Context, pool *pgxpool.Pool, id int64) error
+
+  // Get a list of books in the trash, sorted by deletion time in
+  // descending order.
+  Trash(ctx context.Context, pool *pgxpool.Pool) ([]Book, error)
+
+  // Get a list of authors with at least one book which is not in the
+  // trash, sorted by sort name.
+  Authors(ctx context.Context, pool *pgxpool.Pool) ([]Author, error)
+
+  // Get the given author and their books, excluding books in the
+  // trash.
+  //
+  // Returns ErrAuthorNotFound if the author does not exist.
+  Author(ctx context.Context, pool *pgxpool.Pool, id int64) (AuthorDetail, error)
+
+  // Merge the given duplicate authors into the author with the given
+  // ID.
+  //
+  // Books by the duplicate authors are re-pointed to the author with
+  // the given ID, their author text is updated to match, and the
+  // duplicate authors are removed.
+  //
+  // Returns ErrAuthorNotFound if any of the authors do not exist, or
+  // a ValidationError if the list of duplicate authors is empty or
+  // contains the given ID.
+  MergeAuthors(ctx context.Context, pool *pgxpool.Pool, id int64, dups []int64) error
+
+  // Get tags and collections of given book.
+  //
+  // Returns ErrNotFound if the book does not exist or is in the trash.
+  Labels(ctx context.Context, pool *pgxpool.Pool, id int64) (Labels, error)
+
+  // Get a list of tags with at least one book which is not in the
+  // trash, sorted by name.
+  Tags(ctx context.Context, pool *pgxpool.Pool) ([]Tag, error)
+
+  // Add tag to given book.  The tag is created if it does not exist.
+  //
+  // Returns ErrNotFound if the book does not exist or is in the trash,
+  // or a ValidationError if the tag name is empty.
+  AddTag(ctx context.Context, pool *pgxpool.Pool, id int64, tag string) error
+
+  // Remove tag from given book.
+  //
+  // Tag names are matched the same way as in AddTag().
+  //
+  // Returns ErrTagNotFound if the book does not have the tag, or a
+  // ValidationError if the tag name is empty.
+  RemoveTag(ctx context.Context, pool *pgxpool.Pool, id int64, tag string) error
+
+  // Get a list of collections, sorted by name.
+  Collections(ctx context.Context, pool *pgxpool.Pool) ([]Collection, error)
+
+  // Get the given collection and its books, excluding books in the
+  // trash.
+  //
+  // Returns ErrCollectionNotFound if the collection does not exist.
+  Collection(ctx context.Context, pool *pgxpool.Pool, id int64) (CollectionDetail, error)
+
+  // Add given book to the end of the named collection.  The collection
+  // is created if it does not exist.
+  //
+  // Returns ErrNotFound if the book does not exist or is in the trash,
+  // or a ValidationError if the collection name is empty.
+  AddToCollection(ctx context.Context, pool *pgxpool.Pool, id int64, collection string) error
+
+  // Remove given book from the named collection.
+  //
+  // Collection names are matched the same way as in
+  // AddToCollection().
+  //
+  // Returns ErrCollectionNotFound if the book is not in the
+  // collection, or a ValidationError if the collection name is empty.
+  RemoveFromCollection(ctx context.Context, pool *pgxpool.Pool, id int64, collection string) error
+
+  // Reorder the books in the given collection.
+  //
+  // The given books are moved to the start of the collection in the
+  // given order, followed by the remaining books in their existing
+  // order.
+  //
+  // Returns ErrCollectionNotFound if the collection does not exist.
+  // Sorting an empty collection succeeds and does nothing.
+  SortCollection(ctx context.Context, pool *pgxpool.Pool, id int64, books []int64) error
+
+  // Delete the given collection.  The books in the collection are not
+  // changed.
+  //
+  // Returns ErrCollectionNotFound if the collection does not exist.
+  DeleteCollection(ctx context.Context, pool *pgxpool.Pool, id int64) error
+
+  // Get revision history of given book, newest first.
+  //
+  // A revision is recorded when a book is uploaded, and whenever its
+  // name, author, or body changes.
+  //
+  // Returns ErrNotFound if the book does not exist or is in the trash.
+  Revisions(ctx context.Context, pool *pgxpool.Pool, id int64) ([]Revision, error)
+
+  // Get given revision of given book, including the book body as of
+  // the revision.
+  //
+  // Returns ErrRevisionNotFound if the book does not exist, is in the
+  // trash, or does not have the given revision.
+  Revision(ctx context.Context, pool *pgxpool.Pool, id, rev int64) (RevisionDetail, error)
+
+  // Restore the name, author, and body of the given book from the
+  // given revision.  The revert is recorded as a new revision.
+  //
+  // Returns ErrRevisionNotFound if the book does not exist, is in the
+  // trash, or does not have the given revision, or ErrDuplicate if the
+  // name of the revision is now used by another book.
+  Revert(ctx context.Context, pool *pgxpool.Pool, id, rev int64) error
+
+  // Get the distinct body hashes of all books, including books in the
+  // trash.  See BodyHash().
+  BodyHashes(ctx context.Context, pool *pgxpool.Pool) ([]string, error)
+
+  // Export metadata and contents of all books, including books in the
+  // trash, to the given writer.
+  //
+  // The export is read from a consistent snapshot of the library.
+  // Errors from the writer are returned unchanged.
+  ExportLibrary(ctx context.Context, pool *pgxpool.Pool, w LibraryWriter) error
+
+  // Restore books from the given source in a single transaction.
+  //
+  // Each book is added with its metadata, tags, creation time, and
+  // trash state, and restored books are added to their collections.
+  // Name conflicts with existing books are resolved with the conflict
+  // policy from the given options; replaced books keep their existing
+  // metadata, and skipped books are not changed.  Returns the outcome
+  // of each book, in the same order as they were read from the source.
+  //
+  // Errors from the source are returned unchanged.
+  //
+  // Returns ErrDuplicate if a book with the same name already exists
+  // and the conflict policy is ConflictFail, ErrDuplicateBody if a
+  // book with the same contents already exists and the duplicate
+  // policy is DuplicateFail, or a ValidationError if a name or the
+  // options are invalid.  `opts.ReplaceId` is not supported.
+  //
+  // If `opts.Partial` is true, then errors for individual books are
+  // reported in their result instead, and the remaining books are
+  // still restored.
+  RestoreLibrary(ctx context.Context, pool *pgxpool.Pool, src LibrarySource, opts UploadOptions) ([]UploadResult, error)
+
+  // Find books with identical or similar contents, excluding books in
+  // the trash.
+  //
+  // Pairs of books with a similarity of at least the given threshold
+  // (from 0 to 1) are reported as near duplicates.  If the threshold
+  // is zero, then DefaultDuplicateThreshold is used.
+  //
+  // Does not change the database: bodies without a cached signature
+  // are signed in memory (see SignBodies()).
+  //
+  // Returns a ValidationError if the threshold is out of range.
+  Duplicates(ctx context.Context, pool *pgxpool.Pool, threshold float64) (Duplicates, error)
+
+  // Add the signatures of all books whose body has no cached signature
+  // to the signature cache used by Duplicates(), including books in
+  // the trash.  Returns the number of signatures added.
+  //
+  // Signatures are cached whenever a book is added or its body
+  // changes, so this is only needed for books which were added before
+  // the signature cache existed.
+  SignBodies(ctx context.Context, pool *pgxpool.Pool) (int, error)
+
+  // Add user with the given name, role, and password.  The password is
+  // stored as a bcrypt hash.
+  //
+  // Returns ErrDuplicateUser if the name is already in use (ignoring
+  // case), or a ValidationError if the name, role, or password is
+  // invalid.
+  AddUser(ctx context.Context, pool *pgxpool.Pool, name string, role Role, password string) (User, error)
+
+  // Get all users, sorted by name.
+  Users(ctx context.Context, pool *pgxpool.Pool) ([]User, error)
+
+  // Set role of the given user.  The role of a logged in user changes
+  // on their next request.
+  //
+  // Returns ErrUserNotFound if the user does not exist, or a
+  // ValidationError if the role is invalid.
+  SetRole(ctx context.Context, pool *pgxpool.Pool, name string, role Role) error
+
+  // Remove the given user and log them out of all of their sessions.
+  // Revisions made by the user are kept.
+  //
+  // Returns ErrUserNotFound if the user does not exist.
+  DeleteUser(ctx context.Context, pool *pgxpool.Pool, name string) error
+
+  // Set password of the given user, and log the user out of all of
+  // their sessions.
+  //
+  // Returns ErrUserNotFound if the user does not exist, or a
+  // ValidationError if the password is invalid.
+  SetPassword(ctx context.Context, pool *pgxpool.Pool, name, password string) error
+
+  // Check user name and password, then create a session which expires
+  // after the given duration.  Expired sessions are removed.
+  //
+  // Returns ErrInvalidLogin if the user does not exist or the password
+  // is incorrect.
+  Login(ctx context.Context, pool *pgxpool.Pool, name, password string, ttl time.Duration) (Session, error)
+
+  // Remove the session with the given token.  Does nothing if the
+  // session does not exist.
+  Logout(ctx context.Context, pool *pgxpool.Pool, token string) error
+
+  // Get the user of the session with the given token.
+  //
+  // Returns ErrSessionNotFound if the session does not exist or has
+  // expired.
+  SessionUser(ctx context.Context, pool *pgxpool.Pool, token string) (User, error)
+
+  // Create API token with the given name and scope for the given user.
+  // The token expires after the given duration.  The returned token is
+  // the only copy of the token; only its hash is stored.
+  //
+  // Returns a ValidationError if the name, scope, or duration is
+  // invalid, or ErrUserNotFound if the user does not exist.
+  CreateToken(ctx context.Context, pool *pgxpool.Pool, userId int, name string, scope TokenScope, ttl time.Duration) (ApiToken, error)
+
+  // Get API tokens of the given user, newest first.  Expired tokens are
+  // included.
+  Tokens(ctx context.Context, pool *pgxpool.Pool, userId int) ([]ApiToken, error)
+
+  // Revoke API token of the given user.
+  //
+  // Returns ErrTokenNotFound if the token does not exist or belongs to
+  // another user.
+  RevokeToken(ctx context.Context, pool *pgxpool.Pool, userId int, id int64) error
+
+  // Get the given API token and its user, and set the last used time of
+  // the token.
+  //
+  // Returns ErrTokenNotFound if the token does not exist or has
+  // expired.
+  TokenUser(ctx context.Context, pool *pgxpool.Pool, token string) (ApiToken, User, error)
 }
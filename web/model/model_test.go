@@ -0,0 +1,139 @@
+package model
+
+import (
+  "bookman/similar"
+  "io"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+func TestSnippetOptionsString(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    val SnippetOptions // snippet options
+    exp string // expected string
+  } {{
+    name: "default",
+    val: SnippetOptions {},
+    exp: "MaxFragments=0, MaxWords=15, MinWords=8, StartSel=\ue000, StopSel=\ue001, FragmentDelimiter=\" … \"",
+  }, {
+    name: "fragments",
+    val: SnippetOptions { MaxFragments: 3, MaxWords: 20 },
+    exp: "MaxFragments=3, MaxWords=20, MinWords=10, StartSel=\ue000, StopSel=\ue001, FragmentDelimiter=\" … \"",
+  }, {
+    name: "min words",
+    val: SnippetOptions { MaxFragments: 1, MaxWords: 2 },
+    exp: "MaxFragments=1, MaxWords=2, MinWords=1, StartSel=\ue000, StopSel=\ue001, FragmentDelimiter=\" … \"",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      got := test.val.String()
+      if got != test.exp {
+        t.Fatalf("got \"%s\", exp \"%s\"", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestFileSource(t *testing.T) {
+  exp := []UploadedFile { UploadedFile { Name: "foo" }, UploadedFile { Name: "bar" } }
+  src := NewFileSource(exp)
+
+  // read books
+  var got []UploadedFile
+  for {
+    file, err := src.Next()
+    if err == io.EOF {
+      break
+    } else if err != nil {
+      t.Fatal(err)
+    }
+
+    got = append(got, file)
+  }
+
+  if !reflect.DeepEqual(got, exp) {
+    t.Fatalf("got %#v, exp %#v", got, exp)
+  }
+
+  // check that source stays at end
+  if _, err := src.Next(); err != io.EOF {
+    t.Fatalf("got %v, exp io.EOF", err)
+  }
+}
+
+func TestNormalizeBody(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    val string // body
+    exp string // expected result
+  } {
+    { "empty", "", "" },
+    { "unchanged", "foo\n\n  bar", "foo\n\n  bar" },
+    { "crlf", "foo\r\nbar\r\n", "foo\nbar" },
+    { "cr", "foo\rbar", "foo\nbar" },
+    { "trailing whitespace", "foo \t\nbar  ", "foo\nbar" },
+    { "trailing lines", "foo\n \n\n", "foo" },
+    { "nfc", "cafe\u0301", "caf\u00e9" },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      if got := NormalizeBody(test.val); got != test.exp {
+        t.Fatalf("got %q, exp %q", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestBodyHash(t *testing.T) {
+  // note: sha256 of "foo"
+  exp := "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+  for _, body := range([]string { "foo", "foo\r\n", "foo  \n\n" }) {
+    if got := BodyHash(body); got != exp {
+      t.Fatalf("%q: got \"%s\", exp \"%s\"", body, got, exp)
+    }
+  }
+}
+
+func TestSignatureDb(t *testing.T) {
+  t.Run("roundtrip", func(t *testing.T) {
+    // note: includes values above the maximum int64
+    exp := similar.Signature { 0, 1, 1 << 63, ^uint64(0) }
+    if got := signatureFromDb(signatureToDb(exp)); !reflect.DeepEqual(got, exp) {
+      t.Fatalf("got %v, exp %v", got, exp)
+    }
+  })
+
+  t.Run("empty", func(t *testing.T) {
+    if got := signatureFromDb([]int64{}); got != nil {
+      t.Fatalf("got %v, exp nil", got)
+    }
+  })
+}
+
+// Check that queries which read or change a single book skip books in
+// the trash, so that they return ErrNotFound for trashed books.
+func TestBookSqlSkipsTrash(t *testing.T) {
+  tests := []struct {
+    name string // query name
+    sql string // query
+  } {
+    { "get", getSql },
+    { "text", textSql },
+    { "edit", editSql },
+    { "delete", deleteSql },
+    { "add tag", addTagSql },
+    { "revert", revertSql },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      if !strings.Contains(test.sql, "deleted_at IS NULL") {
+        t.Fatalf("missing trash filter: %s", test.sql)
+      }
+    })
+  }
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+  "regexp"
+  "strings"
+)
+
+// Search query string, split into the full-text search query and the
+// tag and collection filters.
+type searchQuery struct {
+  Text string // full-text search query (websearch_to_tsquery() syntax)
+  Tags []string // lowercase tag names
+  Collections []string // lowercase collection names
+}
+
+// tag and collection filter (e.g. `tag:poetry`, `collection:"to read"`)
+var searchFilterRe = regexp.MustCompile(`(?i)(?:^|\s)(tag|collection):(?:"([^"]*)"|(\S+))`)
+
+// Append lowercase, normalized name to list of names unless the name
+// is empty or already in the list.
+func appendName(names []string, name string) []string {
+  name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+  if name == "" {
+    return names
+  }
+
+  for _, s := range(names) {
+    if s == name {
+      return names
+    }
+  }
+
+  return append(names, name)
+}
+
+// Split search query string into full-text search query and tag and
+// collection filters.
+func parseSearchQuery(q string) searchQuery {
+  var r searchQuery
+
+  // extract filters
+  text := searchFilterRe.ReplaceAllStringFunc(q, func(s string) string {
+    md := searchFilterRe.FindStringSubmatch(s)
+    name := md[2] + md[3]
+
+    switch strings.ToLower(md[1]) {
+    case "tag":
+      r.Tags = appendName(r.Tags, name)
+    case "collection":
+      r.Collections = appendName(r.Collections, name)
+    }
+
+    return " "
+  })
+
+  // collapse whitespace in remaining query
+  r.Text = strings.Join(strings.Fields(text), " ")
+
+  return r
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+  "reflect"
+  "testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+  tests := []struct {
+    val string // test query string
+    exp searchQuery // expected result
+  } {{
+    val: "",
+    exp: searchQuery {},
+  }, {
+    val: "  dark   stormy  ",
+    exp: searchQuery { Text: "dark stormy" },
+  }, {
+    val: "tag:poetry",
+    exp: searchQuery { Tags: []string { "poetry" } },
+  }, {
+    val: `whale tag:Classics collection:"To  Read" -ahab`,
+    exp: searchQuery {
+      Text: "whale -ahab",
+      Tags: []string { "classics" },
+      Collections: []string { "to read" },
+    },
+  }, {
+    val: `TAG:a tag:b tag:A Collection:c`,
+    exp: searchQuery {
+      Tags: []string { "a", "b" },
+      Collections: []string { "c" },
+    },
+  }, {
+    val: `"tag:poetry" hashtag:foo tag:""`,
+    exp: searchQuery { Text: `"tag:poetry" hashtag:foo` },
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.val, func(t *testing.T) {
+      got := parseSearchQuery(test.val)
+      if !reflect.DeepEqual(got, test.exp) {
+        t.Fatalf("got %#v, exp %#v", got, test.exp)
+      }
+    })
+  }
+}
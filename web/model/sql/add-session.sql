@@ -0,0 +1,2 @@
+INSERT INTO bookman.sessions(token_sha256, user_id, expires_at)
+     VALUES (@token_sha256, @user_id, @expires_at);
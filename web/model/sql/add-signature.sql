@@ -0,0 +1,3 @@
+INSERT INTO bookman.body_signatures(body_sha256, signature)
+  VALUES (@body_sha256, @signature)
+  ON CONFLICT (body_sha256) DO NOTHING;
@@ -0,0 +1,22 @@
+-- (note: returns no rows if the book does not exist or is in the
+-- trash)
+WITH book AS (
+  SELECT id
+    FROM bookman.books
+   WHERE id = @id
+     AND deleted_at IS NULL
+), new_tag AS (
+  INSERT INTO bookman.tags(name)
+  SELECT @tag::text FROM book
+      ON CONFLICT ((LOWER(name))) DO NOTHING
+  RETURNING id
+), tag AS (
+  SELECT id FROM new_tag
+   UNION ALL
+  SELECT id FROM bookman.tags WHERE LOWER(name) = LOWER(@tag::text)
+), link AS (
+  INSERT INTO bookman.book_tags(book_id, tag_id)
+  SELECT book.id, tag.id FROM book, tag
+      ON CONFLICT DO NOTHING
+)
+SELECT id FROM book;
@@ -0,0 +1,30 @@
+-- (note: returns no rows if the book does not exist or is in the
+-- trash)
+WITH book AS (
+  SELECT id
+    FROM bookman.books
+   WHERE id = @id
+     AND deleted_at IS NULL
+), new_collection AS (
+  INSERT INTO bookman.collections(name)
+  SELECT @collection::text FROM book
+      ON CONFLICT ((LOWER(name))) DO NOTHING
+  RETURNING id
+), collection AS (
+  SELECT id FROM new_collection
+   UNION ALL
+  SELECT id FROM bookman.collections WHERE LOWER(name) = LOWER(@collection::text)
+), link AS (
+  -- append book to end of collection
+  INSERT INTO bookman.collection_books(collection_id, book_id, position)
+  SELECT collection.id,
+         book.id,
+         COALESCE((
+           SELECT MAX(position)
+             FROM bookman.collection_books
+            WHERE collection_id = collection.id
+         ), 0) + 1
+    FROM book, collection
+      ON CONFLICT DO NOTHING
+)
+SELECT id FROM book;
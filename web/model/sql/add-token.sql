@@ -0,0 +1,3 @@
+INSERT INTO bookman.api_tokens(user_id, name, token_sha256, scope, expires_at)
+     VALUES (@user_id, @name, @token_sha256, @scope, @expires_at)
+  RETURNING id, name, scope, created_at, expires_at, last_used_at;
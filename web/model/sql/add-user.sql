@@ -0,0 +1,3 @@
+INSERT INTO bookman.users(name, role, password_hash)
+     VALUES (@name, @role, @password_hash)
+  RETURNING id, name, role, created_at;
@@ -0,0 +1,13 @@
+SELECT books.id,
+       books.name,
+       books.author,
+       0.0 AS rank,
+       '' AS snippet
+
+  FROM bookman.book_authors book_authors
+  JOIN bookman.books books ON (books.id = book_authors.book_id)
+
+ WHERE book_authors.author_id = @id
+   AND books.deleted_at IS NULL
+
+ ORDER BY LOWER(books.name), books.id;
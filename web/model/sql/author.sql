@@ -0,0 +1,12 @@
+SELECT authors.id,
+       authors.name,
+       authors.sort_name,
+       COUNT(books.id) AS num_books
+
+  FROM bookman.authors authors
+  LEFT JOIN bookman.book_authors book_authors ON (book_authors.author_id = authors.id)
+  LEFT JOIN bookman.books books ON (books.id = book_authors.book_id AND books.deleted_at IS NULL)
+
+ WHERE authors.id = @id
+
+ GROUP BY authors.id;
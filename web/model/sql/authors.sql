@@ -0,0 +1,14 @@
+SELECT authors.id,
+       authors.name,
+       authors.sort_name,
+       COUNT(*) AS num_books
+
+  FROM bookman.authors authors
+  JOIN bookman.book_authors book_authors ON (book_authors.author_id = authors.id)
+  JOIN bookman.books books ON (books.id = book_authors.book_id)
+
+ WHERE books.deleted_at IS NULL
+
+ GROUP BY authors.id
+
+ ORDER BY LOWER(authors.sort_name), authors.id;
@@ -0,0 +1 @@
+SELECT DISTINCT body_sha256 FROM bookman.books;
@@ -0,0 +1,13 @@
+SELECT books.id,
+       books.name,
+       books.author,
+       0.0 AS rank,
+       '' AS snippet
+
+  FROM bookman.collection_books collection_books
+  JOIN bookman.books books ON (books.id = collection_books.book_id)
+
+ WHERE collection_books.collection_id = @id
+   AND books.deleted_at IS NULL
+
+ ORDER BY collection_books.position, books.id;
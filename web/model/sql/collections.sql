@@ -0,0 +1,11 @@
+SELECT collections.id,
+       collections.name,
+       COUNT(books.id) AS num_books
+
+  FROM bookman.collections collections
+  LEFT JOIN bookman.collection_books collection_books ON (collection_books.collection_id = collections.id)
+  LEFT JOIN bookman.books books ON (books.id = collection_books.book_id AND books.deleted_at IS NULL)
+
+ GROUP BY collections.id
+
+ ORDER BY LOWER(collections.name), collections.id;
@@ -0,0 +1 @@
+DELETE FROM bookman.authors WHERE id = ANY(@dups::int[]);
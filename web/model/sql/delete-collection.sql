@@ -0,0 +1 @@
+DELETE FROM bookman.collections WHERE id = @id;
@@ -0,0 +1 @@
+DELETE FROM bookman.sessions WHERE expires_at <= CURRENT_TIMESTAMP;
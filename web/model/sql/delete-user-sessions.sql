@@ -0,0 +1 @@
+DELETE FROM bookman.sessions WHERE user_id = @user_id;
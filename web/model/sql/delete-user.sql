@@ -0,0 +1,3 @@
+DELETE FROM bookman.users
+ WHERE LOWER(name) = LOWER(@name)
+ RETURNING id;
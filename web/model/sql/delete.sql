@@ -0,0 +1,4 @@
+UPDATE bookman.books
+   SET deleted_at = CURRENT_TIMESTAMP
+ WHERE id = @id
+   AND deleted_at IS NULL;
@@ -0,0 +1,13 @@
+-- (note: the signature is NULL for bodies without a cached signature)
+SELECT books.id,
+       books.name,
+       books.author,
+       books.body_sha256,
+       sigs.signature
+
+  FROM bookman.books books
+  LEFT JOIN bookman.body_signatures sigs ON (sigs.body_sha256 = books.body_sha256)
+
+ WHERE books.deleted_at IS NULL
+
+ ORDER BY books.id;
@@ -1,4 +1,5 @@
 UPDATE bookman.books
    SET name = @name,
        author = @author
- WHERE id = @id;
+ WHERE id = @id
+   AND deleted_at IS NULL;
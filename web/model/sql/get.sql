@@ -0,0 +1,10 @@
+SELECT id,
+       name,
+       author,
+       release_date,
+       language,
+       created_at,
+       body
+  FROM bookman.books
+ WHERE id = @id
+   AND deleted_at IS NULL;
@@ -0,0 +1,20 @@
+SELECT ARRAY(
+         SELECT tags.name
+           FROM bookman.book_tags book_tags
+           JOIN bookman.tags tags ON (tags.id = book_tags.tag_id)
+          WHERE book_tags.book_id = books.id
+          ORDER BY LOWER(tags.name)
+       ) AS tags,
+
+       ARRAY(
+         SELECT collections.name
+           FROM bookman.collection_books collection_books
+           JOIN bookman.collections collections ON (collections.id = collection_books.collection_id)
+          WHERE collection_books.book_id = books.id
+          ORDER BY LOWER(collections.name)
+       ) AS collections
+
+  FROM bookman.books books
+
+ WHERE books.id = @id
+   AND books.deleted_at IS NULL;
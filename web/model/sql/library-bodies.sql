@@ -0,0 +1 @@
+SELECT id, body FROM bookman.books ORDER BY id;
@@ -0,0 +1,19 @@
+SELECT books.id,
+       books.name,
+       books.author,
+       books.release_date,
+       books.language,
+       ARRAY(
+         SELECT tags.name
+           FROM bookman.book_tags book_tags
+           JOIN bookman.tags tags ON (tags.id = book_tags.tag_id)
+          WHERE book_tags.book_id = books.id
+          ORDER BY LOWER(tags.name), tags.id
+       ) AS tags,
+       books.created_at,
+       books.deleted_at,
+       books.body_sha256
+
+  FROM bookman.books books
+
+ ORDER BY books.id;
@@ -0,0 +1,11 @@
+SELECT collections.name,
+       ARRAY(
+         SELECT collection_books.book_id
+           FROM bookman.collection_books collection_books
+          WHERE collection_books.collection_id = collections.id
+          ORDER BY collection_books.position, collection_books.book_id
+       ) AS books
+
+  FROM bookman.collections collections
+
+ ORDER BY LOWER(collections.name), collections.id;
@@ -0,0 +1,3 @@
+UPDATE bookman.books
+   SET created_at = @created_at
+ WHERE id = @id;
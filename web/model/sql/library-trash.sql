@@ -0,0 +1,4 @@
+UPDATE bookman.books
+   SET deleted_at = @deleted_at
+ WHERE id = @id
+   AND deleted_at IS NULL;
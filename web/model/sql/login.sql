@@ -0,0 +1,3 @@
+SELECT id, name, role, created_at, password_hash
+  FROM bookman.users
+ WHERE LOWER(name) = LOWER(@name);
@@ -0,0 +1 @@
+DELETE FROM bookman.sessions WHERE token_sha256 = @token_sha256;
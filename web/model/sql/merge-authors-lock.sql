@@ -0,0 +1,7 @@
+SELECT id
+
+  FROM bookman.authors
+
+ WHERE id = @id OR id = ANY(@dups::int[])
+
+   FOR UPDATE;
@@ -0,0 +1,27 @@
+-- replace the duplicate authors in the author text of their books
+-- with the target author (note: the books_sync_authors trigger then
+-- re-links the books to the target author)
+UPDATE bookman.books books
+   SET author = (
+     SELECT string_agg(names.name, '; ' ORDER BY names.position)
+       FROM (
+         SELECT authors.name,
+                MIN(book_authors.position) AS position
+
+           FROM bookman.book_authors book_authors
+           JOIN bookman.authors authors ON (authors.id = CASE
+             WHEN book_authors.author_id = ANY(@dups::int[]) THEN @id::int
+             ELSE book_authors.author_id
+           END)
+
+          WHERE book_authors.book_id = books.id
+
+          GROUP BY authors.id, authors.name
+       ) names
+   )
+
+ WHERE books.id IN (
+   SELECT book_id
+     FROM bookman.book_authors
+    WHERE author_id = ANY(@dups::int[])
+ );
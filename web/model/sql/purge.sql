@@ -0,0 +1,3 @@
+DELETE FROM bookman.books
+ WHERE id = @id
+   AND deleted_at IS NOT NULL;
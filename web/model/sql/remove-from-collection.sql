@@ -0,0 +1,5 @@
+DELETE FROM bookman.collection_books collection_books
+      USING bookman.collections collections
+      WHERE collections.id = collection_books.collection_id
+        AND collection_books.book_id = @id
+        AND LOWER(collections.name) = LOWER(@collection::text);
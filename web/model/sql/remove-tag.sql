@@ -0,0 +1,5 @@
+DELETE FROM bookman.book_tags book_tags
+      USING bookman.tags tags
+      WHERE tags.id = book_tags.tag_id
+        AND book_tags.book_id = @id
+        AND LOWER(tags.name) = LOWER(@tag::text);
@@ -0,0 +1,6 @@
+UPDATE bookman.books
+   SET body = @body,
+       deleted_at = CASE WHEN @restore THEN NULL ELSE deleted_at END
+ WHERE id = @id
+   AND (@restore OR deleted_at IS NULL)
+RETURNING id, name;
@@ -0,0 +1,4 @@
+UPDATE bookman.books
+   SET deleted_at = NULL
+ WHERE id = @id
+   AND deleted_at IS NOT NULL;
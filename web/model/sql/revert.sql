@@ -0,0 +1,21 @@
+WITH revision AS (
+  SELECT r.name,
+         r.author,
+         (SELECT prev.body
+            FROM bookman.book_revisions prev
+           WHERE prev.book_id = r.book_id
+             AND prev.id <= r.id
+             AND prev.body IS NOT NULL
+           ORDER BY prev.id DESC
+           LIMIT 1) AS body
+    FROM bookman.book_revisions r
+   WHERE r.book_id = @id
+     AND r.id = @revision
+)
+UPDATE bookman.books
+   SET name = revision.name,
+       author = revision.author,
+       body = revision.body
+  FROM revision
+ WHERE books.id = @id
+   AND books.deleted_at IS NULL;
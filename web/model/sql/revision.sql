@@ -0,0 +1,20 @@
+SELECT r.id,
+       r.book_id,
+       r.name,
+       r.author,
+       r.body IS NOT NULL AS body_changed,
+       r.actor,
+       r.created_at,
+       (SELECT prev.body
+          FROM bookman.book_revisions prev
+         WHERE prev.book_id = r.book_id
+           AND prev.id <= r.id
+           AND prev.body IS NOT NULL
+         ORDER BY prev.id DESC
+         LIMIT 1) AS body
+  FROM bookman.book_revisions r
+  JOIN bookman.books b
+    ON (b.id = r.book_id)
+ WHERE r.book_id = @id
+   AND r.id = @revision
+   AND b.deleted_at IS NULL;
@@ -0,0 +1,13 @@
+SELECT r.id,
+       r.book_id,
+       r.name,
+       r.author,
+       r.body IS NOT NULL AS body_changed,
+       r.actor,
+       r.created_at
+  FROM bookman.book_revisions r
+  JOIN bookman.books b
+    ON (b.id = r.book_id)
+ WHERE r.book_id = @id
+   AND b.deleted_at IS NULL
+ ORDER BY r.id DESC;
@@ -0,0 +1,4 @@
+DELETE FROM bookman.api_tokens
+ WHERE id = @id
+   AND user_id = @user_id
+ RETURNING id;
@@ -0,0 +1,27 @@
+-- Count search matches.  Uses the same filters as search.sql; the count
+-- does not depend on the sort order, so the author sort key is not
+-- computed here.
+SELECT COUNT(*)
+
+  FROM bookman.books books
+
+ WHERE deleted_at IS NULL
+   AND (@q::text = '' OR websearch_to_tsquery('english', @q::text) @@ ts_vec)
+
+   -- tag filters (book must have all tags)
+   AND (COALESCE(cardinality(@tags::text[]), 0) = 0 OR cardinality(@tags::text[]) = (
+     SELECT COUNT(*)
+       FROM bookman.book_tags book_tags
+       JOIN bookman.tags tags ON (tags.id = book_tags.tag_id)
+      WHERE book_tags.book_id = books.id
+        AND LOWER(tags.name) = ANY(@tags::text[])
+   ))
+
+   -- collection filters (book must be in all collections)
+   AND (COALESCE(cardinality(@collections::text[]), 0) = 0 OR cardinality(@collections::text[]) = (
+     SELECT COUNT(*)
+       FROM bookman.collection_books collection_books
+       JOIN bookman.collections collections ON (collections.id = collection_books.collection_id)
+      WHERE collection_books.book_id = books.id
+        AND LOWER(collections.name) = ANY(@collections::text[])
+   ));
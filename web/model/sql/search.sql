@@ -1,7 +1,95 @@
-SELECT id,
-       name,
-       author,
-       ts_rank_cd(ts_vec, websearch_to_tsquery('english', @q)) AS rank
-  FROM bookman.books
- WHERE websearch_to_tsquery('english', @q) @@ ts_vec
- ORDER BY rank DESC;
+SELECT page.id,
+       page.name,
+       page.author,
+       page.rank,
+       page.sort_text,
+       page.created_at,
+
+       -- fragments of body around matched terms (empty if there is no query)
+       CASE WHEN @q::text = '' THEN ''
+            ELSE ts_headline('english', books.body, websearch_to_tsquery('english', @q::text), @headline::text)
+       END AS snippet
+
+  FROM (
+    SELECT id,
+           name,
+           author,
+           rank,
+           sort_text,
+           created_at
+
+      FROM (
+        SELECT id,
+               name,
+               author,
+               created_at,
+
+               -- search result rank (zero if there is no query)
+               CASE WHEN @q::text = '' THEN 0.0::float8
+                    ELSE ts_rank_cd(ts_vec, websearch_to_tsquery('english', @q::text))::float8
+               END AS rank,
+
+               -- text sort key (author sort uses the sort name of the
+               -- first linked author, e.g. "twain, mark", or the author
+               -- text if the book has no linked authors)
+               CASE WHEN @sort::text = 'author' THEN COALESCE((
+                      SELECT LOWER(authors.sort_name)
+                        FROM bookman.book_authors book_authors
+                        JOIN bookman.authors authors ON (authors.id = book_authors.author_id)
+                       WHERE book_authors.book_id = books.id
+                       ORDER BY book_authors.position
+                       LIMIT 1
+                    ), LOWER(author))
+                    ELSE LOWER(name)
+               END AS sort_text
+
+          FROM bookman.books books
+
+         WHERE deleted_at IS NULL
+           AND (@q::text = '' OR websearch_to_tsquery('english', @q::text) @@ ts_vec)
+
+           -- tag filters (book must have all tags)
+           AND (COALESCE(cardinality(@tags::text[]), 0) = 0 OR cardinality(@tags::text[]) = (
+             SELECT COUNT(*)
+               FROM bookman.book_tags book_tags
+               JOIN bookman.tags tags ON (tags.id = book_tags.tag_id)
+              WHERE book_tags.book_id = books.id
+                AND LOWER(tags.name) = ANY(@tags::text[])
+           ))
+
+           -- collection filters (book must be in all collections)
+           AND (COALESCE(cardinality(@collections::text[]), 0) = 0 OR cardinality(@collections::text[]) = (
+             SELECT COUNT(*)
+               FROM bookman.collection_books collection_books
+               JOIN bookman.collections collections ON (collections.id = collection_books.collection_id)
+              WHERE collection_books.book_id = books.id
+                AND LOWER(collections.name) = ANY(@collections::text[])
+           ))
+      ) matches
+
+     -- skip rows up to and including the cursor, if any
+     WHERE @after_id::int IS NULL OR CASE @sort::text
+       WHEN 'rank' THEN
+         rank < @after_rank::float8 OR (rank = @after_rank::float8 AND id > @after_id::int)
+       WHEN 'created' THEN
+         created_at < @after_time::timestamptz OR (created_at = @after_time::timestamptz AND id > @after_id::int)
+       ELSE
+         (sort_text, id) > (@after_text::text, @after_id::int)
+     END
+
+     ORDER BY CASE WHEN @sort::text = 'rank' THEN rank END DESC,
+              CASE WHEN @sort::text = 'created' THEN created_at END DESC,
+              CASE WHEN @sort::text IN ('name', 'author') THEN sort_text END,
+              id
+
+     LIMIT @limit::int
+  ) page
+
+  -- join page of results to books in order to generate snippets for
+  -- the page of results only
+  JOIN bookman.books books ON (books.id = page.id)
+
+ ORDER BY CASE WHEN @sort::text = 'rank' THEN page.rank END DESC,
+          CASE WHEN @sort::text = 'created' THEN page.created_at END DESC,
+          CASE WHEN @sort::text IN ('name', 'author') THEN page.sort_text END,
+          page.id;
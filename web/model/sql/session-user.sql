@@ -0,0 +1,5 @@
+SELECT users.id, users.name, users.role, users.created_at
+  FROM bookman.sessions sessions
+  JOIN bookman.users users ON (users.id = sessions.user_id)
+ WHERE sessions.token_sha256 = @token_sha256
+   AND sessions.expires_at > CURRENT_TIMESTAMP;
@@ -0,0 +1 @@
+SELECT set_config('bookman.actor', @actor, true);
@@ -0,0 +1,4 @@
+UPDATE bookman.users
+   SET password_hash = @password_hash
+ WHERE LOWER(name) = LOWER(@name)
+ RETURNING id;
@@ -0,0 +1,4 @@
+UPDATE bookman.users
+   SET role = @role
+ WHERE LOWER(name) = LOWER(@name)
+ RETURNING id;
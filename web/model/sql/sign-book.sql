@@ -0,0 +1,10 @@
+-- (note: returns no rows if the body of the book already has a cached
+-- signature)
+SELECT books.body_sha256, books.body
+  FROM bookman.books books
+ WHERE books.id = @id
+   AND NOT EXISTS (
+     SELECT 1
+       FROM bookman.body_signatures sigs
+      WHERE sigs.body_sha256 = books.body_sha256
+   );
@@ -0,0 +1,25 @@
+-- (note: returns no rows if the collection does not exist; an empty
+-- collection is returned like any other collection)
+WITH collection AS (
+  SELECT id
+    FROM bookman.collections
+   WHERE id = @id
+), sorted AS (
+  -- move the given books to the start of the collection in the given
+  -- order, followed by the remaining books in their existing order
+  SELECT collection_books.book_id,
+         ROW_NUMBER() OVER (ORDER BY ids.ord NULLS LAST, collection_books.position, collection_books.book_id) AS position
+
+    FROM bookman.collection_books collection_books
+    LEFT JOIN unnest(@books::int[]) WITH ORDINALITY AS ids(book_id, ord)
+      ON (ids.book_id = collection_books.book_id)
+
+   WHERE collection_books.collection_id = @id
+), reorder AS (
+  UPDATE bookman.collection_books collection_books
+     SET position = sorted.position
+    FROM sorted
+   WHERE collection_books.collection_id = @id
+     AND collection_books.book_id = sorted.book_id
+)
+SELECT id FROM collection;
@@ -0,0 +1,13 @@
+SELECT tags.id,
+       tags.name,
+       COUNT(*) AS num_books
+
+  FROM bookman.tags tags
+  JOIN bookman.book_tags book_tags ON (book_tags.tag_id = tags.id)
+  JOIN bookman.books books ON (books.id = book_tags.book_id)
+
+ WHERE books.deleted_at IS NULL
+
+ GROUP BY tags.id
+
+ ORDER BY LOWER(tags.name), tags.id;
@@ -3,4 +3,5 @@ SELECT id,
        author,
        body
   FROM bookman.books
- WHERE id = @id;
+ WHERE id = @id
+   AND deleted_at IS NULL;
@@ -0,0 +1,16 @@
+UPDATE bookman.api_tokens tokens
+   SET last_used_at = CURRENT_TIMESTAMP
+  FROM bookman.users users
+ WHERE tokens.token_sha256 = @token_sha256
+   AND tokens.expires_at > CURRENT_TIMESTAMP
+   AND users.id = tokens.user_id
+ RETURNING tokens.id,
+           tokens.name,
+           tokens.scope,
+           tokens.created_at,
+           tokens.expires_at,
+           tokens.last_used_at,
+           users.id AS user_id,
+           users.name AS user_name,
+           users.role AS user_role,
+           users.created_at AS user_created_at;
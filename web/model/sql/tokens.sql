@@ -0,0 +1,4 @@
+SELECT id, name, scope, created_at, expires_at, last_used_at
+  FROM bookman.api_tokens
+ WHERE user_id = @user_id
+ ORDER BY created_at DESC, id DESC;
@@ -0,0 +1,11 @@
+SELECT id,
+       name,
+       author,
+       0.0 AS rank,
+       '' AS snippet
+
+  FROM bookman.books
+
+ WHERE deleted_at IS NOT NULL
+
+ ORDER BY deleted_at DESC
@@ -0,0 +1,14 @@
+-- (note: returns one book for each body without a cached signature,
+-- including books in the trash)
+SELECT books.body_sha256, books.body
+  FROM bookman.books books
+ WHERE books.id IN (
+   SELECT MIN(unsigned.id)
+     FROM bookman.books unsigned
+    WHERE NOT EXISTS (
+      SELECT 1
+        FROM bookman.body_signatures sigs
+       WHERE sigs.body_sha256 = unsigned.body_sha256
+    )
+    GROUP BY unsigned.body_sha256
+ );
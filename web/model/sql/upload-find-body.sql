@@ -0,0 +1,6 @@
+SELECT id, name
+  FROM bookman.books
+ WHERE body_sha256 = bookman.body_sha256(@body)
+   AND id <> @exclude_id
+ ORDER BY id
+ LIMIT 1;
@@ -0,0 +1,3 @@
+SELECT id
+  FROM bookman.books
+ WHERE name = @name;
@@ -0,0 +1,9 @@
+SELECT @name::text || ' (' || n || ')'
+  FROM generate_series(2, 1000) n
+ WHERE NOT EXISTS (
+         SELECT 1
+           FROM bookman.books
+          WHERE name = @name::text || ' (' || n || ')'
+       )
+ ORDER BY n
+ LIMIT 1;
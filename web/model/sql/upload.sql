@@ -1,5 +1,7 @@
-INSERT INTO bookman.books(name, author, body) VALUES (
+INSERT INTO bookman.books(name, author, release_date, language, body) VALUES (
   @name,
-  'Unknown Author',
+  COALESCE(NULLIF(@author::text, ''), 'Unknown Author'),
+  @release_date,
+  @language,
   @body
 ) RETURNING id;
@@ -0,0 +1,3 @@
+SELECT id, name, role, created_at
+  FROM bookman.users
+ ORDER BY LOWER(name), id;
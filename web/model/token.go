@@ -0,0 +1,86 @@
+// Personal API tokens
+package model
+
+import (
+  "time"
+  "unicode/utf8"
+)
+
+// API token scope.
+type TokenScope string
+
+const (
+  ScopeRead TokenScope = "read" // search, read, and download books
+  ScopeWrite TokenScope = "write" // everything the user's role allows
+)
+
+// Is the scope valid?
+func (s TokenScope) Valid() bool {
+  return s == ScopeRead || s == ScopeWrite
+}
+
+// Get the role of requests made with a token with this scope by a user
+// with the given role.  Read tokens are limited to the viewer role, and
+// write tokens have the role of the user.  Returns an empty (invalid)
+// role if the scope or user role is invalid.
+func (s TokenScope) Role(userRole Role) Role {
+  switch {
+  case !userRole.Valid():
+    return ""
+  case s == ScopeRead:
+    return RoleViewer
+  case s == ScopeWrite:
+    return userRole
+  default:
+    return ""
+  }
+}
+
+// Personal API token.
+type ApiToken struct {
+  Id int `db:"id" json:"id"` // token ID
+  Name string `db:"name" json:"name"` // token name
+  Scope TokenScope `db:"scope" json:"scope"` // token scope
+  CreatedAt time.Time `db:"created_at" json:"created_at"` // creation time
+  ExpiresAt time.Time `db:"expires_at" json:"expires_at"` // expiration time
+  LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at"` // time last used, or nil if unused
+
+  // Token.  Only the hash of the token is stored, so the token is only
+  // available when the token is created.
+  Token string `db:"-" json:"token,omitempty"`
+}
+
+// Maximum length of token names, in characters.
+const maxTokenNameLength = 100
+
+// Maximum API token lifetime.
+const MaxTokenTtl = 365 * 24 * time.Hour
+
+// Prefix of API tokens, which makes tokens easy to recognize (for
+// example, by secret scanners).
+const apiTokenPrefix = "bookman_"
+
+// Check token name, scope, and lifetime.
+func validateToken(name string, scope TokenScope, ttl time.Duration) error {
+  if name == "" {
+    return &ValidationError { Field: "name", Message: "empty token name" }
+  } else if utf8.RuneCountInString(name) > maxTokenNameLength {
+    return &ValidationError { Field: "name", Message: "token name too long" }
+  } else if !scope.Valid() {
+    return &ValidationError { Field: "scope", Message: "invalid scope" }
+  } else if ttl <= 0 || ttl > MaxTokenTtl {
+    return &ValidationError { Field: "ttl", Message: "lifetime out of range" }
+  }
+
+  return nil
+}
+
+// Generate random API token.
+func newApiToken() (string, error) {
+  token, err := newToken()
+  if err != nil {
+    return "", err
+  }
+
+  return apiTokenPrefix + token, nil
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestTokenScopeRole(t *testing.T) {
+  tests := []struct {
+    scope TokenScope // token scope
+    role Role // user role
+    exp Role // expected role
+  } {
+    { ScopeRead, RoleViewer, RoleViewer },
+    { ScopeRead, RoleEditor, RoleViewer },
+    { ScopeRead, RoleAdmin, RoleViewer },
+    { ScopeWrite, RoleViewer, RoleViewer },
+    { ScopeWrite, RoleEditor, RoleEditor },
+    { ScopeWrite, RoleAdmin, RoleAdmin },
+    { ScopeRead, Role("root"), "" },
+    { TokenScope("admin"), RoleAdmin, "" },
+  }
+
+  for _, test := range(tests) {
+    t.Run(string(test.scope) + "/" + string(test.role), func(t *testing.T) {
+      if got := test.scope.Role(test.role); got != test.exp {
+        t.Fatalf("got %q, exp %q", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestValidateToken(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    tokenName string // token name
+    scope TokenScope // token scope
+    ttl time.Duration // token lifetime
+    ok bool // expect success?
+  } {
+    { "pass", "upload script", ScopeWrite, time.Hour, true },
+    { "max ttl", "upload script", ScopeRead, MaxTokenTtl, true },
+    { "empty name", "", ScopeRead, time.Hour, false },
+    { "long name", strings.Repeat("x", maxTokenNameLength + 1), ScopeRead, time.Hour, false },
+    { "invalid scope", "upload script", TokenScope("admin"), time.Hour, false },
+    { "zero ttl", "upload script", ScopeRead, 0, false },
+    { "long ttl", "upload script", ScopeRead, MaxTokenTtl + time.Second, false },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      err := validateToken(test.tokenName, test.scope, test.ttl)
+      if test.ok && err != nil {
+        t.Fatal(err)
+      } else if !test.ok && err == nil {
+        t.Fatal("got success, exp err")
+      }
+    })
+  }
+}
+
+func TestNewApiToken(t *testing.T) {
+  got, err := newApiToken()
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if !strings.HasPrefix(got, apiTokenPrefix) || len(got) != len(apiTokenPrefix) + 43 {
+    t.Fatalf("got %q", got)
+  }
+}
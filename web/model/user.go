@@ -0,0 +1,163 @@
+// User accounts, passwords, and session tokens
+package model
+
+import (
+  "crypto/rand"
+  "crypto/sha256"
+  "encoding/base64"
+  "encoding/hex"
+  "golang.org/x/crypto/bcrypt"
+  "strings"
+  "sync"
+  "time"
+  "unicode"
+  "unicode/utf8"
+)
+
+// User role.  Each role can do everything the roles before it can do.
+type Role string
+
+const (
+  RoleViewer Role = "viewer" // search, read, and download books
+  RoleEditor Role = "editor" // also upload and edit books, tags, and collections
+  RoleAdmin Role = "admin" // also delete books and manage users
+)
+
+// Rank of each role, used to compare roles.
+var roleRanks = map[Role]int {
+  RoleViewer: 1,
+  RoleEditor: 2,
+  RoleAdmin: 3,
+}
+
+// Is the role valid?
+func (r Role) Valid() bool {
+  _, ok := roleRanks[r]
+  return ok
+}
+
+// Does this role include the given role?  Invalid roles do not include
+// any role.
+func (r Role) Includes(other Role) bool {
+  return r.Valid() && other.Valid() && roleRanks[r] >= roleRanks[other]
+}
+
+// Check role.
+func validateRole(role Role) error {
+  if !role.Valid() {
+    return &ValidationError { Field: "role", Message: "invalid role" }
+  }
+
+  return nil
+}
+
+// User account.
+type User struct {
+  Id int `db:"id" json:"id"` // user ID
+  Name string `db:"name" json:"name"` // user name
+  Role Role `db:"role" json:"role"` // user role
+  CreatedAt time.Time `db:"created_at" json:"created_at"` // creation time
+}
+
+// Login session.
+type Session struct {
+  // Session token.  Only the hash of the token is stored, so the token
+  // is only available when the session is created.
+  Token string
+
+  User User // logged in user
+  ExpiresAt time.Time // expiration time
+}
+
+// Maximum length of user names, in characters.
+const maxUserNameLength = 64
+
+// Minimum length of passwords, in bytes.
+const minPasswordLength = 8
+
+// Maximum length of passwords, in bytes.  bcrypt ignores bytes past
+// the first 72.
+const maxPasswordLength = 72
+
+// Number of random bytes in session tokens.
+const tokenSize = 32
+
+// Check user name.  User names must not be empty, must not be too
+// long, and must not contain whitespace or control characters.
+func validateUserName(name string) error {
+  if name == "" {
+    return &ValidationError { Field: "name", Message: "empty user name" }
+  } else if utf8.RuneCountInString(name) > maxUserNameLength {
+    return &ValidationError { Field: "name", Message: "user name too long" }
+  } else if strings.IndexFunc(name, func(r rune) bool {
+    return unicode.IsSpace(r) || unicode.IsControl(r)
+  }) >= 0 {
+    return &ValidationError { Field: "name", Message: "user name contains whitespace" }
+  }
+
+  return nil
+}
+
+// Check password length.
+func validatePassword(password string) error {
+  if len(password) < minPasswordLength {
+    return &ValidationError { Field: "password", Message: "password too short" }
+  } else if len(password) > maxPasswordLength {
+    return &ValidationError { Field: "password", Message: "password too long" }
+  }
+
+  return nil
+}
+
+// Check password, then get bcrypt hash of password.
+func hashPassword(password string) (string, error) {
+  if err := validatePassword(password); err != nil {
+    return "", err
+  }
+
+  hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+  if err != nil {
+    return "", err
+  }
+
+  return string(hash), nil
+}
+
+// bcrypt hash checked when a user does not exist, so that failed
+// logins take the same time whether or not the user exists.  Created
+// on first use.
+var dummyPasswordHash struct {
+  once sync.Once
+  hash []byte
+}
+
+// Check password against bcrypt hash.  If the hash is empty, then a
+// dummy hash is checked and false is returned.
+func checkPassword(hash, password string) bool {
+  if hash == "" {
+    dummyPasswordHash.once.Do(func() {
+      dummyPasswordHash.hash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+    })
+    bcrypt.CompareHashAndPassword(dummyPasswordHash.hash, []byte(password))
+    return false
+  }
+
+  return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// Generate random URL-safe token.
+func newToken() (string, error) {
+  buf := make([]byte, tokenSize)
+  if _, err := rand.Read(buf); err != nil {
+    return "", err
+  }
+
+  return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Get hex-encoded SHA-256 hash of token.  Tokens are stored as
+// hashes, so the stored hashes cannot be used to log in.
+func tokenHash(token string) string {
+  sum := sha256.Sum256([]byte(token))
+  return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,124 @@
+package model
+
+import (
+  "strings"
+  "testing"
+)
+
+func TestValidateUserName(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    val string // user name
+    ok bool // expect success?
+  } {
+    { "pass", "alice", true },
+    { "unicode", "zoë", true },
+    { "empty", "", false },
+    { "too long", strings.Repeat("x", maxUserNameLength + 1), false },
+    { "space", "alice smith", false },
+    { "control", "alice\x00", false },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      err := validateUserName(test.val)
+      if test.ok && err != nil {
+        t.Fatal(err)
+      } else if !test.ok && err == nil {
+        t.Fatal("got success, exp err")
+      }
+    })
+  }
+}
+
+func TestRoleIncludes(t *testing.T) {
+  tests := []struct {
+    role Role // user role
+    other Role // required role
+    exp bool // expected result
+  } {
+    { RoleViewer, RoleViewer, true },
+    { RoleViewer, RoleEditor, false },
+    { RoleViewer, RoleAdmin, false },
+    { RoleEditor, RoleViewer, true },
+    { RoleEditor, RoleEditor, true },
+    { RoleEditor, RoleAdmin, false },
+    { RoleAdmin, RoleViewer, true },
+    { RoleAdmin, RoleEditor, true },
+    { RoleAdmin, RoleAdmin, true },
+    { Role(""), RoleViewer, false },
+    { Role("root"), RoleViewer, false },
+    { RoleAdmin, Role("root"), false },
+  }
+
+  for _, test := range(tests) {
+    t.Run(string(test.role) + "/" + string(test.other), func(t *testing.T) {
+      if got := test.role.Includes(test.other); got != test.exp {
+        t.Fatalf("got %v, exp %v", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestValidateRole(t *testing.T) {
+  for _, role := range([]Role { RoleViewer, RoleEditor, RoleAdmin }) {
+    if err := validateRole(role); err != nil {
+      t.Fatalf("%s: %v", role, err)
+    }
+  }
+
+  for _, role := range([]Role { "", "root", "Admin" }) {
+    if err := validateRole(role); err == nil {
+      t.Fatalf("%q: got success, exp err", role)
+    }
+  }
+}
+
+func TestHashPassword(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    hash, err := hashPassword("correct horse")
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if !checkPassword(hash, "correct horse") {
+      t.Fatal("got false, exp true")
+    } else if checkPassword(hash, "wrong horse") {
+      t.Fatal("got true, exp false")
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    for _, s := range([]string { "short", strings.Repeat("x", maxPasswordLength + 1) }) {
+      if _, err := hashPassword(s); err == nil {
+        t.Fatalf("%q: got success, exp err", s)
+      }
+    }
+  })
+
+  t.Run("missing hash", func(t *testing.T) {
+    if checkPassword("", "dummy password") {
+      t.Fatal("got true, exp false")
+    }
+  })
+}
+
+func TestNewToken(t *testing.T) {
+  a, err := newToken()
+  if err != nil {
+    t.Fatal(err)
+  }
+  b, err := newToken()
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if len(a) != 43 || a == b {
+    t.Fatalf("got %q and %q", a, b)
+  }
+
+  // check that token hashes are stable and differ from the token
+  if tokenHash(a) != tokenHash(a) || tokenHash(a) == a || len(tokenHash(a)) != 64 {
+    t.Fatalf("got %q", tokenHash(a))
+  }
+}
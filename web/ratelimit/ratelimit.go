@@ -0,0 +1,109 @@
+// Token bucket rate limiting by key.
+//
+// Each key (for example, a client address or user ID) has a bucket
+// which holds up to a fixed number of tokens (the burst size) and is
+// refilled at a fixed rate.  Each request takes one token; requests
+// are rejected when the bucket is empty.
+package ratelimit
+
+import (
+  "math"
+  "sync"
+  "time"
+)
+
+// Interval between sweeps of full buckets.
+const sweepInterval = time.Minute
+
+// Token bucket of a single key.
+type bucket struct {
+  tokens float64 // available tokens, as of last update
+  last time.Time // time tokens were last updated
+}
+
+// Rate limiter.  Safe for concurrent use.
+type Limiter struct {
+  rate float64 // tokens added per second
+  burst float64 // maximum tokens in each bucket
+
+  // current time (replaced in tests)
+  now func() time.Time
+
+  mu sync.Mutex // guards buckets and lastSweep
+  buckets map[string]*bucket // buckets by key
+  lastSweep time.Time // time of last sweep
+}
+
+// Create rate limiter which allows the given number of requests per
+// minute for each key, with bursts of up to the given number of
+// requests.  The burst size is at least 1.
+//
+// Returns nil if the rate is zero or negative.  A nil limiter allows
+// all requests.
+func New(perMinute, burst int) *Limiter {
+  if perMinute <= 0 {
+    return nil
+  }
+
+  return &Limiter {
+    rate: float64(perMinute) / 60,
+    burst: math.Max(float64(burst), 1),
+    now: time.Now,
+    buckets: map[string]*bucket {},
+  }
+}
+
+// Take a token from the bucket of the given key.
+//
+// Returns true if the request is allowed.  If the request is not
+// allowed, then also returns the time until a token is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+  if l == nil {
+    return true, 0
+  }
+
+  l.mu.Lock()
+  defer l.mu.Unlock()
+
+  // remove full buckets, so that the map does not grow without bound
+  now := l.now()
+  if now.Sub(l.lastSweep) >= sweepInterval {
+    l.sweep(now)
+  }
+
+  // get bucket (new buckets are full), add tokens since last update
+  b, ok := l.buckets[key]
+  if !ok {
+    b = &bucket { tokens: l.burst, last: now }
+    l.buckets[key] = b
+  }
+  b.tokens = l.fill(b, now)
+  b.last = now
+
+  // check for available token
+  if b.tokens < 1 {
+    wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+    return false, wait
+  }
+
+  // take token
+  b.tokens -= 1
+  return true, 0
+}
+
+// Get tokens in bucket at the given time.
+func (l *Limiter) fill(b *bucket, now time.Time) float64 {
+  return math.Min(b.tokens + now.Sub(b.last).Seconds() * l.rate, l.burst)
+}
+
+// Remove buckets which are full at the given time.  A full bucket is
+// the same as a missing bucket.
+func (l *Limiter) sweep(now time.Time) {
+  for key, b := range(l.buckets) {
+    if l.fill(b, now) >= l.burst {
+      delete(l.buckets, key)
+    }
+  }
+
+  l.lastSweep = now
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+  "testing"
+  "time"
+)
+
+// Create limiter with a fake clock, return limiter and a function
+// which advances the clock.
+func newTestLimiter(perMinute, burst int) (*Limiter, func(time.Duration)) {
+  now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+  l := New(perMinute, burst)
+  l.now = func() time.Time { return now }
+  return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestNew(t *testing.T) {
+  if l := New(0, 10); l != nil {
+    t.Fatalf("got %v, exp nil", l)
+  }
+
+  // check that a nil limiter allows all requests
+  var l *Limiter
+  if ok, _ := l.Allow("foo"); !ok {
+    t.Fatal("got not allowed, exp allowed")
+  }
+
+  // check minimum burst size
+  if l := New(60, 0); l.burst != 1 {
+    t.Fatalf("got burst %v, exp 1", l.burst)
+  }
+}
+
+func TestAllow(t *testing.T) {
+  // 60 requests per minute (1 per second), bursts of 3
+  l, advance := newTestLimiter(60, 3)
+
+  // burst
+  for i := 0; i < 3; i++ {
+    if ok, _ := l.Allow("a"); !ok {
+      t.Fatalf("request %d: got not allowed, exp allowed", i)
+    }
+  }
+
+  // empty bucket
+  ok, wait := l.Allow("a")
+  if ok {
+    t.Fatal("got allowed, exp not allowed")
+  } else if wait != time.Second {
+    t.Fatalf("got wait %v, exp %v", wait, time.Second)
+  }
+
+  // other keys have separate buckets
+  if ok, _ := l.Allow("b"); !ok {
+    t.Fatal("got not allowed, exp allowed")
+  }
+
+  // partial refill
+  advance(500 * time.Millisecond)
+  if ok, wait := l.Allow("a"); ok || wait != 500 * time.Millisecond {
+    t.Fatalf("got (%v, %v), exp (false, 500ms)", ok, wait)
+  }
+
+  // refill one token
+  advance(500 * time.Millisecond)
+  if ok, _ := l.Allow("a"); !ok {
+    t.Fatal("got not allowed, exp allowed")
+  }
+  if ok, _ := l.Allow("a"); ok {
+    t.Fatal("got allowed, exp not allowed")
+  }
+
+  // refill is capped at the burst size
+  advance(time.Hour)
+  for i := 0; i < 3; i++ {
+    if ok, _ := l.Allow("a"); !ok {
+      t.Fatalf("request %d: got not allowed, exp allowed", i)
+    }
+  }
+  if ok, _ := l.Allow("a"); ok {
+    t.Fatal("got allowed, exp not allowed")
+  }
+}
+
+func TestSweep(t *testing.T) {
+  l, advance := newTestLimiter(60, 2)
+
+  // use buckets
+  l.Allow("a")
+  l.Allow("b")
+  l.Allow("b")
+  if got := len(l.buckets); got != 2 {
+    t.Fatalf("got %d buckets, exp 2", got)
+  }
+
+  // after the sweep interval, both buckets are full and removed
+  // (except for the bucket of the request which triggered the sweep)
+  advance(sweepInterval)
+  l.Allow("c")
+  if got := len(l.buckets); got != 1 {
+    t.Fatalf("got %d buckets, exp 1", got)
+  }
+}
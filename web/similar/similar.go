@@ -0,0 +1,223 @@
+// Near-duplicate text detection with word shingles and MinHash.
+//
+// Each text is split into overlapping sequences of words (shingles).
+// The similarity of two texts is the Jaccard similarity of their
+// shingle sets, which is estimated from a fixed-size MinHash signature
+// of each text.  Candidate pairs are found with locality-sensitive
+// hashing (LSH), so texts do not need to be compared pairwise.
+//
+// Small differences such as different license boilerplate or
+// formatting change only a small fraction of the shingles of a book,
+// so editions of the same book have a high similarity.
+package similar
+
+import (
+  "sort"
+  "strings"
+  "unicode"
+)
+
+// Number of words in each shingle.
+const shingleSize = 5
+
+// Number of hash functions in each signature.
+const numHashes = 128
+
+// Number of LSH bands.  Each band contains numHashes / numBands
+// signature values.
+//
+// With 32 bands of 4 values, pairs with a similarity of 0.8 or more
+// are found with a probability of over 99.99%.
+const numBands = 32
+
+// Number of signature values in each LSH band.
+const bandSize = numHashes / numBands
+
+// Mix 64-bit value (splitmix64 finalizer).
+func mix(x uint64) uint64 {
+  x ^= x >> 30
+  x *= 0xbf58476d1ce4e5b9
+  x ^= x >> 27
+  x *= 0x94d049bb133111eb
+  x ^= x >> 31
+  return x
+}
+
+// seeds of signature hash functions
+var seeds = func() [numHashes]uint64 {
+  var r [numHashes]uint64
+  for i := range(r) {
+    r[i] = mix(uint64(i) + 1)
+  }
+  return r
+}()
+
+// Hash word with FNV-1a.
+func hashWord(w string) uint64 {
+  h := uint64(14695981039346656037)
+  for i := 0; i < len(w); i++ {
+    h ^= uint64(w[i])
+    h *= 1099511628211
+  }
+  return h
+}
+
+// Split text into lowercase words, ignoring punctuation.
+func words(text string) []string {
+  return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+    return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+  })
+}
+
+// MinHash signature of a text.  Empty if the text contains no words.
+type Signature []uint64
+
+// Get MinHash signature of text.
+func Sign(text string) Signature {
+  // hash words
+  ws := words(text)
+  if len(ws) == 0 {
+    return nil
+  }
+  hs := make([]uint64, len(ws))
+  for i, w := range(ws) {
+    hs[i] = hashWord(w)
+  }
+
+  // get number of shingles
+  // (note: texts shorter than a shingle are one shingle)
+  n := len(hs) - shingleSize + 1
+  if n < 1 {
+    n = 1
+  }
+
+  // init signature
+  sig := make(Signature, numHashes)
+  for i := range(sig) {
+    sig[i] = ^uint64(0)
+  }
+
+  // add shingles
+  for i := 0; i < n; i++ {
+    // hash shingle
+    var x uint64
+    for j := i; j < i + shingleSize && j < len(hs); j++ {
+      x = mix(x ^ hs[j])
+    }
+
+    // update minimum of each hash function
+    for k, seed := range(seeds) {
+      if v := mix(x ^ seed); v < sig[k] {
+        sig[k] = v
+      }
+    }
+  }
+
+  return sig
+}
+
+// Get estimated Jaccard similarity of the shingles of two texts, from
+// 0 (no shingles in common) to 1 (identical shingles).  Returns 0 if
+// either signature is empty.
+func (s Signature) Similarity(o Signature) float64 {
+  if len(s) != numHashes || len(o) != numHashes {
+    return 0
+  }
+
+  n := 0
+  for i := range(s) {
+    if s[i] == o[i] {
+      n++
+    }
+  }
+
+  return float64(n) / numHashes
+}
+
+// LSH bucket key.
+type bucketKey struct {
+  band int // band index
+  hash uint64 // hash of signature values in band
+}
+
+// Index of signatures for finding similar pairs.
+type Index struct {
+  sigs map[int]Signature // signatures, by ID
+  buckets map[bucketKey][]int // IDs, by LSH bucket
+}
+
+// Create empty index.
+func NewIndex() *Index {
+  return &Index {
+    sigs: map[int]Signature{},
+    buckets: map[bucketKey][]int{},
+  }
+}
+
+// Add signature with given ID to index.  Empty signatures are ignored.
+func (ix *Index) Add(id int, sig Signature) {
+  if len(sig) != numHashes {
+    return
+  }
+  ix.sigs[id] = sig
+
+  // add ID to the bucket of each band
+  for band := 0; band < numBands; band++ {
+    var h uint64
+    for _, v := range(sig[band * bandSize:(band + 1) * bandSize]) {
+      h = mix(h ^ v)
+    }
+
+    key := bucketKey { band, h }
+    ix.buckets[key] = append(ix.buckets[key], id)
+  }
+}
+
+// Pair of similar signatures.
+type Pair struct {
+  A, B int // IDs, where A < B
+  Similarity float64 // estimated similarity
+}
+
+// Get pairs of signatures with a similarity of at least the given
+// threshold, sorted by similarity in descending order, then by ID.
+//
+// Pairs are found with LSH, so pairs with a similarity much lower
+// than 0.8 may not be found.
+func (ix *Index) Pairs(threshold float64) []Pair {
+  pairs := []Pair{}
+  seen := map[[2]int]bool{}
+
+  for _, ids := range(ix.buckets) {
+    for i := 0; i < len(ids); i++ {
+      for j := i + 1; j < len(ids); j++ {
+        // get ordered IDs, skip pairs which were already checked
+        a, b := ids[i], ids[j]
+        if a > b {
+          a, b = b, a
+        }
+        if a == b || seen[[2]int { a, b }] {
+          continue
+        }
+        seen[[2]int { a, b }] = true
+
+        // add similar pairs
+        if sim := ix.sigs[a].Similarity(ix.sigs[b]); sim >= threshold {
+          pairs = append(pairs, Pair { a, b, sim })
+        }
+      }
+    }
+  }
+
+  // sort pairs
+  sort.Slice(pairs, func(i, j int) bool {
+    if pairs[i].Similarity != pairs[j].Similarity {
+      return pairs[i].Similarity > pairs[j].Similarity
+    } else if pairs[i].A != pairs[j].A {
+      return pairs[i].A < pairs[j].A
+    }
+    return pairs[i].B < pairs[j].B
+  })
+
+  return pairs
+}
@@ -0,0 +1,98 @@
+package similar
+
+import (
+  "fmt"
+  "strings"
+  "testing"
+)
+
+// Generate text of n distinct words.
+func genText(prefix string, n int) string {
+  ws := make([]string, n)
+  for i := range(ws) {
+    ws[i] = fmt.Sprintf("%s%d", prefix, i)
+  }
+  return strings.Join(ws, " ")
+}
+
+func TestSimilarity(t *testing.T) {
+  body := genText("w", 2000)
+
+  tests := []struct {
+    name string // test name
+    a, b string // texts
+    min, max float64 // expected similarity range
+  } {{
+    name: "identical",
+    a: body,
+    b: body,
+    min: 1,
+    max: 1,
+  }, {
+    name: "case and punctuation",
+    a: "The quick brown fox jumps over the lazy dog.",
+    b: "the QUICK brown fox -- jumps over the lazy dog",
+    min: 1,
+    max: 1,
+  }, {
+    name: "different boilerplate",
+    a: "Project Gutenberg header " + genText("h", 50) + " " + body,
+    b: body + " " + genText("f", 50) + " end of license",
+    min: 0.8,
+    max: 1,
+  }, {
+    name: "different",
+    a: body,
+    b: genText("x", 2000),
+    min: 0,
+    max: 0.05,
+  }, {
+    name: "short",
+    a: "foo bar",
+    b: "foo bar",
+    min: 1,
+    max: 1,
+  }, {
+    name: "empty",
+    a: "",
+    b: "",
+    min: 0,
+    max: 0,
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      got := Sign(test.a).Similarity(Sign(test.b))
+      if got < test.min || got > test.max {
+        t.Fatalf("got %f, exp %f-%f", got, test.min, test.max)
+      }
+    })
+  }
+}
+
+func TestIndexPairs(t *testing.T) {
+  body := genText("w", 2000)
+
+  // build index
+  ix := NewIndex()
+  ix.Add(3, Sign(body + " " + genText("f", 20)))
+  ix.Add(1, Sign(body))
+  ix.Add(2, Sign(genText("x", 2000)))
+  ix.Add(4, Sign(""))
+  ix.Add(5, Sign(body))
+
+  // check pairs
+  got := ix.Pairs(0.8)
+  exp := [][2]int { { 1, 5 }, { 1, 3 }, { 3, 5 } }
+  if len(got) != len(exp) {
+    t.Fatalf("got %v, exp %v", got, exp)
+  }
+  for i, p := range(got) {
+    if p.A != exp[i][0] || p.B != exp[i][1] {
+      t.Fatalf("got %v, exp %v", got, exp)
+    }
+  }
+  if got[0].Similarity != 1 {
+    t.Fatalf("got %f, exp 1", got[0].Similarity)
+  }
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+  "bookman/app"
+  "bookman/model"
+  "bufio"
+  "context"
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+  "log"
+  "os"
+  "strings"
+  "time"
+)
+
+// Read password from the first line of the given reader.  The trailing
+// newline (LF or CRLF) is removed.
+func readPassword(r io.Reader) (string, error) {
+  line, err := bufio.NewReader(r).ReadString('\n')
+  if err != nil && err != io.EOF {
+    return "", err
+  }
+
+  password := strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+  if password == "" {
+    return "", errors.New("missing password")
+  }
+
+  return password, nil
+}
+
+// user command options.
+type userOptions struct {
+  sub string // subcommand
+  name string // user name (empty for list)
+  role model.Role // role (add and role only)
+}
+
+// Parse user command arguments.
+func parseUserArgs(args []string) (userOptions, error) {
+  // get subcommand
+  if len(args) < 1 {
+    return userOptions{}, errors.New("missing user command")
+  }
+  opts := userOptions { sub: args[0] }
+  args = args[1:]
+
+  switch opts.sub {
+  case "list":
+    if len(args) != 0 {
+      return userOptions{}, errors.New("usage: user list")
+    }
+  case "add":
+    // parse flags
+    var role string
+    flags := flag.NewFlagSet("user add", flag.ContinueOnError)
+    flags.StringVar(&role, "role", string(model.RoleViewer), "user role: viewer, editor, or admin")
+    if err := flags.Parse(args); err != nil {
+      return userOptions{}, err
+    }
+    if flags.NArg() != 1 {
+      return userOptions{}, errors.New("usage: user add [-role role] <name>")
+    }
+    opts.name, opts.role = flags.Arg(0), model.Role(role)
+  case "passwd", "delete":
+    if len(args) != 1 {
+      return userOptions{}, fmt.Errorf("usage: user %s <name>", opts.sub)
+    }
+    opts.name = args[0]
+  case "role":
+    if len(args) != 2 {
+      return userOptions{}, errors.New("usage: user role <name> <role>")
+    }
+    opts.name, opts.role = args[0], model.Role(args[1])
+  default:
+    return userOptions{}, fmt.Errorf("unknown user command: %s", opts.sub)
+  }
+
+  // check role
+  if opts.role != "" && !opts.role.Valid() {
+    return userOptions{}, fmt.Errorf("unknown role: %s", opts.role)
+  }
+
+  // return success
+  return opts, nil
+}
+
+// user command.
+//
+// Usage:
+//
+//   bookman user list                    # list users
+//   bookman user add [-role role] <name> # add user (default role: viewer)
+//   bookman user passwd <name>           # set user password
+//   bookman user role <name> <role>      # set user role
+//   bookman user delete <name>           # remove user
+//
+// Roles are `viewer`, `editor`, and `admin`.  The password is read
+// from the first line of standard input, so it does not appear in the
+// process list or shell history:
+//
+//   bookman user add alice < password.txt
+//
+// Setting a password or removing a user logs the user out of all of
+// their sessions.
+func userCommand(ctx context.Context, config app.Config, args []string) error {
+  // parse arguments
+  opts, err := parseUserArgs(args)
+  if err != nil {
+    return err
+  }
+
+  // read password
+  var password string
+  if opts.sub == "add" || opts.sub == "passwd" {
+    if password, err = readPassword(os.Stdin); err != nil {
+      return err
+    }
+  }
+
+  // create application context from context and config
+  appCtx, err := app.NewContext(ctx, config)
+  if err != nil {
+    return err
+  }
+  defer appCtx.Pool.Close()
+
+  switch opts.sub {
+  case "list":
+    // print users
+    users, err := appCtx.Model.Users(ctx, appCtx.Pool)
+    if err != nil {
+      return err
+    }
+    for _, user := range(users) {
+      fmt.Printf("%s\t%s\t%s\n", user.Name, user.Role, user.CreatedAt.Format(time.RFC3339))
+    }
+  case "add":
+    user, err := appCtx.Model.AddUser(ctx, appCtx.Pool, opts.name, opts.role, password)
+    if err != nil {
+      return err
+    }
+    log.Printf("added user %s (id %d, role %s)", user.Name, user.Id, user.Role)
+  case "passwd":
+    if err := appCtx.Model.SetPassword(ctx, appCtx.Pool, opts.name, password); err != nil {
+      return err
+    }
+    log.Printf("set password of user %s", opts.name)
+  case "role":
+    if err := appCtx.Model.SetRole(ctx, appCtx.Pool, opts.name, opts.role); err != nil {
+      return err
+    }
+    log.Printf("set role of user %s to %s", opts.name, opts.role)
+  case "delete":
+    if err := appCtx.Model.DeleteUser(ctx, appCtx.Pool, opts.name); err != nil {
+      return err
+    }
+    log.Printf("removed user %s", opts.name)
+  }
+
+  return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+  "bookman/model"
+  "strings"
+  "testing"
+)
+
+func TestReadPassword(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    tests := []struct {
+      name string // test name
+      val string // input
+      exp string // expected password
+    } {
+      { "lf", "secret 123\nfoo\n", "secret 123" },
+      { "crlf", "secret123\r\n", "secret123" },
+      { "no newline", "secret123", "secret123" },
+    }
+
+    for _, test := range(tests) {
+      t.Run(test.name, func(t *testing.T) {
+        got, err := readPassword(strings.NewReader(test.val))
+        if err != nil {
+          t.Fatal(err)
+        } else if got != test.exp {
+          t.Fatalf("got %q, exp %q", got, test.exp)
+        }
+      })
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    for _, val := range([]string { "", "\n" }) {
+      if got, err := readPassword(strings.NewReader(val)); err == nil {
+        t.Fatalf("%q: got %q, exp err", val, got)
+      }
+    }
+  })
+}
+
+func TestParseUserArgs(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    tests := []struct {
+      name string // test name
+      args []string // command arguments
+      exp userOptions // expected options
+    } {{
+      name: "list",
+      args: []string { "list" },
+      exp: userOptions { sub: "list" },
+    }, {
+      name: "add",
+      args: []string { "add", "alice" },
+      exp: userOptions { sub: "add", name: "alice", role: model.RoleViewer },
+    }, {
+      name: "add with role",
+      args: []string { "add", "-role", "admin", "alice" },
+      exp: userOptions { sub: "add", name: "alice", role: model.RoleAdmin },
+    }, {
+      name: "passwd",
+      args: []string { "passwd", "alice" },
+      exp: userOptions { sub: "passwd", name: "alice" },
+    }, {
+      name: "role",
+      args: []string { "role", "alice", "editor" },
+      exp: userOptions { sub: "role", name: "alice", role: model.RoleEditor },
+    }, {
+      name: "delete",
+      args: []string { "delete", "alice" },
+      exp: userOptions { sub: "delete", name: "alice" },
+    }}
+
+    for _, test := range(tests) {
+      t.Run(test.name, func(t *testing.T) {
+        got, err := parseUserArgs(test.args)
+        if err != nil {
+          t.Fatal(err)
+        } else if got != test.exp {
+          t.Fatalf("got %#v, exp %#v", got, test.exp)
+        }
+      })
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    for _, args := range([][]string {
+      []string {},
+      []string { "foo", "alice" },
+      []string { "list", "alice" },
+      []string { "add" },
+      []string { "add", "-role", "root", "alice" },
+      []string { "passwd" },
+      []string { "role", "alice" },
+      []string { "role", "alice", "root" },
+      []string { "delete", "alice", "bob" },
+    }) {
+      if got, err := parseUserArgs(args); err == nil {
+        t.Fatalf("%v: got %#v, exp err", args, got)
+      }
+    }
+  })
+}
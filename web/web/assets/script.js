@@ -7,11 +7,16 @@
   const qsa = (s) => D.querySelectorAll(s);
   const on = (el, ev, fn) => el.addEventListener(ev, fn);
 
-  // cache search field and rows element
+  // cache search field, sort field, and rows element
   const field = get('q'),
+        sort = get('sort'),
         books = get('books'),
         upload = get('upload');
 
+  // search state: cursor for next page of results, request sequence
+  // number (used to ignore stale pages), and loading flag
+  const S = { next: '', seq: 0, busy: false };
+
   // html escape
   const h = (v) => {
     return String(v).replaceAll('&', '&amp;')
@@ -21,6 +26,10 @@
       .replaceAll('"', '&quot;');
   };
 
+  // html escape search result snippet, then replace the match markers
+  // (U+E000 and U+E001) with highlight tags
+  const snippet = (v) => h(v || '').replaceAll('\ue000', '<mark>').replaceAll('\ue001', '</mark>');
+
   // templates
   const T = {
     // result template
@@ -42,10 +51,90 @@
           </svg>
         </span>
 
-        ${h(row.name)}, by ${h(row.author)}
+        <span class='delete-book'>
+          <svg xmlns='http://www.w3.org/2000/svg' width='16' height='16' fill='currentColor' class='bi bi-trash' viewBox='0 0 16 16'>
+            <path d='M5.5 5.5A.5.5 0 0 1 6 6v6a.5.5 0 0 1-1 0V6a.5.5 0 0 1 .5-.5zm2.5 0a.5.5 0 0 1 .5.5v6a.5.5 0 0 1-1 0V6a.5.5 0 0 1 .5-.5zm3 .5a.5.5 0 0 0-1 0v6a.5.5 0 0 0 1 0V6z'/>
+            <path fill-rule='evenodd' d='M14.5 3a1 1 0 0 1-1 1H13v9a2 2 0 0 1-2 2H5a2 2 0 0 1-2-2V4h-.5a1 1 0 0 1-1-1V2a1 1 0 0 1 1-1H6a1 1 0 0 1 1-1h2a1 1 0 0 1 1 1h3.5a1 1 0 0 1 1 1v1zM4.118 4 4 4.059V13a1 1 0 0 0 1 1h6a1 1 0 0 0 1-1V4.059L11.882 4H4.118zM2.5 3V2h11v1h-11z'/>
+          </svg>
+        </span>
+
+        <span class='download-book' title='Download EPUB' aria-label='Download EPUB'>
+          <svg xmlns='http://www.w3.org/2000/svg' width='16' height='16' fill='currentColor' class='bi bi-download' viewBox='0 0 16 16'>
+            <path d='M.5 9.9a.5.5 0 0 1 .5.5v2.5a1 1 0 0 0 1 1h12a1 1 0 0 0 1-1v-2.5a.5.5 0 0 1 1 0v2.5a2 2 0 0 1-2 2H2a2 2 0 0 1-2-2v-2.5a.5.5 0 0 1 .5-.5z'/>
+            <path d='M7.646 11.854a.5.5 0 0 0 .708 0l3-3a.5.5 0 0 0-.708-.708L8.5 10.293V1.5a.5.5 0 0 0-1 0v8.793L5.354 8.146a.5.5 0 1 0-.708.708l3 3z'/>
+          </svg>
+        </span>
+
+        <span class='book-info'>
+          ${h(row.name)}, by ${h(row.author)}
+          ${row.snippet ? T.snippet(row) : ''}
+        </span>
       </a>
     `,
 
+    // search result snippet template
+    snippet: (row) => `
+      <span class='snippet'>
+        ${snippet(row.snippet)}
+      </span>
+    `,
+
+    // trash item template
+    trash_item: (row) => `
+      <div
+        class='panel-block'
+        title='${h(row.name)}, by ${h(row.author)}'
+        aria-label='${h(row.name)}, by ${h(row.author)}'
+      >
+        <span class='trash-name'>
+          ${h(row.name)}, by ${h(row.author)}
+        </span>
+
+        <button
+          class='button is-small is-info restore-book'
+          title='Restore book.'
+          aria-label='Restore book.'
+          data-id='${h(row.id)}'
+        >
+          Restore
+        </button>
+
+        <button
+          class='button is-small is-danger purge-book'
+          title='Permanently delete book.'
+          aria-label='Permanently delete book.'
+          data-id='${h(row.id)}'
+        >
+          Delete Forever
+        </button>
+      </div>
+    `,
+
+    // empty trash template
+    trash_none: () => `
+      <div class='panel-block'>
+        Trash is empty.
+      </div>
+    `,
+
+    // book tag or collection template
+    label: (kind, name) => `
+      <span class='tag is-info is-light'>
+        ${h(name)}
+
+        <button
+          class='delete is-small remove-label'
+          title='Remove ${kind}.'
+          aria-label='Remove ${kind}.'
+          data-kind='${kind}'
+          data-name='${h(name)}'
+        ></button>
+      </span>
+    `,
+
+    // tag or collection name option template
+    option: (row) => `<option value='${h(row.name)}'></option>`,
+
     // no match template
     none: () => `
       <div class='panel-block'>
@@ -55,24 +144,176 @@
 
     // list template
     list: (rows) => rows.map((row) => T.item(row)).join(''),
+
+    // trash list template
+    trash: (rows) => rows.map((row) => T.trash_item(row)).join(''),
+
+    // book tags or collections template
+    labels: (kind, names) => names.map((name) => T.label(kind, name)).join(''),
+
+    // tag or collection name options template
+    options: (rows) => rows.map((row) => T.option(row)).join(''),
+  };
+
+  // fetch page of search results starting at the given cursor
+  const fetch_page = (cursor) => {
+    // build request parameters
+    const params = { q: field.value || '', sort: sort.value || '' };
+    if (cursor) {
+      params.cursor = cursor;
+    }
+
+    // build url
+    const url = './api/search?' + (new URLSearchParams(params)).toString();
+
+    // fetch page; on error (e.g. not logged in), show the error and
+    // return an empty page
+    return fetch(url).then((r) => {
+      if (!r.ok) {
+        show_error(r, 'search failed');
+        return { books: [], next: '' };
+      }
+
+      return r.json();
+    });
   };
 
+  // reload first page of search results
   const refresh = () => {
-    const new_q = field.value || '';
-    const old_q = books.dataset.q || '';
+    const seq = ++S.seq;
 
-    if (!new_q || new_q !== old_q) {
-      // build url
-      const url = './api/search?' + (new URLSearchParams({ q: new_q })).toString();
+    fetch_page(null).then((r) => {
+      // ignore stale responses
+      if (seq !== S.seq) {
+        return;
+      }
 
-      fetch(url).then((r) => r.json()).then((r) => {
-        // cache query string
-        books.dataset.q = q;
+      // cache cursor for next page
+      S.next = r.next;
 
-        // refresh list
-        books.innerHTML = (r.length > 0) ? T.list(r): T.none();
-      });
+      // refresh list
+      books.innerHTML = (r.books.length > 0) ? T.list(r.books) : T.none();
+    });
+  };
+
+  // append next page of search results, if any
+  const load_more = () => {
+    if (!S.next || S.busy) {
+      return;
+    }
+
+    const seq = S.seq;
+    S.busy = true;
+
+    fetch_page(S.next).then((r) => {
+      // ignore pages from stale searches
+      if (seq !== S.seq) {
+        return;
+      }
+
+      // cache cursor for next page, append books to list
+      S.next = r.next;
+      books.insertAdjacentHTML('beforeend', T.list(r.books));
+    }).finally(() => {
+      S.busy = false;
+    });
+  };
+
+  // get CSRF token from cookie (set by the server on the first request)
+  const csrf_token = () => {
+    const c = D.cookie.split('; ').find((c) => c.startsWith('bookman_csrf='));
+    return c ? c.substring(c.indexOf('=') + 1) : '';
+  };
+
+  // send POST request with given body to given URL.  the CSRF token is
+  // sent in a header, so the server can tell the request came from this
+  // page rather than a forged cross-site form.
+  const post = (url, body) => fetch(url, {
+    method: 'POST',
+    headers: { 'X-CSRF-Token': csrf_token() },
+    body: body,
+  });
+
+  // show error message from JSON error response, or the given
+  // fallback message if the response body is not a JSON error
+  //
+  // if the response status is 401, show the login dialog instead
+  const show_error = (r, fallback) => {
+    if (r.status === 401) {
+      get('login-dialog').classList.add('is-active');
+      get('login-name').focus();
+      return;
     }
+
+    r.json().then((e) => alert(e.error.message)).catch(() => alert(fallback));
+  };
+
+  // refresh logged in user name and toggle login and logout buttons.
+  // the upload button is only shown to editors and admins.
+  const refresh_user = () => {
+    fetch('./api/user').then((r) => r.ok ? r.json() : null).then((u) => {
+      get('user-name').textContent = u ? `${u.name} (${u.role})` : '';
+      get('login-btn').classList.toggle('is-hidden', !!u);
+      get('logout-btn').classList.toggle('is-hidden', !u);
+      get('upload-btn').classList.toggle('is-hidden', !u || u.role === 'viewer');
+    });
+  };
+
+  // refresh trash dialog contents
+  const refresh_trash = () => {
+    fetch('./api/trash').then((r) => r.json()).then((r) => {
+      get('trash-books').innerHTML = (r.length > 0) ? T.trash(r) : T.trash_none();
+    });
+  };
+
+  // refresh tags and collections in edit dialog for given book, and
+  // the lists of tag and collection names
+  const refresh_labels = (id) => {
+    fetch(`./api/labels?id=${id}`).then((r) => r.json()).then((r) => {
+      get('edit-tags').innerHTML = T.labels('tag', r.tags);
+      get('edit-collections').innerHTML = T.labels('collection', r.collections);
+    });
+
+    fetch('./api/tags').then((r) => r.json()).then((r) => {
+      get('tag-names').innerHTML = T.options(r);
+    });
+
+    fetch('./api/collections').then((r) => r.json()).then((r) => {
+      get('collection-names').innerHTML = T.options(r);
+    });
+  };
+
+  // add book in edit dialog to, or remove it from, the named tag or
+  // collection.  action is one of 'add' or 'remove', and kind is one of
+  // 'tag' or 'collection'.
+  const post_label = (action, kind, name) => {
+    const id = get('edit-save-btn').dataset.id;
+
+    // build form data
+    const data = new FormData();
+    data.append('id', id);
+    data.append(kind, name);
+
+    // send request
+    return post(`./api/${kind}s/${action}`, data).then((r) => {
+      if (r.ok) {
+        refresh_labels(id);
+      } else {
+        show_error(r, `${action} ${kind} failed`);
+      }
+
+      return r.ok;
+    });
+  };
+
+  // send POST request with given book ID to given API endpoint
+  const post_id = (url, id) => {
+    // build form data
+    const data = new FormData();
+    data.append('id', id);
+
+    // send request
+    return post(url, data);
   };
 
   on(D, 'DOMContentLoaded', () => {
@@ -90,6 +331,16 @@
       t = setTimeout(refresh, 200);
     });
 
+    // sort field handler
+    on(sort, 'change', refresh);
+
+    // load next page of results when scrolled near the bottom of the list
+    on(window, 'scroll', () => {
+      if (window.innerHeight + window.scrollY >= D.body.offsetHeight - 200) {
+        load_more();
+      }
+    });
+
     // edit btn handler
     on(get('books'), 'click', (ev) => {
       if (ev.target.closest('.edit-book')) {
@@ -101,6 +352,11 @@
         get('edit-name').value = data.name;
         get('edit-author').value = data.author;
 
+        // populate tags and collections
+        get('edit-tags').innerHTML = '';
+        get('edit-collections').innerHTML = '';
+        refresh_labels(data.id);
+
         // show edit dialog
         get('edit-dialog').classList.add('is-active');
 
@@ -108,6 +364,70 @@
         ev.preventDefault();
         return false;
       }
+
+      if (ev.target.closest('.download-book')) {
+        // get book data
+        const data = ev.target.closest('a').dataset;
+
+        // download book as epub
+        location.href = `./book/${data.id}.epub`;
+
+        // stop event
+        ev.preventDefault();
+        return false;
+      }
+
+      if (ev.target.closest('.delete-book')) {
+        // get book data
+        const data = ev.target.closest('a').dataset;
+
+        // move book to trash, refresh list
+        post_id('./api/delete', data.id).then((r) => {
+          if (r.ok) {
+            refresh();
+          } else {
+            show_error(r, 'delete failed');
+          }
+        });
+
+        // stop event
+        ev.preventDefault();
+        return false;
+      }
+    });
+
+    // trash btn handler
+    on(get('trash-btn'), 'click', () => {
+      // populate and show trash dialog
+      refresh_trash();
+      get('trash-dialog').classList.add('is-active');
+    });
+
+    // trash dialog restore and purge btn handler
+    on(get('trash-books'), 'click', (ev) => {
+      const restore = ev.target.closest('.restore-book'),
+            purge = ev.target.closest('.purge-book');
+
+      if (restore) {
+        // restore book from trash, refresh lists
+        post_id('./api/restore', restore.dataset.id).then((r) => {
+          if (r.ok) {
+            refresh_trash();
+            refresh();
+          } else {
+            show_error(r, 'restore failed');
+          }
+        });
+      } else if (purge && confirm('Permanently delete book?')) {
+        // permanently delete book, refresh trash
+        post_id('./api/purge', purge.dataset.id).then((r) => {
+          if (r.ok) {
+            refresh_trash();
+          } else {
+            show_error(r, 'delete failed');
+          }
+        });
+      }
     });
 
     on(get('edit-save-btn'), 'click', (ev) => {
@@ -118,18 +438,9 @@
       data.append('author', get('edit-author').value);
 
       // send request
-      fetch('./api/edit', {
-        method: 'POST',
-        body: data,
-      }).then((r) => {
+      post('./api/edit', data).then((r) => {
         if (!r.ok) {
-          alert('edit failed');
-
-          /* TODO: r.text().then(err => {
-            console.log(err);
-            alert(err);
-          }); */
-
+          show_error(r, 'edit failed');
           return;
         }
 
@@ -144,6 +455,91 @@
       return false;
     });
 
+    // add tag and add collection handlers
+    ['tag', 'collection'].forEach((kind) => {
+      const input = get(`edit-${kind}`);
+
+      // add tag or collection named in input field, then clear field
+      const add = (ev) => {
+        if (input.value.trim()) {
+          post_label('add', kind, input.value).then((ok) => {
+            if (ok) {
+              input.value = '';
+            }
+          });
+        }
+
+        // stop event
+        ev.preventDefault();
+        ev.stopPropagation();
+        return false;
+      };
+
+      on(get(`edit-${kind}-add`), 'click', add);
+      on(input, 'keydown', (ev) => {
+        if (ev.key === 'Enter') {
+          return add(ev);
+        }
+      });
+    });
+
+    // remove tag and remove collection handler
+    on(get('edit-dialog'), 'click', (ev) => {
+      const btn = ev.target.closest('.remove-label');
+      if (btn) {
+        post_label('remove', btn.dataset.kind, btn.dataset.name);
+
+        // stop event
+        ev.preventDefault();
+        ev.stopPropagation();
+        return false;
+      }
+    });
+
+    // login btn handler
+    on(get('login-btn'), 'click', () => {
+      // show login dialog
+      get('login-dialog').classList.add('is-active');
+      get('login-name').focus();
+    });
+
+    // login form handler
+    on(get('login-form'), 'submit', (ev) => {
+      // build form data
+      const data = new FormData();
+      data.append('name', get('login-name').value);
+      data.append('password', get('login-password').value);
+
+      // send request
+      post('./api/login', data).then((r) => {
+        if (!r.ok) {
+          // keep dialog open so the user can try again
+          get('login-dialog').classList.add('is-active');
+          r.json().then((e) => alert(e.error.message)).catch(() => alert('login failed'));
+          return;
+        }
+
+        // clear password, hide dialog, refresh user and list
+        get('login-password').value = '';
+        get('login-dialog').classList.remove('is-active');
+        refresh_user();
+        refresh();
+      });
+
+      // stop event
+      ev.preventDefault();
+      ev.stopPropagation();
+      return false;
+    });
+
+    // logout btn handler
+    on(get('logout-btn'), 'click', () => {
+      post('./api/logout', null).then(() => {
+        refresh_user();
+        refresh();
+      });
+    });
+
     // upload btn handler
     on(get('upload-btn'), 'click', () => {
       // show upload dialog
@@ -165,14 +561,23 @@
       }
 
       // fetch files
-      fetch('./api/upload', {
-        method: 'POST',
-        body: data,
-      }).then((r) => {
+      const conflict = get('upload-conflict').value;
+      post(`./api/upload?partial=true&conflict=${encodeURIComponent(conflict)}`, data).then((r) => {
         if (r.ok) {
+          r.json().then((r) => {
+            // report skipped, replaced, renamed, and failed files
+            const msgs = r.files.filter((f) => f.status !== 'created').map((f) => {
+              const name = f.entry ? `${f.file_name}/${f.entry}` : f.file_name;
+              return `${name}: ${f.status}` + (f.error ? ` (${f.error.message})` : '');
+            });
+            if (msgs.length > 0) {
+              alert(msgs.join('\n'));
+            }
+          });
+
           refresh();
         } else {
-          alert('upload failed');
+          show_error(r, 'upload failed');
         }
       });
     });
@@ -198,6 +603,7 @@
     });
   });
 
-  // load initial list
+  // load initial list and logged in user
   refresh();
+  refresh_user();
 })();
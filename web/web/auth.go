@@ -0,0 +1,184 @@
+package web
+
+import (
+  "bookman/app"
+  "bookman/model"
+  "context"
+  "errors"
+  "net/http"
+  "time"
+)
+
+// Name of session cookie.
+const sessionCookieName = "bookman_session"
+
+// user context key
+type userCtxKey struct{}
+
+// Get logged in user from context, or nil if the request does not have
+// a valid session.
+func userFromContext(ctx context.Context) *model.User {
+  user, _ := ctx.Value(userCtxKey{}).(*model.User)
+  return user
+}
+
+// Create error for a request which requires a logged in user.
+func unauthorized(message string) error {
+  return &apiError { http.StatusUnauthorized, "unauthorized", message }
+}
+
+// Create error for a request by a user whose role does not allow it.
+func forbidden(message string) error {
+  return &apiError { http.StatusForbidden, "forbidden", message }
+}
+
+// Create session cookie with the given value and lifetime.  A negative
+// lifetime creates a cookie which removes the session cookie.
+//
+// The cookie is not available to scripts, and is not sent with
+// cross-site subrequests or cross-site POST requests.
+func sessionCookie(config app.Config, value string, maxAge int) *http.Cookie {
+  c := http.Cookie {
+    Name: sessionCookieName,
+    Value: value,
+    Path: "/",
+    MaxAge: maxAge,
+    Secure: config.SessionSecure,
+    HttpOnly: true,
+    SameSite: http.SameSiteLaxMode,
+  }
+
+  if maxAge > 0 {
+    c.Expires = time.Now().Add(time.Duration(maxAge) * time.Second)
+  }
+
+  return &c
+}
+
+// HTTP middleware which gets the logged in user from the session
+// cookie and stores the user in the request context.  The user name is
+// also stored as the actor for changes made by the request.
+//
+// Requests without a session cookie are passed through without a
+// user.  Invalid or expired session cookies are removed.
+//
+// Must be used after AppContextMiddleware.
+func SessionMiddleware(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    // get session cookie
+    c, err := r.Cookie(sessionCookieName)
+    if err != nil {
+      next.ServeHTTP(w, r)
+      return
+    }
+
+    // get context from request and app context from context
+    ctx := r.Context()
+    appCtx := appContextFromContext(ctx)
+
+    // get session user
+    user, err := appCtx.Model.SessionUser(ctx, appCtx.Pool, c.Value)
+    if errors.Is(err, model.ErrSessionNotFound) {
+      // remove invalid session cookie
+      http.SetCookie(w, sessionCookie(appCtx.Config, "", -1))
+      next.ServeHTTP(w, r)
+      return
+    } else if err != nil {
+      writeError(w, err)
+      return
+    }
+
+    // add user and actor to context
+    ctx = context.WithValue(ctx, userCtxKey{}, &user)
+    ctx = model.ContextWithActor(ctx, user.Name)
+
+    // call the next handler in the chain
+    next.ServeHTTP(w, r.WithContext(ctx))
+  })
+}
+
+// Create HTTP middleware which rejects requests unless the logged in
+// user has the given role (or a role which includes it).  Requests
+// without a logged in user are rejected with a 401 error, and requests
+// by users without the role are rejected with a 403 error.
+//
+// Must be used after SessionMiddleware.
+func RequireRoleMiddleware(role model.Role) func(http.Handler) http.Handler {
+  return func(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      // check user and role
+      user := userFromContext(r.Context())
+      if user == nil {
+        writeError(w, unauthorized("login required"))
+        return
+      } else if !user.Role.Includes(role) {
+        writeError(w, forbidden(string(role) + " role required"))
+        return
+      }
+
+      // call the next handler in the chain
+      next.ServeHTTP(w, r)
+    })
+  }
+}
+
+// Log in route handler.
+//
+// Checks the `name` and `password` form values, then creates a session
+// and sets the session cookie.  Returns the logged in user.
+func doApiLogin(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // check credentials, create session
+  name, password := r.FormValue("name"), r.FormValue("password")
+  ttl := time.Duration(appCtx.Config.SessionMaxAge) * time.Second
+  s, err := appCtx.Model.Login(ctx, appCtx.Pool, name, password, ttl)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // set session cookie
+  http.SetCookie(w, sessionCookie(appCtx.Config, s.Token, appCtx.Config.SessionMaxAge))
+
+  // write JSON-encoded user
+  writeJson(w, s.User)
+}
+
+// Log out route handler.
+//
+// Removes the current session, if any, and removes the session cookie.
+func doApiLogout(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // remove session
+  if c, err := r.Cookie(sessionCookieName); err == nil {
+    if err := appCtx.Model.Logout(ctx, appCtx.Pool, c.Value); err != nil {
+      writeError(w, err)
+      return
+    }
+  }
+
+  // remove session cookie
+  http.SetCookie(w, sessionCookie(appCtx.Config, "", -1))
+
+  // send response
+  writeJson(w, nil)
+}
+
+// Route handler which returns the logged in user, or a 401 error if
+// there is no logged in user.
+func doApiUser(w http.ResponseWriter, r *http.Request) {
+  user := userFromContext(r.Context())
+  if user == nil {
+    writeError(w, unauthorized("not logged in"))
+    return
+  }
+
+  // write JSON-encoded user
+  writeJson(w, user)
+}
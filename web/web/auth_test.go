@@ -0,0 +1,386 @@
+package web
+
+import (
+  "bookman/app"
+  "bookman/model"
+  "context"
+  "errors"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+)
+
+// test user
+var testUser = model.User {
+  Id: 1,
+  Name: "alice",
+  Role: model.RoleEditor,
+  CreatedAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+}
+
+// Get cookie with the given name from response, or nil if the response
+// does not set the cookie.
+func getCookie(resp *httptest.ResponseRecorder, name string) *http.Cookie {
+  for _, c := range(resp.Result().Cookies()) {
+    if c.Name == name {
+      return c
+    }
+  }
+
+  return nil
+}
+
+func TestUserFromContext(t *testing.T) {
+  if got := userFromContext(context.Background()); got != nil {
+    t.Fatalf("got %v, exp nil", got)
+  }
+
+  exp := testUser
+  ctx := context.WithValue(context.Background(), userCtxKey{}, &exp)
+  if got := userFromContext(ctx); got != &exp {
+    t.Fatalf("got %v, exp %v", got, &exp)
+  }
+}
+
+func TestSessionMiddleware(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    cookie string // session cookie value (empty for no cookie)
+    result model.MockUserResult // SessionUser() result
+    status int // expected status code
+    user *model.User // expected user
+    actor string // expected actor
+    removed bool // expect session cookie to be removed?
+  } {{
+    name: "no cookie",
+    status: http.StatusOK,
+    actor: "",
+  }, {
+    name: "valid session",
+    cookie: "token",
+    result: model.MockUserResult { User: testUser },
+    status: http.StatusOK,
+    user: &testUser,
+    actor: "alice",
+  }, {
+    name: "expired session",
+    cookie: "token",
+    result: model.MockUserResult { Err: model.ErrSessionNotFound },
+    status: http.StatusOK,
+    removed: true,
+  }, {
+    name: "error",
+    cookie: "token",
+    result: model.MockUserResult { Err: errors.New("some error") },
+    status: http.StatusInternalServerError,
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Model: &model.MockModel { SessionUserResult: test.result },
+      }
+
+      // create handler which checks the user and actor
+      check := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+        got := userFromContext(r.Context())
+        if (got == nil) != (test.user == nil) || (got != nil && *got != *test.user) {
+          t.Fatalf("got user %v, exp %v", got, test.user)
+        }
+
+        if got := model.ActorFromContext(r.Context()); got != test.actor {
+          t.Fatalf("got actor %q, exp %q", got, test.actor)
+        }
+      })
+
+      // create request w/ app context and session cookie
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+      if test.cookie != "" {
+        req.AddCookie(&http.Cookie { Name: sessionCookieName, Value: test.cookie })
+      }
+      resp := httptest.NewRecorder()
+
+      // send request
+      SessionMiddleware(check).ServeHTTP(resp, req)
+
+      // check status
+      if resp.Code != test.status {
+        t.Fatalf("got status %d, exp %d", resp.Code, test.status)
+      }
+
+      // check for removed cookie
+      c := getCookie(resp, sessionCookieName)
+      if removed := c != nil && c.MaxAge < 0; removed != test.removed {
+        t.Fatalf("got removed %v, exp %v", removed, test.removed)
+      }
+    })
+  }
+}
+
+func TestRequireRoleMiddleware(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    user *model.User // logged in user
+    role model.Role // required role
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "no user",
+    role: model.RoleViewer,
+    status: http.StatusUnauthorized,
+    code: "unauthorized",
+  }, {
+    name: "same role",
+    user: &model.User { Name: "alice", Role: model.RoleEditor },
+    role: model.RoleEditor,
+    status: http.StatusOK,
+  }, {
+    name: "higher role",
+    user: &model.User { Name: "alice", Role: model.RoleAdmin },
+    role: model.RoleViewer,
+    status: http.StatusOK,
+  }, {
+    name: "lower role",
+    user: &model.User { Name: "alice", Role: model.RoleViewer },
+    role: model.RoleEditor,
+    status: http.StatusForbidden,
+    code: "forbidden",
+  }, {
+    name: "invalid role",
+    user: &model.User { Name: "alice", Role: model.Role("root") },
+    role: model.RoleViewer,
+    status: http.StatusForbidden,
+    code: "forbidden",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // handler which records whether it was called
+      called := false
+      next := http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+        called = true
+      })
+
+      // create request w/ user
+      ctx := context.Background()
+      if test.user != nil {
+        ctx = context.WithValue(ctx, userCtxKey{}, test.user)
+      }
+      req := httptest.NewRequest("POST", "/", nil).WithContext(ctx)
+      resp := httptest.NewRecorder()
+
+      // send request
+      RequireRoleMiddleware(test.role)(next).ServeHTTP(resp, req)
+
+      if test.status != http.StatusOK {
+        // check response status and error code
+        checkErrorResponse(t, resp, test.status, test.code)
+        if called {
+          t.Fatal("got called, exp not called")
+        }
+      } else if !called {
+        t.Fatal("got not called, exp called")
+      }
+    })
+  }
+}
+
+func TestDoApiLogin(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    result model.MockLoginResult // Login() result
+    status int // expected status code
+    code string // expected error code
+    exp string // expected body
+  } {{
+    name: "pass",
+    result: model.MockLoginResult {
+      Session: model.Session { Token: "token", User: testUser },
+    },
+    status: http.StatusOK,
+    exp: `{"id":1,"name":"alice","role":"editor","created_at":"2020-01-02T03:04:05Z"}`,
+  }, {
+    name: "invalid login",
+    result: model.MockLoginResult { Err: model.ErrInvalidLogin },
+    status: http.StatusUnauthorized,
+    code: "invalid_login",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Config: app.Config { SessionMaxAge: 3600, SessionSecure: true },
+        Model: &model.MockModel { LoginResult: test.result },
+      }
+
+      // create context, request, and response recorder
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      body := strings.NewReader("name=alice&password=secret123")
+      req, err := http.NewRequestWithContext(ctx, "POST", "/api/login", body)
+      if err != nil {
+        t.Fatal(err)
+      }
+      req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+      resp := httptest.NewRecorder()
+
+      // call handler
+      doApiLogin(resp, req)
+
+      if test.status != http.StatusOK {
+        // check response status and error code
+        checkErrorResponse(t, resp, test.status, test.code)
+        if c := getCookie(resp, sessionCookieName); c != nil {
+          t.Fatalf("got cookie %v, exp none", c)
+        }
+        return
+      }
+
+      // check response body
+      if got := strings.TrimSpace(resp.Body.String()); got != test.exp {
+        t.Fatalf("got \"%s\", exp \"%s\"", got, test.exp)
+      }
+
+      // check session cookie
+      c := getCookie(resp, sessionCookieName)
+      if c == nil {
+        t.Fatal("got no cookie, exp session cookie")
+      } else if c.Value != "token" || c.MaxAge != 3600 || !c.HttpOnly || !c.Secure || c.SameSite != http.SameSiteLaxMode {
+        t.Fatalf("got cookie %#v", c)
+      }
+    })
+  }
+}
+
+func TestDoApiLogout(t *testing.T) {
+  // build app context w/ mock model
+  appCtx := app.Context { Model: &model.MockModel {} }
+
+  // create context, request w/ session cookie, and response recorder
+  ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+  req, err := http.NewRequestWithContext(ctx, "POST", "/api/logout", nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+  req.AddCookie(&http.Cookie { Name: sessionCookieName, Value: "token" })
+  resp := httptest.NewRecorder()
+
+  // call handler
+  doApiLogout(resp, req)
+
+  // check status and removed cookie
+  if resp.Code != http.StatusOK {
+    t.Fatalf("got status %d, exp %d", resp.Code, http.StatusOK)
+  }
+  if c := getCookie(resp, sessionCookieName); c == nil || c.MaxAge >= 0 {
+    t.Fatalf("got cookie %v, exp removed cookie", c)
+  }
+}
+
+func TestDoApiUser(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    ctx := context.WithValue(context.Background(), userCtxKey{}, &testUser)
+    req := httptest.NewRequest("GET", "/api/user", nil).WithContext(ctx)
+    resp := httptest.NewRecorder()
+
+    doApiUser(resp, req)
+
+    exp := `{"id":1,"name":"alice","role":"editor","created_at":"2020-01-02T03:04:05Z"}`
+    if got := strings.TrimSpace(resp.Body.String()); got != exp {
+      t.Fatalf("got \"%s\", exp \"%s\"", got, exp)
+    }
+  })
+
+  t.Run("not logged in", func(t *testing.T) {
+    resp := httptest.NewRecorder()
+    doApiUser(resp, httptest.NewRequest("GET", "/api/user", nil))
+    checkErrorResponse(t, resp, http.StatusUnauthorized, "unauthorized")
+  })
+}
+
+func TestRouterRoles(t *testing.T) {
+  tests := []struct {
+    method string // request method
+    path string // request path
+    role model.Role // minimum role
+  } {
+    { "GET", "/api/search", model.RoleViewer },
+    { "GET", "/book/1", model.RoleViewer },
+    { "GET", "/opds", model.RoleViewer },
+    { "GET", "/opds/author", model.RoleViewer },
+    { "GET", "/opds/author/1", model.RoleViewer },
+    { "POST", "/api/upload", model.RoleEditor },
+    { "POST", "/api/edit", model.RoleEditor },
+    { "POST", "/api/tags/add", model.RoleEditor },
+    { "POST", "/api/delete", model.RoleAdmin },
+    { "POST", "/api/purge", model.RoleAdmin },
+    { "GET", "/api/panic", model.RoleAdmin },
+    { "GET", "/api/admin/export", model.RoleAdmin },
+    { "POST", "/api/admin/restore", model.RoleAdmin },
+    { "GET", "/api/duplicates", model.RoleAdmin },
+    { "POST", "/api/duplicates/sign", model.RoleAdmin },
+    { "GET", "/api/admin/users", model.RoleAdmin },
+    { "POST", "/api/admin/users/add", model.RoleAdmin },
+    { "POST", "/api/admin/users/role", model.RoleAdmin },
+    { "POST", "/api/admin/users/delete", model.RoleAdmin },
+  }
+
+  // all roles, in order
+  roles := []model.Role { model.RoleViewer, model.RoleEditor, model.RoleAdmin }
+
+  for _, test := range(tests) {
+    t.Run(test.method + " " + test.path, func(t *testing.T) {
+      t.Run("no user", func(t *testing.T) {
+        // build app context w/ mock model, create router
+        appCtx := app.Context { Model: &model.MockModel {} }
+        router, err := NewRouter(&appCtx)
+        if err != nil {
+          t.Fatal(err)
+        }
+
+        req := httptest.NewRequest(test.method, test.path, nil)
+        addCsrfToken(req)
+        resp := httptest.NewRecorder()
+        router.ServeHTTP(resp, req)
+        checkErrorResponse(t, resp, http.StatusUnauthorized, "unauthorized")
+      })
+
+      for _, role := range(roles) {
+        t.Run(string(role), func(t *testing.T) {
+          // build app context w/ mock model which returns a session user
+          // with the role, create router
+          user := model.User { Id: 1, Name: "alice", Role: role }
+          appCtx := app.Context {
+            Model: &model.MockModel {
+              SessionUserResult: model.MockUserResult { User: user },
+            },
+          }
+          router, err := NewRouter(&appCtx)
+          if err != nil {
+            t.Fatal(err)
+          }
+
+          // create request w/ session cookie and CSRF token
+          req := httptest.NewRequest(test.method, test.path, nil)
+          req.AddCookie(&http.Cookie { Name: sessionCookieName, Value: "token" })
+          addCsrfToken(req)
+          resp := httptest.NewRecorder()
+
+          // send request
+          router.ServeHTTP(resp, req)
+
+          // check that users without the role are rejected, and that
+          // users with the role are not
+          forbidden := resp.Code == http.StatusForbidden
+          if exp := !role.Includes(test.role); forbidden != exp {
+            t.Fatalf("got forbidden %v, exp %v (status %d)", forbidden, exp, resp.Code)
+          }
+        })
+      }
+    })
+  }
+}
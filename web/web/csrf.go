@@ -0,0 +1,102 @@
+package web
+
+import (
+  "crypto/rand"
+  "crypto/subtle"
+  "encoding/base64"
+  "net/http"
+)
+
+// Name of CSRF token cookie.
+const csrfCookieName = "bookman_csrf"
+
+// Name of CSRF token request header.
+const csrfHeaderName = "X-CSRF-Token"
+
+// Number of random bytes in CSRF tokens.
+const csrfTokenSize = 32
+
+// Create error for a request with a missing or mismatched CSRF token.
+func csrfError() error {
+  return &apiError { http.StatusForbidden, "csrf", "missing or invalid CSRF token" }
+}
+
+// Is the request method safe (that is, it does not change anything)?
+func isSafeMethod(method string) bool {
+  switch method {
+  case "GET", "HEAD", "OPTIONS", "TRACE":
+    return true
+  default:
+    return false
+  }
+}
+
+// Generate random CSRF token.
+func newCsrfToken() (string, error) {
+  buf := make([]byte, csrfTokenSize)
+  if _, err := rand.Read(buf); err != nil {
+    return "", err
+  }
+
+  return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HTTP middleware which protects against cross-site request forgery
+// with a double-submit token.
+//
+// Requests without a CSRF cookie are given a new random token in the
+// `bookman_csrf` cookie.  The cookie is readable by scripts, but is
+// only sent with same-site requests.  Requests with unsafe methods
+// (e.g. POST) must echo the cookie value in the `X-CSRF-Token` header;
+// requests without a matching token are rejected with a 403 error with
+// the `csrf` code.  Another site cannot read the cookie, so it cannot
+// forge a matching token.
+//
+// Requests authenticated with an API token are not checked, because
+// browsers do not send bearer tokens automatically.
+//
+// Must be used after TokenMiddleware.
+func CsrfMiddleware(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    // get context from request and app context from context
+    ctx := r.Context()
+    appCtx := appContextFromContext(ctx)
+
+    // get CSRF cookie
+    var cookie string
+    if c, err := r.Cookie(csrfCookieName); err == nil {
+      cookie = c.Value
+    }
+
+    // set CSRF cookie, if missing
+    if cookie == "" {
+      token, err := newCsrfToken()
+      if err != nil {
+        writeError(w, err)
+        return
+      }
+
+      http.SetCookie(w, &http.Cookie {
+        Name: csrfCookieName,
+        Value: token,
+        Path: "/",
+        Secure: appCtx.Config.SessionSecure,
+        SameSite: http.SameSiteStrictMode,
+      })
+    }
+
+    // check CSRF token of unsafe requests
+    // (note: the token is not read from the request body, so that
+    // upload size limits are applied before the body is read)
+    if !isSafeMethod(r.Method) && tokenFromContext(ctx) == nil {
+      token := r.Header.Get(csrfHeaderName)
+      if cookie == "" || subtle.ConstantTimeCompare([]byte(token), []byte(cookie)) != 1 {
+        writeError(w, csrfError())
+        return
+      }
+    }
+
+    // call the next handler in the chain
+    next.ServeHTTP(w, r)
+  })
+}
@@ -0,0 +1,176 @@
+package web
+
+import (
+  "bookman/app"
+  "bookman/model"
+  "context"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+)
+
+// Add matching CSRF cookie and header to request.
+func addCsrfToken(req *http.Request) {
+  req.AddCookie(&http.Cookie { Name: csrfCookieName, Value: "csrf-token" })
+  req.Header.Set(csrfHeaderName, "csrf-token")
+}
+
+func TestCsrfMiddleware(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    method string // request method
+    cookie string // CSRF cookie (empty for no cookie)
+    header string // CSRF header (empty for no header)
+    token bool // authenticated with API token?
+    status int // expected status code
+    setCookie bool // expect new CSRF cookie?
+  } {{
+    name: "get without cookie",
+    method: "GET",
+    status: http.StatusOK,
+    setCookie: true,
+  }, {
+    name: "get with cookie",
+    method: "GET",
+    cookie: "abc",
+    status: http.StatusOK,
+  }, {
+    name: "post with matching token",
+    method: "POST",
+    cookie: "abc",
+    header: "abc",
+    status: http.StatusOK,
+  }, {
+    name: "forged post without token",
+    method: "POST",
+    cookie: "abc",
+    status: http.StatusForbidden,
+  }, {
+    name: "forged post with wrong token",
+    method: "POST",
+    cookie: "abc",
+    header: "xyz",
+    status: http.StatusForbidden,
+  }, {
+    name: "forged post without cookie",
+    method: "POST",
+    header: "abc",
+    status: http.StatusForbidden,
+    setCookie: true,
+  }, {
+    name: "forged delete",
+    method: "DELETE",
+    cookie: "abc",
+    status: http.StatusForbidden,
+  }, {
+    name: "post with api token",
+    method: "POST",
+    token: true,
+    status: http.StatusOK,
+    setCookie: true,
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context
+      appCtx := app.Context { Config: app.Config { SessionSecure: true } }
+
+      // handler which records whether it was called
+      called := false
+      next := http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+        called = true
+      })
+
+      // create request w/ app context, API token, CSRF cookie, and CSRF
+      // header
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      if test.token {
+        ctx = context.WithValue(ctx, tokenCtxKey{}, &model.ApiToken { Id: 1 })
+      }
+      req := httptest.NewRequest(test.method, "/", nil).WithContext(ctx)
+      if test.cookie != "" {
+        req.AddCookie(&http.Cookie { Name: csrfCookieName, Value: test.cookie })
+      }
+      if test.header != "" {
+        req.Header.Set(csrfHeaderName, test.header)
+      }
+      resp := httptest.NewRecorder()
+
+      // send request
+      CsrfMiddleware(next).ServeHTTP(resp, req)
+
+      // check status
+      if test.status != http.StatusOK {
+        checkErrorResponse(t, resp, test.status, "csrf")
+        if called {
+          t.Fatal("got called, exp not called")
+        }
+      } else if !called {
+        t.Fatal("got not called, exp called")
+      }
+
+      // check for new CSRF cookie
+      c := getCookie(resp, csrfCookieName)
+      if (c != nil) != test.setCookie {
+        t.Fatalf("got cookie %v, exp new cookie %v", c, test.setCookie)
+      } else if c != nil && (len(c.Value) != 43 || c.HttpOnly || !c.Secure || c.SameSite != http.SameSiteStrictMode) {
+        t.Fatalf("got cookie %#v", c)
+      }
+    })
+  }
+}
+
+func TestRouterCsrf(t *testing.T) {
+  // build app context w/ mock model which returns a session user,
+  // create router
+  appCtx := app.Context {
+    Model: &model.MockModel {
+      SessionUserResult: model.MockUserResult {
+        User: model.User { Id: 1, Name: "alice", Role: model.RoleAdmin },
+      },
+    },
+  }
+  router, err := NewRouter(&appCtx)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // forged cross-site form posts: the browser sends the session cookie,
+  // but the forging site cannot read the CSRF cookie
+  for _, path := range([]string { "/api/upload", "/api/edit", "/api/delete", "/api/logout", "/api/tokens/create" }) {
+    t.Run(path, func(t *testing.T) {
+      req := httptest.NewRequest("POST", path, nil)
+      req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+      req.AddCookie(&http.Cookie { Name: sessionCookieName, Value: "token" })
+      req.AddCookie(&http.Cookie { Name: csrfCookieName, Value: "csrf-token" })
+      resp := httptest.NewRecorder()
+
+      router.ServeHTTP(resp, req)
+      checkErrorResponse(t, resp, http.StatusForbidden, "csrf")
+    })
+  }
+}
+
+func TestPublicScriptCsrf(t *testing.T) {
+  // read embedded script
+  buf, err := publicFs.ReadFile("public/script.min.js")
+  if err != nil {
+    t.Fatal(err)
+  }
+  s := string(buf)
+
+  // check that the script reads the CSRF cookie and sends the CSRF
+  // header
+  for _, exp := range([]string { csrfCookieName + "=", csrfHeaderName }) {
+    if !strings.Contains(s, exp) {
+      t.Fatalf("embedded script does not contain %q", exp)
+    }
+  }
+
+  // check that all POST requests go through the one helper which sends
+  // the CSRF header
+  if got := strings.Count(s, "POST"); got != 1 {
+    t.Fatalf("got %d POST requests, exp 1", got)
+  }
+}
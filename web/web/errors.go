@@ -0,0 +1,158 @@
+package web
+
+import (
+  "bookman/model"
+  "encoding/json"
+  "errors"
+  "log"
+  "net/http"
+  "strconv"
+)
+
+// Error with an explicit HTTP status and error code.
+type apiError struct {
+  status int // HTTP status code
+  code string // error code
+  message string // error message
+}
+
+// Get error message.
+func (e *apiError) Error() string {
+  return e.message
+}
+
+// Create error for a missing or invalid request parameter.
+func badRequest(message string) error {
+  return &apiError { http.StatusBadRequest, "bad_request", message }
+}
+
+// JSON error response body.
+type errorBody struct {
+  Code string `json:"code"` // error code
+  Message string `json:"message"` // error message
+}
+
+// JSON error response envelope.
+type errorResponse struct {
+  Error errorBody `json:"error"`
+}
+
+// Get HTTP status, error code, and error message for the given error.
+//
+// Errors which are not recognized are logged and reported as an
+// internal server error with a generic message so that database
+// details are not leaked to clients.
+func errorStatus(err error) (int, string, string) {
+  var apiErr *apiError
+  var validationErr *model.ValidationError
+
+  switch {
+  case errors.As(err, &apiErr):
+    return apiErr.status, apiErr.code, apiErr.message
+  case errors.As(err, &validationErr):
+    return http.StatusBadRequest, "invalid", validationErr.Error()
+  case errors.Is(err, model.ErrAuthorNotFound):
+    return http.StatusNotFound, "not_found", "author not found"
+  case errors.Is(err, model.ErrTagNotFound):
+    return http.StatusNotFound, "not_found", "tag not found"
+  case errors.Is(err, model.ErrCollectionNotFound):
+    return http.StatusNotFound, "not_found", "collection not found"
+  case errors.Is(err, model.ErrRevisionNotFound):
+    return http.StatusNotFound, "not_found", "revision not found"
+  case errors.Is(err, model.ErrUserNotFound):
+    return http.StatusNotFound, "not_found", "user not found"
+  case errors.Is(err, model.ErrTokenNotFound):
+    return http.StatusNotFound, "not_found", "API token not found"
+  case errors.Is(err, model.ErrNotFound):
+    return http.StatusNotFound, "not_found", "book not found"
+  case errors.Is(err, model.ErrInvalidLogin):
+    return http.StatusUnauthorized, "invalid_login", "invalid user name or password"
+  case errors.Is(err, model.ErrDuplicateUser):
+    return http.StatusConflict, "duplicate", "user name already exists"
+  case errors.Is(err, model.ErrDuplicateBody):
+    return http.StatusConflict, "duplicate_body", "book contents already exist"
+  case errors.Is(err, model.ErrDuplicate):
+    return http.StatusConflict, "duplicate", "book name already exists"
+  default:
+    log.Print(err)
+    return http.StatusInternalServerError, "internal_error", "internal server error"
+  }
+}
+
+// Write JSON error envelope with the HTTP status for the given error.
+func writeError(w http.ResponseWriter, err error) {
+  status, code, message := errorStatus(err)
+
+  // build response
+  resp := errorResponse {
+    Error: errorBody {
+      Code: code,
+      Message: message,
+    },
+  }
+
+  // write response
+  w.Header().Set("Content-Type", "text/json")
+  w.WriteHeader(status)
+  if err := json.NewEncoder(w).Encode(resp); err != nil {
+    log.Print(err)
+  }
+}
+
+// Write JSON-encoded value as response body.
+func writeJson(w http.ResponseWriter, v any) {
+  w.Header().Set("Content-Type", "text/json")
+  if err := json.NewEncoder(w).Encode(v); err != nil {
+    log.Print(err)
+  }
+}
+
+// Parse book ID from string.
+func parseBookId(s string) (int64, error) {
+  id, err := strconv.ParseInt(s, 10, 32)
+  if err != nil {
+    return 0, badRequest("invalid book ID")
+  }
+
+  return id, nil
+}
+
+// Parse author ID from string.
+func parseAuthorId(s string) (int64, error) {
+  id, err := strconv.ParseInt(s, 10, 32)
+  if err != nil {
+    return 0, badRequest("invalid author ID")
+  }
+
+  return id, nil
+}
+
+// Parse collection ID from string.
+func parseCollectionId(s string) (int64, error) {
+  id, err := strconv.ParseInt(s, 10, 32)
+  if err != nil {
+    return 0, badRequest("invalid collection ID")
+  }
+
+  return id, nil
+}
+
+// Parse API token ID from string.
+func parseTokenId(s string) (int64, error) {
+  id, err := strconv.ParseInt(s, 10, 32)
+  if err != nil {
+    return 0, badRequest("invalid API token ID")
+  }
+
+  return id, nil
+}
+
+// Parse revision ID from string.
+func parseRevisionId(s string) (int64, error) {
+  id, err := strconv.ParseInt(s, 10, 32)
+  if err != nil {
+    return 0, badRequest("invalid revision ID")
+  }
+
+  return id, nil
+}
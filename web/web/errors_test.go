@@ -0,0 +1,177 @@
+package web
+
+import (
+  "bookman/model"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "net/http/httptest"
+  "testing"
+)
+
+// Check that the response has the given status code and a JSON error
+// envelope with the given error code.
+func checkErrorResponse(t *testing.T, resp *httptest.ResponseRecorder, status int, code string) {
+  t.Helper()
+
+  // check status code
+  if resp.Code != status {
+    t.Fatalf("got status %d, exp %d", resp.Code, status)
+  }
+
+  // check content-type
+  if got := resp.Header().Get("Content-Type"); got != "text/json" {
+    t.Fatalf("got content-type \"%s\", exp \"text/json\"", got)
+  }
+
+  // decode response body
+  var body errorResponse
+  if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+    t.Fatal(err)
+  }
+
+  // check error code
+  if body.Error.Code != code {
+    t.Fatalf("got code \"%s\", exp \"%s\"", body.Error.Code, code)
+  }
+
+  // check error message
+  if body.Error.Message == "" {
+    t.Fatal("got empty error message")
+  }
+}
+
+func TestWriteError(t *testing.T) {
+  var tests = []struct {
+    name string // test name
+    err error // error
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "bad request",
+    err: badRequest("some message"),
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "validation",
+    err: &model.ValidationError { Field: "name", Message: "must not be empty" },
+    status: http.StatusBadRequest,
+    code: "invalid",
+  }, {
+    name: "not found",
+    err: model.ErrNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }, {
+    name: "wrapped not found",
+    err: fmt.Errorf("foo: %w", model.ErrNotFound),
+    status: http.StatusNotFound,
+    code: "not_found",
+  }, {
+    name: "author not found",
+    err: model.ErrAuthorNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }, {
+    name: "tag not found",
+    err: model.ErrTagNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }, {
+    name: "collection not found",
+    err: model.ErrCollectionNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }, {
+    name: "revision not found",
+    err: model.ErrRevisionNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }, {
+    name: "duplicate",
+    err: model.ErrDuplicate,
+    status: http.StatusConflict,
+    code: "duplicate",
+  }, {
+    name: "user not found",
+    err: model.ErrUserNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }, {
+    name: "token not found",
+    err: model.ErrTokenNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }, {
+    name: "duplicate user",
+    err: model.ErrDuplicateUser,
+    status: http.StatusConflict,
+    code: "duplicate",
+  }, {
+    name: "invalid login",
+    err: model.ErrInvalidLogin,
+    status: http.StatusUnauthorized,
+    code: "invalid_login",
+  }, {
+    name: "duplicate body",
+    err: fmt.Errorf("%w: foo", model.ErrDuplicateBody),
+    status: http.StatusConflict,
+    code: "duplicate_body",
+  }, {
+    name: "other",
+    err: errors.New("some error"),
+    status: http.StatusInternalServerError,
+    code: "internal_error",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      resp := httptest.NewRecorder()
+      writeError(resp, test.err)
+      checkErrorResponse(t, resp, test.status, test.code)
+    })
+  }
+}
+
+func TestParseBookId(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    got, err := parseBookId("1234")
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if got != 1234 {
+      t.Fatalf("got %d, exp 1234", got)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    for _, s := range([]string { "", "foo", "36893488147419103232" }) {
+      if _, err := parseBookId(s); err == nil {
+        t.Fatalf("%s: got success, exp err", s)
+      }
+    }
+  })
+}
+
+func TestParseAuthorId(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    got, err := parseAuthorId("1234")
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if got != 1234 {
+      t.Fatalf("got %d, exp 1234", got)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    for _, s := range([]string { "", "foo", "36893488147419103232" }) {
+      if _, err := parseAuthorId(s); err == nil {
+        t.Fatalf("%s: got success, exp err", s)
+      }
+    }
+  })
+}
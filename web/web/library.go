@@ -0,0 +1,183 @@
+package web
+
+import (
+  "bookman/library"
+  "bookman/model"
+  "errors"
+  "fmt"
+  "io"
+  "log"
+  "net/http"
+  "time"
+)
+
+// Response writer which records whether any data has been written.
+type exportWriter struct {
+  w http.ResponseWriter // underlying response writer
+  wrote bool // has any data been written?
+}
+
+func (w *exportWriter) Write(p []byte) (int, error) {
+  if !w.wrote {
+    // set response headers before first write
+    filename := fmt.Sprintf("bookman-%s.tar.gz", time.Now().UTC().Format("2006-01-02"))
+    w.w.Header().Add("Content-Type", "application/gzip")
+    w.w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+    w.wrote = true
+  }
+
+  return w.w.Write(p)
+}
+
+// Route handler which exports the whole library as a library archive.
+//
+// The archive is streamed to the client as it is read from the
+// database.  Errors which occur before the archive is started are
+// returned as a JSON error; later errors truncate the archive.
+func doApiLibraryExport(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // write archive
+  ew := &exportWriter { w: w }
+  lw := library.NewWriter(ew)
+  err := appCtx.Model.ExportLibrary(ctx, appCtx.Pool, lw)
+  if err == nil {
+    err = lw.Close()
+  }
+
+  // handle error
+  if err != nil {
+    if ew.wrote {
+      log.Print(err)
+    } else {
+      writeError(w, err)
+    }
+  }
+}
+
+// Outcome of one restored book.
+type restoreReportBook struct {
+  ExportId int `json:"export_id"` // book ID in the archive
+  Id int `json:"id"` // ID of added, replaced, or skipped existing book, or 0 if failed
+  Name string `json:"name"` // book name
+  Status model.UploadStatus `json:"status"` // outcome
+  Error *errorBody `json:"error,omitempty"` // error, if failed
+}
+
+// Restore response body.
+type restoreReport struct {
+  Books []restoreReportBook `json:"books"` // outcome of each book, in archive order
+}
+
+// Library source which records the ID of each book read from a library
+// archive, and converts archive errors to API errors.
+type restoreSource struct {
+  r *library.Reader // library archive reader
+  ids []int // archive ID of each book returned by Next()
+}
+
+// Convert library archive read error to API error.
+func restoreError(err error) error {
+  var maxBytesErr *http.MaxBytesError
+  if errors.As(err, &maxBytesErr) {
+    return tooLarge(fmt.Sprintf("archive is larger than the maximum upload size (%d bytes)", maxBytesErr.Limit))
+  }
+
+  return badRequest(err.Error())
+}
+
+// Get library metadata.
+func (s *restoreSource) Library() model.Library {
+  return s.r.Library()
+}
+
+// Get metadata and body of next book.
+func (s *restoreSource) Next() (model.LibraryBook, string, error) {
+  book, body, err := s.r.Next()
+  if err != nil {
+    if err == io.EOF {
+      return book, body, err
+    }
+    return book, body, restoreError(err)
+  }
+
+  s.ids = append(s.ids, book.Id)
+  return book, body, nil
+}
+
+// Route handler which restores books from a library archive.
+//
+// The request body is a library archive, as returned by the export
+// endpoint.  Accepts the `conflict`, `duplicates`, and `partial` query
+// string parameters, which have the same meaning as for uploads, except
+// that `duplicates` defaults to `allow`.
+//
+// The archive size is limited by the maximum upload request size, and
+// the size of each book is limited by the maximum upload file size.
+//
+// The response is a JSON report containing the archive ID, book ID,
+// name, and outcome of each book.
+func doApiLibraryRestore(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // parse options
+  // (note: duplicate books are allowed by default, so that libraries
+  // which contain duplicates can be restored)
+  opts, err := parseUploadOptions(r)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  if opts.Duplicates == "" {
+    opts.Duplicates = model.DuplicateAllow
+  }
+
+  // limit request size
+  // (note: a larger content length is rejected before reading)
+  maxRequestSize := int64(appCtx.Config.UploadMaxRequestSize)
+  if r.ContentLength > maxRequestSize {
+    writeError(w, tooLarge(fmt.Sprintf("archive is larger than the maximum upload size (%d bytes)", maxRequestSize)))
+    return
+  }
+  r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+
+  // open archive
+  lr, err := library.NewReader(r.Body, int64(appCtx.Config.UploadMaxFileSize))
+  if err != nil {
+    writeError(w, restoreError(err))
+    return
+  }
+
+  // restore books
+  src := restoreSource { r: lr }
+  results, err := appCtx.Model.RestoreLibrary(ctx, appCtx.Pool, &src, opts)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // build report
+  report := restoreReport { Books: make([]restoreReportBook, 0, len(results)) }
+  for i, result := range(results) {
+    b := restoreReportBook {
+      ExportId: src.ids[i],
+      Id: result.Id,
+      Name: result.Name,
+      Status: result.Status,
+    }
+
+    if result.Err != nil {
+      _, code, message := errorStatus(result.Err)
+      b.Error = &errorBody { Code: code, Message: message }
+    }
+
+    report.Books = append(report.Books, b)
+  }
+
+  // write JSON-encoded report
+  writeJson(w, report)
+}
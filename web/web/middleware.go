@@ -2,7 +2,9 @@ package web
 
 import (
   "bookman/app"
+  "bookman/model"
   "context"
+  "net"
   "net/http"
 )
 
@@ -28,6 +30,23 @@ func AppContextMiddleware(appCtx *app.Context) func(next http.Handler) http.Hand
   }
 }
 
+// HTTP middleware which stores the client address in the request
+// context as the actor for changes made by the request.
+//
+// The actor is saved in the revision history of changed books.
+func ActorMiddleware(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    // get client address, sans port
+    actor := r.RemoteAddr
+    if host, _, err := net.SplitHostPort(actor); err == nil {
+      actor = host
+    }
+
+    // call the next handler in the chain
+    next.ServeHTTP(w, r.WithContext(model.ContextWithActor(r.Context(), actor)))
+  })
+}
+
 // HTTP middleware which adds the security headers to all responses:
 //
 // * Access-Control-Allow-Methods
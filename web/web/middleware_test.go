@@ -2,6 +2,7 @@ package web
 
 import (
   "bookman/app"
+  "bookman/model"
   "context"
   "net/http"
   "net/http/httptest"
@@ -43,6 +44,20 @@ func TestAppContextMiddleware(t *testing.T) {
   AppContextMiddleware(&exp)(check).ServeHTTP(nil, fakeRequest)
 }
 
+func TestActorMiddleware(t *testing.T) {
+  // create handler function which gets the actor from the request
+  // context and checks it
+  check := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+    if got, exp := model.ActorFromContext(r.Context()), "192.0.2.1"; got != exp {
+      t.Fatalf("got %q, exp %q", got, exp)
+    }
+  })
+
+  // wrap handler function with actor middleware, then send it a fake
+  // request (note: httptest requests are from 192.0.2.1:1234)
+  ActorMiddleware(check).ServeHTTP(nil, httptest.NewRequest("GET", "/", nil))
+}
+
 func TestSecurityHeadersMiddleware(t *testing.T) {
   // test content-security-policy value
   expCsp := "foo bar"
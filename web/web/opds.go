@@ -0,0 +1,388 @@
+package web
+
+import (
+  "bookman/model"
+  "bytes"
+  "encoding/xml"
+  "fmt"
+  "github.com/go-chi/chi/v5"
+  "log"
+  "net/http"
+  "net/url"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// OPDS 1.2 catalog.
+//
+// The catalog consists of a navigation feed at `/opds` which links to
+// the following feeds:
+//
+// * `/opds/title`: all books, sorted by name.
+// * `/opds/author`: navigation feed of authors, sorted by sort name,
+//   which links to an acquisition feed of the books by each author at
+//   `/opds/author/{id}`.
+// * `/opds/new`: all books, most recently added first.
+// * `/opds/search?q=...`: full-text search results, sorted by rank.
+//
+// Search is advertised to clients with an OpenSearch description at
+// `/opds/opensearch.xml`.  Acquisition feeds are paginated with the
+// same cursors as `/api/search`.
+//
+// Links use absolute paths, so the catalog assumes that Bookman is
+// served from the root of the host.
+//
+// OPDS clients cannot log in with a session cookie, so the catalog and
+// book downloads ask clients without a session for HTTP Basic
+// authentication with an API token as the password (see
+// requestToken() and BasicAuthChallengeMiddleware()).
+
+// OPDS content types
+const (
+  opdsNavigationType = "application/atom+xml;profile=opds-catalog;kind=navigation"
+  opdsAcquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+  openSearchType = "application/opensearchdescription+xml"
+)
+
+// OPDS link relations
+const (
+  opdsAcquisitionRel = "http://opds-spec.org/acquisition"
+  opdsSortNewRel = "http://opds-spec.org/sort/new"
+)
+
+// Atom link.
+type opdsLink struct {
+  Rel string `xml:"rel,attr,omitempty"`
+  Href string `xml:"href,attr"`
+  Type string `xml:"type,attr,omitempty"`
+  Title string `xml:"title,attr,omitempty"`
+}
+
+// Atom person.
+type opdsAuthor struct {
+  Name string `xml:"name"`
+}
+
+// Atom text content.
+type opdsContent struct {
+  Type string `xml:"type,attr"`
+  Text string `xml:",chardata"`
+}
+
+// Atom entry.
+type opdsEntry struct {
+  Title string `xml:"title"`
+  Id string `xml:"id"`
+  Updated string `xml:"updated"`
+  Authors []opdsAuthor `xml:"author"`
+  Content *opdsContent `xml:"content,omitempty"`
+  Links []opdsLink `xml:"link"`
+}
+
+// Atom feed.
+type opdsFeed struct {
+  XMLName xml.Name `xml:"feed"`
+  Xmlns string `xml:"xmlns,attr"`
+  XmlnsOpenSearch string `xml:"xmlns:opensearch,attr,omitempty"`
+  Id string `xml:"id"`
+  Title string `xml:"title"`
+  Updated string `xml:"updated"`
+  Author opdsAuthor `xml:"author"`
+  Links []opdsLink `xml:"link"`
+  TotalResults *int64 `xml:"opensearch:totalResults,omitempty"`
+  ItemsPerPage int `xml:"opensearch:itemsPerPage,omitempty"`
+  Entries []opdsEntry `xml:"entry"`
+}
+
+// Create feed with common links.
+func newOpdsFeed(id, title, selfHref, selfType string) opdsFeed {
+  return opdsFeed {
+    Xmlns: "http://www.w3.org/2005/Atom",
+    Id: id,
+    Title: title,
+    Updated: time.Now().UTC().Format(time.RFC3339),
+    Author: opdsAuthor { Name: "Bookman" },
+    Links: []opdsLink {
+      { Rel: "self", Href: selfHref, Type: selfType },
+      { Rel: "start", Href: "/opds", Type: opdsNavigationType },
+      { Rel: "search", Href: "/opds/opensearch.xml", Type: openSearchType },
+    },
+  }
+}
+
+// Write XML-encoded value as response body with the given content
+// type.
+func writeXml(w http.ResponseWriter, contentType string, v any) {
+  // encode response
+  var buf bytes.Buffer
+  buf.WriteString(xml.Header)
+  e := xml.NewEncoder(&buf)
+  e.Indent("", "  ")
+  if err := e.Encode(v); err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // write response
+  w.Header().Set("Content-Type", contentType)
+  if _, err := w.Write(buf.Bytes()); err != nil {
+    log.Print(err)
+  }
+}
+
+// Route handler for OPDS navigation feed.
+func doOpds(w http.ResponseWriter, r *http.Request) {
+  feed := newOpdsFeed("urn:bookman:opds", "Bookman", "/opds", opdsNavigationType)
+  updated := feed.Updated
+
+  // add navigation entries
+  for _, e := range([]struct {
+    id, title, text, href, rel, hrefType string
+  } {
+    { "title", "By Title", "All books, sorted by title.", "/opds/title", "subsection", opdsAcquisitionType },
+    { "author", "By Author", "Books by each author.", "/opds/author", "subsection", opdsNavigationType },
+    { "new", "Recently Added", "All books, most recently added first.", "/opds/new", opdsSortNewRel, opdsAcquisitionType },
+  }) {
+    feed.Entries = append(feed.Entries, opdsEntry {
+      Title: e.title,
+      Id: "urn:bookman:opds:" + e.id,
+      Updated: updated,
+      Content: &opdsContent { Type: "text", Text: e.text },
+      Links: []opdsLink {
+        { Rel: e.rel, Href: e.href, Type: e.hrefType },
+      },
+    })
+  }
+
+  writeXml(w, opdsNavigationType, feed)
+}
+
+// Build acquisition feed href from path, query string, and cursor.
+func opdsHref(path string, query url.Values, cursor string) string {
+  // copy query, add cursor
+  q := url.Values{}
+  for k, v := range(query) {
+    q[k] = v
+  }
+  if cursor != "" {
+    q.Set("cursor", cursor)
+  }
+
+  if len(q) == 0 {
+    return path
+  }
+  return path + "?" + q.Encode()
+}
+
+// Build acquisition feed entry for book.
+func opdsBookEntry(book model.Book, updated string) opdsEntry {
+  id := strconv.Itoa(book.Id)
+
+  // split author names
+  var authors []opdsAuthor
+  for _, name := range(strings.Split(book.Author, ";")) {
+    if name = strings.TrimSpace(name); name != "" {
+      authors = append(authors, opdsAuthor { Name: name })
+    }
+  }
+
+  return opdsEntry {
+    Title: book.Name,
+    Id: "urn:bookman:book:" + id,
+    Updated: updated,
+    Authors: authors,
+    Links: []opdsLink {
+      { Rel: opdsAcquisitionRel, Href: "/book/" + id + ".epub", Type: "application/epub+zip" },
+      { Rel: opdsAcquisitionRel, Href: "/book/" + id + ".html", Type: "text/html" },
+      { Rel: opdsAcquisitionRel, Href: "/book/" + id, Type: "text/plain" },
+    },
+  }
+}
+
+// Create route handler for OPDS acquisition feed with the given ID
+// suffix, title, path, and sort order.
+//
+// If the sort order is empty, then the feed is a search feed: the `q`
+// request parameter is the search query, and results are sorted by
+// rank (or by name if the query is empty).
+//
+// Accepts the following request parameters:
+//
+// * `q`: search query string (search feed only).
+// * `cursor`: cursor from the `next` link of the previous page.
+func opdsAcquisitionHandler(id, title, path string, sort model.SearchSort) http.HandlerFunc {
+  return func(w http.ResponseWriter, r *http.Request) {
+    // get context from request and app context from context
+    ctx := r.Context()
+    appCtx := appContextFromContext(ctx)
+
+    // build search parameters
+    params := model.SearchParams {
+      Sort: sort,
+      Limit: model.DefaultSearchLimit,
+      Cursor: r.FormValue("cursor"),
+    }
+
+    // build query string for self and next links
+    query := url.Values{}
+    if sort == "" {
+      params.Query = r.FormValue("q")
+      query.Set("q", params.Query)
+    }
+
+    // get books
+    result, err := appCtx.Model.Search(ctx, appCtx.Pool, params)
+    if err != nil {
+      writeError(w, err)
+      return
+    }
+
+    // build feed
+    selfHref := opdsHref(path, query, params.Cursor)
+    feed := newOpdsFeed("urn:bookman:opds:" + id, title, selfHref, opdsAcquisitionType)
+    feed.XmlnsOpenSearch = "http://a9.com/-/spec/opensearch/1.1/"
+    feed.TotalResults = &result.Total
+    feed.ItemsPerPage = params.Limit
+    feed.Links = append(feed.Links, opdsLink {
+      Rel: "up",
+      Href: "/opds",
+      Type: opdsNavigationType,
+    })
+
+    // add next link
+    if result.Next != "" {
+      feed.Links = append(feed.Links, opdsLink {
+        Rel: "next",
+        Href: opdsHref(path, query, result.Next),
+        Type: opdsAcquisitionType,
+      })
+    }
+
+    // add entries
+    for _, book := range(result.Books) {
+      feed.Entries = append(feed.Entries, opdsBookEntry(book, feed.Updated))
+    }
+
+    writeXml(w, opdsAcquisitionType, feed)
+  }
+}
+
+// Route handler for OPDS navigation feed of authors.
+//
+// Each entry links to the acquisition feed of the books by the author.
+func doOpdsAuthors(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // get authors
+  authors, err := appCtx.Model.Authors(ctx, appCtx.Pool)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // build feed
+  feed := newOpdsFeed("urn:bookman:opds:author", "By Author", "/opds/author", opdsNavigationType)
+  feed.Links = append(feed.Links, opdsLink {
+    Rel: "up",
+    Href: "/opds",
+    Type: opdsNavigationType,
+  })
+
+  // add entries
+  for _, author := range(authors) {
+    id := strconv.Itoa(author.Id)
+
+    // build book count
+    text := fmt.Sprintf("%d books", author.NumBooks)
+    if author.NumBooks == 1 {
+      text = "1 book"
+    }
+
+    feed.Entries = append(feed.Entries, opdsEntry {
+      Title: author.Name,
+      Id: "urn:bookman:opds:author:" + id,
+      Updated: feed.Updated,
+      Content: &opdsContent { Type: "text", Text: text },
+      Links: []opdsLink {
+        { Rel: "subsection", Href: "/opds/author/" + id, Type: opdsAcquisitionType },
+      },
+    })
+  }
+
+  writeXml(w, opdsNavigationType, feed)
+}
+
+// Route handler for OPDS acquisition feed of the books by an author,
+// sorted by name.
+//
+// The feed is not paginated.
+func doOpdsAuthor(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // parse author ID
+  id, err := parseAuthorId(chi.URLParam(r, "id"))
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // get author and books
+  author, err := appCtx.Model.Author(ctx, appCtx.Pool, id)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // build feed
+  idStr := strconv.FormatInt(id, 10)
+  feed := newOpdsFeed("urn:bookman:opds:author:" + idStr, author.Name, "/opds/author/" + idStr, opdsAcquisitionType)
+  feed.Links = append(feed.Links, opdsLink {
+    Rel: "up",
+    Href: "/opds/author",
+    Type: opdsNavigationType,
+  })
+
+  // add entries
+  for _, book := range(author.Books) {
+    feed.Entries = append(feed.Entries, opdsBookEntry(book, feed.Updated))
+  }
+
+  writeXml(w, opdsAcquisitionType, feed)
+}
+
+// OpenSearch URL template.
+type openSearchUrl struct {
+  Type string `xml:"type,attr"`
+  Template string `xml:"template,attr"`
+}
+
+// OpenSearch description document.
+type openSearchDescription struct {
+  XMLName xml.Name `xml:"OpenSearchDescription"`
+  Xmlns string `xml:"xmlns,attr"`
+  ShortName string `xml:"ShortName"`
+  Description string `xml:"Description"`
+  InputEncoding string `xml:"InputEncoding"`
+  OutputEncoding string `xml:"OutputEncoding"`
+  Url openSearchUrl `xml:"Url"`
+}
+
+// Route handler for OpenSearch description of OPDS search feed.
+func doOpdsOpenSearch(w http.ResponseWriter, r *http.Request) {
+  writeXml(w, openSearchType, openSearchDescription {
+    Xmlns: "http://a9.com/-/spec/opensearch/1.1/",
+    ShortName: "Bookman",
+    Description: "Full-text search of Bookman books.",
+    InputEncoding: "UTF-8",
+    OutputEncoding: "UTF-8",
+    Url: openSearchUrl {
+      Type: opdsAcquisitionType,
+      Template: "/opds/search?q={searchTerms}",
+    },
+  })
+}
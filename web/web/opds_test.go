@@ -0,0 +1,321 @@
+package web
+
+import (
+  "bookman/app"
+  "bookman/model"
+  "context"
+  "encoding/xml"
+  "errors"
+  "io"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+)
+
+// Send GET request to OPDS router with given mock model and a session
+// for a viewer, return response.
+func getOpds(t *testing.T, m *model.MockModel, path string) *httptest.ResponseRecorder {
+  t.Helper()
+
+  // build app context w/ mock model, create router
+  m.SessionUserResult = model.MockUserResult {
+    User: model.User { Id: 1, Name: "alice", Role: model.RoleViewer },
+  }
+  appCtx := app.Context { Model: m }
+  router, err := NewRouter(&appCtx)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // create request and response recorder
+  req, err := http.NewRequestWithContext(context.Background(), "GET", path, nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+  req.AddCookie(&http.Cookie { Name: sessionCookieName, Value: "token" })
+  resp := httptest.NewRecorder()
+
+  // send request
+  router.ServeHTTP(resp, req)
+  return resp
+}
+
+// Check response content type and well-formed XML, return body.
+func checkOpdsResponse(t *testing.T, resp *httptest.ResponseRecorder, contentType string) string {
+  t.Helper()
+
+  // check status
+  if resp.Code != http.StatusOK {
+    t.Fatalf("got status %d, exp %d", resp.Code, http.StatusOK)
+  }
+
+  // check content type
+  if got := resp.Header().Get("Content-Type"); got != contentType {
+    t.Fatalf("got \"%s\", exp \"%s\"", got, contentType)
+  }
+
+  // read body
+  body, err := io.ReadAll(resp.Result().Body)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // check for well-formed xml
+  d := xml.NewDecoder(strings.NewReader(string(body)))
+  for {
+    if _, err := d.Token(); err == io.EOF {
+      break
+    } else if err != nil {
+      t.Fatalf("invalid xml: %v: %s", err, body)
+    }
+  }
+
+  return string(body)
+}
+
+// Check that string contains all expected fragments.
+func checkContains(t *testing.T, got string, exps []string) {
+  t.Helper()
+
+  for _, exp := range(exps) {
+    if !strings.Contains(got, exp) {
+      t.Errorf("missing %q in %s", exp, got)
+    }
+  }
+}
+
+func TestDoOpds(t *testing.T) {
+  resp := getOpds(t, &model.MockModel{}, "/opds")
+  body := checkOpdsResponse(t, resp, opdsNavigationType)
+
+  checkContains(t, body, []string {
+    `<feed xmlns="http://www.w3.org/2005/Atom">`,
+    `<link rel="self" href="/opds" type="` + opdsNavigationType + `"></link>`,
+    `<link rel="search" href="/opds/opensearch.xml" type="application/opensearchdescription+xml"></link>`,
+    `<link rel="subsection" href="/opds/title" type="` + opdsAcquisitionType + `"></link>`,
+    `<link rel="subsection" href="/opds/author" type="` + opdsNavigationType + `"></link>`,
+    `<link rel="http://opds-spec.org/sort/new" href="/opds/new" type="` + opdsAcquisitionType + `"></link>`,
+  })
+}
+
+func TestOpdsAcquisitionHandler(t *testing.T) {
+  // mock model which returns two books and a next page
+  m := &model.MockModel {
+    SearchResult: model.MockSearchResult {
+      Books: []model.Book {
+        { Id: 1, Name: "Cats & Dogs", Author: "Jane Doe; John Roe" },
+        { Id: 2, Name: "Frankenstein", Author: "Mary Shelley" },
+      },
+      Next: "abc",
+      Total: 123,
+    },
+  }
+
+  t.Run("pass", func(t *testing.T) {
+    tests := []struct {
+      path string // request path
+      exp []string // expected body fragments
+    } {{
+      path: "/opds/title",
+      exp: []string {
+        `<feed xmlns="http://www.w3.org/2005/Atom" xmlns:opensearch="http://a9.com/-/spec/opensearch/1.1/">`,
+        `<id>urn:bookman:opds:title</id>`,
+        `<link rel="self" href="/opds/title" type="` + opdsAcquisitionType + `"></link>`,
+        `<link rel="next" href="/opds/title?cursor=abc" type="` + opdsAcquisitionType + `"></link>`,
+        `<opensearch:totalResults>123</opensearch:totalResults>`,
+        `<title>Cats &amp; Dogs</title>`,
+        `<id>urn:bookman:book:1</id>`,
+        `<name>Jane Doe</name>`,
+        `<name>John Roe</name>`,
+        `<link rel="http://opds-spec.org/acquisition" href="/book/1.epub" type="application/epub+zip"></link>`,
+        `<link rel="http://opds-spec.org/acquisition" href="/book/1.html" type="text/html"></link>`,
+        `<link rel="http://opds-spec.org/acquisition" href="/book/1" type="text/plain"></link>`,
+        `<id>urn:bookman:book:2</id>`,
+      },
+    }, {
+      path: "/opds/new?cursor=xyz",
+      exp: []string {
+        `<link rel="self" href="/opds/new?cursor=xyz" type="` + opdsAcquisitionType + `"></link>`,
+        `<link rel="next" href="/opds/new?cursor=abc" type="` + opdsAcquisitionType + `"></link>`,
+      },
+    }, {
+      path: "/opds/search?q=monster+%26+maker",
+      exp: []string {
+        `<link rel="self" href="/opds/search?q=monster+%26+maker" type="` + opdsAcquisitionType + `"></link>`,
+        `<link rel="next" href="/opds/search?cursor=abc&amp;q=monster+%26+maker" type="` + opdsAcquisitionType + `"></link>`,
+      },
+    }}
+
+    for _, test := range(tests) {
+      t.Run(test.path, func(t *testing.T) {
+        resp := getOpds(t, m, test.path)
+        body := checkOpdsResponse(t, resp, opdsAcquisitionType)
+        checkContains(t, body, test.exp)
+      })
+    }
+  })
+
+  t.Run("last page", func(t *testing.T) {
+    resp := getOpds(t, &model.MockModel{}, "/opds/new")
+    body := checkOpdsResponse(t, resp, opdsAcquisitionType)
+    if strings.Contains(body, `rel="next"`) {
+      t.Fatalf("got next link, exp none: %s", body)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &model.MockModel {
+      SearchResult: model.MockSearchResult {
+        Err: &model.ValidationError { Field: "cursor", Message: "invalid cursor" },
+      },
+    }
+
+    resp := getOpds(t, m, "/opds/title?cursor=bad")
+    checkErrorResponse(t, resp, http.StatusBadRequest, "invalid")
+  })
+
+  t.Run("model fail", func(t *testing.T) {
+    m := &model.MockModel {
+      SearchResult: model.MockSearchResult { Err: errors.New("some error") },
+    }
+
+    resp := getOpds(t, m, "/opds/search?q=foo")
+    checkErrorResponse(t, resp, http.StatusInternalServerError, "internal_error")
+  })
+}
+
+func TestDoOpdsAuthors(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    m := &model.MockModel {
+      AuthorsResult: model.MockAuthorsResult {
+        Authors: []model.Author {
+          { Id: 1, Name: "Jane Doe", SortName: "Doe, Jane", NumBooks: 1 },
+          { Id: 2, Name: "Mary Shelley & Co", SortName: "Shelley, Mary", NumBooks: 3 },
+        },
+      },
+    }
+
+    resp := getOpds(t, m, "/opds/author")
+    body := checkOpdsResponse(t, resp, opdsNavigationType)
+    checkContains(t, body, []string {
+      `<id>urn:bookman:opds:author</id>`,
+      `<link rel="self" href="/opds/author" type="` + opdsNavigationType + `"></link>`,
+      `<link rel="up" href="/opds" type="` + opdsNavigationType + `"></link>`,
+      `<title>Jane Doe</title>`,
+      `<id>urn:bookman:opds:author:1</id>`,
+      `<content type="text">1 book</content>`,
+      `<link rel="subsection" href="/opds/author/1" type="` + opdsAcquisitionType + `"></link>`,
+      `<title>Mary Shelley &amp; Co</title>`,
+      `<content type="text">3 books</content>`,
+      `<link rel="subsection" href="/opds/author/2" type="` + opdsAcquisitionType + `"></link>`,
+    })
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := &model.MockModel {
+      AuthorsResult: model.MockAuthorsResult { Err: errors.New("some error") },
+    }
+
+    resp := getOpds(t, m, "/opds/author")
+    checkErrorResponse(t, resp, http.StatusInternalServerError, "internal_error")
+  })
+}
+
+func TestDoOpdsAuthor(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    m := &model.MockModel {
+      AuthorResult: model.MockAuthorResult {
+        Author: model.AuthorDetail {
+          Author: model.Author { Id: 2, Name: "Mary Shelley", SortName: "Shelley, Mary", NumBooks: 1 },
+          Books: []model.Book {
+            { Id: 3, Name: "Frankenstein", Author: "Mary Shelley" },
+          },
+        },
+      },
+    }
+
+    resp := getOpds(t, m, "/opds/author/2")
+    body := checkOpdsResponse(t, resp, opdsAcquisitionType)
+    checkContains(t, body, []string {
+      `<id>urn:bookman:opds:author:2</id>`,
+      `<title>Mary Shelley</title>`,
+      `<link rel="self" href="/opds/author/2" type="` + opdsAcquisitionType + `"></link>`,
+      `<link rel="up" href="/opds/author" type="` + opdsNavigationType + `"></link>`,
+      `<id>urn:bookman:book:3</id>`,
+      `<link rel="http://opds-spec.org/acquisition" href="/book/3.epub" type="application/epub+zip"></link>`,
+    })
+  })
+
+  t.Run("not found", func(t *testing.T) {
+    m := &model.MockModel {
+      AuthorResult: model.MockAuthorResult { Err: model.ErrAuthorNotFound },
+    }
+
+    resp := getOpds(t, m, "/opds/author/2")
+    checkErrorResponse(t, resp, http.StatusNotFound, "not_found")
+  })
+}
+
+func TestOpdsBasicAuth(t *testing.T) {
+  // read-only token for viewer
+  token := testToken
+  token.Scope = model.ScopeRead
+  user := model.User { Id: 1, Name: "alice", Role: model.RoleViewer }
+
+  // build app context w/ mock model which returns token user, create
+  // router
+  appCtx := app.Context {
+    Model: &model.MockModel {
+      TokenUserResult: model.MockTokenUserResult { Token: token, User: user },
+    },
+  }
+  router, err := NewRouter(&appCtx)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  t.Run("no user", func(t *testing.T) {
+    for _, path := range([]string { "/opds", "/opds/title", "/book/1.epub" }) {
+      t.Run(path, func(t *testing.T) {
+        resp := httptest.NewRecorder()
+        router.ServeHTTP(resp, httptest.NewRequest("GET", path, nil))
+
+        checkErrorResponse(t, resp, http.StatusUnauthorized, "unauthorized")
+        exp := `Basic realm="Bookman", charset="UTF-8"`
+        if got := resp.Header().Get("WWW-Authenticate"); got != exp {
+          t.Fatalf("got %q, exp %q", got, exp)
+        }
+      })
+    }
+  })
+
+  t.Run("api", func(t *testing.T) {
+    resp := httptest.NewRecorder()
+    router.ServeHTTP(resp, httptest.NewRequest("GET", "/api/search", nil))
+
+    checkErrorResponse(t, resp, http.StatusUnauthorized, "unauthorized")
+    if got := resp.Header().Get("WWW-Authenticate"); got != "" {
+      t.Fatalf("got %q, exp none", got)
+    }
+  })
+
+  t.Run("token", func(t *testing.T) {
+    req := httptest.NewRequest("GET", "/opds", nil)
+    req.SetBasicAuth("alice", "token")
+    resp := httptest.NewRecorder()
+    router.ServeHTTP(resp, req)
+
+    checkOpdsResponse(t, resp, opdsNavigationType)
+  })
+}
+
+func TestDoOpdsOpenSearch(t *testing.T) {
+  resp := getOpds(t, &model.MockModel{}, "/opds/opensearch.xml")
+  body := checkOpdsResponse(t, resp, openSearchType)
+
+  checkContains(t, body, []string {
+    `<OpenSearchDescription xmlns="http://a9.com/-/spec/opensearch/1.1/">`,
+    `<Url type="` + opdsAcquisitionType + `" template="/opds/search?q={searchTerms}"></Url>`,
+  })
+}
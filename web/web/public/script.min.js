@@ -1,13 +1,13 @@
-(()=>{"use strict";const r=document,t=a=>r.getElementById(a),f=a=>r.querySelectorAll(a),d=(a,n,s)=>a.addEventListener(n,s),v=t("q"),u=t("books"),m=t("upload"),o=a=>String(a).replaceAll("&","&amp;").replaceAll("<","&lt;").replaceAll(">","&gt;").replaceAll("'","&apos;").replaceAll('"',"&quot;"),p={item:a=>`
+(()=>{"use strict";const m=document,a=e=>m.getElementById(e),y=e=>m.querySelectorAll(e),i=(e,t,d)=>e.addEventListener(t,d),_=a("q"),H=a("sort"),M=a("books"),v=a("upload"),p={next:"",seq:0,busy:!1},n=e=>String(e).replaceAll("&","&amp;").replaceAll("<","&lt;").replaceAll(">","&gt;").replaceAll("'","&apos;").replaceAll('"',"&quot;"),q=e=>n(e||"").replaceAll("\uE000","<mark>").replaceAll("\uE001","</mark>"),c={item:e=>`
       <a
-        href='./book/${o(a.id)}'
+        href='./book/${n(e.id)}'
         class='panel-block'
-        title='${o(a.name)}, by ${o(a.author)}'
-        aria-label='${o(a.name)}, by ${o(a.author)}'
-        data-id='${o(a.id)}'
-        data-name='${o(a.name)}'
-        data-author='${o(a.author)}'
-        data-rank='${o(a.rank)}'
+        title='${n(e.name)}, by ${n(e.author)}'
+        aria-label='${n(e.name)}, by ${n(e.author)}'
+        data-id='${n(e.id)}'
+        data-name='${n(e.name)}'
+        data-author='${n(e.author)}'
+        data-rank='${n(e.rank)}'
       >
         <span class='edit-book'>
           <svg xmlns='http://www.w3.org/2000/svg' width='16' height='16' fill='currentColor' class='bi bi-pencil-square' viewBox='0 0 16 16'>
@@ -16,10 +16,76 @@
           </svg>
         </span>
 
-        ${o(a.name)}, by ${o(a.author)}
+        <span class='delete-book'>
+          <svg xmlns='http://www.w3.org/2000/svg' width='16' height='16' fill='currentColor' class='bi bi-trash' viewBox='0 0 16 16'>
+            <path d='M5.5 5.5A.5.5 0 0 1 6 6v6a.5.5 0 0 1-1 0V6a.5.5 0 0 1 .5-.5zm2.5 0a.5.5 0 0 1 .5.5v6a.5.5 0 0 1-1 0V6a.5.5 0 0 1 .5-.5zm3 .5a.5.5 0 0 0-1 0v6a.5.5 0 0 0 1 0V6z'/>
+            <path fill-rule='evenodd' d='M14.5 3a1 1 0 0 1-1 1H13v9a2 2 0 0 1-2 2H5a2 2 0 0 1-2-2V4h-.5a1 1 0 0 1-1-1V2a1 1 0 0 1 1-1H6a1 1 0 0 1 1-1h2a1 1 0 0 1 1 1h3.5a1 1 0 0 1 1 1v1zM4.118 4 4 4.059V13a1 1 0 0 0 1 1h6a1 1 0 0 0 1-1V4.059L11.882 4H4.118zM2.5 3V2h11v1h-11z'/>
+          </svg>
+        </span>
+
+        <span class='download-book' title='Download EPUB' aria-label='Download EPUB'>
+          <svg xmlns='http://www.w3.org/2000/svg' width='16' height='16' fill='currentColor' class='bi bi-download' viewBox='0 0 16 16'>
+            <path d='M.5 9.9a.5.5 0 0 1 .5.5v2.5a1 1 0 0 0 1 1h12a1 1 0 0 0 1-1v-2.5a.5.5 0 0 1 1 0v2.5a2 2 0 0 1-2 2H2a2 2 0 0 1-2-2v-2.5a.5.5 0 0 1 .5-.5z'/>
+            <path d='M7.646 11.854a.5.5 0 0 0 .708 0l3-3a.5.5 0 0 0-.708-.708L8.5 10.293V1.5a.5.5 0 0 0-1 0v8.793L5.354 8.146a.5.5 0 1 0-.708.708l3 3z'/>
+          </svg>
+        </span>
+
+        <span class='book-info'>
+          ${n(e.name)}, by ${n(e.author)}
+          ${e.snippet?c.snippet(e):""}
+        </span>
       </a>
-    `,none:()=>`
+    `,snippet:e=>`
+      <span class='snippet'>
+        ${q(e.snippet)}
+      </span>
+    `,trash_item:e=>`
+      <div
+        class='panel-block'
+        title='${n(e.name)}, by ${n(e.author)}'
+        aria-label='${n(e.name)}, by ${n(e.author)}'
+      >
+        <span class='trash-name'>
+          ${n(e.name)}, by ${n(e.author)}
+        </span>
+
+        <button
+          class='button is-small is-info restore-book'
+          title='Restore book.'
+          aria-label='Restore book.'
+          data-id='${n(e.id)}'
+        >
+          Restore
+        </button>
+
+        <button
+          class='button is-small is-danger purge-book'
+          title='Permanently delete book.'
+          aria-label='Permanently delete book.'
+          data-id='${n(e.id)}'
+        >
+          Delete Forever
+        </button>
+      </div>
+    `,trash_none:()=>`
+      <div class='panel-block'>
+        Trash is empty.
+      </div>
+    `,label:(e,t)=>`
+      <span class='tag is-info is-light'>
+        ${n(t)}
+
+        <button
+          class='delete is-small remove-label'
+          title='Remove ${e}.'
+          aria-label='Remove ${e}.'
+          data-kind='${e}'
+          data-name='${n(t)}'
+        ></button>
+      </span>
+    `,option:e=>`<option value='${n(e.name)}'></option>`,none:()=>`
       <div class='panel-block'>
         No matching results.
       </div>
-    `,list:a=>a.map(n=>p.item(n)).join("")},h=()=>{const a=v.value||"",n=u.dataset.q||"";if(!a||a!==n){const s="./api/search?"+new URLSearchParams({q:a}).toString();fetch(s).then(i=>i.json()).then(i=>{u.dataset.q=q,u.innerHTML=i.length>0?p.list(i):p.none()})}};d(r,"DOMContentLoaded",()=>{let a=null;d(v,"keydown",()=>{a!==null&&(clearTimeout(a),a=null),a=setTimeout(h,200)}),d(t("books"),"click",e=>{if(e.target.closest(".edit-book")){const l=e.target.closest("a").dataset;return t("edit-save-btn").dataset.id=l.id,t("edit-name").value=l.name,t("edit-author").value=l.author,t("edit-dialog").classList.add("is-active"),e.preventDefault(),!1}}),d(t("edit-save-btn"),"click",e=>{const l=new FormData;return l.append("id",t("edit-save-btn").dataset.id),l.append("name",t("edit-name").value),l.append("author",t("edit-author").value),fetch("./api/edit",{method:"POST",body:l}).then(c=>{if(!c.ok){alert("edit failed");return}t("edit-dialog").classList.remove("is-active"),h()}),e.preventDefault(),e.stopPropagation(),!1}),d(t("upload-btn"),"click",()=>{m.click()}),d(m,"change",()=>{const e=m.files;if(e.length==0)return;console.log(e);let l=new FormData;for(let c of e)l.append("file",c);fetch("./api/upload",{method:"POST",body:l}).then(c=>{c.ok?h():alert("upload failed")})});const n=e=>e.classList.add("is-active"),s=e=>e.classList.remove("is-active"),i=()=>(f(".modal")||[]).forEach(e=>s(e));(f(".modal-background, .modal-close, .modal-card-head .delete, .modal-card-foot")||[]).forEach(e=>{const l=e.closest(".modal");d(e,"click",()=>s(l))}),d(r,"keydown",e=>{(e||window.event).keyCode===27&&i()})}),h()})();
+    `,list:e=>e.map(t=>c.item(t)).join(""),trash:e=>e.map(t=>c.trash_item(t)).join(""),labels:(e,t)=>t.map(d=>c.label(e,d)).join(""),options:e=>e.map(t=>c.option(t)).join("")},D=e=>{const t={q:_.value||"",sort:H.value||""};e&&(t.cursor=e);const d="./api/search?"+new URLSearchParams(t).toString();return fetch(d).then(f=>f.ok?f.json():(g(f,"search failed"),{books:[],next:""}))},h=()=>{const e=++p.seq;D(null).then(t=>{e===p.seq&&(p.next=t.next,M.innerHTML=t.books.length>0?c.list(t.books):c.none())})},A=()=>{if(!p.next||p.busy)return;const e=p.seq;p.busy=!0,D(p.next).then(t=>{e===p.seq&&(p.next=t.next,M.insertAdjacentHTML("beforeend",c.list(t.books)))}).finally(()=>{p.busy=!1})},z=()=>{const e=m.cookie.split("; ").find(t=>t.startsWith("bookman_csrf="));return e?e.substring(e.indexOf("=")+1):""},b=(e,t)=>fetch(e,{method:"POST",headers:{"X-CSRF-Token":z()},body:t}),g=(e,t)=>{if(e.status===401){a("login-dialog").classList.add("is-active"),a("login-name").focus();return}e.json().then(d=>alert(d.error.message)).catch(()=>alert(t))},k=()=>{fetch("./api/user").then(e=>e.ok?e.json():null).then(e=>{a("user-name").textContent=e?`${e.name} (${e.role})`:"",a("login-btn").classList.toggle("is-hidden",!!e),a("logout-btn").classList.toggle("is-hidden",!e),a("upload-btn").classList.toggle("is-hidden",!e||e.role==="viewer")})},$=()=>{fetch("./api/trash").then(e=>e.json()).then(e=>{a("trash-books").innerHTML=e.length>0?c.trash(e):c.trash_none()})},x=e=>{fetch(`./api/labels?id=${e}`).then(t=>t.json()).then(t=>{a("edit-tags").innerHTML=c.labels("tag",t.tags),a("edit-collections").innerHTML=c.labels("collection",t.collections)}),fetch("./api/tags").then(t=>t.json()).then(t=>{a("tag-names").innerHTML=c.options(t)}),fetch("./api/collections").then(t=>t.json()).then(t=>{a("collection-names").innerHTML=c.options(t)})},j=(e,t,d)=>{const f=a("edit-save-btn").dataset.id,s=new FormData;return s.append("id",f),s.append(t,d),b(`./api/${t}s/${e}`,s).then(o=>(o.ok?x(f):g(o,`${e} ${t} failed`),o.ok))},L=(e,t)=>{const d=new FormData;return d.append("id",t),b(e,d)};i(m,"DOMContentLoaded",()=>{let e=null;i(_,"keydown",()=>{e!==null&&(clearTimeout(e),e=null),e=setTimeout(h,200)}),i(H,"change",h),i(window,"scroll",()=>{window.innerHeight+window.scrollY>=m.body.offsetHeight-200&&A()}),i(a("books"),"click",s=>{if(s.target.closest(".edit-book")){const o=s.target.closest("a").dataset;return a("edit-save-btn").dataset.id=o.id,a("edit-name").value=o.name,a("edit-author").value=o.author,a("edit-tags").innerHTML="",a("edit-collections").innerHTML="",x(o.id),a("edit-dialog").classList.add("is-active"),s.preventDefault(),!1}if(s.target.closest(".download-book")){const o=s.target.closest("a").dataset;return location.href=`./book/${o.id}.epub`,s.preventDefault(),!1}if(s.target.closest(".delete-book")){const o=s.target.closest("a").dataset;return L("./api/delete",o.id).then(r=>{r.ok?h():g(r,"delete failed")}),s.preventDefault(),!1}}),i(a("trash-btn"),"click",()=>{$(),a("trash-dialog").classList.add("is-active")}),i(a("trash-books"),"click",s=>{const o=s.target.closest(".restore-book"),r=s.target.closest(".purge-book");o?L("./api/restore",o.dataset.id).then(l=>{l.ok?($(),h()):g(l,"restore failed")}):r&&confirm("Permanently delete book?")&&L("./api/purge",r.dataset.id).then(l=>{l.ok?$():g(l,"delete failed")})}),i(a("edit-save-btn"),"click",s=>{const o=new FormData;return o.append("id",a("edit-save-btn").dataset.id),o.append("name",a("edit-name").value),o.append("author",a("edit-author").value),b("./api/edit",o).then(r=>{if(!r.ok){g(r,"edit failed");return}a("edit-dialog").classList.remove("is-active"),h()}),s.preventDefault(),s.stopPropagation(),!1}),["tag","collection"].forEach(s=>{const o=a(`edit-${s}`),r=l=>(o.value.trim()&&j("add",s,o.value).then(w=>{w&&(o.value="")}),l.preventDefault(),l.stopPropagation(),!1);i(a(`edit-${s}-add`),"click",r),i(o,"keydown",l=>{if(l.key==="Enter")return r(l)})}),i(a("edit-dialog"),"click",s=>{const o=s.target.closest(".remove-label");if(o)return j("remove",o.dataset.kind,o.dataset.name),s.preventDefault(),s.stopPropagation(),!1}),i(a("login-btn"),"click",()=>{a("login-dialog").classList.add("is-active"),a("login-name").focus()}),i(a("login-form"),"submit",s=>{const o=new FormData;return o.append("name",a("login-name").value),o.append("password",a("login-password").value),b("./api/login",o).then(r=>{if(!r.ok){a("login-dialog").classList.add("is-active"),r.json().then(l=>alert(l.error.message)).catch(()=>alert("login failed"));return}a("login-password").value="",a("login-dialog").classList.remove("is-active"),k(),h()}),s.preventDefault(),s.stopPropagation(),!1}),i(a("logout-btn"),"click",()=>{b("./api/logout",null).then(()=>{k(),h()})}),i(a("upload-btn"),"click",()=>{v.click()}),i(v,"change",()=>{const s=v.files;if(s.length==0)return;console.log(s);let o=new FormData;for(let l of s)o.append("file",l);const r=a("upload-conflict").value;b(`./api/upload?partial=true&conflict=${encodeURIComponent(r)}`,o).then(l=>{l.ok?(l.json().then(w=>{const T=w.files.filter(u=>u.status!=="created").map(u=>`${u.entry?`${u.file_name}/${u.entry}`:u.file_name}: ${u.status}`+(u.error?` (${u.error.message})`:""));T.length>0&&alert(T.join(`
+`))}),h()):g(l,"upload failed")})});const t=s=>s.classList.add("is-active"),d=s=>s.classList.remove("is-active"),f=()=>(y(".modal")||[]).forEach(s=>d(s));(y(".modal-background, .modal-close, .modal-card-head .delete, .modal-card-foot")||[]).forEach(s=>{const o=s.closest(".modal");i(s,"click",()=>d(o))}),i(m,"keydown",s=>{(s||window.event).keyCode===27&&f()})}),h(),k()})();
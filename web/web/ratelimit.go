@@ -0,0 +1,133 @@
+package web
+
+import (
+  "bookman/app"
+  "bookman/ratelimit"
+  "math"
+  "net"
+  "net/http"
+  "net/netip"
+  "strconv"
+  "strings"
+)
+
+// Rate limiters for each request budget.
+type rateLimiters struct {
+  search *ratelimit.Limiter // search requests
+  upload *ratelimit.Limiter // upload and restore requests
+  edit *ratelimit.Limiter // other requests which change the library
+}
+
+// Create rate limiters from config.
+func newRateLimiters(config app.Config) rateLimiters {
+  return rateLimiters {
+    search: ratelimit.New(config.RateLimitSearch, config.RateLimitSearchBurst),
+    upload: ratelimit.New(config.RateLimitUpload, config.RateLimitUploadBurst),
+    edit: ratelimit.New(config.RateLimitEdit, config.RateLimitEditBurst),
+  }
+}
+
+// Is the address in one of the given prefixes?
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+  for _, prefix := range(trusted) {
+    if prefix.Contains(addr) {
+      return true
+    }
+  }
+
+  return false
+}
+
+// Get client address of request.
+//
+// If the request is from a trusted proxy, then the `X-Forwarded-For`
+// header is read from right to left, and the first address which is not
+// a trusted proxy is the client address.  Otherwise the client address
+// is the remote address of the connection.  Addresses to the left of
+// the first untrusted address are ignored, because clients can forge
+// them.
+func clientAddr(r *http.Request, trusted []netip.Prefix) string {
+  // get remote address, sans port
+  host := r.RemoteAddr
+  if h, _, err := net.SplitHostPort(host); err == nil {
+    host = h
+  }
+
+  // check for trusted proxy
+  addr, err := netip.ParseAddr(host)
+  if err != nil || !isTrusted(addr.Unmap(), trusted) {
+    return host
+  }
+
+  // get forwarded addresses, from right to left
+  // (note: proxies may send multiple headers, which are equivalent to
+  // one comma-separated header)
+  fwd := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+  for i := len(fwd) - 1; i >= 0; i-- {
+    s := strings.TrimSpace(fwd[i])
+    if s == "" {
+      continue
+    }
+
+    a, err := netip.ParseAddr(s)
+    if err != nil {
+      // malformed address; stop at the last valid address
+      break
+    }
+
+    addr = a
+    if !isTrusted(a.Unmap(), trusted) {
+      break
+    }
+  }
+
+  return addr.Unmap().String()
+}
+
+// Get rate limit key of request: the logged in user, if any, or
+// otherwise the client address.
+func rateLimitKey(r *http.Request, trusted []netip.Prefix) string {
+  if user := userFromContext(r.Context()); user != nil {
+    return "user:" + strconv.Itoa(user.Id)
+  }
+
+  return "addr:" + clientAddr(r, trusted)
+}
+
+// Create error for a request which exceeded its rate limit.
+func tooManyRequests() error {
+  return &apiError { http.StatusTooManyRequests, "rate_limited", "too many requests" }
+}
+
+// Create HTTP middleware which limits the rate of requests from each
+// logged in user or, for requests without a logged in user, each
+// client address (see clientAddr()).  Requests which exceed the limit
+// are rejected with a 429 error and a `Retry-After` header with the
+// number of seconds until the next request is allowed.
+//
+// If the limiter is nil, then all requests are allowed.
+//
+// Must be used after TokenMiddleware.
+func RateLimitMiddleware(l *ratelimit.Limiter) func(http.Handler) http.Handler {
+  return func(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      // get app context from request context
+      appCtx := appContextFromContext(r.Context())
+
+      // check rate limit
+      key := rateLimitKey(r, appCtx.Config.TrustedProxies)
+      if ok, wait := l.Allow(key); !ok {
+        secs := int(math.Ceil(wait.Seconds()))
+        if secs < 1 {
+          secs = 1
+        }
+        w.Header().Set("Retry-After", strconv.Itoa(secs))
+        writeError(w, tooManyRequests())
+        return
+      }
+
+      // call the next handler in the chain
+      next.ServeHTTP(w, r)
+    })
+  }
+}
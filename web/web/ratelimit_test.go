@@ -0,0 +1,175 @@
+package web
+
+import (
+  "bookman/app"
+  "bookman/model"
+  "bookman/ratelimit"
+  "context"
+  "net/http"
+  "net/http/httptest"
+  "net/netip"
+  "testing"
+)
+
+func TestClientAddr(t *testing.T) {
+  // trusted proxies
+  trusted := []netip.Prefix {
+    netip.MustParsePrefix("10.0.0.0/8"),
+    netip.MustParsePrefix("::1/128"),
+  }
+
+  tests := []struct {
+    name string // test name
+    remote string // remote address
+    fwd []string // X-Forwarded-For headers
+    exp string // expected client address
+  } {
+    { "no proxy", "192.0.2.1:1234", nil, "192.0.2.1" },
+    { "untrusted proxy", "192.0.2.1:1234", []string { "198.51.100.1" }, "192.0.2.1" },
+    { "trusted proxy", "10.0.0.1:1234", []string { "198.51.100.1" }, "198.51.100.1" },
+    { "trusted proxy chain", "10.0.0.1:1234", []string { "198.51.100.1, 10.0.0.2" }, "198.51.100.1" },
+    { "forged address", "10.0.0.1:1234", []string { "203.0.113.1, 198.51.100.1" }, "198.51.100.1" },
+    { "multiple headers", "10.0.0.1:1234", []string { "203.0.113.1", "198.51.100.1" }, "198.51.100.1" },
+    { "trusted proxy without header", "10.0.0.1:1234", nil, "10.0.0.1" },
+    { "all trusted", "10.0.0.1:1234", []string { "10.0.0.3, 10.0.0.2" }, "10.0.0.3" },
+    { "malformed address", "10.0.0.1:1234", []string { "foo, 10.0.0.2" }, "10.0.0.2" },
+    { "ipv6 trusted proxy", "[::1]:1234", []string { "2001:db8::1" }, "2001:db8::1" },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      req := httptest.NewRequest("GET", "/", nil)
+      req.RemoteAddr = test.remote
+      for _, v := range(test.fwd) {
+        req.Header.Add("X-Forwarded-For", v)
+      }
+
+      if got := clientAddr(req, trusted); got != test.exp {
+        t.Fatalf("got %q, exp %q", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestRateLimitKey(t *testing.T) {
+  t.Run("address", func(t *testing.T) {
+    req := httptest.NewRequest("GET", "/", nil)
+    req.RemoteAddr = "192.0.2.1:1234"
+
+    if got, exp := rateLimitKey(req, nil), "addr:192.0.2.1"; got != exp {
+      t.Fatalf("got %q, exp %q", got, exp)
+    }
+  })
+
+  t.Run("user", func(t *testing.T) {
+    ctx := context.WithValue(context.Background(), userCtxKey{}, &testUser)
+    req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+
+    if got, exp := rateLimitKey(req, nil), "user:1"; got != exp {
+      t.Fatalf("got %q, exp %q", got, exp)
+    }
+  })
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+  // build app context
+  appCtx := app.Context {}
+
+  // create middleware which allows 2 requests per minute, bursts of 2
+  mw := RateLimitMiddleware(ratelimit.New(2, 2))
+  next := http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {})
+
+  // send request from given address, return response
+  send := func(addr string) *httptest.ResponseRecorder {
+    ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+    req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+    req.RemoteAddr = addr
+    resp := httptest.NewRecorder()
+    mw(next).ServeHTTP(resp, req)
+    return resp
+  }
+
+  // burst
+  for i := 0; i < 2; i++ {
+    if resp := send("192.0.2.1:1234"); resp.Code != http.StatusOK {
+      t.Fatalf("request %d: got status %d, exp %d", i, resp.Code, http.StatusOK)
+    }
+  }
+
+  // rate limited (one request every 30 seconds)
+  resp := send("192.0.2.1:5678")
+  checkErrorResponse(t, resp, http.StatusTooManyRequests, "rate_limited")
+  if got := resp.Header().Get("Retry-After"); got != "30" {
+    t.Fatalf("got Retry-After %q, exp \"30\"", got)
+  }
+
+  // other clients are not limited
+  if resp := send("192.0.2.2:1234"); resp.Code != http.StatusOK {
+    t.Fatalf("got status %d, exp %d", resp.Code, http.StatusOK)
+  }
+
+  // nil limiter allows all requests
+  for i := 0; i < 10; i++ {
+    ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+    req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+    resp := httptest.NewRecorder()
+    RateLimitMiddleware(nil)(next).ServeHTTP(resp, req)
+    if resp.Code != http.StatusOK {
+      t.Fatalf("request %d: got status %d, exp %d", i, resp.Code, http.StatusOK)
+    }
+  }
+}
+
+func TestRouterRateLimits(t *testing.T) {
+  // build app context w/ one request per budget and mock model which
+  // returns a session user, create router
+  appCtx := app.Context {
+    Config: app.Config {
+      RateLimitSearch: 1,
+      RateLimitSearchBurst: 1,
+      RateLimitUpload: 1,
+      RateLimitUploadBurst: 1,
+      RateLimitEdit: 1,
+      RateLimitEditBurst: 1,
+    },
+    Model: &model.MockModel {
+      SessionUserResult: model.MockUserResult {
+        User: model.User { Id: 1, Name: "alice", Role: model.RoleAdmin },
+      },
+    },
+  }
+  router, err := NewRouter(&appCtx)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // send request w/ session cookie and CSRF token, return status
+  send := func(method, path string) int {
+    req := httptest.NewRequest(method, path, nil)
+    req.AddCookie(&http.Cookie { Name: sessionCookieName, Value: "token" })
+    addCsrfToken(req)
+    resp := httptest.NewRecorder()
+    router.ServeHTTP(resp, req)
+    return resp.Code
+  }
+
+  // the first request of each budget is allowed, and the second is
+  // rejected; budgets are separate
+  for _, reqs := range([][]string {
+    []string { "GET", "/api/search", "GET", "/opds/search" },
+    []string { "POST", "/api/upload", "POST", "/api/admin/restore" },
+    []string { "POST", "/api/edit", "POST", "/api/tags/add" },
+  }) {
+    if got := send(reqs[0], reqs[1]); got == http.StatusTooManyRequests {
+      t.Fatalf("%s %s: got status %d, exp allowed", reqs[0], reqs[1], got)
+    }
+    if got := send(reqs[2], reqs[3]); got != http.StatusTooManyRequests {
+      t.Fatalf("%s %s: got status %d, exp %d", reqs[2], reqs[3], got, http.StatusTooManyRequests)
+    }
+  }
+
+  // routes outside the budgets are not limited
+  if got := send("GET", "/api/tags"); got == http.StatusTooManyRequests {
+    t.Fatalf("got status %d, exp allowed", got)
+  }
+}
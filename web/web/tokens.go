@@ -0,0 +1,228 @@
+package web
+
+import (
+  "bookman/model"
+  "context"
+  "errors"
+  "net/http"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Default API token lifetime, in days.
+const defaultTokenDays = 90
+
+// API token context key
+type tokenCtxKey struct{}
+
+// Get API token which authenticated the request from context, or nil
+// if the request was not authenticated with an API token.
+func tokenFromContext(ctx context.Context) *model.ApiToken {
+  token, _ := ctx.Value(tokenCtxKey{}).(*model.ApiToken)
+  return token
+}
+
+// Get API token from Authorization header.  Returns false if the
+// request does not have a token.
+//
+// The token is either a bearer token or, for requests with safe
+// methods (e.g. GET), the password of HTTP Basic authentication (the
+// user name is ignored), because most OPDS clients only support Basic
+// authentication.  Browsers send cached Basic credentials
+// automatically, so they are not accepted for unsafe requests, which
+// are exempt from CSRF checks when authenticated with a token.
+func requestToken(r *http.Request) (string, bool) {
+  // check for basic auth
+  if _, password, ok := r.BasicAuth(); ok {
+    password = strings.TrimSpace(password)
+    if !isSafeMethod(r.Method) || password == "" {
+      return "", false
+    }
+    return password, true
+  }
+
+  // check for bearer token
+  scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+  if !ok || !strings.EqualFold(scheme, "Bearer") {
+    return "", false
+  }
+
+  token = strings.TrimSpace(token)
+  return token, token != ""
+}
+
+// HTTP middleware which gets the user from the API token in the
+// `Authorization` header (see requestToken()), and stores the user and
+// token in the request context.  The user name is also stored as the
+// actor for changes made by the request.
+//
+// The role of the user is limited by the token scope (see
+// TokenScope.Role()).  A token takes precedence over a session cookie.
+// Requests without a token are passed through, and requests with an
+// invalid or expired token are rejected with a 401 error.
+//
+// Must be used after SessionMiddleware.
+func TokenMiddleware(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    // get token
+    s, ok := requestToken(r)
+    if !ok {
+      next.ServeHTTP(w, r)
+      return
+    }
+
+    // get context from request and app context from context
+    ctx := r.Context()
+    appCtx := appContextFromContext(ctx)
+
+    // get token and user, limit role of user to token scope
+    token, user, err := appCtx.Model.TokenUser(ctx, appCtx.Pool, s)
+    if errors.Is(err, model.ErrTokenNotFound) {
+      writeError(w, unauthorized("invalid or expired API token"))
+      return
+    } else if err != nil {
+      writeError(w, err)
+      return
+    }
+    user.Role = token.Scope.Role(user.Role)
+
+    // add user, token, and actor to context
+    ctx = context.WithValue(ctx, userCtxKey{}, &user)
+    ctx = context.WithValue(ctx, tokenCtxKey{}, &token)
+    ctx = model.ContextWithActor(ctx, user.Name)
+
+    // call the next handler in the chain
+    next.ServeHTTP(w, r.WithContext(ctx))
+  })
+}
+
+// HTTP middleware which asks clients without a logged in user to use
+// HTTP Basic authentication by adding a `WWW-Authenticate` header to
+// the response.  Used for the OPDS catalog and book downloads, so that
+// OPDS clients prompt for a user name and API token.
+//
+// Must be used after TokenMiddleware and before RequireRoleMiddleware.
+func BasicAuthChallengeMiddleware(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    // add challenge for clients without a user
+    if userFromContext(r.Context()) == nil {
+      w.Header().Set("WWW-Authenticate", `Basic realm="Bookman", charset="UTF-8"`)
+    }
+
+    // call the next handler in the chain
+    next.ServeHTTP(w, r)
+  })
+}
+
+// HTTP middleware which rejects requests without a logged in user with
+// a 401 error, and requests authenticated with an API token with a 403
+// error.  Used for routes which manage API tokens, so that a token
+// cannot be used to create other tokens.
+//
+// Must be used after TokenMiddleware.
+func RequireSessionMiddleware(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    ctx := r.Context()
+    if userFromContext(ctx) == nil {
+      writeError(w, unauthorized("login required"))
+      return
+    } else if tokenFromContext(ctx) != nil {
+      writeError(w, forbidden("API tokens cannot manage API tokens"))
+      return
+    }
+
+    // call the next handler in the chain
+    next.ServeHTTP(w, r)
+  })
+}
+
+// Route handler which returns the API tokens of the logged in user,
+// newest first.
+//
+// Must be used after RequireSessionMiddleware.
+func doApiTokens(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // get tokens
+  tokens, err := appCtx.Model.Tokens(ctx, appCtx.Pool, userFromContext(ctx).Id)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // write JSON-encoded tokens
+  writeJson(w, tokens)
+}
+
+// Create API token route handler.
+//
+// Creates an API token for the logged in user with the `name`, `scope`
+// (default: `read`), and `days` (default: 90) form values.  Returns the
+// token, including the token itself, which is not available later.
+//
+// Must be used after RequireSessionMiddleware.
+func doApiCreateToken(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // get scope
+  scope := model.TokenScope(r.FormValue("scope"))
+  if scope == "" {
+    scope = model.ScopeRead
+  }
+
+  // get lifetime
+  days := defaultTokenDays
+  if s := r.FormValue("days"); s != "" {
+    var err error
+    maxDays := int(model.MaxTokenTtl / (24 * time.Hour))
+    if days, err = strconv.Atoi(s); err != nil || days < 1 || days > maxDays {
+      writeError(w, badRequest("days must be between 1 and " + strconv.Itoa(maxDays)))
+      return
+    }
+  }
+  ttl := time.Duration(days) * 24 * time.Hour
+
+  // create token
+  userId := userFromContext(ctx).Id
+  token, err := appCtx.Model.CreateToken(ctx, appCtx.Pool, userId, r.FormValue("name"), scope, ttl)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // write JSON-encoded token
+  writeJson(w, token)
+}
+
+// Revoke API token route handler.
+//
+// Revokes the API token of the logged in user with the `id` form
+// value.
+//
+// Must be used after RequireSessionMiddleware.
+func doApiRevokeToken(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // parse token ID
+  id, err := parseTokenId(r.FormValue("id"))
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // revoke token
+  if err := appCtx.Model.RevokeToken(ctx, appCtx.Pool, userFromContext(ctx).Id, id); err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // send response
+  writeJson(w, nil)
+}
@@ -0,0 +1,447 @@
+package web
+
+import (
+  "bookman/app"
+  "bookman/model"
+  "context"
+  "errors"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+)
+
+// test API token
+var testToken = model.ApiToken {
+  Id: 2,
+  Name: "upload script",
+  Scope: model.ScopeWrite,
+  CreatedAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+  ExpiresAt: time.Date(2020, 4, 1, 3, 4, 5, 0, time.UTC),
+}
+
+func TestRequestToken(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    method string // request method
+    val string // Authorization header
+    exp string // expected token
+    ok bool // expect token?
+  } {
+    { "bearer", "GET", "Bearer abc", "abc", true },
+    { "bearer post", "POST", "Bearer abc", "abc", true },
+    { "lowercase scheme", "GET", "bearer abc", "abc", true },
+    { "none", "GET", "", "", false },
+    { "basic", "GET", "Basic YWxpY2U6c2VjcmV0", "secret", true },
+    { "basic head", "HEAD", "Basic YWxpY2U6c2VjcmV0", "secret", true },
+    { "basic post", "POST", "Basic YWxpY2U6c2VjcmV0", "", false },
+    { "basic empty password", "GET", "Basic YWxpY2U6", "", false },
+    { "empty token", "GET", "Bearer ", "", false },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      req := httptest.NewRequest(test.method, "/", nil)
+      if test.val != "" {
+        req.Header.Set("Authorization", test.val)
+      }
+
+      got, ok := requestToken(req)
+      if got != test.exp || ok != test.ok {
+        t.Fatalf("got (%q, %v), exp (%q, %v)", got, ok, test.exp, test.ok)
+      }
+    })
+  }
+}
+
+func TestTokenMiddleware(t *testing.T) {
+  // admin user
+  admin := model.User { Id: 1, Name: "alice", Role: model.RoleAdmin }
+
+  // read-only token
+  readToken := testToken
+  readToken.Scope = model.ScopeRead
+
+  tests := []struct {
+    name string // test name
+    header string // Authorization header
+    result model.MockTokenUserResult // TokenUser() result
+    status int // expected status code
+    code string // expected error code
+    role model.Role // expected role (empty for no user)
+  } {{
+    name: "no token",
+    status: http.StatusOK,
+  }, {
+    name: "write token",
+    header: "Bearer token",
+    result: model.MockTokenUserResult { Token: testToken, User: admin },
+    status: http.StatusOK,
+    role: model.RoleAdmin,
+  }, {
+    name: "read token",
+    header: "Bearer token",
+    result: model.MockTokenUserResult { Token: readToken, User: admin },
+    status: http.StatusOK,
+    role: model.RoleViewer,
+  }, {
+    name: "basic auth token",
+    header: "Basic YWxpY2U6dG9rZW4=",
+    result: model.MockTokenUserResult { Token: readToken, User: admin },
+    status: http.StatusOK,
+    role: model.RoleViewer,
+  }, {
+    name: "invalid token",
+    header: "Bearer token",
+    result: model.MockTokenUserResult { Err: model.ErrTokenNotFound },
+    status: http.StatusUnauthorized,
+    code: "unauthorized",
+  }, {
+    name: "error",
+    header: "Bearer token",
+    result: model.MockTokenUserResult { Err: errors.New("some error") },
+    status: http.StatusInternalServerError,
+    code: "internal_error",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Model: &model.MockModel { TokenUserResult: test.result },
+      }
+
+      // create handler which checks the user, token, and actor
+      check := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+        user, token := userFromContext(r.Context()), tokenFromContext(r.Context())
+        if test.role == "" {
+          if user != nil || token != nil {
+            t.Fatalf("got (%v, %v), exp no user", user, token)
+          }
+          return
+        }
+
+        if user == nil || user.Role != test.role {
+          t.Fatalf("got user %v, exp role %s", user, test.role)
+        } else if token == nil || token.Id != testToken.Id {
+          t.Fatalf("got token %v, exp %v", token, testToken)
+        } else if got := model.ActorFromContext(r.Context()); got != "alice" {
+          t.Fatalf("got actor %q, exp \"alice\"", got)
+        }
+      })
+
+      // create request w/ app context and authorization header
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+      if test.header != "" {
+        req.Header.Set("Authorization", test.header)
+      }
+      resp := httptest.NewRecorder()
+
+      // send request
+      TokenMiddleware(check).ServeHTTP(resp, req)
+
+      // check status
+      if test.status != http.StatusOK {
+        checkErrorResponse(t, resp, test.status, test.code)
+      } else if resp.Code != http.StatusOK {
+        t.Fatalf("got status %d, exp %d", resp.Code, http.StatusOK)
+      }
+    })
+  }
+}
+
+func TestRequireSessionMiddleware(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    user *model.User // logged in user
+    token *model.ApiToken // API token
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "no user",
+    status: http.StatusUnauthorized,
+    code: "unauthorized",
+  }, {
+    name: "session",
+    user: &testUser,
+    status: http.StatusOK,
+  }, {
+    name: "token",
+    user: &testUser,
+    token: &testToken,
+    status: http.StatusForbidden,
+    code: "forbidden",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // handler which records whether it was called
+      called := false
+      next := http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+        called = true
+      })
+
+      // create request w/ user and token
+      ctx := context.Background()
+      if test.user != nil {
+        ctx = context.WithValue(ctx, userCtxKey{}, test.user)
+      }
+      if test.token != nil {
+        ctx = context.WithValue(ctx, tokenCtxKey{}, test.token)
+      }
+      req := httptest.NewRequest("POST", "/", nil).WithContext(ctx)
+      resp := httptest.NewRecorder()
+
+      // send request
+      RequireSessionMiddleware(next).ServeHTTP(resp, req)
+
+      if test.status != http.StatusOK {
+        checkErrorResponse(t, resp, test.status, test.code)
+        if called {
+          t.Fatal("got called, exp not called")
+        }
+      } else if !called {
+        t.Fatal("got not called, exp called")
+      }
+    })
+  }
+}
+
+func TestBasicAuthChallengeMiddleware(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    user *model.User // logged in user
+    exp string // expected WWW-Authenticate header
+  } {
+    { "no user", nil, `Basic realm="Bookman", charset="UTF-8"` },
+    { "user", &testUser, "" },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // handler which records whether it was called
+      called := false
+      next := http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+        called = true
+      })
+
+      // create request w/ user
+      ctx := context.Background()
+      if test.user != nil {
+        ctx = context.WithValue(ctx, userCtxKey{}, test.user)
+      }
+      req := httptest.NewRequest("GET", "/opds", nil).WithContext(ctx)
+      resp := httptest.NewRecorder()
+
+      // send request
+      BasicAuthChallengeMiddleware(next).ServeHTTP(resp, req)
+
+      if !called {
+        t.Fatal("got not called, exp called")
+      } else if got := resp.Header().Get("WWW-Authenticate"); got != test.exp {
+        t.Fatalf("got %q, exp %q", got, test.exp)
+      }
+    })
+  }
+}
+
+// Send request with the given method, form body, mock model, and
+// logged in test user to the given handler, return response.
+func callTokenHandler(t *testing.T, m *model.MockModel, fn http.HandlerFunc, method, form string) *httptest.ResponseRecorder {
+  t.Helper()
+
+  // build app context w/ mock model
+  appCtx := app.Context { Model: m }
+
+  // create context w/ app context and logged in user, create request
+  ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+  ctx = context.WithValue(ctx, userCtxKey{}, &testUser)
+  req, err := http.NewRequestWithContext(ctx, method, "/", strings.NewReader(form))
+  if err != nil {
+    t.Fatal(err)
+  }
+  req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+  resp := httptest.NewRecorder()
+
+  // call handler
+  fn(resp, req)
+  return resp
+}
+
+func TestDoApiTokens(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    m := model.MockModel {
+      TokensResult: model.MockTokensResult { Tokens: []model.ApiToken { testToken } },
+    }
+    resp := callTokenHandler(t, &m, doApiTokens, "GET", "")
+
+    // check response body
+    exp := `[{"id":2,"name":"upload script","scope":"write","created_at":"2020-01-02T03:04:05Z","expires_at":"2020-04-01T03:04:05Z","last_used_at":null}]`
+    if got := strings.TrimSpace(resp.Body.String()); got != exp {
+      t.Fatalf("got \"%s\", exp \"%s\"", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    m := model.MockModel {
+      TokensResult: model.MockTokensResult { Err: errors.New("some error") },
+    }
+    resp := callTokenHandler(t, &m, doApiTokens, "GET", "")
+    checkErrorResponse(t, resp, http.StatusInternalServerError, "internal_error")
+  })
+}
+
+func TestDoApiCreateToken(t *testing.T) {
+  // created token
+  created := testToken
+  created.Token = "bookman_abc"
+
+  tests := []struct {
+    name string // test name
+    form string // request body
+    result model.MockTokenResult // CreateToken() result
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "pass",
+    form: "name=upload+script&scope=write&days=90",
+    result: model.MockTokenResult { Token: created },
+    status: http.StatusOK,
+  }, {
+    name: "defaults",
+    form: "name=upload+script",
+    result: model.MockTokenResult { Token: created },
+    status: http.StatusOK,
+  }, {
+    name: "invalid days",
+    form: "name=upload+script&days=foo",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "too many days",
+    form: "name=upload+script&days=366",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "invalid scope",
+    form: "name=upload+script&scope=admin",
+    result: model.MockTokenResult {
+      Err: &model.ValidationError { Field: "scope", Message: "invalid scope" },
+    },
+    status: http.StatusBadRequest,
+    code: "invalid",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      m := model.MockModel { CreateTokenResult: test.result }
+      resp := callTokenHandler(t, &m, doApiCreateToken, "POST", test.form)
+
+      if test.status != http.StatusOK {
+        checkErrorResponse(t, resp, test.status, test.code)
+        return
+      }
+
+      // check response body
+      exp := `{"id":2,"name":"upload script","scope":"write","created_at":"2020-01-02T03:04:05Z","expires_at":"2020-04-01T03:04:05Z","last_used_at":null,"token":"bookman_abc"}`
+      if got := strings.TrimSpace(resp.Body.String()); got != exp {
+        t.Fatalf("got \"%s\", exp \"%s\"", got, exp)
+      }
+    })
+  }
+}
+
+func TestDoApiRevokeToken(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    form string // request body
+    result error // RevokeToken() result
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "pass",
+    form: "id=2",
+    status: http.StatusOK,
+  }, {
+    name: "bad id",
+    form: "id=foo",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "not found",
+    form: "id=3",
+    result: model.ErrTokenNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      m := model.MockModel { RevokeTokenResult: test.result }
+      resp := callTokenHandler(t, &m, doApiRevokeToken, "POST", test.form)
+
+      if test.status != http.StatusOK {
+        checkErrorResponse(t, resp, test.status, test.code)
+      } else if resp.Code != http.StatusOK {
+        t.Fatalf("got status %d, exp %d", resp.Code, http.StatusOK)
+      }
+    })
+  }
+}
+
+func TestRouterTokens(t *testing.T) {
+  // editor user
+  editor := model.User { Id: 1, Name: "alice", Role: model.RoleEditor }
+
+  // read-only token
+  readToken := testToken
+  readToken.Scope = model.ScopeRead
+
+  tests := []struct {
+    name string // test name
+    token model.ApiToken // API token
+    method string // request method
+    path string // request path
+    forbidden bool // expect 403 error?
+  } {
+    { "read token search", readToken, "GET", "/api/search", false },
+    { "read token upload", readToken, "POST", "/api/upload", true },
+    { "write token upload", testToken, "POST", "/api/upload", false },
+    { "write token delete", testToken, "POST", "/api/delete", true },
+    { "write token list tokens", testToken, "GET", "/api/tokens", true },
+    { "write token create token", testToken, "POST", "/api/tokens/create", true },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model which returns the token and
+      // user, create router
+      appCtx := app.Context {
+        Model: &model.MockModel {
+          TokenUserResult: model.MockTokenUserResult { Token: test.token, User: editor },
+        },
+      }
+      router, err := NewRouter(&appCtx)
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      // create request w/ bearer token
+      req := httptest.NewRequest(test.method, test.path, nil)
+      req.Header.Set("Authorization", "Bearer bookman_abc")
+      resp := httptest.NewRecorder()
+
+      // send request
+      router.ServeHTTP(resp, req)
+
+      // check for 401 and 403 errors
+      if resp.Code == http.StatusUnauthorized {
+        t.Fatalf("got status %d, exp authenticated", resp.Code)
+      } else if forbidden := resp.Code == http.StatusForbidden; forbidden != test.forbidden {
+        t.Fatalf("got forbidden %v, exp %v (status %d)", forbidden, test.forbidden, resp.Code)
+      }
+    })
+  }
+}
@@ -0,0 +1,445 @@
+package web
+
+import (
+  "bookman/ingest"
+  "bookman/model"
+  "errors"
+  "fmt"
+  "io"
+  "log"
+  "mime/multipart"
+  "net/http"
+  "os"
+  "strconv"
+)
+
+// Parse upload options from query string.
+//
+// (note: options are read from the query string rather than the form
+// because the form body is read as a stream of uploaded files)
+func parseUploadOptions(r *http.Request) (model.UploadOptions, error) {
+  query := r.URL.Query()
+
+  // get conflict and duplicate policies
+  opts := model.UploadOptions {
+    Conflict: model.ConflictPolicy(query.Get("conflict")),
+    Duplicates: model.DuplicatePolicy(query.Get("duplicates")),
+  }
+
+  // get ID of book to replace
+  if s := query.Get("id"); s != "" {
+    id, err := parseBookId(s)
+    if err != nil {
+      return model.UploadOptions{}, err
+    }
+    opts.ReplaceId = id
+  }
+
+  // get partial success flag
+  if s := query.Get("partial"); s != "" {
+    partial, err := strconv.ParseBool(s)
+    if err != nil {
+      return model.UploadOptions{}, badRequest("invalid partial")
+    }
+    opts.Partial = partial
+  }
+
+  // return success
+  return opts, nil
+}
+
+// Create error for an upload which is too large.
+func tooLarge(message string) error {
+  return &apiError { http.StatusRequestEntityTooLarge, "too_large", message }
+}
+
+// Outcome of one uploaded file or archive entry.
+type uploadReportFile struct {
+  FileName string `json:"file_name"` // name of uploaded file
+  Entry string `json:"entry,omitempty"` // path of entry in uploaded archive, if any
+  Size int64 `json:"size"` // size of uploaded file (or decompressed archive entry) in bytes, or 0 if the file is too large
+  Encoding string `json:"encoding"` // detected character encoding, or empty if the file could not be read
+  Id int `json:"id"` // ID of added, replaced, or skipped existing book, or 0 if failed
+  Name string `json:"name"` // book name, or empty if the file could not be read
+  Status model.UploadStatus `json:"status"` // outcome
+  Error *errorBody `json:"error,omitempty"` // error, if failed
+}
+
+// Set status of uploaded file to failed with the given error.
+func (f *uploadReportFile) fail(err error) {
+  _, code, message := errorStatus(err)
+  f.Status = model.UploadFailed
+  f.Error = &errorBody { Code: code, Message: message }
+}
+
+// Upload response body.
+type uploadReport struct {
+  Files []uploadReportFile `json:"files"` // outcome of each file, in upload order
+}
+
+// Convert archive or EPUB error to API error.
+func archiveError(err error) error {
+  if errors.Is(err, ingest.ErrArchiveTooLarge) {
+    return tooLarge(err.Error())
+  }
+
+  return badRequest(err.Error())
+}
+
+// Uploaded file which was read into a temporary file.
+type spooledPart struct {
+  fileName string // name of uploaded file
+  file *os.File // temporary file, or nil if the file is too large
+}
+
+// Upload source which reads and converts the files in a multipart form
+// one at a time, and adds each file to an upload report.
+//
+// The files are first copied to temporary files with spool(), so that
+// a slow client cannot hold the upload transaction open.  The
+// temporary files are removed with Close().
+//
+// Uploaded zip and tar.gz archives are expanded one entry at a time,
+// and each entry is added to the report separately.
+//
+// Files which are larger than the maximum file size, or which cannot
+// be converted to a book, fail the upload.  If partial is true, then
+// they are marked as failed in the report and skipped instead.
+type multipartSource struct {
+  parts []spooledPart // remaining spooled files
+  opts ingest.Options // upload pipeline options
+  maxFileSize int // maximum file size, in bytes
+  limits ingest.ArchiveLimits // archive limits
+  partial bool // skip files which fail instead of failing the upload?
+
+  archive ingest.Archive // archive being read, or nil
+  archiveName string // file name of archive being read
+
+  report uploadReport // upload report
+  indexes []int // report index of each book returned by Next()
+}
+
+// Convert multipart form read error to API error.
+func (s *multipartSource) readError(err error) error {
+  var maxBytesErr *http.MaxBytesError
+  if errors.As(err, &maxBytesErr) {
+    return tooLarge(fmt.Sprintf("upload is larger than the maximum upload size (%d bytes)", maxBytesErr.Limit))
+  }
+
+  return badRequest("invalid multipart form")
+}
+
+// Copy the files in a multipart form to temporary files.
+//
+// Errors from reading the multipart form are converted to API errors.
+// Files which are larger than the maximum file size are not kept.
+func (s *multipartSource) spool(mpr *multipart.Reader) error {
+  for {
+    // get next part
+    part, err := mpr.NextPart()
+    if err == io.EOF {
+      return nil
+    } else if err != nil {
+      return s.readError(err)
+    }
+
+    // create temporary file, add it to list before writing so that it
+    // is removed by Close() on error
+    tmp, err := os.CreateTemp("", "bookman-upload-*")
+    if err != nil {
+      return err
+    }
+    s.parts = append(s.parts, spooledPart { fileName: part.FileName(), file: tmp })
+
+    // copy part data, up to one byte past the size limit
+    n, err := io.Copy(tmp, io.LimitReader(part, int64(s.maxFileSize) + 1))
+    var pathErr *os.PathError
+    if errors.As(err, &pathErr) {
+      // temporary file write error
+      return err
+    } else if err != nil {
+      return s.readError(err)
+    }
+
+    // drop files which are too large
+    // (note: the rest of the part is skipped by NextPart())
+    if n > int64(s.maxFileSize) {
+      s.parts[len(s.parts) - 1].file = nil
+      if err := removeTemp(tmp); err != nil {
+        return err
+      }
+    }
+  }
+}
+
+// Close and remove temporary file.
+func removeTemp(f *os.File) error {
+  closeErr := f.Close()
+  if err := os.Remove(f.Name()); err != nil {
+    return err
+  }
+  return closeErr
+}
+
+// Remove remaining temporary files.
+func (s *multipartSource) Close() error {
+  var r error
+  for _, p := range(s.parts) {
+    if p.file != nil {
+      if err := removeTemp(p.file); err != nil && r == nil {
+        r = err
+      }
+    }
+  }
+  s.parts = nil
+  return r
+}
+
+// Read spooled file, then remove its temporary file.
+//
+// If the file is too large, then the error is returned in fileErr
+// instead of err.
+func (s *multipartSource) readPart(p spooledPart) (data []byte, fileErr, err error) {
+  if p.file == nil {
+    return nil, tooLarge(fmt.Sprintf("%s: file is larger than the maximum file size (%d bytes)", p.fileName, s.maxFileSize)), nil
+  }
+  defer removeTemp(p.file)
+
+  // read file
+  if _, err := p.file.Seek(0, io.SeekStart); err != nil {
+    return nil, nil, err
+  }
+  if data, err = io.ReadAll(p.file); err != nil {
+    return nil, nil, err
+  }
+
+  // return success
+  return data, nil, nil
+}
+
+// Add failed file to report.
+//
+// Returns the error instead if partial uploads are disabled.
+func (s *multipartSource) fail(f uploadReportFile, err error) error {
+  if !s.partial {
+    return err
+  }
+
+  f.fail(err)
+  s.report.Files = append(s.report.Files, f)
+  return nil
+}
+
+// Add file to report and return book.
+func (s *multipartSource) add(f uploadReportFile, file model.UploadedFile) model.UploadedFile {
+  f.Name = file.Name
+  f.Encoding = file.Encoding
+  s.indexes = append(s.indexes, len(s.report.Files))
+  s.report.Files = append(s.report.Files, f)
+  return file
+}
+
+// Get next book from archive.  Returns io.EOF at the end of the
+// archive.
+func (s *multipartSource) nextEntry() (model.UploadedFile, error) {
+  for {
+    entry, err := s.archive.Next()
+    if err == io.EOF {
+      return model.UploadedFile{}, io.EOF
+    } else if err != nil {
+      // archive errors end the archive
+      if err := s.fail(uploadReportFile { FileName: s.archiveName }, archiveError(err)); err != nil {
+        return model.UploadedFile{}, err
+      }
+      return model.UploadedFile{}, io.EOF
+    }
+
+    // build report entry
+    f := uploadReportFile {
+      FileName: s.archiveName,
+      Entry: entry.Path,
+      Size: entry.Size,
+    }
+
+    // skip failed entry
+    if entry.Err != nil {
+      f.Name = entry.File.Name
+      f.Encoding = entry.File.Encoding
+      if err := s.fail(f, archiveError(entry.Err)); err != nil {
+        return model.UploadedFile{}, err
+      }
+      continue
+    }
+
+    // add entry to report, return book
+    return s.add(f, entry.File), nil
+  }
+}
+
+// Get next book.  Returns io.EOF if there are no more files.
+func (s *multipartSource) Next() (model.UploadedFile, error) {
+  for {
+    // read next archive entry
+    if s.archive != nil {
+      file, err := s.nextEntry()
+      if err == io.EOF {
+        s.archive = nil
+        continue
+      }
+      return file, err
+    }
+
+    // get next spooled file
+    if len(s.parts) == 0 {
+      return model.UploadedFile{}, io.EOF
+    }
+    part := s.parts[0]
+    s.parts = s.parts[1:]
+
+    // read file
+    data, fileErr, err := s.readPart(part)
+    if err != nil {
+      return model.UploadedFile{}, err
+    }
+
+    // build report entry
+    f := uploadReportFile {
+      FileName: part.fileName,
+      Size: int64(len(data)),
+    }
+
+    // open archive
+    if fileErr == nil && ingest.IsArchive(data) {
+      archive, err := ingest.OpenArchive(data, s.opts, s.limits)
+      if err == nil {
+        s.archive = archive
+        s.archiveName = part.fileName
+        continue
+      }
+      fileErr = archiveError(err)
+    }
+
+    // convert file to book
+    var file model.UploadedFile
+    if fileErr == nil {
+      if file, err = ingest.File(part.fileName, data, s.opts, s.limits); err != nil {
+        fileErr = archiveError(err)
+      }
+    }
+
+    // skip failed file
+    if fileErr != nil {
+      if err := s.fail(f, fileErr); err != nil {
+        return model.UploadedFile{}, err
+      }
+      continue
+    }
+
+    // add file to report, return book
+    return s.add(f, file), nil
+  }
+}
+
+// Route handler for file uploads.
+//
+// Accepts the following query string parameters:
+//
+// * `conflict`: action taken when an uploaded book has the same name
+//   as an existing book: `fail` (default), `skip`, `replace` (replace
+//   body of existing book), or `rename` (add book with a numbered
+//   name).
+// * `id`: ID of book whose body is replaced by the uploaded file.  The
+//   metadata of the book is kept.  Exactly one file must be uploaded.
+// * `partial`: if true, then files which fail are reported in the
+//   response and the remaining files are still uploaded.  By default,
+//   any failure fails the whole upload.
+//
+// The uploaded files are copied to temporary files before the upload
+// transaction begins, so that a slow client cannot hold the
+// transaction open.  Files are then read, converted, and inserted one
+// at a time, so only one file is held in memory at a time.  Uploads
+// larger than the maximum request size, and files larger than the
+// maximum file size, are rejected with a 413 error.
+//
+// Uploaded zip and tar.gz archives are expanded, and each `.txt` or
+// `.epub` entry is added as a separate book.  Archives which exceed
+// the archive entry count or decompressed size limits are rejected
+// with a 413 error.
+//
+// The response is a JSON report containing the file name, size,
+// detected encoding, book ID, and outcome of each uploaded file.
+func doApiUpload(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // parse upload options
+  uploadOpts, err := parseUploadOptions(r)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // limit request size
+  // (note: a larger content length is rejected before reading)
+  maxRequestSize := int64(appCtx.Config.UploadMaxRequestSize)
+  if r.ContentLength > maxRequestSize {
+    writeError(w, tooLarge(fmt.Sprintf("upload is larger than the maximum upload size (%d bytes)", maxRequestSize)))
+    return
+  }
+  r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+
+  // get multipart reader from request
+  mpr, err := r.MultipartReader()
+  if err != nil {
+    writeError(w, badRequest("expected multipart form"))
+    return
+  }
+
+  // create upload source
+  src := multipartSource {
+    opts: ingest.Options {
+      StripBoilerplate: appCtx.Config.UploadStripBoilerplate,
+    },
+    maxFileSize: appCtx.Config.UploadMaxFileSize,
+    limits: ingest.ArchiveLimits {
+      MaxEntries: appCtx.Config.UploadMaxArchiveEntries,
+      MaxFileSize: int64(appCtx.Config.UploadMaxFileSize),
+      MaxSize: int64(appCtx.Config.UploadMaxArchiveSize),
+    },
+    partial: uploadOpts.Partial,
+    report: uploadReport { Files: []uploadReportFile{} },
+  }
+  defer func() {
+    if err := src.Close(); err != nil {
+      log.Print(err)
+    }
+  }()
+
+  // copy uploaded files to temporary files
+  if err := src.spool(mpr); err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // upload files
+  results, err := appCtx.Model.Upload(ctx, appCtx.Pool, &src, uploadOpts)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // add upload results to report
+  for i, result := range(results) {
+    f := &src.report.Files[src.indexes[i]]
+    f.Id = result.Id
+    f.Name = result.Name
+    f.Status = result.Status
+    if result.Err != nil {
+      f.fail(result.Err)
+    }
+  }
+
+  // write JSON-encoded report
+  writeJson(w, src.report)
+}
@@ -0,0 +1,112 @@
+package web
+
+import (
+  "bookman/model"
+  "net/http"
+  "strings"
+)
+
+// Route handler which returns all users, sorted by name.
+func doApiUsers(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // get users
+  users, err := appCtx.Model.Users(ctx, appCtx.Pool)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // write JSON-encoded users
+  writeJson(w, users)
+}
+
+// Add user route handler.
+//
+// Adds a user with the `name`, `password`, and `role` form values.  The
+// role defaults to `viewer` if unspecified.  Returns the added user.
+func doApiAddUser(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // get role
+  role := model.Role(r.FormValue("role"))
+  if role == "" {
+    role = model.RoleViewer
+  }
+
+  // add user
+  name, password := r.FormValue("name"), r.FormValue("password")
+  user, err := appCtx.Model.AddUser(ctx, appCtx.Pool, name, role, password)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // write JSON-encoded user
+  writeJson(w, user)
+}
+
+// Is the given user name the name of the logged in user?  User names
+// are case-insensitive.
+func isCurrentUser(r *http.Request, name string) bool {
+  user := userFromContext(r.Context())
+  return user != nil && strings.EqualFold(user.Name, name)
+}
+
+// Set user role route handler.
+//
+// Sets the role of the user named by the `name` form value to the
+// `role` form value.  Admins cannot change their own role, so that the
+// last admin cannot lock themselves out.
+func doApiSetRole(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // check user
+  name := r.FormValue("name")
+  if isCurrentUser(r, name) {
+    writeError(w, badRequest("cannot change own role"))
+    return
+  }
+
+  // set role
+  role := model.Role(r.FormValue("role"))
+  if err := appCtx.Model.SetRole(ctx, appCtx.Pool, name, role); err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // send response
+  writeJson(w, nil)
+}
+
+// Delete user route handler.
+//
+// Removes the user named by the `name` form value and their sessions.
+// Admins cannot remove themselves.
+func doApiDeleteUser(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // check user
+  name := r.FormValue("name")
+  if isCurrentUser(r, name) {
+    writeError(w, badRequest("cannot delete own user"))
+    return
+  }
+
+  // remove user
+  if err := appCtx.Model.DeleteUser(ctx, appCtx.Pool, name); err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // send response
+  writeJson(w, nil)
+}
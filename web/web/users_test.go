@@ -0,0 +1,200 @@
+package web
+
+import (
+  "bookman/app"
+  "bookman/model"
+  "context"
+  "errors"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+)
+
+// Send form POST request with the given mock model and logged in user
+// to the given handler, return response.
+func postUserForm(t *testing.T, m *model.MockModel, fn http.HandlerFunc, form string) *httptest.ResponseRecorder {
+  t.Helper()
+
+  // build app context w/ mock model
+  appCtx := app.Context { Model: m }
+
+  // create context w/ app context and logged in admin, create request
+  ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+  ctx = context.WithValue(ctx, userCtxKey{}, &model.User { Id: 1, Name: "alice", Role: model.RoleAdmin })
+  req, err := http.NewRequestWithContext(ctx, "POST", "/", strings.NewReader(form))
+  if err != nil {
+    t.Fatal(err)
+  }
+  req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+  resp := httptest.NewRecorder()
+
+  // call handler
+  fn(resp, req)
+  return resp
+}
+
+func TestDoApiUsers(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    // build app context w/ mock model
+    appCtx := app.Context {
+      Model: &model.MockModel {
+        UsersResult: model.MockUsersResult {
+          Users: []model.User { testUser },
+        },
+      },
+    }
+
+    // create request and response recorder
+    ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+    req := httptest.NewRequest("GET", "/api/admin/users", nil).WithContext(ctx)
+    resp := httptest.NewRecorder()
+
+    // call handler
+    doApiUsers(resp, req)
+
+    // check response body
+    exp := `[{"id":1,"name":"alice","role":"editor","created_at":"2020-01-02T03:04:05Z"}]`
+    if got := strings.TrimSpace(resp.Body.String()); got != exp {
+      t.Fatalf("got \"%s\", exp \"%s\"", got, exp)
+    }
+  })
+
+  t.Run("fail", func(t *testing.T) {
+    // build app context w/ mock model
+    appCtx := app.Context {
+      Model: &model.MockModel {
+        UsersResult: model.MockUsersResult { Err: errors.New("some error") },
+      },
+    }
+
+    // create request and response recorder
+    ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+    req := httptest.NewRequest("GET", "/api/admin/users", nil).WithContext(ctx)
+    resp := httptest.NewRecorder()
+
+    // call handler
+    doApiUsers(resp, req)
+
+    checkErrorResponse(t, resp, http.StatusInternalServerError, "internal_error")
+  })
+}
+
+func TestDoApiAddUser(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    result model.MockUserResult // AddUser() result
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "pass",
+    result: model.MockUserResult { User: testUser },
+    status: http.StatusOK,
+  }, {
+    name: "duplicate",
+    result: model.MockUserResult { Err: model.ErrDuplicateUser },
+    status: http.StatusConflict,
+    code: "duplicate",
+  }, {
+    name: "invalid",
+    result: model.MockUserResult {
+      Err: &model.ValidationError { Field: "role", Message: "invalid role" },
+    },
+    status: http.StatusBadRequest,
+    code: "invalid",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      m := model.MockModel { AddUserResult: test.result }
+      resp := postUserForm(t, &m, doApiAddUser, "name=bob&password=secret123&role=editor")
+
+      if test.status != http.StatusOK {
+        checkErrorResponse(t, resp, test.status, test.code)
+        return
+      }
+
+      // check response body
+      exp := `{"id":1,"name":"alice","role":"editor","created_at":"2020-01-02T03:04:05Z"}`
+      if got := strings.TrimSpace(resp.Body.String()); got != exp {
+        t.Fatalf("got \"%s\", exp \"%s\"", got, exp)
+      }
+    })
+  }
+}
+
+func TestDoApiSetRole(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    form string // request body
+    result error // SetRole() result
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "pass",
+    form: "name=bob&role=editor",
+    status: http.StatusOK,
+  }, {
+    name: "own role",
+    form: "name=ALICE&role=viewer",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "not found",
+    form: "name=bob&role=editor",
+    result: model.ErrUserNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      m := model.MockModel { SetRoleResult: test.result }
+      resp := postUserForm(t, &m, doApiSetRole, test.form)
+
+      if test.status != http.StatusOK {
+        checkErrorResponse(t, resp, test.status, test.code)
+      } else if resp.Code != http.StatusOK {
+        t.Fatalf("got status %d, exp %d", resp.Code, http.StatusOK)
+      }
+    })
+  }
+}
+
+func TestDoApiDeleteUser(t *testing.T) {
+  tests := []struct {
+    name string // test name
+    form string // request body
+    result error // DeleteUser() result
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "pass",
+    form: "name=bob",
+    status: http.StatusOK,
+  }, {
+    name: "own user",
+    form: "name=alice",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "not found",
+    form: "name=bob",
+    result: model.ErrUserNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      m := model.MockModel { DeleteUserResult: test.result }
+      resp := postUserForm(t, &m, doApiDeleteUser, test.form)
+
+      if test.status != http.StatusOK {
+        checkErrorResponse(t, resp, test.status, test.code)
+      } else if resp.Code != http.StatusOK {
+        t.Fatalf("got status %d, exp %d", resp.Code, http.StatusOK)
+      }
+    })
+  }
+}
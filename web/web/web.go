@@ -3,44 +3,77 @@ package web
 
 import (
   "bookman/app"
+  "bookman/diff"
+  "bookman/export"
   "bookman/model"
+  "bytes"
+  "context"
   "embed"
-  "encoding/json"
+  "fmt"
   "github.com/go-chi/chi/v5"
   "github.com/go-chi/chi/v5/middleware"
-  "io"
+  "github.com/jackc/pgx/v5/pgxpool"
   io_fs "io/fs"
+  "log"
   "net/http"
   "strconv"
-  "strings"
 )
 
-// Get a list of books.
+// Get a page of books.
 //
-// If the `q` request parameter is not empty, then the returned list of
-// books is a list of books which match the query string, sorted in
-// descending order by relevance.
+// Accepts the following request parameters:
 //
-// If the `q` request parameter is empty, then the returned list of
-// books is a complete list of books, sorted by name.
+// * `q`: search query string.  If empty, then all books are matched.
+// * `sort`: sort order (`name`, `author`, `rank`, or `created`).
+//   Defaults to `rank` if `q` is not empty, or `name` otherwise.  The
+//   `author` order sorts by the sort name of the first author of each
+//   book (e.g. "Twain, Mark").
+// * `limit`: maximum number of books per page.
+// * `cursor`: value of `next` from the previous page of results.
+//
+// If `q` is not empty, then each book includes a snippet of the text
+// around the matched terms.
+//
+// The response is a JSON object containing the page of books, the
+// cursor for the next page (empty if there are no more results), and
+// the total number of matching books.
 func doApiSearch(w http.ResponseWriter, r *http.Request) {
   // get context from request and app context from context
   ctx := r.Context()
   appCtx := appContextFromContext(ctx)
 
-  // set response header
-  w.Header().Add("Content-Type", "text/json")
+  // parse limit
+  var limit int
+  if s := r.FormValue("limit"); s != "" {
+    val, err := strconv.Atoi(s)
+    if err != nil || val < 0 {
+      writeError(w, badRequest("invalid limit"))
+      return
+    }
+    limit = val
+  }
+
+  // build search parameters
+  params := model.SearchParams {
+    Query: r.FormValue("q"),
+    Sort: model.SearchSort(r.FormValue("sort")),
+    Limit: limit,
+    Cursor: r.FormValue("cursor"),
+    Snippets: model.SnippetOptions {
+      MaxFragments: appCtx.Config.SnippetFragments,
+      MaxWords: appCtx.Config.SnippetWords,
+    },
+  }
 
   // get books
-  books, err := appCtx.Model.Search(ctx, appCtx.Pool, r.FormValue("q"))
+  result, err := appCtx.Model.Search(ctx, appCtx.Pool, params)
   if err != nil {
-    panic(err)
+    writeError(w, err)
+    return
   }
 
-  // write JSON-encoded list of books
-  if err := json.NewEncoder(w).Encode(books); err != nil {
-    panic(err)
-  }
+  // write JSON-encoded page of books
+  writeJson(w, result)
 }
 
 // Route handler which shows contents of given book.
@@ -50,68 +83,92 @@ func doBook(w http.ResponseWriter, r *http.Request) {
   appCtx := appContextFromContext(ctx)
 
   // parse book ID
-  bookId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 32)
+  bookId, err := parseBookId(chi.URLParam(r, "id"))
   if err != nil {
-    panic(err)
+    writeError(w, err)
+    return
   }
 
   // get book body
   body, err := appCtx.Model.Body(ctx, appCtx.Pool, bookId)
   if err != nil {
-    panic(err)
+    writeError(w, err)
+    return
   }
 
   // set response header, write body
   w.Header().Add("Content-Type", "text/plain")
   if _, err := w.Write([]byte(body)); err != nil {
-    panic(err)
+    log.Print(err)
   }
 }
 
-// Route handler for file uploads
-func doApiUpload(w http.ResponseWriter, r *http.Request) {
+// Get book with ID from URL for book export route handlers.
+func getExportBook(r *http.Request) (model.FullBook, error) {
   // get context from request and app context from context
   ctx := r.Context()
   appCtx := appContextFromContext(ctx)
 
-  // get multipart reader from request
-  mpr, err := r.MultipartReader()
+  // parse book ID
+  bookId, err := parseBookId(chi.URLParam(r, "id"))
   if err != nil {
-    panic(err)
+    return model.FullBook{}, err
   }
 
-  // build list of uploaded files
-  var files []model.UploadedFile
-  for {
-    part, err := mpr.NextPart()
-    if err == io.EOF {
-      break;
-    } else if err != nil {
-      panic(err)
-    }
+  // get book
+  return appCtx.Model.Get(ctx, appCtx.Pool, bookId)
+}
 
-    // read part data
-    data, err := io.ReadAll(part)
-    if err != nil {
-      panic(err)
-    }
+// Route handler which exports given book as an EPUB file.
+func doBookEpub(w http.ResponseWriter, r *http.Request) {
+  // get book
+  book, err := getExportBook(r)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
 
-    // add to list of files
-    files = append(files, model.UploadedFile {
-      Name: strings.TrimSuffix(part.FileName(), ".txt"),
-      Body: string(data),
-    })
+  // generate epub
+  var buf bytes.Buffer
+  if err := export.Epub(&buf, book); err != nil {
+    writeError(w, err)
+    return
   }
 
-  // upload files
-  if err := appCtx.Model.Upload(ctx, appCtx.Pool, files); err != nil {
-    panic(err)
+  // set response headers, write epub
+  w.Header().Add("Content-Type", "application/epub+zip")
+  w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.FileName(book.Name, ".epub")))
+  if _, err := w.Write(buf.Bytes()); err != nil {
+    log.Print(err)
   }
+}
 
-  // send response
-  w.Header().Add("Content-Type", "text/json")
-  if _, err := w.Write([]byte("null")); err != nil {
-    panic(err)
+// Content security policy for exported HTML documents, which contain
+// an inline stylesheet.
+const exportHtmlCsp = "default-src 'none'; style-src 'unsafe-inline'"
+
+// Route handler which exports given book as a standalone HTML
+// document.
+func doBookHtml(w http.ResponseWriter, r *http.Request) {
+  // get book
+  book, err := getExportBook(r)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // generate html
+  var buf bytes.Buffer
+  if err := export.HTML(&buf, book); err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // set response headers, write html
+  w.Header().Add("Content-Type", "text/html; charset=utf-8")
+  w.Header().Set("Content-Security-Policy", exportHtmlCsp)
+  if _, err := w.Write(buf.Bytes()); err != nil {
+    log.Print(err)
   }
 }
 
@@ -122,9 +179,10 @@ func doApiEdit(w http.ResponseWriter, r *http.Request) {
   appCtx := appContextFromContext(ctx)
 
   // parse book ID
-  id, err := strconv.ParseInt(r.FormValue("id"), 10, 32)
+  id, err := parseBookId(r.FormValue("id"))
   if err != nil {
-    panic(err)
+    writeError(w, err)
+    return
   }
 
   // get new name and author
@@ -133,14 +191,546 @@ func doApiEdit(w http.ResponseWriter, r *http.Request) {
 
   // edit book
   if err := appCtx.Model.Edit(ctx, appCtx.Pool, id, name, author); err != nil {
-    panic(err)
+    writeError(w, err)
+    return
+  }
+
+  // send response
+  writeJson(w, nil)
+}
+
+// Move book to trash route handler.
+func doApiDelete(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // parse book ID
+  id, err := parseBookId(r.FormValue("id"))
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // move book to trash
+  if err := appCtx.Model.Delete(ctx, appCtx.Pool, id); err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // send response
+  writeJson(w, nil)
+}
+
+// Restore book from trash route handler.
+func doApiRestore(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // parse book ID
+  id, err := parseBookId(r.FormValue("id"))
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // restore book from trash
+  if err := appCtx.Model.Restore(ctx, appCtx.Pool, id); err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // send response
+  writeJson(w, nil)
+}
+
+// Permanently remove book from trash route handler.
+func doApiPurge(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // parse book ID
+  id, err := parseBookId(r.FormValue("id"))
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // remove book from trash
+  if err := appCtx.Model.Purge(ctx, appCtx.Pool, id); err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // send response
+  writeJson(w, nil)
+}
+
+// Get a list of books in the trash, sorted by deletion time in
+// descending order.
+func doApiTrash(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // get books in trash
+  books, err := appCtx.Model.Trash(ctx, appCtx.Pool)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // write JSON-encoded list of books
+  writeJson(w, books)
+}
+
+// Route handler which returns a report of books with identical or
+// similar contents, excluding books in the trash.
+//
+// Accepts an optional `threshold` query string parameter, which sets
+// the minimum similarity (from 0 to 1) of near duplicates.
+func doApiDuplicates(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // parse threshold
+  var threshold float64
+  if s := r.URL.Query().Get("threshold"); s != "" {
+    var err error
+    if threshold, err = strconv.ParseFloat(s, 64); err != nil {
+      writeError(w, badRequest("invalid threshold"))
+      return
+    }
+  }
+
+  // get duplicates
+  dups, err := appCtx.Model.Duplicates(ctx, appCtx.Pool, threshold)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // write JSON-encoded report
+  writeJson(w, dups)
+}
+
+// Result of signing bodies for the duplicate report.
+type signBodiesResult struct {
+  Signed int `json:"signed"` // number of signatures added
+}
+
+// Route handler which adds the signatures of books whose body has no
+// cached signature to the signature cache used by the duplicate
+// report.
+func doApiSignBodies(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // sign bodies
+  count, err := appCtx.Model.SignBodies(ctx, appCtx.Pool)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // write JSON-encoded result
+  writeJson(w, signBodiesResult { Signed: count })
+}
+
+// Route handler which returns a list of authors with at least one book
+// which is not in the trash, sorted by sort name.
+func doApiAuthors(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // get authors
+  authors, err := appCtx.Model.Authors(ctx, appCtx.Pool)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // write JSON-encoded authors
+  writeJson(w, authors)
+}
+
+// Route handler which returns the given author and their books.
+func doApiAuthor(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // parse author ID
+  id, err := parseAuthorId(chi.URLParam(r, "id"))
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // get author
+  author, err := appCtx.Model.Author(ctx, appCtx.Pool, id)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // write JSON-encoded author
+  writeJson(w, author)
+}
+
+// Route handler which merges duplicate authors into an author.
+//
+// Accepts the following request parameters:
+//
+// * `id`: ID of author to keep.
+// * `merge`: ID of duplicate author.  May be repeated.
+func doApiMergeAuthors(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // parse author ID
+  id, err := parseAuthorId(r.FormValue("id"))
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // parse duplicate author IDs
+  var dups []int64
+  for _, s := range(r.Form["merge"]) {
+    dup, err := parseAuthorId(s)
+    if err != nil {
+      writeError(w, err)
+      return
+    }
+    dups = append(dups, dup)
+  }
+
+  // merge authors
+  if err := appCtx.Model.MergeAuthors(ctx, appCtx.Pool, id, dups); err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // send response
+  writeJson(w, nil)
+}
+
+// Route handler which returns the tags and collections of the given
+// book.
+func doApiLabels(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // parse book ID
+  id, err := parseBookId(r.FormValue("id"))
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // get labels
+  labels, err := appCtx.Model.Labels(ctx, appCtx.Pool, id)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // write JSON-encoded labels
+  writeJson(w, labels)
+}
+
+// Route handler which returns a list of tags.
+func doApiTags(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // get tags
+  tags, err := appCtx.Model.Tags(ctx, appCtx.Pool)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // write JSON-encoded tags
+  writeJson(w, tags)
+}
+
+// Model method which adds or removes a book from a named tag or
+// collection.
+type labelFunc func(model.Model, context.Context, *pgxpool.Pool, int64, string) error
+
+// Create route handler which calls the given model method with the
+// book ID from the `id` request parameter and the name from the given
+// request parameter.
+func labelHandler(param string, fn labelFunc) http.HandlerFunc {
+  return func(w http.ResponseWriter, r *http.Request) {
+    // get context from request and app context from context
+    ctx := r.Context()
+    appCtx := appContextFromContext(ctx)
+
+    // parse book ID
+    id, err := parseBookId(r.FormValue("id"))
+    if err != nil {
+      writeError(w, err)
+      return
+    }
+
+    // call model method
+    if err := fn(appCtx.Model, ctx, appCtx.Pool, id, r.FormValue(param)); err != nil {
+      writeError(w, err)
+      return
+    }
+
+    // send response
+    writeJson(w, nil)
+  }
+}
+
+// Route handler which returns a list of collections.
+func doApiCollections(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // get collections
+  collections, err := appCtx.Model.Collections(ctx, appCtx.Pool)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // write JSON-encoded collections
+  writeJson(w, collections)
+}
+
+// Route handler which returns the given collection and its books.
+func doApiCollection(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // parse collection ID
+  id, err := parseCollectionId(chi.URLParam(r, "id"))
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // get collection
+  collection, err := appCtx.Model.Collection(ctx, appCtx.Pool, id)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // write JSON-encoded collection
+  writeJson(w, collection)
+}
+
+// Route handler which reorders the books in a collection.
+//
+// Accepts the following request parameters:
+//
+// * `id`: collection ID.
+// * `book`: book ID.  May be repeated.  The given books are moved to
+//   the start of the collection in the given order.
+func doApiSortCollection(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // parse collection ID
+  id, err := parseCollectionId(r.FormValue("id"))
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // parse book IDs
+  var books []int64
+  for _, s := range(r.Form["book"]) {
+    book, err := parseBookId(s)
+    if err != nil {
+      writeError(w, err)
+      return
+    }
+    books = append(books, book)
+  }
+
+  // sort collection
+  if err := appCtx.Model.SortCollection(ctx, appCtx.Pool, id, books); err != nil {
+    writeError(w, err)
+    return
   }
 
   // send response
-  w.Header().Add("Content-Type", "text/json")
-  if _, err := w.Write([]byte("null")); err != nil {
-    panic(err)
+  writeJson(w, nil)
+}
+
+// Route handler which deletes a collection.
+func doApiDeleteCollection(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // parse collection ID
+  id, err := parseCollectionId(r.FormValue("id"))
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // delete collection
+  if err := appCtx.Model.DeleteCollection(ctx, appCtx.Pool, id); err != nil {
+    writeError(w, err)
+    return
   }
+
+  // send response
+  writeJson(w, nil)
+}
+
+// Route handler which returns the revision history of the given book,
+// newest first.
+func doApiRevisions(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // parse book ID
+  id, err := parseBookId(r.FormValue("id"))
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // get revisions
+  revisions, err := appCtx.Model.Revisions(ctx, appCtx.Pool, id)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // write JSON-encoded revisions
+  writeJson(w, revisions)
+}
+
+// Number of lines of context around each change in revision diffs.
+const revisionDiffContext = 3
+
+// Diff between two revisions of a book.
+type revisionDiff struct {
+  From model.Revision `json:"from"` // old revision
+  To model.Revision `json:"to"` // new revision
+  Diff string `json:"diff"` // unified diff, or empty if the revisions are the same
+}
+
+// Get text of book revision for diffs: the name and author header
+// lines, followed by a blank line and the book body.
+func revisionText(rev model.RevisionDetail) string {
+  return fmt.Sprintf("Name: %s\nAuthor: %s\n\n%s", rev.Name, rev.Author, rev.Body)
+}
+
+// Route handler which returns a unified diff between two revisions of
+// a book.
+//
+// Accepts the following request parameters:
+//
+// * `id`: book ID.
+// * `from`: old revision ID.
+// * `to`: new revision ID.
+func doApiRevisionDiff(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // parse book ID
+  id, err := parseBookId(r.FormValue("id"))
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // parse revision IDs
+  fromId, err := parseRevisionId(r.FormValue("from"))
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  toId, err := parseRevisionId(r.FormValue("to"))
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // get revisions
+  from, err := appCtx.Model.Revision(ctx, appCtx.Pool, id, fromId)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  to, err := appCtx.Model.Revision(ctx, appCtx.Pool, id, toId)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // write JSON-encoded diff
+  writeJson(w, revisionDiff {
+    From: from.Revision,
+    To: to.Revision,
+    Diff: diff.Unified(
+      fmt.Sprintf("revision %d", fromId),
+      fmt.Sprintf("revision %d", toId),
+      revisionText(from),
+      revisionText(to),
+      revisionDiffContext,
+    ),
+  })
+}
+
+// Route handler which restores a book from one of its revisions.
+//
+// Accepts the following request parameters:
+//
+// * `id`: book ID.
+// * `revision`: revision ID.
+func doApiRevert(w http.ResponseWriter, r *http.Request) {
+  // get context from request and app context from context
+  ctx := r.Context()
+  appCtx := appContextFromContext(ctx)
+
+  // parse book ID
+  id, err := parseBookId(r.FormValue("id"))
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // parse revision ID
+  rev, err := parseRevisionId(r.FormValue("revision"))
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // revert book
+  if err := appCtx.Model.Revert(ctx, appCtx.Pool, id, rev); err != nil {
+    writeError(w, err)
+    return
+  }
+
+  // send response
+  writeJson(w, nil)
 }
 
 // Route handler which panics.
@@ -181,13 +771,126 @@ func NewRouter(appCtx *app.Context) (*chi.Mux, error) {
   r.Use(middleware.Compress(5, compressContentTypes...))
   r.Use(SecurityHeadersMiddleware(contentSecurityPolicy))
   r.Use(AppContextMiddleware(appCtx))
+  r.Use(ActorMiddleware)
+  r.Use(SessionMiddleware)
+  r.Use(TokenMiddleware)
+  r.Use(CsrfMiddleware)
+
+  // create rate limiting middleware for each request budget
+  limits := newRateLimiters(appCtx.Config)
+  searchLimit := RateLimitMiddleware(limits.search)
+  uploadLimit := RateLimitMiddleware(limits.upload)
+  editLimit := RateLimitMiddleware(limits.edit)
+
+  // bind login routes (login attempts count against the edit budget)
+  r.With(editLimit).Post("/api/login", doApiLogin)
+  r.Post("/api/logout", doApiLogout)
+  r.Get("/api/user", doApiUser)
+
+  // bind routes which manage the API tokens of the logged in user,
+  // which require a session (rather than an API token)
+  r.Group(func(r chi.Router) {
+    r.Use(RequireSessionMiddleware)
+
+    r.Get("/api/tokens", doApiTokens)
+    r.With(editLimit).Post("/api/tokens/create", doApiCreateToken)
+    r.With(editLimit).Post("/api/tokens/revoke", doApiRevokeToken)
+  })
+
+  // bind routes which search and read books, which require the viewer
+  // role
+  r.Group(func(r chi.Router) {
+    r.Use(RequireRoleMiddleware(model.RoleViewer))
+
+    r.Get("/api/trash", doApiTrash)
+    r.Get("/api/authors", doApiAuthors)
+    r.Get("/api/authors/{id:^\\d+$}", doApiAuthor)
+    r.Get("/api/labels", doApiLabels)
+    r.Get("/api/tags", doApiTags)
+    r.Get("/api/collections", doApiCollections)
+    r.Get("/api/collections/{id:^\\d+$}", doApiCollection)
+    r.Get("/api/revisions", doApiRevisions)
+    r.Get("/api/revisions/diff", doApiRevisionDiff)
+
+    // bind routes which search books, which count against the search
+    // budget
+    r.With(searchLimit).Get("/api/search", doApiSearch)
+  })
+
+  // bind OPDS catalog and book download routes, which require the
+  // viewer role, and which ask clients without a session for HTTP Basic
+  // authentication
+  r.Group(func(r chi.Router) {
+    r.Use(BasicAuthChallengeMiddleware)
+    r.Use(RequireRoleMiddleware(model.RoleViewer))
+
+    r.Get("/opds", doOpds)
+    r.Get("/opds/opensearch.xml", doOpdsOpenSearch)
+    r.Get("/opds/author", doOpdsAuthors)
+    r.Get("/opds/author/{id:^\\d+$}", doOpdsAuthor)
+    r.Get("/book/{id:^\\d+$}", doBook)
+    r.Get("/book/{id:^\\d+}.epub", doBookEpub)
+    r.Get("/book/{id:^\\d+}.html", doBookHtml)
+
+    // bind routes which search books, which count against the search
+    // budget
+    r.Group(func(r chi.Router) {
+      r.Use(searchLimit)
+
+      r.Get("/opds/title", opdsAcquisitionHandler("title", "By Title", "/opds/title", model.SortName))
+      r.Get("/opds/new", opdsAcquisitionHandler("new", "Recently Added", "/opds/new", model.SortCreated))
+      r.Get("/opds/search", opdsAcquisitionHandler("search", "Search", "/opds/search", ""))
+    })
+  })
+
+  // bind routes which add or change books, which require the editor
+  // role
+  r.Group(func(r chi.Router) {
+    r.Use(RequireRoleMiddleware(model.RoleEditor))
+
+    r.With(uploadLimit).Post("/api/upload", doApiUpload)
+
+    // bind routes which count against the edit budget
+    r.Group(func(r chi.Router) {
+      r.Use(editLimit)
+
+      r.Post("/api/edit", doApiEdit)
+      r.Post("/api/restore", doApiRestore)
+      r.Post("/api/authors/merge", doApiMergeAuthors)
+      r.Post("/api/tags/add", labelHandler("tag", model.Model.AddTag))
+      r.Post("/api/tags/remove", labelHandler("tag", model.Model.RemoveTag))
+      r.Post("/api/collections/add", labelHandler("collection", model.Model.AddToCollection))
+      r.Post("/api/collections/remove", labelHandler("collection", model.Model.RemoveFromCollection))
+      r.Post("/api/collections/sort", doApiSortCollection)
+      r.Post("/api/revisions/revert", doApiRevert)
+    })
+  })
+
+  // bind routes which delete books, find duplicates, manage users, or
+  // export or restore the whole library, which require the admin role
+  r.Group(func(r chi.Router) {
+    r.Use(RequireRoleMiddleware(model.RoleAdmin))
+
+    r.Get("/api/panic", doApiPanic)
+    r.Get("/api/admin/export", doApiLibraryExport)
+    r.Get("/api/admin/users", doApiUsers)
+    r.With(searchLimit).Get("/api/duplicates", doApiDuplicates)
+    r.With(uploadLimit).Post("/api/admin/restore", doApiLibraryRestore)
+
+    // bind routes which count against the edit budget
+    r.Group(func(r chi.Router) {
+      r.Use(editLimit)
+
+      r.Post("/api/delete", doApiDelete)
+      r.Post("/api/purge", doApiPurge)
+      r.Post("/api/collections/delete", doApiDeleteCollection)
+      r.Post("/api/duplicates/sign", doApiSignBodies)
+      r.Post("/api/admin/users/add", doApiAddUser)
+      r.Post("/api/admin/users/role", doApiSetRole)
+      r.Post("/api/admin/users/delete", doApiDeleteUser)
+    })
+  })
 
-  // bind routes
-  r.Get("/api/search", doApiSearch)
-  r.Get("/api/panic", doApiPanic)
-  r.Post("/api/upload", doApiUpload)
-  r.Post("/api/edit", doApiEdit)
-  r.Get("/book/{id:^\\d+$}", doBook)
   // bind static site (note the "/*" to match all files)
   r.Handle("/*", http.FileServer(http.FS(public)))
 
@@ -1,15 +1,22 @@
 package web
 
 import (
+  "archive/zip"
   "bookman/app"
+  "bookman/library"
   "bookman/model"
+  "bytes"
   "context"
+  "encoding/json"
   "errors"
   "fmt"
   "github.com/go-chi/chi/v5"
   "io"
+  "mime/multipart"
   "net/http"
   "net/http/httptest"
+  "os"
+  "strconv"
   "strings"
   "testing"
 )
@@ -19,18 +26,47 @@ func TestDoApiSearch(t *testing.T) {
     // tests expected to pass
     var passTests = []struct {
       name string       // test name
-      data []model.Book // data to return
+      data model.MockSearchResult // data to return
       exp  string       // expected body
     } {{
       name: "empty",
-      data: []model.Book{},
-      exp: `[]`,
+      data: model.MockSearchResult {
+        Books: []model.Book{},
+      },
+      exp: `{"books":[],"next":"","total":0}`,
     }, {
-      name: "empty",
-      data: []model.Book {
-        model.Book { Id: 1, Name: "foo" },
+      name: "one",
+      data: model.MockSearchResult {
+        Books: []model.Book {
+          model.Book { Id: 1, Name: "foo" },
+        },
+        Total: 1,
+      },
+      exp: `{"books":[{"id":1,"name":"foo","author":"","rank":0}],"next":"","total":1}`,
+    }, {
+      name: "next",
+      data: model.MockSearchResult {
+        Books: []model.Book {
+          model.Book { Id: 1, Name: "foo" },
+        },
+        Next: "abc",
+        Total: 2,
+      },
+      exp: `{"books":[{"id":1,"name":"foo","author":"","rank":0}],"next":"abc","total":2}`,
+    }, {
+      name: "snippet",
+      data: model.MockSearchResult {
+        Books: []model.Book {
+          model.Book {
+            Id: 1,
+            Name: "foo",
+            Rank: 0.5,
+            Snippet: "the " + model.SnippetStart + "bar" + model.SnippetStop + " is" + model.SnippetDelimiter + "a <b>",
+          },
+        },
+        Total: 1,
       },
-      exp: `[{"id":1,"name":"foo","author":"","rank":0}]`,
+      exp: "{\"books\":[{\"id\":1,\"name\":\"foo\",\"author\":\"\",\"rank\":0.5,\"snippet\":\"the \ue000bar\ue001 is … a \\u003cb\\u003e\"}],\"next\":\"\",\"total\":1}",
     }}
 
     // run pass tests
@@ -39,9 +75,7 @@ func TestDoApiSearch(t *testing.T) {
         // build app context w/ mock model
         appCtx := app.Context {
           Model: &model.MockModel {
-            SearchResult: model.MockSearchResult {
-              Books: test.data,
-            },
+            SearchResult: test.data,
           },
         }
 
@@ -102,21 +136,52 @@ func TestDoApiSearch(t *testing.T) {
     }
     resp := httptest.NewRecorder()
 
-    // doApiSearch() panics on error, so recover from it and log the
-    // error here.  this is probably not the best way to do things, but
-    // it was good enough for testing purposes
-    defer func() {
-      if err := recover(); err != nil {
-        // log recovered error
-        t.Log(err)
-      }
-    }()
-
     // call handler
     doApiSearch(resp, req)
 
-    // shouldn't be reached
-    t.Fatal("got success, exp err")
+    // check response status and error code
+    checkErrorResponse(t, resp, http.StatusInternalServerError, "internal_error")
+  })
+
+  // test invalid request parameters
+  t.Run("bad params", func(t *testing.T) {
+    var failTests = []struct {
+      name string // test name
+      query string // request query string
+      err error // Search() error
+      code string // expected error code
+    } {
+      { "limit", "limit=foo", nil, "bad_request" },
+      { "negative limit", "limit=-1", nil, "bad_request" },
+      { "sort", "sort=foo", &model.ValidationError { Field: "sort", Message: "unknown sort order" }, "invalid" },
+    }
+
+    for _, test := range(failTests) {
+      t.Run(test.name, func(t *testing.T) {
+        // build app context w/ mock model
+        appCtx := app.Context {
+          Model: &model.MockModel {
+            SearchResult: model.MockSearchResult {
+              Err: test.err,
+            },
+          },
+        }
+
+        // create context, request, and response recorder
+        ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+        req, err := http.NewRequestWithContext(ctx, "GET", "/api/search?" + test.query, nil)
+        if err != nil {
+          t.Fatal(err)
+        }
+        resp := httptest.NewRecorder()
+
+        // call handler
+        doApiSearch(resp, req)
+
+        // check response status and error code
+        checkErrorResponse(t, resp, http.StatusBadRequest, test.code)
+      })
+    }
   })
 
   // TODO: test JSON encode write error
@@ -194,30 +259,535 @@ func TestDoBook(t *testing.T) {
   })
 
 
-  // test strconv.ParseInt() failure
-  t.Run("parseint fail", func(t *testing.T) {
+  // tests expected to fail
+  var failTests = []struct {
+    name string // test name
+    path string // request path
+    err error // Body() error
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "parseint fail",
+    path: "/book/36893488147419103232",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "not found",
+    path: "/book/1",
+    err: model.ErrNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }, {
+    name: "body fail",
+    path: "/book/1",
+    err: errors.New("some error"),
+    status: http.StatusInternalServerError,
+    code: "internal_error",
+  }}
+
+  for _, test := range(failTests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Model: &model.MockModel {
+          BodyResult: model.MockBodyResult {
+            Err: test.err,
+          },
+        },
+      }
+
+      // create context, request, and response recorder
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req, err := http.NewRequestWithContext(ctx, "GET", test.path, nil)
+      if err != nil {
+        t.Fatal(err)
+      }
+      resp := httptest.NewRecorder()
+
+      // send request
+      router.ServeHTTP(resp, req)
+
+      // check response status and error code
+      checkErrorResponse(t, resp, test.status, test.code)
+    })
+  }
+}
+
+func TestDoBookExport(t *testing.T) {
+  // note: because the export handlers use chi.URLParam(), we need a
+  // mock chi router (see TestDoBook())
+  router := chi.NewRouter()
+  router.Get("/book/{id:^\\d+}.epub", doBookEpub)
+  router.Get("/book/{id:^\\d+}.html", doBookHtml)
+
+  // test book
+  book := model.FullBook {
+    Id: 1,
+    Name: "Cats & Dogs",
+    Author: "Jane Doe",
+    Body: "It was a dark and stormy night.",
+  }
+
+  t.Run("pass", func(t *testing.T) {
+    // tests expected to pass
+    var passTests = []struct {
+      path string // request path
+      contentType string // expected content type
+      disposition string // expected content disposition
+      body string // expected body fragment
+    } {{
+      path: "/book/1.epub",
+      contentType: "application/epub+zip",
+      disposition: `attachment; filename="Cats_Dogs.epub"`,
+      body: "mimetypeapplication/epub+zip",
+    }, {
+      path: "/book/1.html",
+      contentType: "text/html; charset=utf-8",
+      body: "<p>It was a dark and stormy night.</p>",
+    }}
+
+    for _, test := range(passTests) {
+      t.Run(test.path, func(t *testing.T) {
+        // build app context w/ mock model
+        appCtx := app.Context {
+          Model: &model.MockModel {
+            GetResult: model.MockGetResult { Book: book },
+          },
+        }
+
+        // create context, request, and response recorder
+        ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+        req, err := http.NewRequestWithContext(ctx, "GET", test.path, nil)
+        if err != nil {
+          t.Fatal(err)
+        }
+        resp := httptest.NewRecorder()
+
+        // send request
+        router.ServeHTTP(resp, req)
+
+        // check response headers
+        for _, h := range([][2]string {
+          { "Content-Type", test.contentType },
+          { "Content-Disposition", test.disposition },
+        }) {
+          if got := resp.Header().Get(h[0]); got != h[1] {
+            t.Fatalf("%s: got \"%s\", exp \"%s\"", h[0], got, h[1])
+          }
+        }
+
+        // read response body
+        body, err := io.ReadAll(resp.Result().Body)
+        if err != nil {
+          t.Fatal(err)
+        }
+
+        // check response body
+        if !strings.Contains(string(body), test.body) {
+          t.Fatalf("got \"%s\", exp \"%s\"", body, test.body)
+        }
+      })
+    }
+  })
+
+  // tests expected to fail
+  var failTests = []struct {
+    name string // test name
+    path string // request path
+    err error // Get() error
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "epub parseint fail",
+    path: "/book/36893488147419103232.epub",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "epub not found",
+    path: "/book/1.epub",
+    err: model.ErrNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }, {
+    name: "html not found",
+    path: "/book/1.html",
+    err: model.ErrNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }, {
+    name: "html get fail",
+    path: "/book/1.html",
+    err: errors.New("some error"),
+    status: http.StatusInternalServerError,
+    code: "internal_error",
+  }}
+
+  for _, test := range(failTests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Model: &model.MockModel {
+          GetResult: model.MockGetResult { Err: test.err },
+        },
+      }
+
+      // create context, request, and response recorder
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req, err := http.NewRequestWithContext(ctx, "GET", test.path, nil)
+      if err != nil {
+        t.Fatal(err)
+      }
+      resp := httptest.NewRecorder()
+
+      // send request
+      router.ServeHTTP(resp, req)
+
+      // check response status and error code
+      checkErrorResponse(t, resp, test.status, test.code)
+    })
+  }
+}
+
+func TestDoApiTrash(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    // build app context w/ mock model
+    appCtx := app.Context {
+      Model: &model.MockModel {
+        TrashResult: model.MockSearchResult {
+          Books: []model.Book {
+            model.Book { Id: 1, Name: "foo", Author: "bar" },
+          },
+        },
+      },
+    }
+
+    // create context, request, and response recorder
+    ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+    req, err := http.NewRequestWithContext(ctx, "GET", "/api/trash", nil)
+    if err != nil {
+      t.Fatal(err)
+    }
+    resp := httptest.NewRecorder()
+
+    // call handler
+    doApiTrash(resp, req)
+
+    // read response body
+    body, err := io.ReadAll(resp.Result().Body)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    // check response body
+    exp := `[{"id":1,"name":"foo","author":"bar","rank":0}]`
+    got := strings.TrimSpace(string(body))
+    if got != exp {
+      t.Fatalf("got \"%s\", exp \"%s\"", got, exp)
+    }
+  })
+}
+
+func TestDoApiDuplicates(t *testing.T) {
+  // test report
+  dups := model.Duplicates {
+    Exact: []model.DuplicateGroup {
+      model.DuplicateGroup {
+        BodySha256: "abc",
+        Books: []model.Book {
+          model.Book { Id: 1, Name: "foo", Author: "bar" },
+          model.Book { Id: 2, Name: "foo (2)", Author: "bar" },
+        },
+      },
+    },
+    Near: []model.DuplicatePair {
+      model.DuplicatePair {
+        Similarity: 0.9,
+        Books: [2]model.Book {
+          model.Book { Id: 1, Name: "foo", Author: "bar" },
+          model.Book { Id: 3, Name: "baz", Author: "bar" },
+        },
+      },
+    },
+  }
+
+  var tests = []struct {
+    name string // test name
+    query string // request query string
+    err error // Duplicates() error
+    status int // expected status code
+    code string // expected error code
+    exp string // expected body
+  } {{
+    name: "pass",
+    query: "threshold=0.9",
+    status: http.StatusOK,
+    exp: `{"exact":[{"body_sha256":"abc","books":[` +
+      `{"id":1,"name":"foo","author":"bar","rank":0},` +
+      `{"id":2,"name":"foo (2)","author":"bar","rank":0}` +
+    `]}],"near":[{"similarity":0.9,"books":[` +
+      `{"id":1,"name":"foo","author":"bar","rank":0},` +
+      `{"id":3,"name":"baz","author":"bar","rank":0}` +
+    `]}]}`,
+  }, {
+    name: "bad threshold",
+    query: "threshold=foo",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "invalid threshold",
+    query: "threshold=2",
+    err: &model.ValidationError { Field: "threshold", Message: "must be between 0 and 1" },
+    status: http.StatusBadRequest,
+    code: "invalid",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Model: &model.MockModel {
+          DuplicatesResult: model.MockDuplicatesResult {
+            Duplicates: dups,
+            Err: test.err,
+          },
+        },
+      }
+
+      // create context, request, and response recorder
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req, err := http.NewRequestWithContext(ctx, "GET", "/api/duplicates?" + test.query, nil)
+      if err != nil {
+        t.Fatal(err)
+      }
+      resp := httptest.NewRecorder()
+
+      // call handler
+      doApiDuplicates(resp, req)
+
+      if test.status != http.StatusOK {
+        // check response status and error code
+        checkErrorResponse(t, resp, test.status, test.code)
+        return
+      }
+
+      // check response body
+      got := strings.TrimSpace(resp.Body.String())
+      if got != test.exp {
+        t.Fatalf("got \"%s\", exp \"%s\"", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestDoApiSignBodies(t *testing.T) {
+  var tests = []struct {
+    name string // test name
+    err error // SignBodies() error
+    status int // expected status code
+    code string // expected error code
+    exp string // expected body
+  } {{
+    name: "pass",
+    status: http.StatusOK,
+    exp: `{"signed":3}`,
+  }, {
+    name: "fail",
+    err: errors.New("some error"),
+    status: http.StatusInternalServerError,
+    code: "internal_error",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Model: &model.MockModel {
+          SignBodiesResult: model.MockSignBodiesResult {
+            Count: 3,
+            Err: test.err,
+          },
+        },
+      }
+
+      // create context, request, and response recorder
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req, err := http.NewRequestWithContext(ctx, "POST", "/api/duplicates/sign", nil)
+      if err != nil {
+        t.Fatal(err)
+      }
+      resp := httptest.NewRecorder()
+
+      // call handler
+      doApiSignBodies(resp, req)
+
+      if test.status != http.StatusOK {
+        // check response status and error code
+        checkErrorResponse(t, resp, test.status, test.code)
+        return
+      }
+
+      // check response body
+      got := strings.TrimSpace(resp.Body.String())
+      if got != test.exp {
+        t.Fatalf("got \"%s\", exp \"%s\"", got, test.exp)
+      }
+    })
+  }
+}
+
+func TestDoApiDelete(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
     // build app context w/ mock model
     appCtx := app.Context {
       Model: &model.MockModel {},
     }
 
-    // create context, url path, request, and response recorder
+    // create context, request, and response recorder
     ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
-    req, err := http.NewRequestWithContext(ctx, "GET", "/book/36893488147419103232", nil)
+    req, err := http.NewRequestWithContext(ctx, "POST", "/api/delete?id=1", nil)
     if err != nil {
       t.Fatal(err)
     }
     resp := httptest.NewRecorder()
 
-    defer func() {
-      if err := recover(); err != nil {
-        // log recovered error
-        t.Logf("got expected error: %s", err)
+    // call handler
+    doApiDelete(resp, req)
+
+    // check response status
+    if resp.Code != http.StatusOK {
+      t.Fatalf("got %d, exp %d", resp.Code, http.StatusOK)
+    }
+  })
+
+  // test model.Delete() failure
+  t.Run("not found", func(t *testing.T) {
+    // build app context w/ mock model
+    appCtx := app.Context {
+      Model: &model.MockModel {
+        DeleteResult: model.ErrNotFound,
+      },
+    }
+
+    // create context, request, and response recorder
+    ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+    req, err := http.NewRequestWithContext(ctx, "POST", "/api/delete?id=1", nil)
+    if err != nil {
+      t.Fatal(err)
+    }
+    resp := httptest.NewRecorder()
+
+    // call handler
+    doApiDelete(resp, req)
+
+    // check response status and error code
+    checkErrorResponse(t, resp, http.StatusNotFound, "not_found")
+  })
+}
+
+func TestDoApiEdit(t *testing.T) {
+  var tests = []struct {
+    name string // test name
+    query string // request query string
+    err error // Edit() error
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "pass",
+    query: "id=1&name=foo&author=bar",
+    status: http.StatusOK,
+  }, {
+    name: "bad id",
+    query: "id=foo&name=foo&author=bar",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "invalid",
+    query: "id=1&name=&author=bar",
+    err: &model.ValidationError { Field: "name", Message: "must not be empty" },
+    status: http.StatusBadRequest,
+    code: "invalid",
+  }, {
+    name: "not found",
+    query: "id=1&name=foo&author=bar",
+    err: model.ErrNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }, {
+    name: "trashed",
+    query: "id=2&name=foo&author=bar",
+    err: model.ErrNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }, {
+    name: "duplicate",
+    query: "id=1&name=foo&author=bar",
+    err: fmt.Errorf("%w: some detail", model.ErrDuplicate),
+    status: http.StatusConflict,
+    code: "duplicate",
+  }, {
+    name: "model edit fail",
+    query: "id=1&name=foo&author=bar",
+    err: errors.New("some error"),
+    status: http.StatusInternalServerError,
+    code: "internal_error",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Model: &model.MockModel {
+          EditResult: test.err,
+        },
+      }
+
+      // create context, request, and response recorder
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req, err := http.NewRequestWithContext(ctx, "POST", "/api/edit?" + test.query, nil)
+      if err != nil {
+        t.Fatal(err)
       }
-    }()
+      resp := httptest.NewRecorder()
 
-    // send request
-    router.ServeHTTP(resp, req)
+      // call handler
+      doApiEdit(resp, req)
+
+      if test.status == http.StatusOK {
+        // check response status
+        if resp.Code != http.StatusOK {
+          t.Fatalf("got %d, exp %d", resp.Code, http.StatusOK)
+        }
+      } else {
+        // check response status and error code
+        checkErrorResponse(t, resp, test.status, test.code)
+      }
+    })
+  }
+}
+
+func TestDoApiAuthors(t *testing.T) {
+  t.Run("pass", func(t *testing.T) {
+    // build app context w/ mock model
+    appCtx := app.Context {
+      Model: &model.MockModel {
+        AuthorsResult: model.MockAuthorsResult {
+          Authors: []model.Author {
+            model.Author { Id: 1, Name: "Mark Twain", SortName: "Twain, Mark", NumBooks: 2 },
+          },
+        },
+      },
+    }
+
+    // create context, request, and response recorder
+    ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+    req, err := http.NewRequestWithContext(ctx, "GET", "/api/authors", nil)
+    if err != nil {
+      t.Fatal(err)
+    }
+    resp := httptest.NewRecorder()
+
+    // call handler
+    doApiAuthors(resp, req)
 
     // read response body
     body, err := io.ReadAll(resp.Result().Body)
@@ -225,49 +795,1343 @@ func TestDoBook(t *testing.T) {
       t.Fatal(err)
     }
 
-    // shouldn't be reached, log response body
-    t.Fatalf("got success, exp err; body = \"%s\"", string(body))
+    // check response body
+    exp := `[{"id":1,"name":"Mark Twain","sort_name":"Twain, Mark","num_books":2}]`
+    got := strings.TrimSpace(string(body))
+    if got != exp {
+      t.Fatalf("got \"%s\", exp \"%s\"", got, exp)
+    }
   })
 
-  // test model.Body() failure
-  t.Run("body fail", func(t *testing.T) {
+  // test model.Authors() failure
+  t.Run("fail", func(t *testing.T) {
     // build app context w/ mock model
     appCtx := app.Context {
       Model: &model.MockModel {
-        BodyResult: model.MockBodyResult {
+        AuthorsResult: model.MockAuthorsResult {
           Err: errors.New("some error"),
         },
       },
     }
 
-    // create context, url path, request, and response recorder
+    // create context, request, and response recorder
     ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
-    req, err := http.NewRequestWithContext(ctx, "GET", "/book/1", nil)
+    req, err := http.NewRequestWithContext(ctx, "GET", "/api/authors", nil)
     if err != nil {
       t.Fatal(err)
     }
     resp := httptest.NewRecorder()
 
-    defer func() {
-      if err := recover(); err != nil {
-        // log recovered error
-        t.Logf("got expected error: %s", err)
+    // call handler
+    doApiAuthors(resp, req)
+
+    // check response status and error code
+    checkErrorResponse(t, resp, http.StatusInternalServerError, "internal_error")
+  })
+}
+
+func TestDoApiAuthor(t *testing.T) {
+  // note: because doApiAuthor uses chi.URLParam(), we need a mock chi
+  // router (see TestDoBook())
+  router := chi.NewRouter()
+  router.Get("/api/authors/{id:^\\d+$}", doApiAuthor)
+
+  var tests = []struct {
+    name string // test name
+    path string // request path
+    err error // Author() error
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "pass",
+    path: "/api/authors/1",
+    status: http.StatusOK,
+  }, {
+    name: "bad id",
+    path: "/api/authors/36893488147419103232",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "not found",
+    path: "/api/authors/1",
+    err: model.ErrAuthorNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }, {
+    name: "model author fail",
+    path: "/api/authors/1",
+    err: errors.New("some error"),
+    status: http.StatusInternalServerError,
+    code: "internal_error",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Model: &model.MockModel {
+          AuthorResult: model.MockAuthorResult {
+            Author: model.AuthorDetail {
+              Author: model.Author { Id: 1, Name: "Mark Twain", SortName: "Twain, Mark", NumBooks: 1 },
+              Books: []model.Book {
+                model.Book { Id: 2, Name: "Roughing It", Author: "Mark Twain" },
+              },
+            },
+            Err: test.err,
+          },
+        },
       }
-    }()
 
-    // send request
-    router.ServeHTTP(resp, req)
+      // create context, request, and response recorder
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req, err := http.NewRequestWithContext(ctx, "GET", test.path, nil)
+      if err != nil {
+        t.Fatal(err)
+      }
+      resp := httptest.NewRecorder()
 
-    // read response body
-    body, err := io.ReadAll(resp.Result().Body)
+      // send request
+      router.ServeHTTP(resp, req)
+
+      if test.status != http.StatusOK {
+        // check response status and error code
+        checkErrorResponse(t, resp, test.status, test.code)
+        return
+      }
+
+      // read response body
+      body, err := io.ReadAll(resp.Result().Body)
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      // check response body
+      exp := `{"id":1,"name":"Mark Twain","sort_name":"Twain, Mark","num_books":1,"books":[{"id":2,"name":"Roughing It","author":"Mark Twain","rank":0}]}`
+      got := strings.TrimSpace(string(body))
+      if got != exp {
+        t.Fatalf("got \"%s\", exp \"%s\"", got, exp)
+      }
+    })
+  }
+}
+
+func TestDoApiMergeAuthors(t *testing.T) {
+  var tests = []struct {
+    name string // test name
+    query string // request query string
+    err error // MergeAuthors() error
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "pass",
+    query: "id=1&merge=2&merge=3",
+    status: http.StatusOK,
+  }, {
+    name: "bad id",
+    query: "id=foo&merge=2",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "bad merge id",
+    query: "id=1&merge=foo",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "invalid",
+    query: "id=1",
+    err: &model.ValidationError { Field: "merge", Message: "must not be empty" },
+    status: http.StatusBadRequest,
+    code: "invalid",
+  }, {
+    name: "not found",
+    query: "id=1&merge=2",
+    err: model.ErrAuthorNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }, {
+    name: "model merge fail",
+    query: "id=1&merge=2",
+    err: errors.New("some error"),
+    status: http.StatusInternalServerError,
+    code: "internal_error",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Model: &model.MockModel {
+          MergeAuthorsResult: test.err,
+        },
+      }
+
+      // create context, request, and response recorder
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req, err := http.NewRequestWithContext(ctx, "POST", "/api/authors/merge?" + test.query, nil)
+      if err != nil {
+        t.Fatal(err)
+      }
+      resp := httptest.NewRecorder()
+
+      // call handler
+      doApiMergeAuthors(resp, req)
+
+      if test.status == http.StatusOK {
+        // check response status
+        if resp.Code != http.StatusOK {
+          t.Fatalf("got %d, exp %d", resp.Code, http.StatusOK)
+        }
+      } else {
+        // check response status and error code
+        checkErrorResponse(t, resp, test.status, test.code)
+      }
+    })
+  }
+}
+
+func TestDoApiLabels(t *testing.T) {
+  var tests = []struct {
+    name string // test name
+    query string // request query string
+    err error // Labels() error
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "pass",
+    query: "id=1",
+    status: http.StatusOK,
+  }, {
+    name: "bad id",
+    query: "id=foo",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "not found",
+    query: "id=1",
+    err: model.ErrNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Model: &model.MockModel {
+          LabelsResult: model.MockLabelsResult {
+            Labels: model.Labels {
+              Tags: []string { "poetry" },
+              Collections: []string { "to read" },
+            },
+            Err: test.err,
+          },
+        },
+      }
+
+      // create context, request, and response recorder
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req, err := http.NewRequestWithContext(ctx, "GET", "/api/labels?" + test.query, nil)
+      if err != nil {
+        t.Fatal(err)
+      }
+      resp := httptest.NewRecorder()
+
+      // call handler
+      doApiLabels(resp, req)
+
+      if test.status != http.StatusOK {
+        // check response status and error code
+        checkErrorResponse(t, resp, test.status, test.code)
+        return
+      }
+
+      // check response body
+      exp := `{"tags":["poetry"],"collections":["to read"]}`
+      got := strings.TrimSpace(resp.Body.String())
+      if got != exp {
+        t.Fatalf("got \"%s\", exp \"%s\"", got, exp)
+      }
+    })
+  }
+}
+
+func TestDoApiTags(t *testing.T) {
+  // build app context w/ mock model
+  appCtx := app.Context {
+    Model: &model.MockModel {
+      TagsResult: model.MockTagsResult {
+        Tags: []model.Tag {
+          model.Tag { Id: 1, Name: "poetry", NumBooks: 2 },
+        },
+      },
+    },
+  }
+
+  // create context, request, and response recorder
+  ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+  req, err := http.NewRequestWithContext(ctx, "GET", "/api/tags", nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+  resp := httptest.NewRecorder()
+
+  // call handler
+  doApiTags(resp, req)
+
+  // check response body
+  exp := `[{"id":1,"name":"poetry","num_books":2}]`
+  got := strings.TrimSpace(resp.Body.String())
+  if got != exp {
+    t.Fatalf("got \"%s\", exp \"%s\"", got, exp)
+  }
+}
+
+func TestLabelHandler(t *testing.T) {
+  var tests = []struct {
+    name string // test name
+    path string // request path and query string
+    model model.MockModel // mock model
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "add tag",
+    path: "/api/tags/add?id=1&tag=poetry",
+    status: http.StatusOK,
+  }, {
+    name: "add tag bad id",
+    path: "/api/tags/add?id=foo&tag=poetry",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "add tag invalid",
+    path: "/api/tags/add?id=1&tag=",
+    model: model.MockModel {
+      AddTagResult: &model.ValidationError { Field: "tag", Message: "must not be empty" },
+    },
+    status: http.StatusBadRequest,
+    code: "invalid",
+  }, {
+    name: "remove tag",
+    path: "/api/tags/remove?id=1&tag=poetry",
+    status: http.StatusOK,
+  }, {
+    name: "remove tag not found",
+    path: "/api/tags/remove?id=1&tag=poetry",
+    model: model.MockModel {
+      RemoveTagResult: model.ErrTagNotFound,
+    },
+    status: http.StatusNotFound,
+    code: "not_found",
+  }, {
+    name: "add to collection",
+    path: "/api/collections/add?id=1&collection=to+read",
+    status: http.StatusOK,
+  }, {
+    name: "add to collection not found",
+    path: "/api/collections/add?id=1&collection=to+read",
+    model: model.MockModel {
+      AddToCollectionResult: model.ErrNotFound,
+    },
+    status: http.StatusNotFound,
+    code: "not_found",
+  }, {
+    name: "remove from collection fail",
+    path: "/api/collections/remove?id=1&collection=to+read",
+    model: model.MockModel {
+      RemoveFromCollectionResult: errors.New("some error"),
+    },
+    status: http.StatusInternalServerError,
+    code: "internal_error",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model and logged in user, create
+      // router
+      test.model.SessionUserResult = model.MockUserResult {
+        User: model.User { Id: 1, Name: "alice", Role: model.RoleEditor },
+      }
+      appCtx := app.Context { Model: &test.model }
+      router, err := NewRouter(&appCtx)
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      // create request w/ session cookie and CSRF token, create
+      // response recorder
+      req, err := http.NewRequestWithContext(context.Background(), "POST", test.path, nil)
+      if err != nil {
+        t.Fatal(err)
+      }
+      req.AddCookie(&http.Cookie { Name: sessionCookieName, Value: "token" })
+      addCsrfToken(req)
+      resp := httptest.NewRecorder()
+
+      // send request
+      router.ServeHTTP(resp, req)
+
+      if test.status == http.StatusOK {
+        // check response status
+        if resp.Code != http.StatusOK {
+          t.Fatalf("got %d, exp %d", resp.Code, http.StatusOK)
+        }
+      } else {
+        // check response status and error code
+        checkErrorResponse(t, resp, test.status, test.code)
+      }
+    })
+  }
+}
+
+func TestDoApiCollections(t *testing.T) {
+  // build app context w/ mock model
+  appCtx := app.Context {
+    Model: &model.MockModel {
+      CollectionsResult: model.MockCollectionsResult {
+        Collections: []model.Collection {
+          model.Collection { Id: 1, Name: "to read", NumBooks: 0 },
+        },
+      },
+    },
+  }
+
+  // create context, request, and response recorder
+  ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+  req, err := http.NewRequestWithContext(ctx, "GET", "/api/collections", nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+  resp := httptest.NewRecorder()
+
+  // call handler
+  doApiCollections(resp, req)
+
+  // check response body
+  exp := `[{"id":1,"name":"to read","num_books":0}]`
+  got := strings.TrimSpace(resp.Body.String())
+  if got != exp {
+    t.Fatalf("got \"%s\", exp \"%s\"", got, exp)
+  }
+}
+
+func TestDoApiCollection(t *testing.T) {
+  // note: because doApiCollection uses chi.URLParam(), we need a mock
+  // chi router (see TestDoBook())
+  router := chi.NewRouter()
+  router.Get("/api/collections/{id:^\\d+$}", doApiCollection)
+
+  var tests = []struct {
+    name string // test name
+    path string // request path
+    err error // Collection() error
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "pass",
+    path: "/api/collections/1",
+    status: http.StatusOK,
+  }, {
+    name: "bad id",
+    path: "/api/collections/36893488147419103232",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "not found",
+    path: "/api/collections/1",
+    err: model.ErrCollectionNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Model: &model.MockModel {
+          CollectionResult: model.MockCollectionResult {
+            Collection: model.CollectionDetail {
+              Collection: model.Collection { Id: 1, Name: "to read", NumBooks: 1 },
+              Books: []model.Book {
+                model.Book { Id: 2, Name: "foo", Author: "bar" },
+              },
+            },
+            Err: test.err,
+          },
+        },
+      }
+
+      // create context, request, and response recorder
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req, err := http.NewRequestWithContext(ctx, "GET", test.path, nil)
+      if err != nil {
+        t.Fatal(err)
+      }
+      resp := httptest.NewRecorder()
+
+      // send request
+      router.ServeHTTP(resp, req)
+
+      if test.status != http.StatusOK {
+        // check response status and error code
+        checkErrorResponse(t, resp, test.status, test.code)
+        return
+      }
+
+      // check response body
+      exp := `{"id":1,"name":"to read","num_books":1,"books":[{"id":2,"name":"foo","author":"bar","rank":0}]}`
+      got := strings.TrimSpace(resp.Body.String())
+      if got != exp {
+        t.Fatalf("got \"%s\", exp \"%s\"", got, exp)
+      }
+    })
+  }
+}
+
+func TestDoApiSortCollection(t *testing.T) {
+  var tests = []struct {
+    name string // test name
+    query string // request query string
+    err error // SortCollection() error
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "pass",
+    query: "id=1&book=3&book=2",
+    status: http.StatusOK,
+  }, {
+    name: "empty collection",
+    query: "id=1",
+    status: http.StatusOK,
+  }, {
+    name: "bad id",
+    query: "id=foo&book=2",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "bad book id",
+    query: "id=1&book=foo",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "not found",
+    query: "id=1&book=2",
+    err: model.ErrCollectionNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Model: &model.MockModel {
+          SortCollectionResult: test.err,
+        },
+      }
+
+      // create context, request, and response recorder
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req, err := http.NewRequestWithContext(ctx, "POST", "/api/collections/sort?" + test.query, nil)
+      if err != nil {
+        t.Fatal(err)
+      }
+      resp := httptest.NewRecorder()
+
+      // call handler
+      doApiSortCollection(resp, req)
+
+      if test.status == http.StatusOK {
+        // check response status
+        if resp.Code != http.StatusOK {
+          t.Fatalf("got %d, exp %d", resp.Code, http.StatusOK)
+        }
+      } else {
+        // check response status and error code
+        checkErrorResponse(t, resp, test.status, test.code)
+      }
+    })
+  }
+}
+
+func TestDoApiDeleteCollection(t *testing.T) {
+  var tests = []struct {
+    name string // test name
+    query string // request query string
+    err error // DeleteCollection() error
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "pass",
+    query: "id=1",
+    status: http.StatusOK,
+  }, {
+    name: "bad id",
+    query: "id=foo",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "not found",
+    query: "id=1",
+    err: model.ErrCollectionNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Model: &model.MockModel {
+          DeleteCollectionResult: test.err,
+        },
+      }
+
+      // create context, request, and response recorder
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req, err := http.NewRequestWithContext(ctx, "POST", "/api/collections/delete?" + test.query, nil)
+      if err != nil {
+        t.Fatal(err)
+      }
+      resp := httptest.NewRecorder()
+
+      // call handler
+      doApiDeleteCollection(resp, req)
+
+      if test.status == http.StatusOK {
+        // check response status
+        if resp.Code != http.StatusOK {
+          t.Fatalf("got %d, exp %d", resp.Code, http.StatusOK)
+        }
+      } else {
+        // check response status and error code
+        checkErrorResponse(t, resp, test.status, test.code)
+      }
+    })
+  }
+}
+
+func TestDoApiRevisions(t *testing.T) {
+  var tests = []struct {
+    name string // test name
+    query string // request query string
+    err error // Revisions() error
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "pass",
+    query: "id=1",
+    status: http.StatusOK,
+  }, {
+    name: "bad id",
+    query: "id=foo",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "not found",
+    query: "id=1",
+    err: model.ErrNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Model: &model.MockModel {
+          RevisionsResult: model.MockRevisionsResult {
+            Revisions: []model.Revision {
+              model.Revision { Id: 2, BookId: 1, Name: "foo", Author: "bar", Actor: "127.0.0.1" },
+            },
+            Err: test.err,
+          },
+        },
+      }
+
+      // create context, request, and response recorder
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req, err := http.NewRequestWithContext(ctx, "GET", "/api/revisions?" + test.query, nil)
+      if err != nil {
+        t.Fatal(err)
+      }
+      resp := httptest.NewRecorder()
+
+      // call handler
+      doApiRevisions(resp, req)
+
+      if test.status != http.StatusOK {
+        // check response status and error code
+        checkErrorResponse(t, resp, test.status, test.code)
+        return
+      }
+
+      // check response body
+      exp := `[{"id":2,"book_id":1,"name":"foo","author":"bar","body_changed":false,"actor":"127.0.0.1","created_at":"0001-01-01T00:00:00Z"}]`
+      got := strings.TrimSpace(resp.Body.String())
+      if got != exp {
+        t.Fatalf("got \"%s\", exp \"%s\"", got, exp)
+      }
+    })
+  }
+}
+
+func TestDoApiRevisionDiff(t *testing.T) {
+  var tests = []struct {
+    name string // test name
+    query string // request query string
+    status int // expected status code
+    code string // expected error code
+    exp string // expected diff
+  } {{
+    name: "pass",
+    query: "id=1&from=2&to=3",
+    status: http.StatusOK,
+    exp: "--- revision 2\n+++ revision 3\n@@ -1,4 +1,4 @@\n-Name: foo\n+Name: bar\n Author: baz\n \n line 1\n",
+  }, {
+    name: "same",
+    query: "id=1&from=2&to=2",
+    status: http.StatusOK,
+    exp: "",
+  }, {
+    name: "bad id",
+    query: "id=foo&from=2&to=3",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "bad from",
+    query: "id=1&from=foo&to=3",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "bad to",
+    query: "id=1&from=2",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "not found",
+    query: "id=1&from=2&to=4",
+    status: http.StatusNotFound,
+    code: "not_found",
+  }}
+
+  // build app context w/ mock model
+  appCtx := app.Context {
+    Model: &model.MockModel {
+      RevisionResults: map[int64]model.MockRevisionResult {
+        2: model.MockRevisionResult {
+          Revision: model.RevisionDetail {
+            Revision: model.Revision { Id: 2, BookId: 1, Name: "foo", Author: "baz" },
+            Body: "line 1\n",
+          },
+        },
+        3: model.MockRevisionResult {
+          Revision: model.RevisionDetail {
+            Revision: model.Revision { Id: 3, BookId: 1, Name: "bar", Author: "baz" },
+            Body: "line 1\n",
+          },
+        },
+      },
+    },
+  }
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // create context, request, and response recorder
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req, err := http.NewRequestWithContext(ctx, "GET", "/api/revisions/diff?" + test.query, nil)
+      if err != nil {
+        t.Fatal(err)
+      }
+      resp := httptest.NewRecorder()
+
+      // call handler
+      doApiRevisionDiff(resp, req)
+
+      if test.status != http.StatusOK {
+        // check response status and error code
+        checkErrorResponse(t, resp, test.status, test.code)
+        return
+      }
+
+      // decode response body
+      var got revisionDiff
+      if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+        t.Fatal(err)
+      }
+
+      // check diff
+      if got.Diff != test.exp {
+        t.Fatalf("got %q, exp %q", got.Diff, test.exp)
+      }
+    })
+  }
+}
+
+func TestDoApiRevert(t *testing.T) {
+  var tests = []struct {
+    name string // test name
+    query string // request query string
+    err error // Revert() error
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "pass",
+    query: "id=1&revision=2",
+    status: http.StatusOK,
+  }, {
+    name: "bad id",
+    query: "id=foo&revision=2",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "bad revision",
+    query: "id=1&revision=foo",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "not found",
+    query: "id=1&revision=2",
+    err: model.ErrRevisionNotFound,
+    status: http.StatusNotFound,
+    code: "not_found",
+  }, {
+    name: "duplicate",
+    query: "id=1&revision=2",
+    err: model.ErrDuplicate,
+    status: http.StatusConflict,
+    code: "duplicate",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Model: &model.MockModel {
+          RevertResult: test.err,
+        },
+      }
+
+      // create context, request, and response recorder
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req, err := http.NewRequestWithContext(ctx, "POST", "/api/revisions/revert?" + test.query, nil)
+      if err != nil {
+        t.Fatal(err)
+      }
+      resp := httptest.NewRecorder()
+
+      // call handler
+      doApiRevert(resp, req)
+
+      if test.status == http.StatusOK {
+        // check response status
+        if resp.Code != http.StatusOK {
+          t.Fatalf("got %d, exp %d", resp.Code, http.StatusOK)
+        }
+      } else {
+        // check response status and error code
+        checkErrorResponse(t, resp, test.status, test.code)
+      }
+    })
+  }
+}
+
+// Build multipart form body with the given file names and contents.
+// Returns the body and the content type.
+func uploadBody(t *testing.T, files [][2]string) (*bytes.Buffer, string) {
+  var buf bytes.Buffer
+  mw := multipart.NewWriter(&buf)
+  for _, file := range(files) {
+    fw, err := mw.CreateFormFile("file", file[0])
     if err != nil {
       t.Fatal(err)
     }
+    if _, err := fw.Write([]byte(file[1])); err != nil {
+      t.Fatal(err)
+    }
+  }
+  if err := mw.Close(); err != nil {
+    t.Fatal(err)
+  }
 
-    // shouldn't be reached, log response body
-    t.Fatalf("got success, exp err; body = \"%s\"", string(body))
-  })
+  return &buf, mw.FormDataContentType()
+}
+
+func TestDoApiUpload(t *testing.T) {
+  // build invalid epub (mimetype, but no container)
+  var epub bytes.Buffer
+  zw := zip.NewWriter(&epub)
+  if fw, err := zw.Create("mimetype"); err != nil {
+    t.Fatal(err)
+  } else if _, err := fw.Write([]byte("application/epub+zip")); err != nil {
+    t.Fatal(err)
+  }
+  if err := zw.Close(); err != nil {
+    t.Fatal(err)
+  }
+
+  // build archive
+  var archive bytes.Buffer
+  zw = zip.NewWriter(&archive)
+  for _, file := range([][2]string {
+    { "books/a.txt", "aaa" },
+    { "books/cover.jpg", "jpg" },
+    { "books/big.txt", strings.Repeat("x", 2000) },
+    { "books/b.txt", "bb" },
+  }) {
+    if fw, err := zw.Create(file[0]); err != nil {
+      t.Fatal(err)
+    } else if _, err := fw.Write([]byte(file[1])); err != nil {
+      t.Fatal(err)
+    }
+  }
+  if err := zw.Close(); err != nil {
+    t.Fatal(err)
+  }
+
+  // uploaded files
+  oneFile := [][2]string { { "foo.txt", "bar" } }
+  twoFiles := [][2]string {
+    { "foo.epub", epub.String() },
+    { "baz.txt", "caf\xe9" },
+  }
+
+  var tests = []struct {
+    name string // test name
+    query string // request query string
+    contentType string // request content type (defaults to multipart)
+    files [][2]string // uploaded files
+    maxFileSize int // maximum file size (defaults to 1 MiB)
+    maxRequestSize int // maximum request size (defaults to 1 MiB)
+    maxArchiveEntries int // maximum archive entry count (defaults to 100)
+    chunked bool // send request without content length?
+    results []model.UploadResult // Upload() results
+    err error // Upload() error
+    status int // expected status code
+    code string // expected error code
+    exp string // expected body
+  } {{
+    name: "pass",
+    query: "conflict=rename",
+    files: oneFile,
+    results: []model.UploadResult {
+      model.UploadResult { Id: 2, Name: "foo (2)", Status: model.UploadRenamed },
+    },
+    status: http.StatusOK,
+    exp: `{"files":[{"file_name":"foo.txt","size":3,"encoding":"utf-8","id":2,"name":"foo (2)","status":"renamed"}]}`,
+  }, {
+    name: "replace",
+    query: "id=1",
+    files: oneFile,
+    results: []model.UploadResult {
+      model.UploadResult { Id: 1, Name: "bar", Status: model.UploadReplaced },
+    },
+    status: http.StatusOK,
+    exp: `{"files":[{"file_name":"foo.txt","size":3,"encoding":"utf-8","id":1,"name":"bar","status":"replaced"}]}`,
+  }, {
+    name: "empty",
+    status: http.StatusOK,
+    exp: `{"files":[]}`,
+  }, {
+    name: "partial",
+    query: "partial=true",
+    files: twoFiles,
+    results: []model.UploadResult {
+      model.UploadResult { Name: "baz", Status: model.UploadFailed, Err: model.ErrDuplicate },
+    },
+    status: http.StatusOK,
+    exp: `{"files":[` +
+      `{"file_name":"foo.epub","size":` + strconv.Itoa(epub.Len()) + `,"encoding":"","id":0,"name":"","status":"failed","error":{"code":"bad_request","message":"foo.epub: invalid EPUB: META-INF/container.xml: open META-INF/container.xml: file does not exist"}},` +
+      `{"file_name":"baz.txt","size":4,"encoding":"iso-8859-1","id":0,"name":"baz","status":"failed","error":{"code":"duplicate","message":"book name already exists"}}` +
+    `]}`,
+  }, {
+    name: "not partial",
+    files: twoFiles,
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "file too large",
+    files: oneFile,
+    maxFileSize: 2,
+    status: http.StatusRequestEntityTooLarge,
+    code: "too_large",
+  }, {
+    name: "file too large partial",
+    query: "partial=1",
+    files: [][2]string { { "foo.txt", "bar" }, { "baz.txt", "ok" } },
+    maxFileSize: 2,
+    results: []model.UploadResult {
+      model.UploadResult { Id: 3, Name: "baz", Status: model.UploadCreated },
+    },
+    status: http.StatusOK,
+    exp: `{"files":[` +
+      `{"file_name":"foo.txt","size":0,"encoding":"","id":0,"name":"","status":"failed","error":{"code":"too_large","message":"foo.txt: file is larger than the maximum file size (2 bytes)"}},` +
+      `{"file_name":"baz.txt","size":2,"encoding":"utf-8","id":3,"name":"baz","status":"created"}` +
+    `]}`,
+  }, {
+    name: "archive",
+    query: "partial=true",
+    files: [][2]string { { "books.zip", archive.String() }, { "foo.txt", "bar" } },
+    maxFileSize: 1000,
+    results: []model.UploadResult {
+      model.UploadResult { Id: 4, Name: "a", Status: model.UploadCreated },
+      model.UploadResult { Id: 5, Name: "b", Status: model.UploadCreated },
+      model.UploadResult { Id: 6, Name: "foo", Status: model.UploadCreated },
+    },
+    status: http.StatusOK,
+    exp: `{"files":[` +
+      `{"file_name":"books.zip","entry":"books/a.txt","size":3,"encoding":"utf-8","id":4,"name":"a","status":"created"},` +
+      `{"file_name":"books.zip","entry":"books/big.txt","size":0,"encoding":"","id":0,"name":"","status":"failed","error":{"code":"too_large","message":"archive too large: books/big.txt: file is larger than 1000 bytes"}},` +
+      `{"file_name":"books.zip","entry":"books/b.txt","size":2,"encoding":"utf-8","id":5,"name":"b","status":"created"},` +
+      `{"file_name":"foo.txt","size":3,"encoding":"utf-8","id":6,"name":"foo","status":"created"}` +
+    `]}`,
+  }, {
+    name: "archive entry too large",
+    files: [][2]string { { "books.zip", archive.String() } },
+    maxFileSize: 1000,
+    status: http.StatusRequestEntityTooLarge,
+    code: "too_large",
+  }, {
+    name: "archive too many entries",
+    files: [][2]string { { "books.zip", archive.String() } },
+    maxArchiveEntries: 3,
+    status: http.StatusRequestEntityTooLarge,
+    code: "too_large",
+  }, {
+    name: "archive too many entries partial",
+    query: "partial=true",
+    files: [][2]string { { "books.zip", archive.String() } },
+    maxArchiveEntries: 3,
+    status: http.StatusOK,
+    exp: `{"files":[` +
+      `{"file_name":"books.zip","size":` + strconv.Itoa(archive.Len()) + `,"encoding":"","id":0,"name":"","status":"failed","error":{"code":"too_large","message":"archive too large: archive has more than 3 entries"}}` +
+    `]}`,
+  }, {
+    name: "request too large",
+    files: oneFile,
+    maxRequestSize: 100,
+    status: http.StatusRequestEntityTooLarge,
+    code: "too_large",
+  }, {
+    name: "chunked request too large",
+    files: oneFile,
+    maxRequestSize: 100,
+    chunked: true,
+    status: http.StatusRequestEntityTooLarge,
+    code: "too_large",
+  }, {
+    name: "bad id",
+    query: "id=foo",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "bad partial",
+    query: "partial=foo",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "not multipart",
+    contentType: "text/plain",
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "duplicate",
+    files: oneFile,
+    err: model.ErrDuplicate,
+    status: http.StatusConflict,
+    code: "duplicate",
+  }, {
+    name: "duplicate body",
+    files: oneFile,
+    err: model.ErrDuplicateBody,
+    status: http.StatusConflict,
+    code: "duplicate_body",
+  }, {
+    name: "invalid duplicates",
+    query: "duplicates=foo",
+    files: oneFile,
+    err: &model.ValidationError { Field: "duplicates", Message: "unknown duplicate policy" },
+    status: http.StatusBadRequest,
+    code: "invalid",
+  }, {
+    name: "invalid conflict",
+    query: "conflict=foo",
+    files: oneFile,
+    err: &model.ValidationError { Field: "conflict", Message: "unknown conflict policy" },
+    status: http.StatusBadRequest,
+    code: "invalid",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Config: app.Config {
+          UploadMaxFileSize: 1 << 20,
+          UploadMaxRequestSize: 1 << 20,
+          UploadMaxArchiveEntries: 100,
+          UploadMaxArchiveSize: 1 << 20,
+        },
+        Model: &model.MockModel {
+          UploadResult: model.MockUploadResult {
+            Results: test.results,
+            Err: test.err,
+          },
+        },
+      }
+      if test.maxFileSize > 0 {
+        appCtx.Config.UploadMaxFileSize = test.maxFileSize
+      }
+      if test.maxRequestSize > 0 {
+        appCtx.Config.UploadMaxRequestSize = test.maxRequestSize
+      }
+      if test.maxArchiveEntries > 0 {
+        appCtx.Config.UploadMaxArchiveEntries = test.maxArchiveEntries
+      }
+
+      // build request body
+      body, contentType := uploadBody(t, test.files)
+      if test.contentType != "" {
+        contentType = test.contentType
+      }
+
+      // create context, request, and response recorder
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req, err := http.NewRequestWithContext(ctx, "POST", "/api/upload?" + test.query, body)
+      if err != nil {
+        t.Fatal(err)
+      }
+      req.Header.Set("Content-Type", contentType)
+      if test.chunked {
+        req.ContentLength = -1
+      }
+      resp := httptest.NewRecorder()
+
+      // use empty temporary directory for spooled files
+      tmpDir := t.TempDir()
+      t.Setenv("TMPDIR", tmpDir)
+
+      // call handler
+      doApiUpload(resp, req)
+
+      // check that spooled files were removed
+      if entries, err := os.ReadDir(tmpDir); err != nil {
+        t.Fatal(err)
+      } else if len(entries) > 0 {
+        t.Fatalf("got %d temporary files, exp 0", len(entries))
+      }
+
+      if test.status != http.StatusOK {
+        // check response status and error code
+        checkErrorResponse(t, resp, test.status, test.code)
+        return
+      }
+
+      // check response body
+      got := strings.TrimSpace(resp.Body.String())
+      if got != test.exp {
+        t.Fatalf("got \"%s\", exp \"%s\"", got, test.exp)
+      }
+    })
+  }
 }
 
-// TODO: TestDoUpload()
-// TODO: TestDoEdit()
+func TestDoApiLibraryExport(t *testing.T) {
+  // test library
+  lib := model.Library {
+    Books: []model.LibraryBook {
+      model.LibraryBook { Id: 1, Name: "foo", Tags: []string{}, BodySha256: model.BodyHash("bar") },
+    },
+  }
+
+  var tests = []struct {
+    name string // test name
+    err error // ExportLibrary() error
+    status int // expected status code
+    code string // expected error code
+  } {{
+    name: "pass",
+    status: http.StatusOK,
+  }, {
+    name: "error",
+    err: errors.New("foo"),
+    status: http.StatusInternalServerError,
+    code: "internal_error",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Model: &model.MockModel {
+          ExportLibraryResult: model.MockExportLibraryResult {
+            Library: lib,
+            Bodies: map[int]string { 1: "bar" },
+            Err: test.err,
+          },
+        },
+      }
+
+      // create context, request, and response recorder
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req, err := http.NewRequestWithContext(ctx, "GET", "/api/admin/export", nil)
+      if err != nil {
+        t.Fatal(err)
+      }
+      resp := httptest.NewRecorder()
+
+      // call handler
+      doApiLibraryExport(resp, req)
+
+      if test.status != http.StatusOK {
+        // check response status and error code
+        checkErrorResponse(t, resp, test.status, test.code)
+        return
+      }
+
+      // check response status and headers
+      if resp.Code != http.StatusOK {
+        t.Fatalf("got %d, exp %d", resp.Code, http.StatusOK)
+      } else if got := resp.Header().Get("Content-Type"); got != "application/gzip" {
+        t.Fatalf("got content type \"%s\", exp \"application/gzip\"", got)
+      }
+
+      // check archive
+      r, err := library.NewReader(resp.Body, 1000)
+      if err != nil {
+        t.Fatal(err)
+      }
+      book, body, err := r.Next()
+      if err != nil {
+        t.Fatal(err)
+      } else if book.Name != "foo" || body != "bar" {
+        t.Fatalf("got %#v, %q", book, body)
+      }
+    })
+  }
+}
+
+func TestDoApiLibraryRestore(t *testing.T) {
+  // build test archive
+  var archive bytes.Buffer
+  w := library.NewWriter(&archive)
+  if err := w.WriteLibrary(model.Library {
+    Books: []model.LibraryBook {
+      model.LibraryBook { Id: 5, Name: "foo", Tags: []string{}, BodySha256: model.BodyHash("foo") },
+      model.LibraryBook { Id: 7, Name: "bar", Tags: []string{}, BodySha256: model.BodyHash("bar") },
+    },
+  }); err != nil {
+    t.Fatal(err)
+  }
+  if err := w.WriteBody(5, "foo"); err != nil {
+    t.Fatal(err)
+  }
+  if err := w.WriteBody(7, "bar"); err != nil {
+    t.Fatal(err)
+  }
+  if err := w.Close(); err != nil {
+    t.Fatal(err)
+  }
+
+  var tests = []struct {
+    name string // test name
+    query string // request query string
+    body []byte // request body
+    maxFileSize int // maximum file size (defaults to 1 MiB)
+    maxRequestSize int // maximum request size (defaults to 1 MiB)
+    results []model.UploadResult // RestoreLibrary() results
+    err error // RestoreLibrary() error
+    status int // expected status code
+    code string // expected error code
+    exp string // expected body
+  } {{
+    name: "pass",
+    query: "conflict=rename&partial=true",
+    body: archive.Bytes(),
+    results: []model.UploadResult {
+      model.UploadResult { Id: 1, Name: "foo", Status: model.UploadCreated },
+      model.UploadResult { Name: "bar", Status: model.UploadFailed, Err: model.ErrDuplicate },
+    },
+    status: http.StatusOK,
+    exp: `{"books":[` +
+      `{"export_id":5,"id":1,"name":"foo","status":"created"},` +
+      `{"export_id":7,"id":0,"name":"bar","status":"failed","error":{"code":"duplicate","message":"book name already exists"}}` +
+    `]}`,
+  }, {
+    name: "not archive",
+    body: []byte("foo"),
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "file too large",
+    body: archive.Bytes(),
+    maxFileSize: 2,
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "request too large",
+    body: archive.Bytes(),
+    maxRequestSize: 10,
+    status: http.StatusRequestEntityTooLarge,
+    code: "too_large",
+  }, {
+    name: "bad partial",
+    query: "partial=foo",
+    body: archive.Bytes(),
+    status: http.StatusBadRequest,
+    code: "bad_request",
+  }, {
+    name: "duplicate",
+    body: archive.Bytes(),
+    err: model.ErrDuplicate,
+    status: http.StatusConflict,
+    code: "duplicate",
+  }}
+
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      // build app context w/ mock model
+      appCtx := app.Context {
+        Config: app.Config {
+          UploadMaxFileSize: 1 << 20,
+          UploadMaxRequestSize: 1 << 20,
+        },
+        Model: &model.MockModel {
+          RestoreLibraryResult: model.MockUploadResult {
+            Results: test.results,
+            Err: test.err,
+          },
+        },
+      }
+      if test.maxFileSize > 0 {
+        appCtx.Config.UploadMaxFileSize = test.maxFileSize
+      }
+      if test.maxRequestSize > 0 {
+        appCtx.Config.UploadMaxRequestSize = test.maxRequestSize
+      }
+
+      // create context, request, and response recorder
+      ctx := context.WithValue(context.Background(), appCtxKey, &appCtx)
+      req, err := http.NewRequestWithContext(ctx, "POST", "/api/admin/restore?" + test.query, bytes.NewReader(test.body))
+      if err != nil {
+        t.Fatal(err)
+      }
+      req.Header.Set("Content-Type", "application/gzip")
+      resp := httptest.NewRecorder()
+
+      // call handler
+      doApiLibraryRestore(resp, req)
+
+      if test.status != http.StatusOK {
+        // check response status and error code
+        checkErrorResponse(t, resp, test.status, test.code)
+        return
+      }
+
+      // check response body
+      got := strings.TrimSpace(resp.Body.String())
+      if got != test.exp {
+        t.Fatalf("got \"%s\", exp \"%s\"", got, test.exp)
+      }
+    })
+  }
+}